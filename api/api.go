@@ -0,0 +1,135 @@
+// Package api provides an HTTP/JSON API that exposes a twelvefactor.Scheduler,
+// so that apps can be managed by a central control plane. The client package
+// provides a Go client for the API, which itself implements
+// twelvefactor.Scheduler.
+//
+// The API has the following endpoints:
+//
+//	PUT    /apps/{app}                            Run the app (RunRequest)
+//	DELETE /apps/{app}                            Remove the app
+//	POST   /apps/{app}/restart                    Restart the app
+//	PATCH  /apps/{app}/processes/{process}        Scale a process (ScaleRequest)
+//	POST   /apps/{app}/processes/{process}/restart Restart a process
+//	GET    /apps/{app}/tasks                      List the app's tasks
+//	DELETE /tasks/{task}                          Stop a task
+//	GET    /capabilities                          List the scheduler's capabilities
+//
+// Errors are returned as an Error, with a status code and error code that
+// reflect the kind of error from the twelvefactor package, such as a 404 with
+// "process_not_found" for twelvefactor.ErrProcessNotFound.
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/remind101/12factor"
+)
+
+// RunRequest is the request body for running an app.
+type RunRequest struct {
+	App       twelvefactor.App       `json:"app"`
+	Processes []twelvefactor.Process `json:"processes"`
+}
+
+// ScaleRequest is the request body for scaling a process.
+type ScaleRequest struct {
+	DesiredCount int `json:"desired_count"`
+}
+
+// Error is the response body when a request fails.
+type Error struct {
+	Message string `json:"message"`
+
+	// Code identifies the kind of error, such as "process_not_found", so
+	// that clients can tell errors apart without parsing the message.
+	Code string `json:"code,omitempty"`
+}
+
+// errorKinds maps the kinds of errors in the twelvefactor package to the code
+// and HTTP status code that they're returned with.
+var errorKinds = []struct {
+	kind   error
+	code   string
+	status int
+}{
+	{twelvefactor.ErrAppNotFound, "app_not_found", http.StatusNotFound},
+	{twelvefactor.ErrProcessNotFound, "process_not_found", http.StatusNotFound},
+	{twelvefactor.ErrTaskNotFound, "task_not_found", http.StatusNotFound},
+	{twelvefactor.ErrInvalidConfig, "invalid_config", http.StatusUnprocessableEntity},
+	{twelvefactor.ErrConflict, "conflict", http.StatusConflict},
+	{twelvefactor.ErrCapacityExceeded, "capacity_exceeded", http.StatusServiceUnavailable},
+	{twelvefactor.ErrUnsupported, "unsupported", http.StatusNotImplemented},
+}
+
+// ErrorCode returns the code for the kind of err, or an empty string if it's
+// not one of the kinds in the twelvefactor package.
+func ErrorCode(err error) string {
+	for _, k := range errorKinds {
+		if errors.Is(err, k.kind) {
+			return k.code
+		}
+	}
+	return ""
+}
+
+// StatusCode returns the HTTP status code for err, falling back to a 500 for
+// errors that aren't one of the kinds in the twelvefactor package.
+func StatusCode(err error) int {
+	for _, k := range errorKinds {
+		if errors.Is(err, k.kind) {
+			return k.status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// ErrorKind returns the twelvefactor error kind for an error code, or nil if
+// the code is unknown.
+func ErrorKind(code string) error {
+	for _, k := range errorKinds {
+		if k.code == code {
+			return k.kind
+		}
+	}
+	return nil
+}
+
+// ErrUnauthorized is returned by an Authenticator when a request doesn't have
+// valid credentials.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// Authenticator authenticates requests to the API.
+type Authenticator interface {
+	// Authenticate returns an error if the request shouldn't be allowed.
+	// Returning ErrUnauthorized results in a 401 response, and any other
+	// error results in a 403.
+	Authenticate(r *http.Request) error
+}
+
+// AuthenticatorFunc is a function that implements the Authenticator interface.
+type AuthenticatorFunc func(r *http.Request) error
+
+// Authenticate implements the Authenticator interface.
+func (fn AuthenticatorFunc) Authenticate(r *http.Request) error {
+	return fn(r)
+}
+
+// BearerToken returns an Authenticator that allows requests with an
+// "Authorization: Bearer <token>" header matching token.
+func BearerToken(token string) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) error {
+		h := r.Header.Get("Authorization")
+		if !strings.HasPrefix(h, "Bearer ") {
+			return ErrUnauthorized
+		}
+
+		if subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(h, "Bearer ")), []byte(token)) != 1 {
+			return ErrUnauthorized
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,167 @@
+// Package client provides a Go client for the api package, which implements
+// twelvefactor.Scheduler so that a remote scheduler can be used in place of a
+// local one.
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/api"
+)
+
+// Error is returned when the API responds with an error.
+type Error struct {
+	// The HTTP status code of the response.
+	StatusCode int
+
+	// The error message from the API.
+	Message string
+
+	// The error code from the API, if any. See api.Error.
+	Code string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.StatusCode)
+}
+
+// Is reports whether the error is of the given kind from the twelvefactor
+// package, such as twelvefactor.ErrProcessNotFound, so that errors.Is works
+// the same for remote schedulers as for local ones.
+func (e *Error) Is(target error) bool {
+	kind := api.ErrorKind(e.Code)
+	return kind != nil && kind == target
+}
+
+// Client is a client for the API.
+type Client struct {
+	// URL is the base URL of the API, such as "https://12factor.acme.com".
+	URL string
+
+	// Token, when provided, is sent as a bearer token with every request.
+	Token string
+
+	// HTTPClient is the http.Client used to make requests. The zero value
+	// is http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// New returns a new Client for the API at url.
+func New(url string) *Client {
+	return &Client{URL: url}
+}
+
+// Run runs the app.
+func (c *Client) Run(app twelvefactor.App, processes ...twelvefactor.Process) error {
+	req := &api.RunRequest{
+		App: app,
+	}
+
+	for _, p := range processes {
+		// Streams can't be sent over the wire, and processes run by the
+		// API are always detached.
+		p.Stdout, p.Stdin = nil, nil
+		req.Processes = append(req.Processes, p)
+	}
+
+	return c.do("PUT", path("apps", app.ID), req, nil)
+}
+
+// Remove removes the app.
+func (c *Client) Remove(app string) error {
+	return c.do("DELETE", path("apps", app), nil, nil)
+}
+
+// ScaleProcess scales a process of the app.
+func (c *Client) ScaleProcess(app, process string, desired int) error {
+	return c.do("PATCH", path("apps", app, "processes", process), &api.ScaleRequest{DesiredCount: desired}, nil)
+}
+
+// Restart restarts the app.
+func (c *Client) Restart(app string) error {
+	return c.do("POST", path("apps", app, "restart"), nil, nil)
+}
+
+// RestartProcess restarts a process of the app.
+func (c *Client) RestartProcess(app, process string) error {
+	return c.do("POST", path("apps", app, "processes", process, "restart"), nil, nil)
+}
+
+// Tasks returns the tasks for the app.
+func (c *Client) Tasks(app string) ([]twelvefactor.Task, error) {
+	var tasks []twelvefactor.Task
+	err := c.do("GET", path("apps", app, "tasks"), nil, &tasks)
+	return tasks, err
+}
+
+// StopTask stops a task.
+func (c *Client) StopTask(taskID string) error {
+	return c.do("DELETE", path("tasks", taskID), nil, nil)
+}
+
+// do makes a request to the API, encoding in as the request body and decoding
+// the response body into out when they're not nil.
+func (c *Client) do(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		raw, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.URL, "/")+path, body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		var e api.Error
+		if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || e.Message == "" {
+			e.Message = http.StatusText(resp.StatusCode)
+		}
+		return &Error{StatusCode: resp.StatusCode, Message: e.Message, Code: e.Code}
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// path joins the escaped path segments into a path.
+func path(segments ...string) string {
+	var p string
+	for _, s := range segments {
+		p += "/" + url.PathEscape(s)
+	}
+	return p
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/api"
+	"github.com/remind101/12factor/scheduler/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that the Client can be used in place of any other Scheduler.
+var _ twelvefactor.Scheduler = (*Client)(nil)
+
+func TestClient(t *testing.T) {
+	s := memory.NewScheduler()
+	ts := httptest.NewServer(api.NewServer(s, api.BearerToken("secret")))
+	defer ts.Close()
+
+	c := New(ts.URL)
+	c.Token = "secret"
+
+	err := c.Run(twelvefactor.App{ID: "acme", Version: "v1"}, twelvefactor.Process{
+		Name:         "web",
+		Command:      []string{"acme-inc", "web"},
+		DesiredCount: 1,
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.ScaleProcess("acme", "web", 2))
+
+	tasks, err := c.Tasks("acme")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(tasks))
+	assert.Equal(t, "web", tasks[0].Process)
+	assert.Equal(t, "v1", tasks[0].Version)
+
+	assert.NoError(t, c.StopTask(tasks[0].ID))
+	assert.NoError(t, c.RestartProcess("acme", "web"))
+	assert.NoError(t, c.Restart("acme"))
+	assert.NoError(t, c.Remove("acme"))
+
+	_, err = c.Tasks("acme")
+	assert.True(t, errors.Is(err, twelvefactor.ErrAppNotFound))
+}
+
+func TestClient_Error(t *testing.T) {
+	s := memory.NewScheduler()
+	ts := httptest.NewServer(api.NewServer(s, api.BearerToken("secret")))
+	defer ts.Close()
+
+	c := New(ts.URL)
+
+	err := c.Restart("acme")
+	assert.Equal(t, &Error{StatusCode: 401, Message: "unauthorized"}, err)
+
+	c.Token = "secret"
+	err = c.ScaleProcess("acme", "web", 1)
+	assert.Equal(t, &Error{StatusCode: 404, Message: "acme app not found", Code: "app_not_found"}, err)
+	assert.True(t, errors.Is(err, twelvefactor.ErrAppNotFound))
+	assert.False(t, errors.Is(err, twelvefactor.ErrProcessNotFound))
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/scheduler/middleware"
+)
+
+// Server is an http.Handler that serves the API for a Scheduler.
+type Server struct {
+	// The Scheduler to expose.
+	Scheduler twelvefactor.Scheduler
+
+	// Authenticator is used to authenticate every request. The zero value
+	// allows all requests.
+	Authenticator Authenticator
+}
+
+// NewServer returns a new Server for the scheduler.
+func NewServer(s twelvefactor.Scheduler, auth Authenticator) *Server {
+	return &Server{
+		Scheduler:     s,
+		Authenticator: auth,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Authenticator != nil {
+		if err := s.Authenticator.Authenticate(r); err != nil {
+			status := http.StatusForbidden
+			if err == ErrUnauthorized {
+				status = http.StatusUnauthorized
+			}
+			writeError(w, status, err)
+			return
+		}
+	}
+
+	parts := segments(r.URL)
+
+	switch {
+	case len(parts) == 1 && parts[0] == "capabilities":
+		s.route(w, r, map[string]http.HandlerFunc{
+			"GET": s.capabilities,
+		})
+	case len(parts) == 2 && parts[0] == "apps":
+		s.route(w, r, map[string]http.HandlerFunc{
+			"PUT":    func(w http.ResponseWriter, r *http.Request) { s.run(w, r, parts[1]) },
+			"DELETE": func(w http.ResponseWriter, r *http.Request) { s.remove(w, r, parts[1]) },
+		})
+	case len(parts) == 3 && parts[0] == "apps" && parts[2] == "restart":
+		s.route(w, r, map[string]http.HandlerFunc{
+			"POST": func(w http.ResponseWriter, r *http.Request) { s.restart(w, r, parts[1]) },
+		})
+	case len(parts) == 3 && parts[0] == "apps" && parts[2] == "tasks":
+		s.route(w, r, map[string]http.HandlerFunc{
+			"GET": func(w http.ResponseWriter, r *http.Request) { s.tasks(w, r, parts[1]) },
+		})
+	case len(parts) == 4 && parts[0] == "apps" && parts[2] == "processes":
+		s.route(w, r, map[string]http.HandlerFunc{
+			"PATCH": func(w http.ResponseWriter, r *http.Request) { s.scale(w, r, parts[1], parts[3]) },
+		})
+	case len(parts) == 5 && parts[0] == "apps" && parts[2] == "processes" && parts[4] == "restart":
+		s.route(w, r, map[string]http.HandlerFunc{
+			"POST": func(w http.ResponseWriter, r *http.Request) { s.restartProcess(w, r, parts[1], parts[3]) },
+		})
+	case len(parts) == 2 && parts[0] == "tasks":
+		s.route(w, r, map[string]http.HandlerFunc{
+			"DELETE": func(w http.ResponseWriter, r *http.Request) { s.stopTask(w, r, parts[1]) },
+		})
+	default:
+		writeJSON(w, http.StatusNotFound, &Error{Message: "not found"})
+	}
+}
+
+// route calls the handler for the request method, or responds with a 405 if
+// there isn't one.
+func (s *Server) route(w http.ResponseWriter, r *http.Request, handlers map[string]http.HandlerFunc) {
+	h, ok := handlers[r.Method]
+	if !ok {
+		writeJSON(w, http.StatusMethodNotAllowed, &Error{Message: "method not allowed"})
+		return
+	}
+	h(w, r)
+}
+
+// scheduler returns the Scheduler, with calls made with the context of the
+// request, so that middleware like tracing can see it.
+func (s *Server) scheduler(r *http.Request) twelvefactor.Scheduler {
+	return middleware.WithContext(s.Scheduler, r.Context())
+}
+
+func (s *Server) run(w http.ResponseWriter, r *http.Request, app string) {
+	var req RunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// The app in the path always wins.
+	req.App.ID = app
+
+	if err := s.scheduler(r).Run(req.App, req.Processes...); err != nil {
+		writeError(w, StatusCode(err), err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) remove(w http.ResponseWriter, r *http.Request, app string) {
+	if err := s.scheduler(r).Remove(app); err != nil {
+		writeError(w, StatusCode(err), err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) restart(w http.ResponseWriter, r *http.Request, app string) {
+	if err := s.scheduler(r).Restart(app); err != nil {
+		writeError(w, StatusCode(err), err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) restartProcess(w http.ResponseWriter, r *http.Request, app, process string) {
+	if err := s.scheduler(r).RestartProcess(app, process); err != nil {
+		writeError(w, StatusCode(err), err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) scale(w http.ResponseWriter, r *http.Request, app, process string) {
+	var req ScaleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := s.scheduler(r).ScaleProcess(app, process, req.DesiredCount); err != nil {
+		writeError(w, StatusCode(err), err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) tasks(w http.ResponseWriter, r *http.Request, app string) {
+	tasks, err := s.scheduler(r).Tasks(app)
+	if err != nil {
+		writeError(w, StatusCode(err), err)
+		return
+	}
+
+	if tasks == nil {
+		tasks = []twelvefactor.Task{}
+	}
+	writeJSON(w, http.StatusOK, tasks)
+}
+
+func (s *Server) stopTask(w http.ResponseWriter, r *http.Request, task string) {
+	if err := s.scheduler(r).StopTask(task); err != nil {
+		writeError(w, StatusCode(err), err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) capabilities(w http.ResponseWriter, r *http.Request) {
+	capabilities := twelvefactor.Capabilities(s.Scheduler)
+	if capabilities == nil {
+		capabilities = []twelvefactor.Capability{}
+	}
+	writeJSON(w, http.StatusOK, capabilities)
+}
+
+// segments returns the unescaped segments of the URL's path.
+func segments(u *url.URL) []string {
+	parts := strings.Split(strings.Trim(u.EscapedPath(), "/"), "/")
+	for i, p := range parts {
+		if s, err := url.PathUnescape(p); err == nil {
+			parts[i] = s
+		}
+	}
+	return parts
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, &Error{Message: err.Error(), Code: ErrorCode(err)})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/scheduler/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer(t *testing.T) {
+	s := memory.NewScheduler()
+	h := NewServer(s, nil)
+
+	tests := []struct {
+		method, path, body string
+		status             int
+		resp               string
+	}{
+		{"PUT", "/apps/acme", `{"app": {"Version": "v1"}, "processes": [{"Name": "web", "DesiredCount": 1}]}`, 204, ""},
+		{"PATCH", "/apps/acme/processes/web", `{"desired_count": 2}`, 204, ""},
+		{"PATCH", "/apps/acme/processes/worker", `{"desired_count": 2}`, 404, `{"message": "worker process not found", "code": "process_not_found"}`},
+		{"POST", "/apps/acme/restart", "", 204, ""},
+		{"POST", "/apps/acme/processes/web/restart", "", 204, ""},
+		{"DELETE", "/tasks/000000000005", "", 204, ""},
+		{"PUT", "/apps/acme", `{`, 400, `{"message": "unexpected EOF"}`},
+		{"GET", "/apps/acme", "", 405, `{"message": "method not allowed"}`},
+		{"GET", "/bogus", "", 404, `{"message": "not found"}`},
+		{"DELETE", "/apps/acme", "", 204, ""},
+		{"GET", "/apps/acme/tasks", "", 404, `{"message": "acme app not found", "code": "app_not_found"}`},
+		{"GET", "/capabilities", "", 200, `["run", "plan"]`},
+	}
+
+	for i, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+
+		assert.Equal(t, tt.status, resp.Code, "#%d", i)
+		if tt.resp != "" {
+			assert.JSONEq(t, tt.resp, resp.Body.String(), "#%d", i)
+		}
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{&twelvefactor.AppNotFoundError{App: "acme"}, 404, "app_not_found"},
+		{&twelvefactor.ProcessNotFoundError{App: "acme", Process: "web"}, 404, "process_not_found"},
+		{&twelvefactor.TaskNotFoundError{Task: "1234"}, 404, "task_not_found"},
+		{twelvefactor.NewError(twelvefactor.ErrInvalidConfig, errors.New("bad cpu")), 422, "invalid_config"},
+		{twelvefactor.NewError(twelvefactor.ErrConflict, errors.New("busy")), 409, "conflict"},
+		{twelvefactor.NewError(twelvefactor.ErrCapacityExceeded, errors.New("full")), 503, "capacity_exceeded"},
+		{&twelvefactor.UnsupportedStrategyError{Strategy: "canary"}, 501, "unsupported"},
+		{errors.New("boom"), 500, ""},
+	}
+
+	for i, tt := range tests {
+		assert.Equal(t, tt.status, StatusCode(tt.err), "#%d", i)
+		assert.Equal(t, tt.code, ErrorCode(tt.err), "#%d", i)
+		if tt.code != "" {
+			assert.True(t, errors.Is(tt.err, ErrorKind(tt.code)), "#%d", i)
+		}
+	}
+}
+
+func TestServer_Tasks(t *testing.T) {
+	s := memory.NewScheduler()
+	s.Run(twelvefactor.App{ID: "acme", Version: "v1"}, twelvefactor.Process{Name: "web", DesiredCount: 1})
+	h := NewServer(s, nil)
+
+	req := httptest.NewRequest("GET", "/apps/acme/tasks", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	assert.Equal(t, 200, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"ID":"000000000001","Version":"v1","Process":"web","State":"RUNNING"`)
+}
+
+func TestServer_Authentication(t *testing.T) {
+	s := memory.NewScheduler()
+	s.Run(twelvefactor.App{ID: "acme"})
+	h := NewServer(s, BearerToken("secret"))
+
+	tests := []struct {
+		header string
+		status int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer bogus", http.StatusUnauthorized},
+		{"Basic c2VjcmV0", http.StatusUnauthorized},
+		{"Bearer secret", http.StatusOK},
+	}
+
+	for i, tt := range tests {
+		req := httptest.NewRequest("GET", "/apps/acme/tasks", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+
+		assert.Equal(t, tt.status, resp.Code, "#%d", i)
+	}
+}
+
+func TestServer_Forbidden(t *testing.T) {
+	h := NewServer(memory.NewScheduler(), AuthenticatorFunc(func(r *http.Request) error {
+		return errors.New("not allowed")
+	}))
+
+	req := httptest.NewRequest("GET", "/apps/acme/tasks", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	assert.JSONEq(t, `{"message": "not allowed"}`, resp.Body.String())
+}
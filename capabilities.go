@@ -0,0 +1,86 @@
+package twelvefactor
+
+// Capability is an optional feature of a scheduler, such as running attached
+// processes or honoring health checks.
+type Capability string
+
+// The optional features that a scheduler may support.
+const (
+	// Running detached one off processes with ProcessRunner.
+	CapabilityRunProcess Capability = "run"
+
+	// Running attached one off processes with ProcessRunner, where Stdout
+	// is connected to the process. Stdin is too on schedulers that can
+	// attach to processes, rather than following their logs.
+	CapabilityAttachedRun Capability = "attached_run"
+
+	// Running commands inside of tasks with Execer.
+	CapabilityExec Capability = "exec"
+
+	// Retrieving logs with LogStreamer.
+	CapabilityLogs Capability = "logs"
+
+	// Scaling processes automatically, based on their load.
+	CapabilityAutoscaling Capability = "autoscaling"
+
+	// Checking the health of tasks with Process.HealthCheck.
+	CapabilityHealthChecks Capability = "health_checks"
+
+	// Placing tasks according to Process.Placement.
+	CapabilityPlacement Capability = "placement"
+
+	// Previewing the changes that Run would make with Planner.
+	CapabilityPlan Capability = "plan"
+
+	// Running processes with a Schedule independently of the Scheduler, so
+	// that they keep running on schedule after it's closed.
+	CapabilitySchedules Capability = "schedules"
+)
+
+// CapabilityProvider is an optional interface for schedulers to declare the
+// capabilities that they support.
+type CapabilityProvider interface {
+	// Capabilities returns the capabilities that the scheduler supports.
+	Capabilities() []Capability
+}
+
+// Capabilities returns the capabilities of the scheduler.
+//
+// Schedulers that implement CapabilityProvider are trusted to declare their
+// own. Schedulers that wrap another scheduler, like middleware, can implement
+// an Unwrap method that returns it, in which case the capabilities of the
+// wrapped scheduler are returned. Otherwise, capabilities are inferred from the
+// optional interfaces that the scheduler implements.
+func Capabilities(s Runner) []Capability {
+	switch s := s.(type) {
+	case CapabilityProvider:
+		return s.Capabilities()
+	case interface{ Unwrap() Scheduler }:
+		return Capabilities(s.Unwrap())
+	}
+
+	var capabilities []Capability
+	if _, ok := s.(ProcessRunner); ok {
+		capabilities = append(capabilities, CapabilityRunProcess)
+	}
+	if _, ok := s.(Execer); ok {
+		capabilities = append(capabilities, CapabilityExec)
+	}
+	if _, ok := s.(LogStreamer); ok {
+		capabilities = append(capabilities, CapabilityLogs)
+	}
+	if _, ok := s.(Planner); ok {
+		capabilities = append(capabilities, CapabilityPlan)
+	}
+	return capabilities
+}
+
+// Supports reports whether the scheduler supports the capability.
+func Supports(s Runner, capability Capability) bool {
+	for _, c := range Capabilities(s) {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package twelvefactor
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilities(t *testing.T) {
+	tests := []struct {
+		s            Runner
+		capabilities []Capability
+	}{
+		{new(runner), nil},
+		{new(logStreamer), []Capability{CapabilityLogs}},
+		{&provider{capabilities: []Capability{CapabilityExec, CapabilityPlacement}}, []Capability{CapabilityExec, CapabilityPlacement}},
+		{&wrapper{next: &provider{capabilities: []Capability{CapabilityHealthChecks}}}, []Capability{CapabilityHealthChecks}},
+	}
+
+	for i, tt := range tests {
+		assert.Equal(t, tt.capabilities, Capabilities(tt.s), "#%d", i)
+	}
+}
+
+func TestSupports(t *testing.T) {
+	s := &provider{capabilities: []Capability{CapabilityRunProcess}}
+	assert.True(t, Supports(s, CapabilityRunProcess))
+	assert.False(t, Supports(s, CapabilityAttachedRun))
+}
+
+// runner is a Runner that doesn't implement any optional interfaces.
+type runner struct{}
+
+func (r *runner) Run(App, ...Process) error {
+	return nil
+}
+
+// logStreamer is a Runner that implements LogStreamer.
+type logStreamer struct {
+	runner
+}
+
+func (s *logStreamer) StreamLogs(w io.Writer, opts LogsOptions) error {
+	return nil
+}
+
+// provider is a Scheduler that declares its capabilities.
+type provider struct {
+	Scheduler
+	capabilities []Capability
+}
+
+func (p *provider) Capabilities() []Capability {
+	return p.capabilities
+}
+
+// wrapper is a Scheduler that wraps another, like middleware. It implements
+// LogStreamer, but that shouldn't be mistaken for a capability.
+type wrapper struct {
+	Scheduler
+	next Scheduler
+}
+
+func (w *wrapper) StreamLogs(io.Writer, LogsOptions) error {
+	return nil
+}
+
+func (w *wrapper) Unwrap() Scheduler {
+	return w.next
+}
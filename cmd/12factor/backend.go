@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/scheduler/docker"
+	"github.com/remind101/12factor/scheduler/ecs"
+	"github.com/remind101/12factor/scheduler/ecs/builders/raw"
+	"github.com/remind101/12factor/scheduler/memory"
+)
+
+// backendOptions configures the backends.
+type backendOptions struct {
+	// Options for the ecs backend.
+	Cluster     string
+	ServiceRole string
+	LogGroup    string
+}
+
+// newBackend returns the scheduler with the given name. Commands check for the
+// optional interfaces that they need.
+func newBackend(name string, opts backendOptions) (twelvefactor.Scheduler, error) {
+	switch name {
+	case "docker":
+		return docker.NewSchedulerFromEnv()
+	case "ecs":
+		config := aws.NewConfig()
+
+		b := raw.NewStackBuilder(config)
+		b.Cluster = opts.Cluster
+		b.ServiceRole = opts.ServiceRole
+		b.LogGroup = opts.LogGroup
+
+		s := ecs.NewSchedulerWithStackBuilder(config, b)
+		s.Cluster = opts.Cluster
+		s.LogGroup = opts.LogGroup
+		return s, nil
+	case "memory":
+		// The memory backend only lives as long as the command, so it's
+		// only useful for trying things out.
+		return memory.NewScheduler(), nil
+	default:
+		return nil, fmt.Errorf("unknown backend: %s", name)
+	}
+}
@@ -0,0 +1,495 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/manifest"
+	"github.com/remind101/12factor/procfile"
+)
+
+// command is a subcommand of the CLI.
+type command struct {
+	name  string
+	usage string
+	help  string
+	run   func(c *cli, args []string) error
+}
+
+// commands are all of the available commands, in the order that they're shown
+// in the usage.
+var commands = []*command{
+	{"deploy", "deploy [-f file] [-image image] [-version version] [-plan]", "Deploy the app from a manifest or Procfile", (*cli).deploy},
+	{"scale", "scale <process>=<count>...", "Scale processes", (*cli).scale},
+	{"ps", "ps", "List the app's tasks", (*cli).ps},
+	{"restart", "restart [process]", "Restart the app, or a single process", (*cli).restart},
+	{"stop", "stop <task>", "Stop a task", (*cli).stop},
+	{"run", "run [-d] [-name name] -- <command>...", "Run a one off process", (*cli).run},
+	{"destroy", "destroy -confirm <app>", "Remove the app and all of its processes", (*cli).destroy},
+	{"logs", "logs [-f] [-p process] [-t task] [-since duration]", "Show the app's logs", (*cli).logs},
+}
+
+// findCommand returns the command with the given name, or nil.
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// defaultManifests are the files that deploy looks for, in order, when no file
+// is given.
+var defaultManifests = []string{"12factor.yml", "12factor.yaml", "app.json", "Procfile"}
+
+// errAppRequired is returned by commands that need an app when none was given.
+var errAppRequired = errors.New("no app given, use -app or $TWELVEFACTOR_APP")
+
+// cli holds the state that's shared by all commands.
+type cli struct {
+	backend     twelvefactor.Scheduler
+	backendName string
+	app         string
+	format      string
+
+	// The command that's running.
+	command *command
+
+	stdin          io.Reader
+	stdout, stderr io.Writer
+}
+
+// flagSet returns a FlagSet for parsing the arguments to the command that's
+// running.
+func (c *cli) flagSet() *flag.FlagSet {
+	cmd := c.command
+
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: 12factor %s\n\n%s.\n", cmd.usage, cmd.help)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// unsupported returns an error for a command that the backend doesn't support.
+func (c *cli) unsupported(cmd string) error {
+	return twelvefactor.NewError(twelvefactor.ErrUnsupported, fmt.Errorf("the %s backend does not support %s", c.backendName, cmd))
+}
+
+// requireApp returns the app, or an error if no app was given.
+func (c *cli) requireApp() (string, error) {
+	if c.app == "" {
+		return "", errAppRequired
+	}
+	return c.app, nil
+}
+
+func (c *cli) deploy(args []string) error {
+	fs := c.flagSet()
+	var (
+		file    = fs.String("f", "", "The manifest or Procfile to deploy. Defaults to the first of "+strings.Join(defaultManifests, ", ")+" that exists.")
+		image   = fs.String("image", "", "The image to deploy, overriding the image in the manifest. Required for a Procfile.")
+		version = fs.String("version", "", "The version of the app being deployed.")
+		dryRun  = fs.Bool("plan", false, "Show what the deploy would change, without changing anything.")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	path := *file
+	if path == "" {
+		for _, p := range defaultManifests {
+			if _, err := os.Stat(p); err == nil {
+				path = p
+				break
+			}
+		}
+		if path == "" {
+			return fmt.Errorf("no manifest found, expected one of %s", strings.Join(defaultManifests, ", "))
+		}
+	}
+
+	app, processes, err := load(path)
+	if err != nil {
+		return err
+	}
+
+	if c.app != "" {
+		app.ID = c.app
+	}
+	if app.ID == "" {
+		app.ID = app.Name
+	}
+	if app.ID == "" {
+		return errAppRequired
+	}
+	if app.Name == "" {
+		app.Name = app.ID
+	}
+	if *image != "" {
+		app.Image = *image
+	}
+	if app.Image == "" {
+		return errors.New("no image given, use -image")
+	}
+	if *version != "" {
+		app.Version = *version
+	}
+
+	c.warnUnsupported(processes)
+
+	if *dryRun {
+		return c.plan(app, processes)
+	}
+
+	if err := c.backend.Run(app, processes...); err != nil {
+		return err
+	}
+
+	var names []string
+	for _, p := range processes {
+		names = append(names, p.Name)
+	}
+
+	return c.output(map[string]interface{}{
+		"app":       app.ID,
+		"version":   app.Version,
+		"processes": names,
+	}, func(w io.Writer) {
+		fmt.Fprintf(w, "Deployed %s", app.ID)
+		if app.Version != "" {
+			fmt.Fprintf(w, " (%s)", app.Version)
+		}
+		fmt.Fprintf(w, ": %s\n", strings.Join(names, ", "))
+	})
+}
+
+// plan shows the changes that deploying the processes would make.
+func (c *cli) plan(app twelvefactor.App, processes []twelvefactor.Process) error {
+	p, ok := c.backend.(twelvefactor.Planner)
+	if !ok || !twelvefactor.Supports(c.backend, twelvefactor.CapabilityPlan) {
+		return c.unsupported("plan")
+	}
+
+	plan, err := p.Plan(app, processes...)
+	if err != nil {
+		return err
+	}
+
+	return c.output(plan, func(w io.Writer) {
+		io.WriteString(w, plan.String())
+	})
+}
+
+// warnUnsupported warns about the parts of the processes that the backend will
+// ignore, since it doesn't support them.
+func (c *cli) warnUnsupported(processes []twelvefactor.Process) {
+	warn := func(capability twelvefactor.Capability, feature string, uses func(twelvefactor.Process) bool) {
+		if twelvefactor.Supports(c.backend, capability) {
+			return
+		}
+		for _, p := range processes {
+			if uses(p) {
+				fmt.Fprintf(c.stderr, "warning: the %s backend does not support %s, ignoring them for %s\n", c.backendName, feature, p.Name)
+			}
+		}
+	}
+
+	warn(twelvefactor.CapabilityHealthChecks, "health checks", func(p twelvefactor.Process) bool {
+		return p.HealthCheck != nil
+	})
+	warn(twelvefactor.CapabilityPlacement, "placement rules", func(p twelvefactor.Process) bool {
+		return len(p.Placement.Constraints) > 0 || len(p.Placement.Strategies) > 0
+	})
+
+	// Backends like docker run schedules from within the process, so they
+	// stop as soon as the command exits.
+	if !twelvefactor.Supports(c.backend, twelvefactor.CapabilitySchedules) {
+		for _, p := range processes {
+			if p.Schedule != "" {
+				fmt.Fprintf(c.stderr, "warning: the %s backend only runs schedules while 12factor is running, so %s won't run on its schedule\n", c.backendName, p.Name)
+			}
+		}
+	}
+}
+
+// load loads the app and processes from a manifest or Procfile.
+func load(path string) (twelvefactor.App, []twelvefactor.Process, error) {
+	if !strings.HasPrefix(filepath.Base(path), "Procfile") {
+		m, err := manifest.Load(path)
+		if err != nil {
+			return twelvefactor.App{}, nil, err
+		}
+		app, processes := m.App()
+		return app, processes, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return twelvefactor.App{}, nil, err
+	}
+	defer f.Close()
+
+	p, err := procfile.Parse(f)
+	if err != nil {
+		return twelvefactor.App{}, nil, err
+	}
+
+	// Like Heroku, only the web process is scaled up by default.
+	processes := procfile.Processes(p)
+	for i := range processes {
+		if processes[i].Name == "web" {
+			processes[i].DesiredCount = 1
+		}
+	}
+
+	return twelvefactor.App{}, processes, nil
+}
+
+func (c *cli) scale(args []string) error {
+	fs := c.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	app, err := c.requireApp()
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		return errors.New("expected at least one <process>=<count>")
+	}
+
+	type scale struct {
+		process string
+		count   int
+	}
+
+	// Parse everything first, so that a typo doesn't leave the app half
+	// scaled.
+	var scales []scale
+	for _, arg := range fs.Args() {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("expected <process>=<count>, got %q", arg)
+		}
+
+		count, err := strconv.Atoi(parts[1])
+		if err != nil || count < 0 {
+			return fmt.Errorf("invalid count for %s: %q", parts[0], parts[1])
+		}
+
+		scales = append(scales, scale{parts[0], count})
+	}
+
+	counts := make(map[string]int)
+	for _, sc := range scales {
+		if err := c.backend.ScaleProcess(app, sc.process, sc.count); err != nil {
+			return err
+		}
+		counts[sc.process] = sc.count
+	}
+
+	return c.output(counts, func(w io.Writer) {
+		for _, sc := range scales {
+			fmt.Fprintf(w, "Scaled %s to %d\n", sc.process, sc.count)
+		}
+	})
+}
+
+func (c *cli) ps(args []string) error {
+	fs := c.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	app, err := c.requireApp()
+	if err != nil {
+		return err
+	}
+
+	tasks, err := c.backend.Tasks(app)
+	if err != nil {
+		return err
+	}
+
+	out := make([]task, 0, len(tasks))
+	for _, t := range tasks {
+		out = append(out, newTask(t))
+	}
+
+	return c.output(out, func(w io.Writer) {
+		fmt.Fprintf(w, "ID\tPROCESS\tVERSION\tSTATE\tHEALTH\tHOST\tUPDATED\tREASON\n")
+		for _, t := range tasks {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", t.ID, t.Process, t.Version, formatState(t), orDash(t.Health), orDash(t.Host), formatTime(t.Time), t.StopReason)
+		}
+	})
+}
+
+func (c *cli) restart(args []string) error {
+	fs := c.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	app, err := c.requireApp()
+	if err != nil {
+		return err
+	}
+
+	target := app
+	switch fs.NArg() {
+	case 0:
+		err = c.backend.Restart(app)
+	case 1:
+		target = app + "/" + fs.Arg(0)
+		err = c.backend.RestartProcess(app, fs.Arg(0))
+	default:
+		return errors.New("expected at most one process")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.output(map[string]string{"restarted": target}, func(w io.Writer) {
+		fmt.Fprintf(w, "Restarted %s\n", target)
+	})
+}
+
+func (c *cli) stop(args []string) error {
+	fs := c.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return errors.New("expected a task id")
+	}
+
+	id := fs.Arg(0)
+	if err := c.backend.StopTask(id); err != nil {
+		return err
+	}
+
+	return c.output(map[string]string{"stopped": id}, func(w io.Writer) {
+		fmt.Fprintf(w, "Stopped %s\n", id)
+	})
+}
+
+func (c *cli) run(args []string) error {
+	fs := c.flagSet()
+	var (
+		detached = fs.Bool("d", false, "Run the process detached, rather than attaching to it.")
+		name     = fs.String("name", "run", "The name of the process.")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	s, ok := c.backend.(twelvefactor.ProcessRunner)
+	if !ok || !twelvefactor.Supports(c.backend, twelvefactor.CapabilityRunProcess) {
+		return c.unsupported("run")
+	}
+	if !*detached && !twelvefactor.Supports(c.backend, twelvefactor.CapabilityAttachedRun) {
+		return c.unsupported("attached processes, use -d to run it detached")
+	}
+
+	app, err := c.requireApp()
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		return errors.New("expected a command to run")
+	}
+
+	process := twelvefactor.Process{
+		Name:    *name,
+		Command: fs.Args(),
+	}
+
+	// Whether a process is attached is determined by Stdout.
+	if !*detached {
+		process.Stdout = c.stdout
+		process.Stdin = c.stdin
+	}
+
+	if err := s.RunProcess(app, process); err != nil {
+		return err
+	}
+
+	if !*detached {
+		return nil
+	}
+
+	return c.output(map[string]string{"started": process.Name}, func(w io.Writer) {
+		fmt.Fprintf(w, "Started %s detached\n", process.Name)
+	})
+}
+
+func (c *cli) destroy(args []string) error {
+	fs := c.flagSet()
+	confirm := fs.String("confirm", "", "The name of the app, to confirm that it should be destroyed.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	app, err := c.requireApp()
+	if err != nil {
+		return err
+	}
+
+	if *confirm != app {
+		return fmt.Errorf("this removes %s and all of its processes, pass -confirm %s to continue", app, app)
+	}
+
+	if err := c.backend.Remove(app); err != nil {
+		return err
+	}
+
+	return c.output(map[string]string{"destroyed": app}, func(w io.Writer) {
+		fmt.Fprintf(w, "Destroyed %s\n", app)
+	})
+}
+
+func (c *cli) logs(args []string) error {
+	fs := c.flagSet()
+	var opts twelvefactor.LogsOptions
+	fs.BoolVar(&opts.Follow, "f", false, "Follow the logs, streaming new lines as they're written.")
+	fs.StringVar(&opts.Process, "p", "", "Only show logs from this process.")
+	fs.StringVar(&opts.Task, "t", "", "Only show logs from this task.")
+	since := fs.Duration("since", 0, "Only show logs from this long ago, like 10m.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	s, ok := c.backend.(twelvefactor.LogStreamer)
+	if !ok || !twelvefactor.Supports(c.backend, twelvefactor.CapabilityLogs) {
+		return c.unsupported("logs")
+	}
+
+	app, err := c.requireApp()
+	if err != nil {
+		return err
+	}
+
+	opts.App = app
+	if *since != 0 {
+		opts.Since = time.Now().Add(-*since)
+	}
+
+	// Log lines are written as is, regardless of the output format.
+	return s.StreamLogs(c.stdout, opts)
+}
@@ -0,0 +1,111 @@
+// Command 12factor is a command line tool for deploying and managing 12factor
+// apps with any of the supported schedulers.
+//
+// Usage:
+//
+//	12factor [flags] <command> [arguments]
+//
+// Run `12factor -h` for the list of flags and commands.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run parses the global flags, builds the backend and runs the command,
+// returning the exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("12factor", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { usage(fs, stderr) }
+
+	var (
+		backend = fs.String("backend", env("TWELVEFACTOR_BACKEND", "docker"), "The scheduler to use: docker, ecs or memory. ($TWELVEFACTOR_BACKEND)")
+		app     = fs.String("app", os.Getenv("TWELVEFACTOR_APP"), "The app to operate on. Defaults to the app in the manifest when deploying. ($TWELVEFACTOR_APP)")
+		format  = fs.String("format", env("TWELVEFACTOR_FORMAT", formatTable), "The output format: table or json. ($TWELVEFACTOR_FORMAT)")
+		opts    backendOptions
+	)
+	fs.StringVar(&opts.Cluster, "ecs-cluster", os.Getenv("ECS_CLUSTER"), "The ECS cluster to use with the ecs backend. ($ECS_CLUSTER)")
+	fs.StringVar(&opts.ServiceRole, "ecs-service-role", os.Getenv("ECS_SERVICE_ROLE"), "The IAM role for ECS services with load balancers. ($ECS_SERVICE_ROLE)")
+	fs.StringVar(&opts.LogGroup, "ecs-log-group", os.Getenv("ECS_LOG_GROUP"), "The CloudWatch Logs log group for containers. ($ECS_LOG_GROUP)")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	if *format != formatTable && *format != formatJSON {
+		fmt.Fprintf(stderr, "12factor: unknown output format: %s\n", *format)
+		return 2
+	}
+
+	cmd := findCommand(fs.Arg(0))
+	if cmd == nil {
+		fmt.Fprintf(stderr, "12factor: unknown command: %s\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+
+	s, err := newBackend(*backend, opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "12factor: %v\n", err)
+		return 1
+	}
+	if c, ok := s.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	c := &cli{
+		backend:     s,
+		backendName: *backend,
+		app:         *app,
+		format:      *format,
+		command:     cmd,
+		stdin:       stdin,
+		stdout:      stdout,
+		stderr:      stderr,
+	}
+
+	if err := cmd.run(c, fs.Args()[1:]); err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintf(stderr, "12factor %s: %v\n", cmd.name, err)
+		}
+		return 1
+	}
+
+	return 0
+}
+
+func usage(fs *flag.FlagSet, w io.Writer) {
+	fmt.Fprintf(w, "Usage: 12factor [flags] <command> [arguments]\n\nCommands:\n\n")
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.usage, cmd.help)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\nFlags:\n\n")
+	fs.PrintDefaults()
+}
+
+// env returns the value of the environment variable, or fallback if it's not
+// set.
+func env(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	dockerclient "github.com/fsouza/go-dockerclient"
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/scheduler/docker"
+	"github.com/remind101/12factor/scheduler/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeploy_Procfile(t *testing.T) {
+	c, stdout := newTestCLI(memory.NewScheduler())
+	c.app = "acme"
+
+	path := writeFile(t, "Procfile", "web: ./bin/web\nworker: ./bin/worker\n")
+
+	err := c.do("deploy", "-f", path, "-image", "remind101/acme-inc", "-version", "v1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Deployed acme (v1): web, worker\n", stdout.String())
+
+	tasks, _ := c.backend.(*memory.Scheduler).Tasks("acme")
+	assert.Equal(t, 1, len(tasks))
+	assert.Equal(t, "web", tasks[0].Process)
+}
+
+func TestDeploy_Manifest(t *testing.T) {
+	c, stdout := newTestCLI(memory.NewScheduler())
+	c.format = formatJSON
+
+	path := writeFile(t, "12factor.yml", `version: 1
+name: acme
+image: remind101/acme-inc
+processes:
+  web:
+    command: ["acme-inc", "web"]
+    scale: 2
+`)
+
+	err := c.do("deploy", "-f", path)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"app": "acme", "version": "", "processes": ["web"]}`, stdout.String())
+
+	tasks, _ := c.backend.(*memory.Scheduler).Tasks("acme")
+	assert.Equal(t, 2, len(tasks))
+}
+
+func TestDeploy_NoImage(t *testing.T) {
+	c, _ := newTestCLI(memory.NewScheduler())
+	c.app = "acme"
+
+	path := writeFile(t, "Procfile", "web: ./bin/web\n")
+
+	err := c.do("deploy", "-f", path)
+	assert.EqualError(t, err, "no image given, use -image")
+}
+
+func TestScale(t *testing.T) {
+	s := memory.NewScheduler()
+	s.Run(twelvefactor.App{ID: "acme"}, twelvefactor.Process{Name: "web"}, twelvefactor.Process{Name: "worker"})
+
+	c, stdout := newTestCLI(s)
+	c.app = "acme"
+
+	err := c.do("scale", "web=3", "worker=2")
+	assert.NoError(t, err)
+	assert.Equal(t, "Scaled web to 3\nScaled worker to 2\n", stdout.String())
+
+	tasks, _ := s.Tasks("acme")
+	assert.Equal(t, 5, len(tasks))
+
+	assert.EqualError(t, c.do("scale", "web=3", "worker"), `expected <process>=<count>, got "worker"`)
+	assert.EqualError(t, c.do("scale", "web=-1"), `invalid count for web: "-1"`)
+}
+
+func TestPs(t *testing.T) {
+	s := memory.NewScheduler()
+	s.Run(twelvefactor.App{ID: "acme", Version: "v1"}, twelvefactor.Process{Name: "web", DesiredCount: 1})
+
+	c, stdout := newTestCLI(s)
+	c.app = "acme"
+
+	err := c.do("ps")
+	assert.NoError(t, err)
+
+	lines := strings.Split(stdout.String(), "\n")
+	assert.Equal(t, []string{"ID", "PROCESS", "VERSION", "STATE", "HEALTH", "HOST", "UPDATED", "REASON"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"000000000001", "web", "v1", "RUNNING", "-", "memory"}, strings.Fields(lines[1])[:6])
+}
+
+func TestFormatState(t *testing.T) {
+	exitCode := 137
+	assert.Equal(t, "RUNNING", formatState(twelvefactor.Task{State: "RUNNING"}))
+	assert.Equal(t, "STOPPED (exit 137)", formatState(twelvefactor.Task{State: "STOPPED", ExitCode: &exitCode}))
+}
+
+func TestRestart(t *testing.T) {
+	s := memory.NewScheduler()
+	s.Run(twelvefactor.App{ID: "acme"}, twelvefactor.Process{Name: "web", DesiredCount: 1})
+
+	c, stdout := newTestCLI(s)
+	c.app = "acme"
+
+	assert.NoError(t, c.do("restart", "web"))
+	assert.Equal(t, "Restarted acme/web\n", stdout.String())
+
+	tasks, _ := s.Tasks("acme")
+	assert.Equal(t, "000000000002", tasks[0].ID)
+}
+
+func TestStop(t *testing.T) {
+	s := memory.NewScheduler()
+	s.Run(twelvefactor.App{ID: "acme"}, twelvefactor.Process{Name: "web", DesiredCount: 1})
+
+	c, stdout := newTestCLI(s)
+
+	assert.NoError(t, c.do("stop", "000000000001"))
+	assert.Equal(t, "Stopped 000000000001\n", stdout.String())
+}
+
+func TestRun_Detached(t *testing.T) {
+	s := memory.NewScheduler()
+	s.Run(twelvefactor.App{ID: "acme"})
+
+	c, stdout := newTestCLI(s)
+	c.app = "acme"
+
+	assert.NoError(t, c.do("run", "-d", "-name", "migrate", "--", "rake", "db:migrate"))
+	assert.Equal(t, "Started migrate detached\n", stdout.String())
+
+	tasks, _ := s.Tasks("acme")
+	assert.Equal(t, "migrate", tasks[0].Process)
+}
+
+func TestRun_Attached(t *testing.T) {
+	s := memory.NewScheduler()
+	s.Run(twelvefactor.App{ID: "acme"})
+
+	c, _ := newTestCLI(s)
+	c.app = "acme"
+
+	assert.EqualError(t, c.do("run", "--", "rails", "console"), "the test backend does not support attached processes, use -d to run it detached")
+}
+
+func TestRun_Docker(t *testing.T) {
+	d := newFakeDocker(t)
+	c, stdout := newTestCLI(docker.NewScheduler(d.client))
+	c.app = "acme"
+
+	assert.NoError(t, c.do("run", "-name", "migrate", "--", "rake", "db:migrate"))
+	assert.Equal(t, "Migrating\n", stdout.String())
+	assert.Equal(t, []string{
+		"GET /containers/json",
+		"POST /containers/create",
+		"POST /containers/2a2e27e0/attach",
+		"POST /containers/2a2e27e0/start",
+		"POST /containers/2a2e27e0/wait",
+		"DELETE /containers/2a2e27e0",
+	}, d.requests)
+
+	// The one off container is based on the app's web container.
+	assert.Equal(t, "remind101/acme-inc:v1", d.created.Image)
+	assert.Equal(t, []string{"rake", "db:migrate"}, d.created.Cmd)
+	assert.Equal(t, map[string]string{
+		docker.AppLabel:     "acme",
+		docker.ProcessLabel: "migrate",
+	}, d.created.Labels)
+
+	d.exitCode = 1
+	assert.EqualError(t, c.do("run", "-name", "migrate", "--", "rake", "db:migrate"), "migrate exited with code 1")
+}
+
+func TestDeploy_Unsupported(t *testing.T) {
+	c, _ := newTestCLI(memory.NewScheduler())
+	stderr := new(bytes.Buffer)
+	c.stderr = stderr
+
+	path := writeFile(t, "12factor.yml", `version: 1
+name: acme
+image: remind101/acme-inc
+processes:
+  web:
+    command: ["acme-inc", "web"]
+    health_check:
+      command: ["curl", "-f", "http://localhost/health"]
+  cleanup:
+    command: ["acme-inc", "cleanup"]
+    schedule: "@daily"
+`)
+
+	assert.NoError(t, c.do("deploy", "-f", path))
+	assert.Equal(t, "warning: the test backend does not support health checks, ignoring them for web\n"+
+		"warning: the test backend only runs schedules while 12factor is running, so cleanup won't run on its schedule\n", stderr.String())
+}
+
+func TestDeploy_Version(t *testing.T) {
+	c, stdout := newTestCLI(memory.NewScheduler())
+	c.format = formatJSON
+
+	path := writeFile(t, "12factor.yml", `version: 1
+name: acme
+image: remind101/acme-inc
+app_version: v41
+processes:
+  web:
+    command: ["acme-inc", "web"]
+`)
+
+	// The version in the manifest is kept unless -version is given.
+	assert.NoError(t, c.do("deploy", "-f", path))
+	assert.JSONEq(t, `{"app": "acme", "version": "v41", "processes": ["web"]}`, stdout.String())
+
+	stdout.Reset()
+	assert.NoError(t, c.do("deploy", "-f", path, "-version", "v42"))
+	assert.JSONEq(t, `{"app": "acme", "version": "v42", "processes": ["web"]}`, stdout.String())
+}
+
+func TestDeploy_Plan(t *testing.T) {
+	c, stdout := newTestCLI(&planner{plan: &twelvefactor.Plan{
+		App: "acme",
+		Changes: []twelvefactor.ProcessChange{
+			{Process: "web", Action: twelvefactor.ChangeCreate, Scale: &twelvefactor.ScaleChange{From: 0, To: 1}},
+		},
+	}})
+	c.app = "acme"
+
+	path := writeFile(t, "Procfile", "web: ./bin/web\n")
+
+	assert.NoError(t, c.do("deploy", "-f", path, "-image", "remind101/acme-inc", "-plan"))
+	assert.Equal(t, "+ web (create)\n    scale: 0 -> 1\n", stdout.String())
+
+	c, _ = newTestCLI(new(nopScheduler))
+	c.app = "acme"
+	assert.EqualError(t, c.do("deploy", "-f", path, "-image", "remind101/acme-inc", "-plan"), "the test backend does not support plan")
+}
+
+func TestDestroy(t *testing.T) {
+	s := memory.NewScheduler()
+	s.Run(twelvefactor.App{ID: "acme"}, twelvefactor.Process{Name: "web", DesiredCount: 1})
+
+	c, stdout := newTestCLI(s)
+	c.app = "acme"
+
+	assert.EqualError(t, c.do("destroy"), "this removes acme and all of its processes, pass -confirm acme to continue")
+
+	assert.NoError(t, c.do("destroy", "-confirm", "acme"))
+	assert.Equal(t, "Destroyed acme\n", stdout.String())
+
+	_, err := s.Tasks("acme")
+	assert.True(t, errors.Is(err, twelvefactor.ErrAppNotFound))
+}
+
+func TestUnsupported(t *testing.T) {
+	c, _ := newTestCLI(new(nopScheduler))
+	c.app = "acme"
+
+	assert.EqualError(t, c.do("logs"), "the test backend does not support logs")
+	assert.EqualError(t, c.do("run", "-d", "--", "rake"), "the test backend does not support run")
+}
+
+func TestAppRequired(t *testing.T) {
+	c, _ := newTestCLI(memory.NewScheduler())
+	assert.Equal(t, errAppRequired, c.do("ps"))
+}
+
+func TestMain_Usage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	assert.Equal(t, 2, run([]string{"bogus"}, nil, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "unknown command: bogus")
+
+	stderr.Reset()
+	assert.Equal(t, 2, run([]string{"-format", "xml", "ps"}, nil, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "unknown output format: xml")
+
+	stderr.Reset()
+	assert.Equal(t, 1, run([]string{"-backend", "memory", "ps"}, nil, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "12factor ps: no app given")
+}
+
+// nopScheduler is a backend that only implements twelvefactor.Scheduler, and
+// never changes anything.
+type nopScheduler struct{}
+
+func (s *nopScheduler) Run(twelvefactor.App, ...twelvefactor.Process) error { return nil }
+func (s *nopScheduler) Remove(string) error                                 { return nil }
+func (s *nopScheduler) ScaleProcess(string, string, int) error              { return nil }
+func (s *nopScheduler) Restart(string) error                                { return nil }
+func (s *nopScheduler) RestartProcess(string, string) error                 { return nil }
+func (s *nopScheduler) Tasks(string) ([]twelvefactor.Task, error)           { return nil, nil }
+func (s *nopScheduler) StopTask(string) error                               { return nil }
+
+// planner is a backend that also supports Plan.
+type planner struct {
+	nopScheduler
+	plan *twelvefactor.Plan
+}
+
+func (p *planner) Plan(twelvefactor.App, ...twelvefactor.Process) (*twelvefactor.Plan, error) {
+	return p.plan, nil
+}
+
+func newTestCLI(backend twelvefactor.Scheduler) (*cli, *bytes.Buffer) {
+	stdout := new(bytes.Buffer)
+	return &cli{
+		backend:     backend,
+		backendName: "test",
+		format:      formatTable,
+		stdout:      stdout,
+		stderr:      ioutil.Discard,
+	}, stdout
+}
+
+// fakeDocker is a Docker daemon that has a web container for the acme app, and
+// runs one off containers that print "Migrating".
+type fakeDocker struct {
+	client   *dockerclient.Client
+	exitCode int
+
+	mu       sync.Mutex
+	requests []string
+	created  dockerclient.Config
+}
+
+func newFakeDocker(t *testing.T) *fakeDocker {
+	spec, _ := json.Marshal(map[string]interface{}{
+		"Config": dockerclient.Config{
+			Image: "remind101/acme-inc:v1",
+			Cmd:   []string{"acme-inc", "web"},
+			Labels: map[string]string{
+				docker.AppLabel:     "acme",
+				docker.ProcessLabel: "web",
+			},
+		},
+		"HostConfig": dockerclient.HostConfig{},
+	})
+
+	d := new(fakeDocker)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		// The client checks the API version itself.
+		if r.URL.Path == "/version" {
+			json.NewEncoder(w).Encode(map[string]string{"ApiVersion": "1.41"})
+			return
+		}
+		d.requests = append(d.requests, r.Method+" "+r.URL.Path)
+
+		switch {
+		case r.URL.Path == "/containers/json":
+			json.NewEncoder(w).Encode([]dockerclient.APIContainers{
+				{
+					ID:    "8b1d8e13",
+					State: "running",
+					Labels: map[string]string{
+						docker.AppLabel:     "acme",
+						docker.ProcessLabel: "web",
+						docker.ServiceLabel: "acme/web",
+						docker.SpecLabel:    string(spec),
+					},
+				},
+			})
+		case r.URL.Path == "/containers/create":
+			json.NewDecoder(r.Body).Decode(&d.created)
+			json.NewEncoder(w).Encode(dockerclient.Container{ID: "2a2e27e0"})
+		case strings.HasSuffix(r.URL.Path, "/attach"):
+			// Output is multiplexed, with a header giving the
+			// stream and length of each frame.
+			w.Write([]byte{1, 0, 0, 0, 0, 0, 0, 10})
+			w.Write([]byte("Migrating\n"))
+		case strings.HasSuffix(r.URL.Path, "/start"):
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(r.URL.Path, "/wait"):
+			json.NewEncoder(w).Encode(map[string]int{"StatusCode": d.exitCode})
+		case r.Method == "DELETE":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := dockerclient.NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.client = client
+	return d
+}
+
+// do runs the named command.
+func (c *cli) do(name string, args ...string) error {
+	c.command = findCommand(name)
+	return c.command.run(c, args)
+}
+
+// writeFile writes a file to a temporary directory, and returns its path.
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/remind101/12factor"
+)
+
+// The supported output formats.
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// output writes v as JSON when the JSON format was requested, otherwise it
+// calls table to write human readable output. Tab separated columns written by
+// table are aligned.
+func (c *cli) output(v interface{}, table func(w io.Writer)) error {
+	if c.format == formatJSON {
+		e := json.NewEncoder(c.stdout)
+		e.SetIndent("", "  ")
+		return e.Encode(v)
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 8, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+// task is the JSON representation of a twelvefactor.Task.
+type task struct {
+	ID          string     `json:"id"`
+	Process     string     `json:"process"`
+	Version     string     `json:"version"`
+	State       string     `json:"state"`
+	StopReason  string     `json:"stop_reason,omitempty"`
+	ExitCode    *int       `json:"exit_code,omitempty"`
+	Health      string     `json:"health,omitempty"`
+	Host        string     `json:"host,omitempty"`
+	Location    string     `json:"location,omitempty"`
+	IPAddresses []string   `json:"ip_addresses,omitempty"`
+	Time        time.Time  `json:"time"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	StoppedAt   *time.Time `json:"stopped_at,omitempty"`
+	TriggeredAt *time.Time `json:"triggered_at,omitempty"`
+}
+
+func newTask(t twelvefactor.Task) task {
+	return task{
+		ID:          t.ID,
+		Process:     t.Process,
+		Version:     t.Version,
+		State:       t.State,
+		StopReason:  t.StopReason,
+		ExitCode:    t.ExitCode,
+		Health:      t.Health,
+		Host:        t.Host,
+		Location:    t.Location,
+		IPAddresses: t.IPAddresses,
+		Time:        t.Time,
+		StartedAt:   optionalTime(t.StartedAt),
+		StoppedAt:   optionalTime(t.StoppedAt),
+		TriggeredAt: optionalTime(t.TriggeredAt),
+	}
+}
+
+// optionalTime returns nil for the zero time, so that it's omitted from JSON.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// formatState formats the state of a task for table output, including the
+// exit code of stopped tasks.
+func formatState(t twelvefactor.Task) string {
+	if t.ExitCode != nil {
+		return fmt.Sprintf("%s (exit %d)", t.State, *t.ExitCode)
+	}
+	return t.State
+}
+
+// orDash returns s, or "-" if it's empty, so that table columns stay aligned.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// formatTime formats a time for table output.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,119 @@
+package twelvefactor
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The kinds of errors that schedulers return. Schedulers translate their
+// native errors into errors that match one of these, so that callers can handle
+// them without knowing which scheduler is in use:
+//
+//	if errors.Is(err, twelvefactor.ErrProcessNotFound) {
+//		// ...
+//	}
+var (
+	ErrAppNotFound      = errors.New("app not found")
+	ErrProcessNotFound  = errors.New("process not found")
+	ErrTaskNotFound     = errors.New("task not found")
+	ErrInvalidConfig    = errors.New("invalid config")
+	ErrCapacityExceeded = errors.New("capacity exceeded")
+	ErrConflict         = errors.New("conflict")
+	ErrUnsupported      = errors.New("unsupported")
+)
+
+// Error is an error that has been classified as one of the kinds of errors
+// above, while keeping the original error.
+type Error struct {
+	// The kind of error, such as ErrInvalidConfig.
+	Kind error
+
+	// The original error.
+	Err error
+}
+
+// NewError returns err classified as kind. It returns nil if err is nil.
+func NewError(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the original error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error is of the given kind.
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// AppNotFoundError is returned when operating on an app that doesn't exist.
+type AppNotFoundError struct {
+	App string
+}
+
+// Error implements the error interface.
+func (e *AppNotFoundError) Error() string {
+	return fmt.Sprintf("%s app not found", e.App)
+}
+
+// Is reports whether target is ErrAppNotFound.
+func (e *AppNotFoundError) Is(target error) bool {
+	return target == ErrAppNotFound
+}
+
+// ProcessNotFoundError is returned when operating on a process that doesn't
+// exist.
+type ProcessNotFoundError struct {
+	App     string
+	Process string
+}
+
+// Error implements the error interface.
+func (e *ProcessNotFoundError) Error() string {
+	return fmt.Sprintf("%s process not found", e.Process)
+}
+
+// Is reports whether target is ErrProcessNotFound.
+func (e *ProcessNotFoundError) Is(target error) bool {
+	return target == ErrProcessNotFound
+}
+
+// TaskNotFoundError is returned when operating on a task that doesn't exist.
+type TaskNotFoundError struct {
+	Task string
+}
+
+// Error implements the error interface.
+func (e *TaskNotFoundError) Error() string {
+	return fmt.Sprintf("%s task not found", e.Task)
+}
+
+// Is reports whether target is ErrTaskNotFound.
+func (e *TaskNotFoundError) Is(target error) bool {
+	return target == ErrTaskNotFound
+}
+
+// UnsupportedStrategyError is returned by a scheduler when a Process requests a
+// deployment strategy that the scheduler does not support.
+type UnsupportedStrategyError struct {
+	Strategy DeploymentStrategy
+}
+
+// Error implements the error interface.
+func (e *UnsupportedStrategyError) Error() string {
+	return fmt.Sprintf("%s deployment strategy is not supported", e.Strategy)
+}
+
+// Is reports whether target is ErrUnsupported.
+func (e *UnsupportedStrategyError) Is(target error) bool {
+	return target == ErrUnsupported
+}
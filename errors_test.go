@@ -0,0 +1,54 @@
+package twelvefactor
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrors(t *testing.T) {
+	kinds := []error{
+		ErrAppNotFound,
+		ErrProcessNotFound,
+		ErrTaskNotFound,
+		ErrInvalidConfig,
+		ErrCapacityExceeded,
+		ErrConflict,
+		ErrUnsupported,
+	}
+
+	tests := []struct {
+		err  error
+		kind error
+	}{
+		{&AppNotFoundError{App: "acme"}, ErrAppNotFound},
+		{&ProcessNotFoundError{App: "acme", Process: "web"}, ErrProcessNotFound},
+		{&TaskNotFoundError{Task: "abcd"}, ErrTaskNotFound},
+		{&UnsupportedStrategyError{Strategy: Canary}, ErrUnsupported},
+		{NewError(ErrCapacityExceeded, errors.New("no room")), ErrCapacityExceeded},
+		{fmt.Errorf("scaling: %w", NewError(ErrConflict, errors.New("busy"))), ErrConflict},
+	}
+
+	for i, tt := range tests {
+		for _, kind := range kinds {
+			assert.Equal(t, kind == tt.kind, errors.Is(tt.err, kind), "#%d: errors.Is(%v, %v)", i, tt.err, kind)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	original := errors.New("ClusterNotFoundException: Cluster not found.")
+	err := NewError(ErrInvalidConfig, original)
+
+	assert.EqualError(t, err, original.Error())
+	assert.True(t, errors.Is(err, original))
+
+	var e *Error
+	if assert.True(t, errors.As(err, &e)) {
+		assert.Equal(t, ErrInvalidConfig, e.Kind)
+	}
+
+	assert.Nil(t, NewError(ErrInvalidConfig, nil))
+}
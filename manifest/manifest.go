@@ -0,0 +1,526 @@
+// Package manifest implements a versioned file format for describing 12factor
+// apps, which can be written as either YAML (12factor.yml) or JSON (app.json).
+//
+// An example manifest:
+//
+//	version: "1"
+//	name: acme-inc
+//	app_version: v42
+//	image: remind101/acme-inc:latest
+//	env:
+//	  RAILS_ENV: production
+//	processes:
+//	  web:
+//	    command: ["acme-inc", "server"]
+//	    scale: 2
+//	    memory: 512MiB
+//	    cpu: 250m
+//	    health_check:
+//	      command: ["curl", "-f", "http://localhost/health"]
+//	      interval: 30s
+//	    placement:
+//	      constraints:
+//	        - type: distinct-instance
+//	    deployment:
+//	      strategy: rolling
+//	      rolling:
+//	        minimum_healthy_percent: 50
+//	  cleanup:
+//	    command: ["acme-inc", "cleanup"]
+//	    schedule: "@daily"
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/pkg/bytesize"
+	"github.com/remind101/12factor/pkg/cpu"
+	"gopkg.in/yaml.v2"
+)
+
+// Version is the version of the manifest format that this package reads and
+// writes.
+const Version = "1"
+
+// Format is a serialization format for a manifest.
+type Format string
+
+const (
+	YAML Format = "yaml"
+	JSON Format = "json"
+)
+
+// FormatForPath returns the format of a manifest file based on its extension.
+// Files ending in .json are JSON, and everything else is treated as YAML, which
+// is a superset of JSON.
+func FormatForPath(path string) Format {
+	if filepath.Ext(path) == ".json" {
+		return JSON
+	}
+	return YAML
+}
+
+// Manifest describes an App and its Processes.
+type Manifest struct {
+	// The version of the manifest format.
+	Version string `json:"version" yaml:"version"`
+
+	// Unique identifier of the application.
+	ID string `json:"id,omitempty" yaml:"id,omitempty"`
+
+	// Name of the application.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+
+	// The version of the application, such as a release number or a git
+	// sha. Not to be confused with Version, which is the version of the
+	// manifest format.
+	AppVersion string `json:"app_version,omitempty" yaml:"app_version,omitempty"`
+
+	// The container image for the app.
+	Image string `json:"image" yaml:"image"`
+
+	// Environment variables shared by all processes.
+	Env map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+
+	// The processes that make up the app, keyed by name.
+	Processes map[string]Process `json:"processes" yaml:"processes"`
+}
+
+// Process describes a single Process in the manifest.
+type Process struct {
+	// The command to run.
+	Command []string `json:"command" yaml:"command"`
+
+	// The desired number of instances.
+	Scale int `json:"scale,omitempty" yaml:"scale,omitempty"`
+
+	// Hard and soft limits on memory, such as "512MiB".
+	Memory            bytesize.ByteSize `json:"memory,omitempty" yaml:"memory,omitempty"`
+	MemoryReservation bytesize.ByteSize `json:"memory_reservation,omitempty" yaml:"memory_reservation,omitempty"`
+
+	// CPU reservation and hard limit, such as "0.5" or "250m".
+	CPU      cpu.CPU `json:"cpu,omitempty" yaml:"cpu,omitempty"`
+	CPULimit cpu.CPU `json:"cpu_limit,omitempty" yaml:"cpu_limit,omitempty"`
+
+	// A cron expression that makes this a scheduled process.
+	Schedule string `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+
+	// How to check that instances of the process are healthy.
+	HealthCheck *HealthCheck `json:"health_check,omitempty" yaml:"health_check,omitempty"`
+
+	// Where instances of the process should be placed.
+	Placement *Placement `json:"placement,omitempty" yaml:"placement,omitempty"`
+
+	// How new versions of the process are rolled out.
+	Deployment *Deployment `json:"deployment,omitempty" yaml:"deployment,omitempty"`
+
+	// Environment variables specific to this process.
+	Env map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+
+	// Free form labels to attach to the process.
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+}
+
+// HealthCheck describes a process health check in the manifest.
+type HealthCheck struct {
+	Command     []string `json:"command" yaml:"command"`
+	Interval    Duration `json:"interval,omitempty" yaml:"interval,omitempty"`
+	Timeout     Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Retries     int      `json:"retries,omitempty" yaml:"retries,omitempty"`
+	StartPeriod Duration `json:"start_period,omitempty" yaml:"start_period,omitempty"`
+}
+
+// Placement describes the placement rules for a process in the manifest.
+type Placement struct {
+	Constraints []PlacementConstraint `json:"constraints,omitempty" yaml:"constraints,omitempty"`
+	Strategies  []PlacementStrategy   `json:"strategies,omitempty" yaml:"strategies,omitempty"`
+}
+
+// PlacementConstraint describes a placement constraint in the manifest.
+type PlacementConstraint struct {
+	Type      twelvefactor.PlacementConstraintType `json:"type" yaml:"type"`
+	Attribute string                               `json:"attribute,omitempty" yaml:"attribute,omitempty"`
+	Operator  string                               `json:"operator,omitempty" yaml:"operator,omitempty"`
+	Value     string                               `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+// PlacementStrategy describes a placement strategy in the manifest.
+type PlacementStrategy struct {
+	Type  twelvefactor.PlacementStrategyType `json:"type" yaml:"type"`
+	Field string                             `json:"field,omitempty" yaml:"field,omitempty"`
+}
+
+// Deployment describes how a process is rolled out in the manifest.
+type Deployment struct {
+	Strategy  twelvefactor.DeploymentStrategy `json:"strategy,omitempty" yaml:"strategy,omitempty"`
+	Rolling   *RollingDeployment              `json:"rolling,omitempty" yaml:"rolling,omitempty"`
+	BlueGreen *BlueGreenDeployment            `json:"blue_green,omitempty" yaml:"blue_green,omitempty"`
+	Canary    *CanaryDeployment               `json:"canary,omitempty" yaml:"canary,omitempty"`
+}
+
+// RollingDeployment describes the options for a rolling deployment in the
+// manifest.
+type RollingDeployment struct {
+	MinimumHealthyPercent int `json:"minimum_healthy_percent,omitempty" yaml:"minimum_healthy_percent,omitempty"`
+	MaximumPercent        int `json:"maximum_percent,omitempty" yaml:"maximum_percent,omitempty"`
+}
+
+// BlueGreenDeployment describes the options for a blue-green deployment in the
+// manifest.
+type BlueGreenDeployment struct {
+	TrafficSwitch   twelvefactor.TrafficSwitch `json:"traffic_switch,omitempty" yaml:"traffic_switch,omitempty"`
+	TerminationWait Duration                   `json:"termination_wait,omitempty" yaml:"termination_wait,omitempty"`
+}
+
+// CanaryDeployment describes the options for a canary deployment in the
+// manifest.
+type CanaryDeployment struct {
+	Percent  int      `json:"percent,omitempty" yaml:"percent,omitempty"`
+	BakeTime Duration `json:"bake_time,omitempty" yaml:"bake_time,omitempty"`
+}
+
+// Duration is a time.Duration that's represented as a string like "30s".
+type Duration time.Duration
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Load reads and validates the manifest at path.
+func Load(path string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, FormatForPath(path))
+}
+
+// Parse parses and validates a manifest. If the manifest is invalid, the
+// returned error is a ValidationErrors describing every problem that was
+// found.
+func Parse(data []byte, format Format) (*Manifest, error) {
+	doc, err := decode(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validate(doc); err != nil {
+		return nil, err
+	}
+
+	// The document has been validated, so it can be decoded into a
+	// Manifest without any surprises. Going through JSON lets YAML and JSON
+	// manifests share the same decoding.
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// decode decodes a manifest into generic maps and slices, with all map keys as
+// strings.
+func decode(data []byte, format Format) (interface{}, error) {
+	switch format {
+	case JSON:
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.UseNumber()
+
+		var doc interface{}
+		if err := d.Decode(&doc); err != nil {
+			return nil, err
+		}
+		return doc, nil
+	case YAML:
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		return normalize("", doc)
+	default:
+		return nil, fmt.Errorf("unknown manifest format: %s", format)
+	}
+}
+
+// normalize converts the map[interface{}]interface{} values that the yaml
+// package decodes into map[string]interface{}.
+func normalize(path string, v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, vv := range v {
+			key, ok := k.(string)
+			if !ok {
+				return nil, &ValidationError{Path: path, Message: fmt.Sprintf("key %v must be a string", k)}
+			}
+
+			nv, err := normalize(join(path, key), vv)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = nv
+		}
+		return m, nil
+	case []interface{}:
+		for i, vv := range v {
+			nv, err := normalize(fmt.Sprintf("%s[%d]", path, i), vv)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = nv
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
+
+// Write writes the manifest to w in the given format.
+func Write(w io.Writer, m *Manifest, format Format) error {
+	switch format {
+	case JSON:
+		raw, err := json.MarshalIndent(m, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(raw, '\n'))
+		return err
+	case YAML:
+		raw, err := yaml.Marshal(m)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(raw)
+		return err
+	default:
+		return fmt.Errorf("unknown manifest format: %s", format)
+	}
+}
+
+// New returns a Manifest describing the app and its processes.
+func New(app twelvefactor.App, processes ...twelvefactor.Process) *Manifest {
+	m := &Manifest{
+		Version:    Version,
+		ID:         app.ID,
+		Name:       app.Name,
+		AppVersion: app.Version,
+		Image:      app.Image,
+		Env:        app.Env,
+		Processes:  make(map[string]Process, len(processes)),
+	}
+
+	for _, p := range processes {
+		var check *HealthCheck
+		if c := p.HealthCheck; c != nil {
+			check = &HealthCheck{
+				Command:     c.Command,
+				Interval:    Duration(c.Interval),
+				Timeout:     Duration(c.Timeout),
+				Retries:     c.Retries,
+				StartPeriod: Duration(c.StartPeriod),
+			}
+		}
+
+		m.Processes[p.Name] = Process{
+			Command:           p.Command,
+			Scale:             p.DesiredCount,
+			Memory:            p.Memory,
+			MemoryReservation: p.MemoryReservation,
+			CPU:               p.CPUReservation(),
+			CPULimit:          p.CPULimit,
+			Schedule:          p.Schedule,
+			HealthCheck:       check,
+			Placement:         newPlacement(p.Placement),
+			Deployment:        newDeployment(p.Deployment),
+			Env:               p.Env,
+			Labels:            p.Labels,
+		}
+	}
+
+	return m
+}
+
+// newPlacement converts process placement into its manifest form, or nil if
+// the process has no placement rules.
+func newPlacement(p twelvefactor.Placement) *Placement {
+	if len(p.Constraints) == 0 && len(p.Strategies) == 0 {
+		return nil
+	}
+
+	placement := new(Placement)
+	for _, c := range p.Constraints {
+		placement.Constraints = append(placement.Constraints, PlacementConstraint{
+			Type:      c.Type,
+			Attribute: c.Attribute,
+			Operator:  c.Operator,
+			Value:     c.Value,
+		})
+	}
+	for _, s := range p.Strategies {
+		placement.Strategies = append(placement.Strategies, PlacementStrategy{
+			Type:  s.Type,
+			Field: s.Field,
+		})
+	}
+	return placement
+}
+
+// newDeployment converts process deployment options into their manifest form,
+// or nil if the process uses the default deployment.
+func newDeployment(d twelvefactor.Deployment) *Deployment {
+	if d == (twelvefactor.Deployment{}) {
+		return nil
+	}
+
+	deployment := &Deployment{Strategy: d.Strategy}
+	if d.Rolling != (twelvefactor.RollingDeployment{}) {
+		deployment.Rolling = &RollingDeployment{
+			MinimumHealthyPercent: d.Rolling.MinimumHealthyPercent,
+			MaximumPercent:        d.Rolling.MaximumPercent,
+		}
+	}
+	if d.BlueGreen != (twelvefactor.BlueGreenDeployment{}) {
+		deployment.BlueGreen = &BlueGreenDeployment{
+			TrafficSwitch:   d.BlueGreen.TrafficSwitch,
+			TerminationWait: Duration(d.BlueGreen.TerminationWait),
+		}
+	}
+	if d.Canary != (twelvefactor.CanaryDeployment{}) {
+		deployment.Canary = &CanaryDeployment{
+			Percent:  d.Canary.Percent,
+			BakeTime: Duration(d.Canary.BakeTime),
+		}
+	}
+	return deployment
+}
+
+// App returns the App described by the manifest, along with its Processes
+// sorted by name.
+func (m *Manifest) App() (twelvefactor.App, []twelvefactor.Process) {
+	app := twelvefactor.App{
+		ID:      m.ID,
+		Name:    m.Name,
+		Version: m.AppVersion,
+		Image:   m.Image,
+		Env:     m.Env,
+	}
+
+	var names []string
+	for name := range m.Processes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var processes []twelvefactor.Process
+	for _, name := range names {
+		p := m.Processes[name]
+
+		var check *twelvefactor.HealthCheck
+		if c := p.HealthCheck; c != nil {
+			check = &twelvefactor.HealthCheck{
+				Command:     c.Command,
+				Interval:    time.Duration(c.Interval),
+				Timeout:     time.Duration(c.Timeout),
+				Retries:     c.Retries,
+				StartPeriod: time.Duration(c.StartPeriod),
+			}
+		}
+
+		processes = append(processes, twelvefactor.Process{
+			Name:              name,
+			Command:           p.Command,
+			Env:               p.Env,
+			Labels:            p.Labels,
+			DesiredCount:      p.Scale,
+			Memory:            p.Memory,
+			MemoryReservation: p.MemoryReservation,
+			CPU:               p.CPU,
+			CPULimit:          p.CPULimit,
+			Schedule:          p.Schedule,
+			HealthCheck:       check,
+			Placement:         p.Placement.placement(),
+			Deployment:        p.Deployment.deployment(),
+		})
+	}
+
+	return app, processes
+}
+
+// placement converts the manifest form of placement rules into
+// twelvefactor.Placement. A nil Placement is the zero value.
+func (p *Placement) placement() twelvefactor.Placement {
+	var placement twelvefactor.Placement
+	if p == nil {
+		return placement
+	}
+
+	for _, c := range p.Constraints {
+		placement.Constraints = append(placement.Constraints, twelvefactor.PlacementConstraint{
+			Type:      c.Type,
+			Attribute: c.Attribute,
+			Operator:  c.Operator,
+			Value:     c.Value,
+		})
+	}
+	for _, s := range p.Strategies {
+		placement.Strategies = append(placement.Strategies, twelvefactor.PlacementStrategy{
+			Type:  s.Type,
+			Field: s.Field,
+		})
+	}
+	return placement
+}
+
+// deployment converts the manifest form of deployment options into
+// twelvefactor.Deployment. A nil Deployment is the zero value.
+func (d *Deployment) deployment() twelvefactor.Deployment {
+	var deployment twelvefactor.Deployment
+	if d == nil {
+		return deployment
+	}
+
+	deployment.Strategy = d.Strategy
+	if r := d.Rolling; r != nil {
+		deployment.Rolling = twelvefactor.RollingDeployment{
+			MinimumHealthyPercent: r.MinimumHealthyPercent,
+			MaximumPercent:        r.MaximumPercent,
+		}
+	}
+	if bg := d.BlueGreen; bg != nil {
+		deployment.BlueGreen = twelvefactor.BlueGreenDeployment{
+			TrafficSwitch:   bg.TrafficSwitch,
+			TerminationWait: time.Duration(bg.TerminationWait),
+		}
+	}
+	if c := d.Canary; c != nil {
+		deployment.Canary = twelvefactor.CanaryDeployment{
+			Percent:  c.Percent,
+			BakeTime: time.Duration(c.BakeTime),
+		}
+	}
+	return deployment
+}
@@ -0,0 +1,400 @@
+package manifest
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/pkg/bytesize"
+	"github.com/remind101/12factor/pkg/cpu"
+	"github.com/stretchr/testify/assert"
+)
+
+const yamlManifest = `version: 1
+name: acme-inc
+image: remind101/acme-inc:latest
+env:
+  RAILS_ENV: production
+processes:
+  web:
+    command: ["acme-inc", "server"]
+    scale: 2
+    memory: 512MiB
+    cpu: 0.25
+    health_check:
+      command: ["curl", "-f", "http://localhost/health"]
+      interval: 30s
+    labels:
+      team: core
+  cleanup:
+    command: ["acme-inc", "cleanup"]
+    schedule: "@daily"
+`
+
+const jsonManifest = `{
+  "version": "1",
+  "name": "acme-inc",
+  "image": "remind101/acme-inc:latest",
+  "env": {"RAILS_ENV": "production"},
+  "processes": {
+    "web": {
+      "command": ["acme-inc", "server"],
+      "scale": 2,
+      "memory": "512MiB",
+      "cpu": "250m",
+      "health_check": {
+        "command": ["curl", "-f", "http://localhost/health"],
+        "interval": "30s"
+      },
+      "labels": {"team": "core"}
+    },
+    "cleanup": {
+      "command": ["acme-inc", "cleanup"],
+      "schedule": "@daily"
+    }
+  }
+}`
+
+var expectedApp = twelvefactor.App{
+	Name:  "acme-inc",
+	Image: "remind101/acme-inc:latest",
+	Env:   map[string]string{"RAILS_ENV": "production"},
+}
+
+var expectedProcesses = []twelvefactor.Process{
+	{
+		Name:     "cleanup",
+		Command:  []string{"acme-inc", "cleanup"},
+		Schedule: "@daily",
+	},
+	{
+		Name:         "web",
+		Command:      []string{"acme-inc", "server"},
+		Labels:       map[string]string{"team": "core"},
+		DesiredCount: 2,
+		Memory:       512 * bytesize.MiB,
+		CPU:          250 * cpu.MilliCPU,
+		HealthCheck: &twelvefactor.HealthCheck{
+			Command:  []string{"curl", "-f", "http://localhost/health"},
+			Interval: 30 * time.Second,
+		},
+	},
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		data   string
+		format Format
+	}{
+		{yamlManifest, YAML},
+		{jsonManifest, JSON},
+		{jsonManifest, YAML},
+	}
+
+	for i, tt := range tests {
+		m, err := Parse([]byte(tt.data), tt.format)
+		if !assert.NoError(t, err, "#%d", i) {
+			continue
+		}
+
+		assert.Equal(t, Version, m.Version, "#%d", i)
+
+		app, processes := m.App()
+		assert.Equal(t, expectedApp, app, "#%d", i)
+		assert.Equal(t, expectedProcesses, processes, "#%d", i)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		data string
+		errs ValidationErrors
+	}{
+		{
+			`image: acme-inc`,
+			ValidationErrors{
+				{Path: "processes", Message: "is required"},
+				{Path: "version", Message: "is required"},
+			},
+		},
+		{
+			`{version: 2, image: acme-inc, processes: {}, extra: true}`,
+			ValidationErrors{
+				{Path: "extra", Message: "unknown field"},
+				{Path: "version", Message: `unsupported version "2", expected "1"`},
+			},
+		},
+		{
+			`
+version: 1
+image: acme-inc
+env:
+  PORT: 8080
+processes:
+  web:
+    command: acme-inc server
+    scale: -1
+    memory: 512 bananas
+    memory_reservation: 1GiB
+  worker:
+    command: []
+    cpu: 2
+    cpu_limit: 1
+    schedule: every day
+  bad.name:
+    command: [a]
+    health_check:
+      interval: soon
+`,
+			ValidationErrors{
+				{Path: "env.PORT", Message: "must be a string"},
+				{Path: "processes.bad.name", Message: "process names may only contain letters, numbers, hyphens and underscores"},
+				{Path: "processes.bad.name.health_check.command", Message: "is required"},
+				{Path: "processes.bad.name.health_check.interval", Message: `"soon" is not a valid duration, like "30s"`},
+				{Path: "processes.web.command", Message: "must be a list of strings"},
+				{Path: "processes.web.memory", Message: `"512 bananas" is not a valid size, like "512MiB"`},
+				{Path: "processes.web.scale", Message: "must not be negative"},
+				{Path: "processes.worker.command", Message: "must not be empty"},
+				{Path: "processes.worker.cpu", Message: "must not exceed cpu_limit (1)"},
+				{Path: "processes.worker.schedule", Message: "invalid schedule expression"},
+			},
+		},
+		{
+			`{version: 1, image: acme-inc, processes: {web: {command: [a], memory: 1GiB, memory_reservation: 2GiB}}}`,
+			ValidationErrors{
+				{Path: "processes.web.memory_reservation", Message: "must not exceed memory (1GiB)"},
+			},
+		},
+		{
+			`
+version: 1
+image: acme-inc
+processes:
+  web:
+    command: [a]
+    placement:
+      constraints:
+        - type: member-of
+          attribute: ecs.instance-type
+          operator: "~="
+        - type: anywhere
+      strategies: spread
+    deployment:
+      rolling:
+        minimum_healthy_percent: 50
+        maximum_percent: 50
+      canary:
+        percent: 10
+  worker:
+    command: [a]
+    placement:
+      strategies:
+        - field: memory
+    deployment:
+      strategy: blue
+      blue_green:
+        traffic_switch: slowly
+`,
+			ValidationErrors{
+				{Path: "processes.web.deployment.canary", Message: "can't be used with the rolling strategy"},
+				{Path: "processes.web.deployment.rolling.maximum_percent", Message: "must be at least 100"},
+				{Path: "processes.web.placement.constraints[0].operator", Message: "must be one of ==, !="},
+				{Path: "processes.web.placement.constraints[0].value", Message: "is required for member-of constraints"},
+				{Path: "processes.web.placement.constraints[1].type", Message: "must be one of distinct-instance, member-of"},
+				{Path: "processes.web.placement.strategies", Message: "must be a list"},
+				{Path: "processes.worker.deployment.blue_green.traffic_switch", Message: "must be one of all-at-once, linear"},
+				{Path: "processes.worker.deployment.strategy", Message: "must be one of rolling, blue-green, canary"},
+				{Path: "processes.worker.placement.strategies[0].type", Message: "is required"},
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		_, err := Parse([]byte(tt.data), YAML)
+		assert.Equal(t, tt.errs, err, "#%d", i)
+	}
+}
+
+func TestParse_Error(t *testing.T) {
+	_, err := Parse([]byte(`processes: [`), YAML)
+	assert.Error(t, err)
+
+	_, err = Parse([]byte(`{"processes": }`), JSON)
+	assert.Error(t, err)
+}
+
+func TestValidationErrors_Error(t *testing.T) {
+	err := ValidationErrors{
+		{Path: "image", Message: "is required"},
+		{Path: "processes.web.memory", Message: "must be a string or a number"},
+	}
+	assert.EqualError(t, err, "invalid manifest: image: is required; processes.web.memory: must be a string or a number")
+}
+
+func TestWrite(t *testing.T) {
+	m := New(twelvefactor.App{
+		Name:  "acme-inc",
+		Image: "remind101/acme-inc:latest",
+	}, twelvefactor.Process{
+		Name:         "web",
+		Command:      []string{"acme-inc", "server"},
+		DesiredCount: 2,
+		Memory:       512 * bytesize.MiB,
+		CPUShares:    256,
+		HealthCheck: &twelvefactor.HealthCheck{
+			Command:  []string{"true"},
+			Interval: 30 * time.Second,
+		},
+	})
+
+	tests := []struct {
+		format Format
+		out    string
+	}{
+		{YAML, `version: "1"
+name: acme-inc
+image: remind101/acme-inc:latest
+processes:
+  web:
+    command:
+    - acme-inc
+    - server
+    scale: 2
+    memory: 512MiB
+    cpu: 250m
+    health_check:
+      command:
+      - "true"
+      interval: 30s
+`},
+		{JSON, `{
+  "version": "1",
+  "name": "acme-inc",
+  "image": "remind101/acme-inc:latest",
+  "processes": {
+    "web": {
+      "command": [
+        "acme-inc",
+        "server"
+      ],
+      "scale": 2,
+      "memory": "512MiB",
+      "cpu": "250m",
+      "health_check": {
+        "command": [
+          "true"
+        ],
+        "interval": "30s"
+      }
+    }
+  }
+}
+`},
+	}
+
+	for _, tt := range tests {
+		buf := new(bytes.Buffer)
+		if !assert.NoError(t, Write(buf, m, tt.format)) {
+			continue
+		}
+		assert.Equal(t, tt.out, buf.String())
+
+		// Whatever is written should be readable.
+		parsed, err := Parse(buf.Bytes(), tt.format)
+		assert.NoError(t, err)
+		assert.Equal(t, m, parsed)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	app := twelvefactor.App{
+		ID:      "acme-inc",
+		Name:    "acme-inc",
+		Version: "v42",
+		Image:   "remind101/acme-inc:latest",
+		Env:     map[string]string{"RAILS_ENV": "production"},
+	}
+	processes := []twelvefactor.Process{
+		{
+			Name:         "deploy",
+			Command:      []string{"acme-inc", "deploy"},
+			DesiredCount: 1,
+			Deployment: twelvefactor.Deployment{
+				Strategy: twelvefactor.Canary,
+				Canary: twelvefactor.CanaryDeployment{
+					Percent:  10,
+					BakeTime: 5 * time.Minute,
+				},
+			},
+		},
+		{
+			Name:              "web",
+			Command:           []string{"acme-inc", "server"},
+			Env:               map[string]string{"PORT": "8080"},
+			Labels:            map[string]string{"team": "core"},
+			DesiredCount:      2,
+			Memory:            bytesize.GiB,
+			MemoryReservation: 512 * bytesize.MiB,
+			CPU:               250 * cpu.MilliCPU,
+			CPULimit:          cpu.VCPU,
+			HealthCheck: &twelvefactor.HealthCheck{
+				Command:     []string{"curl", "-f", "http://localhost/health"},
+				Interval:    30 * time.Second,
+				Timeout:     5 * time.Second,
+				Retries:     3,
+				StartPeriod: time.Minute,
+			},
+			Placement: twelvefactor.Placement{
+				Constraints: []twelvefactor.PlacementConstraint{
+					{Type: twelvefactor.DistinctInstance},
+					{Type: twelvefactor.MemberOf, Attribute: "ecs.instance-type", Operator: "!=", Value: "t2.micro"},
+				},
+				Strategies: []twelvefactor.PlacementStrategy{
+					{Type: twelvefactor.Spread, Field: twelvefactor.FieldAvailabilityZone},
+				},
+			},
+			Deployment: twelvefactor.Deployment{
+				Strategy: twelvefactor.Rolling,
+				Rolling: twelvefactor.RollingDeployment{
+					MinimumHealthyPercent: 50,
+					MaximumPercent:        200,
+				},
+			},
+		},
+		{
+			Name:     "cleanup",
+			Command:  []string{"acme-inc", "cleanup"},
+			Schedule: "@daily",
+			Deployment: twelvefactor.Deployment{
+				Strategy: twelvefactor.BlueGreen,
+				BlueGreen: twelvefactor.BlueGreenDeployment{
+					TrafficSwitch:   twelvefactor.Linear,
+					TerminationWait: time.Hour,
+				},
+			},
+		},
+	}
+
+	for _, format := range []Format{YAML, JSON} {
+		buf := new(bytes.Buffer)
+		if !assert.NoError(t, Write(buf, New(app, processes...), format)) {
+			continue
+		}
+
+		m, err := Parse(buf.Bytes(), format)
+		if !assert.NoError(t, err, "%s", format) {
+			continue
+		}
+
+		gotApp, gotProcesses := m.App()
+		assert.Equal(t, app, gotApp, "%s", format)
+		assert.Equal(t, []twelvefactor.Process{processes[2], processes[0], processes[1]}, gotProcesses, "%s", format)
+	}
+}
+
+func TestFormatForPath(t *testing.T) {
+	assert.Equal(t, JSON, FormatForPath("app.json"))
+	assert.Equal(t, YAML, FormatForPath("12factor.yml"))
+	assert.Equal(t, YAML, FormatForPath("12factor.yaml"))
+}
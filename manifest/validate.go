@@ -0,0 +1,508 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/pkg/bytesize"
+	"github.com/remind101/12factor/pkg/cpu"
+	"github.com/remind101/12factor/pkg/cron"
+)
+
+// ValidationError describes a single problem with a manifest.
+type ValidationError struct {
+	// The location of the invalid value, such as "processes.web.memory".
+	// Empty for problems with the manifest as a whole.
+	Path string
+
+	// What's wrong with the value.
+	Message string
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidationErrors is returned when a manifest is invalid, and contains every
+// problem that was found, sorted by path.
+type ValidationErrors []*ValidationError
+
+// Error implements the error interface.
+func (e ValidationErrors) Error() string {
+	var msgs []string
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("invalid manifest: %s", strings.Join(msgs, "; "))
+}
+
+// Is reports whether target is twelvefactor.ErrInvalidConfig.
+func (e ValidationErrors) Is(target error) bool {
+	return target == twelvefactor.ErrInvalidConfig
+}
+
+// processName matches the allowed names for processes, which need to be usable
+// as part of service and task definition names.
+var processName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// validator checks a decoded manifest, in the same spirit as a JSON schema,
+// collecting errors as it goes.
+type validator struct {
+	errs ValidationErrors
+}
+
+// validate validates a decoded manifest document, returning ValidationErrors
+// if it's invalid. The version is normalized to a string, so that YAML
+// manifests can use `version: 1`.
+func validate(doc interface{}) error {
+	v := new(validator)
+	v.manifest(doc)
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(v.errs, func(i, j int) bool {
+		return v.errs[i].Path < v.errs[j].Path
+	})
+	return v.errs
+}
+
+func (v *validator) errorf(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) manifest(doc interface{}) {
+	m := v.object("", doc, []string{"version", "image", "processes"},
+		"version", "id", "name", "app_version", "image", "env", "processes")
+	if m == nil {
+		return
+	}
+
+	if version, ok := m["version"]; ok {
+		s := scalar(version)
+		if s != Version {
+			v.errorf("version", "unsupported version %q, expected %q", s, Version)
+		}
+		m["version"] = s
+	}
+
+	v.str("id", m["id"])
+	v.str("name", m["name"])
+	v.str("app_version", m["app_version"])
+	if image, ok := m["image"]; ok {
+		if s, ok := v.str("image", image); ok && s == "" {
+			v.errorf("image", "must not be empty")
+		}
+	}
+	v.stringMap("env", m["env"])
+
+	if processes, ok := m["processes"]; ok {
+		ps, ok := processes.(map[string]interface{})
+		if !ok {
+			v.errorf("processes", "must be an object")
+			return
+		}
+
+		for name, p := range ps {
+			path := join("processes", name)
+			if !processName.MatchString(name) {
+				v.errorf(path, "process names may only contain letters, numbers, hyphens and underscores")
+			}
+			v.process(path, p)
+		}
+	}
+}
+
+func (v *validator) process(path string, doc interface{}) {
+	p := v.object(path, doc, []string{"command"},
+		"command", "scale", "memory", "memory_reservation", "cpu", "cpu_limit",
+		"schedule", "health_check", "placement", "deployment", "env", "labels")
+	if p == nil {
+		return
+	}
+
+	v.command(join(path, "command"), p["command"])
+	v.integer(join(path, "scale"), p["scale"])
+
+	memory, _ := v.byteSize(join(path, "memory"), p["memory"])
+	reservation, _ := v.byteSize(join(path, "memory_reservation"), p["memory_reservation"])
+	if memory != 0 && reservation > memory {
+		v.errorf(join(path, "memory_reservation"), "must not exceed memory (%s)", memory)
+	}
+
+	c, _ := v.cpu(join(path, "cpu"), p["cpu"])
+	limit, _ := v.cpu(join(path, "cpu_limit"), p["cpu_limit"])
+	if limit != 0 && c > limit {
+		v.errorf(join(path, "cpu"), "must not exceed cpu_limit (%s)", limit)
+	}
+
+	if schedule, ok := v.str(join(path, "schedule"), p["schedule"]); ok {
+		if _, err := cron.Parse(schedule); err != nil {
+			v.errorf(join(path, "schedule"), "%v", err)
+		}
+	}
+
+	if check, ok := p["health_check"]; ok {
+		v.healthCheck(join(path, "health_check"), check)
+	}
+
+	if placement, ok := p["placement"]; ok {
+		v.placement(join(path, "placement"), placement)
+	}
+
+	if deployment, ok := p["deployment"]; ok {
+		v.deployment(join(path, "deployment"), deployment)
+	}
+
+	v.stringMap(join(path, "env"), p["env"])
+	v.stringMap(join(path, "labels"), p["labels"])
+}
+
+func (v *validator) healthCheck(path string, doc interface{}) {
+	c := v.object(path, doc, []string{"command"},
+		"command", "interval", "timeout", "retries", "start_period")
+	if c == nil {
+		return
+	}
+
+	v.command(join(path, "command"), c["command"])
+	v.duration(join(path, "interval"), c["interval"])
+	v.duration(join(path, "timeout"), c["timeout"])
+	v.integer(join(path, "retries"), c["retries"])
+	v.duration(join(path, "start_period"), c["start_period"])
+}
+
+func (v *validator) placement(path string, doc interface{}) {
+	p := v.object(path, doc, nil, "constraints", "strategies")
+	if p == nil {
+		return
+	}
+
+	for i, doc := range v.list(join(path, "constraints"), p["constraints"]) {
+		path := fmt.Sprintf("%s[%d]", join(path, "constraints"), i)
+		c := v.object(path, doc, []string{"type"}, "type", "attribute", "operator", "value")
+		if c == nil {
+			continue
+		}
+
+		typ, _ := v.oneOf(join(path, "type"), c["type"],
+			string(twelvefactor.DistinctInstance), string(twelvefactor.MemberOf))
+		v.str(join(path, "attribute"), c["attribute"])
+		v.oneOf(join(path, "operator"), c["operator"], "==", "!=")
+		v.str(join(path, "value"), c["value"])
+
+		if typ == string(twelvefactor.MemberOf) {
+			for _, key := range []string{"attribute", "value"} {
+				if _, ok := c[key]; !ok {
+					v.errorf(join(path, key), "is required for %s constraints", typ)
+				}
+			}
+		}
+	}
+
+	for i, doc := range v.list(join(path, "strategies"), p["strategies"]) {
+		path := fmt.Sprintf("%s[%d]", join(path, "strategies"), i)
+		s := v.object(path, doc, []string{"type"}, "type", "field")
+		if s == nil {
+			continue
+		}
+
+		v.oneOf(join(path, "type"), s["type"],
+			string(twelvefactor.Spread), string(twelvefactor.Binpack), string(twelvefactor.Random))
+		v.str(join(path, "field"), s["field"])
+	}
+}
+
+func (v *validator) deployment(path string, doc interface{}) {
+	d := v.object(path, doc, nil, "strategy", "rolling", "blue_green", "canary")
+	if d == nil {
+		return
+	}
+
+	strategy, ok := v.oneOf(join(path, "strategy"), d["strategy"],
+		string(twelvefactor.Rolling), string(twelvefactor.BlueGreen), string(twelvefactor.Canary))
+	if _, set := d["strategy"]; !set {
+		strategy, ok = string(twelvefactor.Rolling), true
+	}
+
+	// Only the options for the chosen strategy can be set.
+	for key, s := range map[string]twelvefactor.DeploymentStrategy{
+		"rolling":    twelvefactor.Rolling,
+		"blue_green": twelvefactor.BlueGreen,
+		"canary":     twelvefactor.Canary,
+	} {
+		if _, set := d[key]; set && ok && strategy != string(s) {
+			v.errorf(join(path, key), "can't be used with the %s strategy", strategy)
+		}
+	}
+
+	if doc, ok := d["rolling"]; ok {
+		path := join(path, "rolling")
+		if r := v.object(path, doc, nil, "minimum_healthy_percent", "maximum_percent"); r != nil {
+			min, _ := v.integer(join(path, "minimum_healthy_percent"), r["minimum_healthy_percent"])
+			max, ok := v.integer(join(path, "maximum_percent"), r["maximum_percent"])
+			if min > 100 {
+				v.errorf(join(path, "minimum_healthy_percent"), "must not be more than 100")
+			}
+			if ok && max < 100 {
+				v.errorf(join(path, "maximum_percent"), "must be at least 100")
+			} else if ok && max <= min {
+				v.errorf(join(path, "maximum_percent"), "must be more than minimum_healthy_percent (%d)", min)
+			}
+		}
+	}
+
+	if doc, ok := d["blue_green"]; ok {
+		path := join(path, "blue_green")
+		if bg := v.object(path, doc, nil, "traffic_switch", "termination_wait"); bg != nil {
+			v.oneOf(join(path, "traffic_switch"), bg["traffic_switch"],
+				string(twelvefactor.AllAtOnce), string(twelvefactor.Linear))
+			v.duration(join(path, "termination_wait"), bg["termination_wait"])
+		}
+	}
+
+	if doc, ok := d["canary"]; ok {
+		path := join(path, "canary")
+		if c := v.object(path, doc, nil, "percent", "bake_time"); c != nil {
+			if percent, ok := v.integer(join(path, "percent"), c["percent"]); ok && percent > 100 {
+				v.errorf(join(path, "percent"), "must not be more than 100")
+			}
+			v.duration(join(path, "bake_time"), c["bake_time"])
+		}
+	}
+}
+
+// object checks that doc is an object with all of the required keys and no
+// keys that aren't allowed. It returns nil if doc isn't an object.
+func (v *validator) object(path string, doc interface{}, required []string, allowed ...string) map[string]interface{} {
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		v.errorf(path, "must be an object")
+		return nil
+	}
+
+	for _, key := range required {
+		if _, ok := m[key]; !ok {
+			v.errorf(join(path, key), "is required")
+		}
+	}
+
+	known := make(map[string]bool, len(allowed))
+	for _, key := range allowed {
+		known[key] = true
+	}
+	for key := range m {
+		if !known[key] {
+			v.errorf(join(path, key), "unknown field")
+		}
+	}
+
+	return m
+}
+
+// str checks that val, if present, is a string.
+func (v *validator) str(path string, val interface{}) (string, bool) {
+	if val == nil {
+		return "", false
+	}
+
+	s, ok := val.(string)
+	if !ok {
+		v.errorf(path, "must be a string")
+	}
+	return s, ok
+}
+
+// oneOf checks that val, if present, is one of the allowed strings.
+func (v *validator) oneOf(path string, val interface{}, allowed ...string) (string, bool) {
+	s, ok := v.str(path, val)
+	if !ok {
+		return "", false
+	}
+
+	for _, a := range allowed {
+		if s == a {
+			return s, true
+		}
+	}
+
+	v.errorf(path, "must be one of %s", strings.Join(allowed, ", "))
+	return "", false
+}
+
+// list checks that val, if present, is a list.
+func (v *validator) list(path string, val interface{}) []interface{} {
+	if val == nil {
+		return nil
+	}
+
+	l, ok := val.([]interface{})
+	if !ok {
+		v.errorf(path, "must be a list")
+	}
+	return l
+}
+
+// command checks that val is a non-empty list of strings.
+func (v *validator) command(path string, val interface{}) {
+	if val == nil {
+		return
+	}
+
+	l, ok := val.([]interface{})
+	if !ok {
+		v.errorf(path, "must be a list of strings")
+		return
+	}
+
+	if len(l) == 0 {
+		v.errorf(path, "must not be empty")
+	}
+
+	for i, s := range l {
+		v.str(fmt.Sprintf("%s[%d]", path, i), s)
+	}
+}
+
+// stringMap checks that val, if present, is an object with string values.
+func (v *validator) stringMap(path string, val interface{}) {
+	if val == nil {
+		return
+	}
+
+	m, ok := val.(map[string]interface{})
+	if !ok {
+		v.errorf(path, "must be an object")
+		return
+	}
+
+	for k, s := range m {
+		v.str(join(path, k), s)
+	}
+}
+
+// integer checks that val, if present, is a whole number that isn't negative.
+func (v *validator) integer(path string, val interface{}) (int, bool) {
+	if val == nil {
+		return 0, false
+	}
+
+	if _, ok := number(val); !ok {
+		v.errorf(path, "must be a number")
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(scalar(val))
+	if err != nil {
+		v.errorf(path, "must be a whole number")
+		return 0, false
+	} else if n < 0 {
+		v.errorf(path, "must not be negative")
+		return 0, false
+	}
+	return n, true
+}
+
+// byteSize checks that val, if present, is a byte size like "512MiB" or a
+// number of bytes.
+func (v *validator) byteSize(path string, val interface{}) (bytesize.ByteSize, bool) {
+	if val == nil {
+		return 0, false
+	}
+
+	if _, ok := number(val); !ok {
+		if _, ok := val.(string); !ok {
+			v.errorf(path, "must be a string or a number")
+			return 0, false
+		}
+	}
+
+	b, err := bytesize.Parse(scalar(val))
+	if err != nil {
+		v.errorf(path, "%q is not a valid size, like \"512MiB\"", scalar(val))
+		return 0, false
+	}
+	return b, true
+}
+
+// cpu checks that val, if present, is an amount of CPU like "250m" or 0.25.
+func (v *validator) cpu(path string, val interface{}) (cpu.CPU, bool) {
+	if val == nil {
+		return 0, false
+	}
+
+	if _, ok := number(val); !ok {
+		if _, ok := val.(string); !ok {
+			v.errorf(path, "must be a string or a number")
+			return 0, false
+		}
+	}
+
+	c, err := cpu.Parse(scalar(val))
+	if err != nil {
+		v.errorf(path, "%q is not a valid amount of CPU, like \"0.5\" or \"250m\"", scalar(val))
+		return 0, false
+	}
+	return c, true
+}
+
+// duration checks that val, if present, is a duration like "30s".
+func (v *validator) duration(path string, val interface{}) {
+	s, ok := v.str(path, val)
+	if !ok {
+		return
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		v.errorf(path, "%q is not a valid duration, like \"30s\"", s)
+	} else if d < 0 {
+		v.errorf(path, "must not be negative")
+	}
+}
+
+// number returns the string representation of val if it's a number decoded
+// from JSON or YAML.
+func number(val interface{}) (string, bool) {
+	switch n := val.(type) {
+	case json.Number:
+		return n.String(), true
+	case int:
+		return strconv.Itoa(n), true
+	case int64:
+		return strconv.FormatInt(n, 10), true
+	case uint64:
+		return strconv.FormatUint(n, 10), true
+	case float64:
+		return strconv.FormatFloat(n, 'f', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+// scalar returns the string representation of a decoded string or number.
+func scalar(val interface{}) string {
+	if s, ok := number(val); ok {
+		return s
+	}
+	return fmt.Sprint(val)
+}
+
+// join joins a path and a key with a ".".
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -3,6 +3,8 @@ package arn
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
 	"strings"
 )
 
@@ -16,14 +18,49 @@ var (
 
 const delimiter = ":"
 
+// Partitions that AWS resources can exist within, as returned by
+// PartitionForRegion.
+const (
+	PartitionAWS      = "aws"
+	PartitionChina    = "aws-cn"
+	PartitionGovCloud = "aws-us-gov"
+	PartitionISO      = "aws-iso"
+	PartitionISOB     = "aws-iso-b"
+	PartitionISOE     = "aws-iso-e"
+	PartitionISOF     = "aws-iso-f"
+	PartitionEUSC     = "aws-eusc"
+)
+
+// partitionPattern matches the names of AWS partitions. New partitions are
+// added from time to time, so Parse accepts any name in the form that they
+// all follow, rather than only the partitions above.
+var partitionPattern = regexp.MustCompile(`^aws(-[a-z]+)*$`)
+
 // ARN represents a parsed Amazon Resource Name.
 type ARN struct {
-	ARN      string
-	AWS      string
-	Service  string
-	Region   string
-	Account  string
-	Resource string
+	ARN       string
+	Partition string
+	Service   string
+	Region    string
+	Account   string
+	Resource  string
+
+	// Deprecated: Use Partition instead. Parse and New set both fields,
+	// and String only uses AWS when Partition is empty.
+	AWS string
+}
+
+// New returns a new ARN for a resource.
+func New(partition, service, region, account, resource string) *ARN {
+	return &ARN{
+		ARN:       "arn",
+		Partition: partition,
+		AWS:       partition,
+		Service:   service,
+		Region:    region,
+		Account:   account,
+		Resource:  resource,
+	}
 }
 
 // Parse parses an Amazon Resource Name from a String into an ARN.
@@ -36,16 +73,22 @@ func Parse(arn string) (*ARN, error) {
 	}
 
 	a := &ARN{
-		ARN:      p[0],
-		AWS:      p[1],
-		Service:  p[2],
-		Region:   p[3],
-		Account:  p[4],
-		Resource: p[5],
+		ARN:       p[0],
+		Partition: p[1],
+		AWS:       p[1],
+		Service:   p[2],
+		Region:    p[3],
+		Account:   p[4],
+		Resource:  p[5],
 	}
 
-	// ARN's always start with "arn:aws" (hopefully).
-	if a.ARN != "arn" || a.AWS != "aws" {
+	// ARN's always start with "arn", followed by a partition such as "aws"
+	// or "aws-cn".
+	if a.ARN != "arn" || !partitionPattern.MatchString(a.Partition) {
+		return nil, ErrInvalidARN
+	}
+
+	if a.Service == "" || a.Resource == "" {
 		return nil, ErrInvalidARN
 	}
 
@@ -54,28 +97,84 @@ func Parse(arn string) (*ARN, error) {
 
 // String returns the string representation of an Amazon Resource Name.
 func (a *ARN) String() string {
+	partition := a.Partition
+	if partition == "" {
+		partition = a.AWS
+	}
+
 	return strings.Join(
-		[]string{a.ARN, a.AWS, a.Service, a.Region, a.Account, a.Resource},
+		[]string{a.ARN, partition, a.Service, a.Region, a.Account, a.Resource},
 		delimiter,
 	)
 }
 
+// MarshalText implements the encoding.TextMarshaler interface.
+func (a ARN) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (a *ARN) UnmarshalText(text []byte) error {
+	p, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*a = *p
+	return nil
+}
+
+// ResourceType returns the type of the resource, such as "service" for ECS
+// services or "role" for IAM roles. Resources without a type, like SNS topics,
+// return an empty string.
+func (a *ARN) ResourceType() string {
+	t, _ := splitResource(a.Resource)
+	return t
+}
+
+// ResourceParts returns the components of the resource that follow the
+// resource type. For example, the resource "task/cluster/id" has the parts
+// "cluster" and "id".
+func (a *ARN) ResourceParts() []string {
+	_, parts := splitResource(a.Resource)
+	return parts
+}
+
+// ResourceID returns the last component of the resource, which is generally the
+// name or id of the resource.
+func (a *ARN) ResourceID() string {
+	parts := a.ResourceParts()
+	return parts[len(parts)-1]
+}
+
 // SplitResource splits the Resource section of an ARN into its type and id
-// components.
+// components. Both "/" and ":" are accepted as the delimiter after the type, and
+// the id is the last component of the resource, so "task/cluster/id" returns
+// "task" and "id".
 func SplitResource(r string) (resource, id string, err error) {
-	p := strings.Split(r, "/")
+	t, parts := splitResource(r)
 
-	if len(p) != 2 {
+	if t == "" || parts[len(parts)-1] == "" {
 		err = ErrInvalidResource
 		return
 	}
 
-	resource = p[0]
-	id = p[1]
+	resource = t
+	id = parts[len(parts)-1]
 
 	return
 }
 
+// splitResource splits r on the first "/" or ":", whichever comes first, and
+// then splits the remainder on the same delimiter.
+func splitResource(r string) (resource string, parts []string) {
+	i := strings.IndexAny(r, "/:")
+	if i < 0 {
+		return "", []string{r}
+	}
+
+	return r[:i], strings.Split(r[i+1:], r[i:i+1])
+}
+
 // ResourceID takes an ARN string and returns the resource ID from it.
 func ResourceID(arn string) (string, error) {
 	a, err := Parse(arn)
@@ -86,3 +185,64 @@ func ResourceID(arn string) (string, error) {
 	_, id, err := SplitResource(a.Resource)
 	return id, err
 }
+
+// PartitionForRegion returns the partition that the region belongs to.
+func PartitionForRegion(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return PartitionChina
+	case strings.HasPrefix(region, "us-gov-"):
+		return PartitionGovCloud
+	case strings.HasPrefix(region, "us-isob-"):
+		return PartitionISOB
+	case strings.HasPrefix(region, "us-iso-"):
+		return PartitionISO
+	case strings.HasPrefix(region, "eu-isoe-"):
+		return PartitionISOE
+	case strings.HasPrefix(region, "us-isof-"):
+		return PartitionISOF
+	case strings.HasPrefix(region, "eusc-"):
+		return PartitionEUSC
+	default:
+		return PartitionAWS
+	}
+}
+
+// ECSCluster returns the ARN of an ECS cluster.
+func ECSCluster(region, account, cluster string) *ARN {
+	return New(PartitionForRegion(region), "ecs", region, account, "cluster/"+cluster)
+}
+
+// ECSService returns the ARN of an ECS service, in the long format that
+// includes the cluster name.
+func ECSService(region, account, cluster, service string) *ARN {
+	return New(PartitionForRegion(region), "ecs", region, account, fmt.Sprintf("service/%s/%s", cluster, service))
+}
+
+// ECSTask returns the ARN of an ECS task, in the long format that includes the
+// cluster name.
+func ECSTask(region, account, cluster, id string) *ARN {
+	return New(PartitionForRegion(region), "ecs", region, account, fmt.Sprintf("task/%s/%s", cluster, id))
+}
+
+// ECSTaskDefinition returns the ARN of a revision of an ECS task definition.
+func ECSTaskDefinition(region, account, family string, revision int) *ARN {
+	return New(PartitionForRegion(region), "ecs", region, account, fmt.Sprintf("task-definition/%s:%d", family, revision))
+}
+
+// IAMRole returns the ARN of an IAM role. IAM is a global service, so the ARN
+// has no region. name may include a path, like "service-role/ecs".
+func IAMRole(partition, account, name string) *ARN {
+	return New(partition, "iam", "", account, "role/"+name)
+}
+
+// ELBLoadBalancer returns the ARN of a classic Elastic Load Balancer.
+func ELBLoadBalancer(region, account, name string) *ARN {
+	return New(PartitionForRegion(region), "elasticloadbalancing", region, account, "loadbalancer/"+name)
+}
+
+// ELBTargetGroup returns the ARN of an application or network load balancer
+// target group.
+func ELBTargetGroup(region, account, name, id string) *ARN {
+	return New(PartitionForRegion(region), "elasticloadbalancing", region, account, fmt.Sprintf("targetgroup/%s/%s", name, id))
+}
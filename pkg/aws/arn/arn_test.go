@@ -1,6 +1,10 @@
 package arn
 
-import "testing"
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
 
 func TestParse(t *testing.T) {
 	tests := []struct {
@@ -8,25 +12,60 @@ func TestParse(t *testing.T) {
 		out ARN
 	}{
 		{"arn:aws:ecs:us-east-1:249285743859:service/acme-inc--web", ARN{
-			ARN:      "arn",
-			AWS:      "aws",
-			Service:  "ecs",
-			Region:   "us-east-1",
-			Account:  "249285743859",
-			Resource: "service/acme-inc--web",
+			ARN:       "arn",
+			Partition: "aws",
+			AWS:       "aws",
+			Service:   "ecs",
+			Region:    "us-east-1",
+			Account:   "249285743859",
+			Resource:  "service/acme-inc--web",
 		}},
 		{"arn:aws:ecs:us-east-1:249285743859:service/acme-inc:web", ARN{
-			ARN:      "arn",
-			AWS:      "aws",
-			Service:  "ecs",
-			Region:   "us-east-1",
-			Account:  "249285743859",
-			Resource: "service/acme-inc:web",
+			ARN:       "arn",
+			Partition: "aws",
+			AWS:       "aws",
+			Service:   "ecs",
+			Region:    "us-east-1",
+			Account:   "249285743859",
+			Resource:  "service/acme-inc:web",
+		}},
+		{"arn:aws-cn:ecs:cn-north-1:249285743859:task/cluster/0b69d5c0", ARN{
+			ARN:       "arn",
+			Partition: "aws-cn",
+			AWS:       "aws-cn",
+			Service:   "ecs",
+			Region:    "cn-north-1",
+			Account:   "249285743859",
+			Resource:  "task/cluster/0b69d5c0",
+		}},
+		{"arn:aws-us-gov:iam::249285743859:role/service-role/ecs", ARN{
+			ARN:       "arn",
+			Partition: "aws-us-gov",
+			AWS:       "aws-us-gov",
+			Service:   "iam",
+			Account:   "249285743859",
+			Resource:  "role/service-role/ecs",
+		}},
+		{"arn:aws-iso-x:ecs:us-isox-east-1:249285743859:cluster/cluster", ARN{
+			ARN:       "arn",
+			Partition: "aws-iso-x",
+			AWS:       "aws-iso-x",
+			Service:   "ecs",
+			Region:    "us-isox-east-1",
+			Account:   "249285743859",
+			Resource:  "cluster/cluster",
 		}},
 	}
 
 	for i, tt := range tests {
-		arn, _ := Parse(tt.in)
+		arn, err := Parse(tt.in)
+		if err != nil {
+			t.Fatalf("#%d: Parse(%q): %v", i, tt.in, err)
+		}
+
+		if got, want := *arn, tt.out; got != want {
+			t.Errorf("#%d: Parse(%q) => %#v; want %#v", i, tt.in, got, want)
+		}
 
 		if got, want := arn.String(), tt.out.String(); got != want {
 			t.Errorf("#%d: Parse(%q) => %s; want %s", i, tt.in, got, want)
@@ -42,6 +81,9 @@ func TestSplitResource(t *testing.T) {
 	}{
 		{"service/acme-inc", "service", "acme-inc", nil},
 		{"service", "", "", ErrInvalidResource},
+		{"service/cluster/acme-inc", "service", "acme-inc", nil},
+		{"function:acme-inc", "function", "acme-inc", nil},
+		{"service/", "", "", ErrInvalidResource},
 	}
 
 	for i, tt := range tests {
@@ -59,3 +101,135 @@ func TestSplitResource(t *testing.T) {
 		}
 	}
 }
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"arn:aws:ecs",
+		"urn:aws:ecs:us-east-1:249285743859:service/acme-inc",
+		"arn:azure:ecs:us-east-1:249285743859:service/acme-inc",
+		"arn:awsome:ecs:us-east-1:249285743859:service/acme-inc",
+		"arn:aws-:ecs:us-east-1:249285743859:service/acme-inc",
+		"arn:aws-CN:ecs:cn-north-1:249285743859:service/acme-inc",
+		"arn:aws::us-east-1:249285743859:service/acme-inc",
+		"arn:aws:ecs:us-east-1:249285743859:",
+	}
+
+	for i, tt := range tests {
+		if _, err := Parse(tt); err != ErrInvalidARN {
+			t.Errorf("#%d: Parse(%q): err => %v; want %v", i, tt, err, ErrInvalidARN)
+		}
+	}
+}
+
+func TestARN_Resource(t *testing.T) {
+	tests := []struct {
+		in    string
+		typ   string
+		parts []string
+		id    string
+	}{
+		{"arn:aws:ecs:us-east-1:249285743859:service/acme-inc--web", "service", []string{"acme-inc--web"}, "acme-inc--web"},
+		{"arn:aws:ecs:us-east-1:249285743859:task/cluster/0b69d5c0", "task", []string{"cluster", "0b69d5c0"}, "0b69d5c0"},
+		{"arn:aws:lambda:us-east-1:249285743859:function:acme:live", "function", []string{"acme", "live"}, "live"},
+		{"arn:aws:sns:us-east-1:249285743859:acme-inc", "", []string{"acme-inc"}, "acme-inc"},
+	}
+
+	for i, tt := range tests {
+		a, err := Parse(tt.in)
+		if err != nil {
+			t.Fatalf("#%d: Parse(%q): %v", i, tt.in, err)
+		}
+
+		if got, want := a.ResourceType(), tt.typ; got != want {
+			t.Errorf("#%d: ResourceType() => %q; want %q", i, got, want)
+		}
+
+		if got, want := a.ResourceParts(), tt.parts; !reflect.DeepEqual(got, want) {
+			t.Errorf("#%d: ResourceParts() => %q; want %q", i, got, want)
+		}
+
+		if got, want := a.ResourceID(), tt.id; got != want {
+			t.Errorf("#%d: ResourceID() => %q; want %q", i, got, want)
+		}
+	}
+}
+
+func TestResourceID(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"arn:aws:ecs:us-east-1:249285743859:task/0b69d5c0", "0b69d5c0"},
+		{"arn:aws:ecs:us-east-1:249285743859:task/cluster/0b69d5c0", "0b69d5c0"},
+		{"arn:aws:ecs:us-east-1:249285743859:service/cluster/acme-inc--web", "acme-inc--web"},
+	}
+
+	for i, tt := range tests {
+		id, err := ResourceID(tt.in)
+		if err != nil {
+			t.Fatalf("#%d: ResourceID(%q): %v", i, tt.in, err)
+		}
+
+		if got, want := id, tt.out; got != want {
+			t.Errorf("#%d: ResourceID(%q) => %s; want %s", i, tt.in, got, want)
+		}
+	}
+}
+
+func TestBuilders(t *testing.T) {
+	tests := []struct {
+		arn *ARN
+		out string
+	}{
+		{ECSCluster("us-east-1", "249285743859", "default"), "arn:aws:ecs:us-east-1:249285743859:cluster/default"},
+		{ECSService("us-west-2", "249285743859", "default", "acme-inc--web"), "arn:aws:ecs:us-west-2:249285743859:service/default/acme-inc--web"},
+		{ECSTask("cn-north-1", "249285743859", "default", "0b69d5c0"), "arn:aws-cn:ecs:cn-north-1:249285743859:task/default/0b69d5c0"},
+		{ECSTaskDefinition("us-gov-west-1", "249285743859", "acme-inc--web", 3), "arn:aws-us-gov:ecs:us-gov-west-1:249285743859:task-definition/acme-inc--web:3"},
+		{IAMRole(PartitionAWS, "249285743859", "service-role/ecs"), "arn:aws:iam::249285743859:role/service-role/ecs"},
+		{ELBLoadBalancer("us-east-1", "249285743859", "acme-inc"), "arn:aws:elasticloadbalancing:us-east-1:249285743859:loadbalancer/acme-inc"},
+		{ELBTargetGroup("us-east-1", "249285743859", "acme-inc", "73e2d6bc24d8a067"), "arn:aws:elasticloadbalancing:us-east-1:249285743859:targetgroup/acme-inc/73e2d6bc24d8a067"},
+	}
+
+	for i, tt := range tests {
+		if got, want := tt.arn.String(), tt.out; got != want {
+			t.Errorf("#%d: => %s; want %s", i, got, want)
+		}
+	}
+}
+
+func TestARN_String_AWS(t *testing.T) {
+	// ARNs that were built with the deprecated AWS field still work.
+	a := &ARN{ARN: "arn", AWS: "aws-cn", Service: "sns", Region: "cn-north-1", Account: "249285743859", Resource: "acme-inc"}
+
+	if got, want := a.String(), "arn:aws-cn:sns:cn-north-1:249285743859:acme-inc"; got != want {
+		t.Errorf("String() => %s; want %s", got, want)
+	}
+}
+
+func TestARN_Text(t *testing.T) {
+	var v struct {
+		Role ARN
+	}
+
+	in := `{"Role":"arn:aws:iam::249285743859:role/ecs"}`
+	if err := json.Unmarshal([]byte(in), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := v.Role.ResourceID(), "ecs"; got != want {
+		t.Errorf("ResourceID() => %s; want %s", got, want)
+	}
+
+	out, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := string(out), in; got != want {
+		t.Errorf("Marshal => %s; want %s", got, want)
+	}
+
+	if err := json.Unmarshal([]byte(`{"Role":"ecs"}`), &v); err != ErrInvalidARN {
+		t.Errorf("Unmarshal: err => %v; want %v", err, ErrInvalidARN)
+	}
+}
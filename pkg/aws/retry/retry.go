@@ -0,0 +1,147 @@
+// Package retry provides a policy for retrying AWS API calls that fail because
+// of throttling or transient server errors, with exponential backoff and
+// jitter.
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// DefaultPolicy is the Policy used when none is configured.
+var DefaultPolicy = &Policy{
+	MaxAttempts: 5,
+	BaseDelay:   200 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+	Timeout:     time.Minute,
+}
+
+// Policy determines how failed calls are retried.
+type Policy struct {
+	// The maximum number of times to call the function, including the
+	// first call. Values less than 1 mean a single attempt.
+	MaxAttempts int
+
+	// The delay before the first retry, which doubles after each retry up
+	// to MaxDelay. A random jitter of up to the delay is used, so that
+	// clients retrying at the same time don't retry in lockstep.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// Timeout is an overall deadline for the call, including retries. No
+	// retries are attempted after the deadline. The zero value means no
+	// timeout.
+	Timeout time.Duration
+
+	// Retryable reports whether an error should be retried. The zero
+	// value is IsRetryable.
+	Retryable func(error) bool
+}
+
+// Do calls fn until it succeeds, returns an error that isn't retryable, or the
+// attempts run out. The error from the last attempt is returned. Retries stop
+// early if ctx is done or the next attempt would start after its deadline, and
+// fn isn't called at all if ctx is already done, in which case ctx's error is
+// returned.
+func (p *Policy) Do(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if p.Timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = fn(); err == nil || !retryable(err) || attempt+1 >= p.MaxAttempts {
+			return err
+		}
+
+		delay := p.delay(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return err
+		}
+
+		if delay > 0 {
+			t := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return err
+			case <-t.C:
+			}
+		}
+	}
+}
+
+// delay returns how long to wait before the retry that follows attempt, which
+// starts at 0. It uses "full jitter", picking a random delay between 0 and the
+// exponential backoff.
+func (p *Policy) delay(attempt int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+
+	backoff := p.BaseDelay << uint(attempt)
+	if backoff <= 0 || (p.MaxDelay > 0 && backoff > p.MaxDelay) {
+		backoff = p.MaxDelay
+	}
+
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
+
+// throttlingCodes are the AWS error codes that mean a request was throttled.
+var throttlingCodes = map[string]bool{
+	"Throttling":                             true,
+	"ThrottlingException":                    true,
+	"ThrottledException":                     true,
+	"RequestThrottled":                       true,
+	"RequestThrottledException":              true,
+	"RequestLimitExceeded":                   true,
+	"TooManyRequestsException":               true,
+	"ProvisionedThroughputExceededException": true,
+	"SlowDown":                               true,
+}
+
+// transientCodes are the AWS error codes that mean a request failed because
+// of a transient problem on the server.
+var transientCodes = map[string]bool{
+	"ServerException":         true, // ECS's 5xx error
+	"InternalFailure":         true,
+	"InternalError":           true,
+	"ServiceUnavailable":      true,
+	"RequestTimeout":          true,
+	"RequestTimeoutException": true,
+}
+
+// IsRetryable reports whether err is an AWS error that's worth retrying:
+// throttling, transient server errors, and any response with a 429 or 5xx
+// status code. Everything else, like validation errors, is terminal.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if err, ok := err.(awserr.RequestFailure); ok {
+		if err.StatusCode() == 429 || err.StatusCode() >= 500 {
+			return true
+		}
+	}
+
+	if err, ok := err.(awserr.Error); ok {
+		return throttlingCodes[err.Code()] || transientCodes[err.Code()]
+	}
+
+	return false
+}
@@ -0,0 +1,138 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+var (
+	errThrottled = awserr.New("ThrottlingException", "Rate exceeded", nil)
+	errServer    = awserr.NewRequestFailure(awserr.New("ServerException", "Service Unavailable", nil), 503, "")
+	errInvalid   = awserr.NewRequestFailure(awserr.New("InvalidParameterException", "Invalid", nil), 400, "")
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err       error
+		retryable bool
+	}{
+		{nil, false},
+		{errors.New("boom"), false},
+		{errThrottled, true},
+		{errServer, true},
+		{errInvalid, false},
+		{awserr.New("ClientException", "Service not found", nil), false},
+		{awserr.New("RequestLimitExceeded", "Request limit exceeded", nil), true},
+		{awserr.NewRequestFailure(awserr.New("Unknown", "", nil), 429, ""), true},
+		{awserr.NewRequestFailure(awserr.New("Unknown", "", nil), 502, ""), true},
+	}
+
+	for i, tt := range tests {
+		if got, want := IsRetryable(tt.err), tt.retryable; got != want {
+			t.Errorf("#%d: IsRetryable(%v) => %v; want %v", i, tt.err, got, want)
+		}
+	}
+}
+
+func TestPolicy_Do(t *testing.T) {
+	tests := []struct {
+		policy Policy
+		errs   []error
+		calls  int
+		err    error
+	}{
+		// Succeeds the first time.
+		{Policy{MaxAttempts: 3}, []error{nil}, 1, nil},
+
+		// Retries throttling and server errors.
+		{Policy{MaxAttempts: 3}, []error{errThrottled, errServer, nil}, 3, nil},
+
+		// Gives up after MaxAttempts.
+		{Policy{MaxAttempts: 2}, []error{errThrottled, errThrottled, nil}, 2, errThrottled},
+
+		// Terminal errors aren't retried.
+		{Policy{MaxAttempts: 3}, []error{errInvalid, nil}, 1, errInvalid},
+
+		// A single attempt by default.
+		{Policy{}, []error{errThrottled, nil}, 1, errThrottled},
+
+		// A custom classifier.
+		{Policy{MaxAttempts: 3, Retryable: func(error) bool { return true }}, []error{errInvalid, nil}, 2, nil},
+
+		// Nothing is retried after the deadline.
+		{Policy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour, Timeout: time.Millisecond}, []error{errThrottled, nil}, 1, errThrottled},
+	}
+
+	for i, tt := range tests {
+		calls := 0
+		err := tt.policy.Do(context.Background(), func() error {
+			err := tt.errs[calls]
+			calls++
+			return err
+		})
+
+		if got, want := err, tt.err; got != want {
+			t.Errorf("#%d: err => %v; want %v", i, got, want)
+		}
+
+		if got, want := calls, tt.calls; got != want {
+			t.Errorf("#%d: calls => %d; want %d", i, got, want)
+		}
+	}
+}
+
+func TestPolicy_Do_Canceled(t *testing.T) {
+	p := &Policy{MaxAttempts: 3, BaseDelay: time.Hour}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := p.Do(ctx, func() error {
+		calls++
+		cancel()
+		return errThrottled
+	})
+
+	if err != errThrottled {
+		t.Errorf("err => %v; want %v", err, errThrottled)
+	}
+	if calls != 1 {
+		t.Errorf("calls => %d; want 1", calls)
+	}
+
+	// Nothing is called once the context is done.
+	calls = 0
+	err = p.Do(ctx, func() error {
+		calls++
+		return nil
+	})
+
+	if err != context.Canceled {
+		t.Errorf("err => %v; want %v", err, context.Canceled)
+	}
+	if calls != 0 {
+		t.Errorf("calls => %d; want 0", calls)
+	}
+}
+
+func TestPolicy_delay(t *testing.T) {
+	p := &Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for attempt, max := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		for i := 0; i < 10; i++ {
+			if d := p.delay(attempt); d < 0 || d > max {
+				t.Errorf("delay(%d) => %v; want between 0 and %v", attempt, d, max)
+			}
+		}
+	}
+}
@@ -1,7 +1,21 @@
 // package bytesize contains constants for easily switching between different
-// byte sizes.
+// byte sizes, and a ByteSize type for parsing and formatting human readable
+// sizes like "512MiB".
 package bytesize
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Binary multiples, kept as uint constants for compatibility. New code should
+// use the ByteSize units below.
 const (
 	_       = iota // ignore first value by assigning to blank identifier
 	KB uint = 1 << (10 * iota)
@@ -10,3 +24,209 @@ const (
 	TB
 	PB
 )
+
+// ErrInvalidByteSize may be returned when parsing a string that is not a valid
+// byte size.
+var ErrInvalidByteSize = errors.New("invalid byte size")
+
+// ByteSize represents a number of bytes.
+type ByteSize uint64
+
+// IEC units, which are powers of 1024.
+const (
+	Byte ByteSize = 1
+	KiB           = 1024 * Byte
+	MiB           = 1024 * KiB
+	GiB           = 1024 * MiB
+	TiB           = 1024 * GiB
+	PiB           = 1024 * TiB
+)
+
+// SI units, which are powers of 1000.
+const (
+	Kilobyte = 1000 * Byte
+	Megabyte = 1000 * Kilobyte
+	Gigabyte = 1000 * Megabyte
+	Terabyte = 1000 * Gigabyte
+	Petabyte = 1000 * Terabyte
+)
+
+// units maps the lower cased unit suffixes that Parse accepts to their size.
+// Single letter suffixes follow the Docker convention of being binary
+// multiples.
+var units = map[string]ByteSize{
+	"":    Byte,
+	"b":   Byte,
+	"k":   KiB,
+	"m":   MiB,
+	"g":   GiB,
+	"t":   TiB,
+	"p":   PiB,
+	"kib": KiB,
+	"mib": MiB,
+	"gib": GiB,
+	"tib": TiB,
+	"pib": PiB,
+	"kb":  Kilobyte,
+	"mb":  Megabyte,
+	"gb":  Gigabyte,
+	"tb":  Terabyte,
+	"pb":  Petabyte,
+}
+
+// Parse parses a human readable byte size, such as "512MB", "1.5GiB" or "256m".
+// Both SI units (kB, MB, GB, ...) which are powers of 1000, and IEC units
+// (KiB, MiB, GiB, ...) which are powers of 1024, are supported. Units are case
+// insensitive, and a number without a unit is a number of bytes.
+func Parse(s string) (ByteSize, error) {
+	s = strings.TrimSpace(s)
+
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+
+	num, suffix := s[:i], strings.ToLower(strings.TrimSpace(s[i:]))
+
+	unit, ok := units[suffix]
+	if !ok || num == "" {
+		return 0, ErrInvalidByteSize
+	}
+
+	// Use exact arithmetic, so that sizes like "1.5GiB" don't suffer from
+	// floating point rounding.
+	r, ok := new(big.Rat).SetString(num)
+	if !ok {
+		return 0, ErrInvalidByteSize
+	}
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).SetUint64(uint64(unit))))
+
+	if !r.IsInt() || !r.Num().IsUint64() {
+		return 0, ErrInvalidByteSize
+	}
+
+	return ByteSize(r.Num().Uint64()), nil
+}
+
+// String returns the size in the largest IEC unit that can represent it exactly
+// with at most two decimal places, falling back to bytes. For example,
+// 1.5GiB is formatted as "1.5GiB" and 1000 as "1000B".
+func (b ByteSize) String() string {
+	for _, u := range []struct {
+		size ByteSize
+		name string
+	}{
+		{PiB, "PiB"},
+		{TiB, "TiB"},
+		{GiB, "GiB"},
+		{MiB, "MiB"},
+		{KiB, "KiB"},
+	} {
+		if b < u.size || b > math.MaxUint64/100 || (b*100)%u.size != 0 {
+			continue
+		}
+
+		hundredths := uint64(b * 100 / u.size)
+		v := strconv.FormatUint(hundredths/100, 10)
+		if frac := hundredths % 100; frac != 0 {
+			v += strings.TrimRight(fmt.Sprintf(".%02d", frac), "0")
+		}
+		return v + u.name
+	}
+
+	return strconv.FormatUint(uint64(b), 10) + "B"
+}
+
+// Set implements the flag.Value interface.
+func (b *ByteSize) Set(s string) error {
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*b = v
+	return nil
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (b ByteSize) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	return b.Set(string(text))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. Both strings like
+// "512MiB" and plain numbers of bytes are accepted.
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	var n uint64
+	if err := json.Unmarshal(data, &n); err == nil {
+		*b = ByteSize(n)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return ErrInvalidByteSize
+	}
+	return b.Set(s)
+}
+
+// MarshalYAML implements the yaml.Marshaler interface.
+func (b ByteSize) MarshalYAML() (interface{}, error) {
+	return b.String(), nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface. Both strings like
+// "512MiB" and plain numbers of bytes are accepted.
+func (b *ByteSize) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var n uint64
+	if err := unmarshal(&n); err == nil {
+		*b = ByteSize(n)
+		return nil
+	}
+
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return ErrInvalidByteSize
+	}
+	return b.Set(s)
+}
+
+// Rounding is a policy for rounding when converting a ByteSize into a whole
+// number of a larger unit.
+type Rounding int
+
+const (
+	// RoundUp rounds up to the next whole unit, so that the result is never
+	// smaller than the original size.
+	RoundUp Rounding = iota
+
+	// RoundDown truncates to the whole unit below.
+	RoundDown
+
+	// RoundNearest rounds to the nearest whole unit, rounding halves up.
+	RoundNearest
+)
+
+// In returns the size as a whole number of unit, using r to round any
+// remainder.
+func (b ByteSize) In(unit ByteSize, r Rounding) uint64 {
+	n, rem := uint64(b/unit), b%unit
+	if rem == 0 {
+		return n
+	}
+
+	switch r {
+	case RoundUp:
+		return n + 1
+	case RoundNearest:
+		if rem >= unit-rem {
+			return n + 1
+		}
+	}
+	return n
+}
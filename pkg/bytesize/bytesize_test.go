@@ -1,6 +1,10 @@
 package bytesize
 
-import "testing"
+import (
+	"encoding/json"
+	"flag"
+	"testing"
+)
 
 func TestSizes(t *testing.T) {
 	tests := map[uint]uint{
@@ -17,3 +21,134 @@ func TestSizes(t *testing.T) {
 		}
 	}
 }
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in  string
+		out ByteSize
+		err error
+	}{
+		{"0", 0, nil},
+		{"1024", KiB, nil},
+		{"512MB", 512 * Megabyte, nil},
+		{"512mb", 512 * Megabyte, nil},
+		{"512 MiB", 512 * MiB, nil},
+		{"1.5GiB", 1536 * MiB, nil},
+		{"1.5gb", 1500 * Megabyte, nil},
+		{"256m", 256 * MiB, nil},
+		{"2k", 2 * KiB, nil},
+		{"10kB", 10 * Kilobyte, nil},
+		{"0.5KiB", 512, nil},
+		{"1TiB", TiB, nil},
+		{"", 0, ErrInvalidByteSize},
+		{"MB", 0, ErrInvalidByteSize},
+		{"-1MB", 0, ErrInvalidByteSize},
+		{"1.5", 0, ErrInvalidByteSize},
+		{"1.2.3MB", 0, ErrInvalidByteSize},
+		{"10XB", 0, ErrInvalidByteSize},
+		{"20000PiB", 0, ErrInvalidByteSize},
+	}
+
+	for i, tt := range tests {
+		out, err := Parse(tt.in)
+		if err != tt.err {
+			t.Fatalf("#%d: Parse(%q): err => %v; want %v", i, tt.in, err, tt.err)
+		}
+
+		if got, want := out, tt.out; got != want {
+			t.Errorf("#%d: Parse(%q) => %d; want %d", i, tt.in, got, want)
+		}
+	}
+}
+
+func TestByteSize_String(t *testing.T) {
+	tests := []struct {
+		in  ByteSize
+		out string
+	}{
+		{0, "0B"},
+		{1000, "1000B"},
+		{KiB, "1KiB"},
+		{1536 * MiB, "1.5GiB"},
+		{1280 * KiB, "1.25MiB"},
+		{512 * Megabyte, "500000KiB"},
+		{1234567, "1234567B"},
+		{3 * PiB, "3PiB"},
+	}
+
+	for i, tt := range tests {
+		if got, want := tt.in.String(), tt.out; got != want {
+			t.Errorf("#%d: String() => %s; want %s", i, got, want)
+		}
+
+		// Formatted sizes should always parse back to the same value.
+		if v, err := Parse(tt.out); err != nil || v != tt.in {
+			t.Errorf("#%d: Parse(%q) => %d, %v; want %d", i, tt.out, v, err, tt.in)
+		}
+	}
+}
+
+func TestByteSize_JSON(t *testing.T) {
+	var v struct {
+		Memory ByteSize
+	}
+
+	for _, in := range []string{`{"Memory":"1.5GiB"}`, `{"Memory":1610612736}`} {
+		if err := json.Unmarshal([]byte(in), &v); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", in, err)
+		}
+
+		if got, want := v.Memory, 1536*MiB; got != want {
+			t.Errorf("Unmarshal(%s) => %d; want %d", in, got, want)
+		}
+	}
+
+	out, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := string(out), `{"Memory":"1.5GiB"}`; got != want {
+		t.Errorf("Marshal => %s; want %s", got, want)
+	}
+
+	if err := json.Unmarshal([]byte(`{"Memory":true}`), &v); err != ErrInvalidByteSize {
+		t.Errorf("Unmarshal: err => %v; want %v", err, ErrInvalidByteSize)
+	}
+}
+
+func TestByteSize_Flag(t *testing.T) {
+	var b ByteSize
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(&b, "memory", "")
+	if err := fs.Parse([]string{"-memory", "256m"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := b, 256*MiB; got != want {
+		t.Errorf("-memory => %d; want %d", got, want)
+	}
+}
+
+func TestByteSize_In(t *testing.T) {
+	tests := []struct {
+		in       ByteSize
+		unit     ByteSize
+		rounding Rounding
+		out      uint64
+	}{
+		{MiB, MiB, RoundUp, 1},
+		{MiB + 1, MiB, RoundUp, 2},
+		{MiB + 1, MiB, RoundDown, 1},
+		{MiB + 1, MiB, RoundNearest, 1},
+		{MiB + MiB/2, MiB, RoundNearest, 2},
+		{MiB - 1, MiB, RoundDown, 0},
+	}
+
+	for i, tt := range tests {
+		if got, want := tt.in.In(tt.unit, tt.rounding), tt.out; got != want {
+			t.Errorf("#%d: In() => %d; want %d", i, got, want)
+		}
+	}
+}
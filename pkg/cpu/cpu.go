@@ -0,0 +1,142 @@
+// package cpu contains a CPU type for representing an amount of CPU in a way
+// that's portable between schedulers.
+package cpu
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidCPU may be returned when parsing a string that is not a valid
+// amount of CPU.
+var ErrInvalidCPU = errors.New("invalid CPU")
+
+// CPU represents an amount of CPU, in millicores (thousandths of a vCPU).
+type CPU int64
+
+const (
+	MilliCPU CPU = 1
+	VCPU         = 1000 * MilliCPU
+)
+
+// SharesPerCPU is the number of ECS CPU units, or Docker CPU shares, that make
+// up a single vCPU.
+const SharesPerCPU = 1024
+
+// Parse parses an amount of CPU, either as a fractional number of vCPUs (e.g.
+// "0.25" or "2") or as a number of millicores (e.g. "250m").
+func Parse(s string) (CPU, error) {
+	s = strings.TrimSpace(s)
+
+	unit := VCPU
+	if strings.HasSuffix(s, "m") {
+		s, unit = strings.TrimSuffix(s, "m"), MilliCPU
+	}
+
+	if s == "" || strings.HasPrefix(s, "-") {
+		return 0, ErrInvalidCPU
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, ErrInvalidCPU
+	}
+	r.Mul(r, big.NewRat(int64(unit), 1))
+
+	if !r.IsInt() || !r.Num().IsInt64() {
+		return 0, ErrInvalidCPU
+	}
+
+	return CPU(r.Num().Int64()), nil
+}
+
+// FromShares returns the amount of CPU for a number of ECS CPU units or Docker
+// CPU shares, rounding up to the nearest millicore.
+func FromShares(shares int64) CPU {
+	return CPU(ceilDiv(shares*int64(VCPU), SharesPerCPU))
+}
+
+// String returns the amount of CPU as a whole number of vCPUs if possible,
+// otherwise as millicores, such as "2" or "250m".
+func (c CPU) String() string {
+	if c%VCPU == 0 {
+		return strconv.FormatInt(int64(c/VCPU), 10)
+	}
+	return strconv.FormatInt(int64(c), 10) + "m"
+}
+
+// MilliCores returns the amount of CPU in millicores, as used by Kubernetes.
+func (c CPU) MilliCores() int64 {
+	return int64(c)
+}
+
+// NanoCPUs returns the amount of CPU in billionths of a vCPU, as used by
+// Docker's NanoCPUs limit.
+func (c CPU) NanoCPUs() int64 {
+	return int64(c) * 1000000
+}
+
+// Shares returns the amount of CPU in ECS CPU units or Docker CPU shares, where
+// a vCPU is SharesPerCPU. Amounts that aren't a whole number of units are
+// rounded up, so that the result is never less than the original amount.
+func (c CPU) Shares() int64 {
+	return ceilDiv(int64(c)*SharesPerCPU, int64(VCPU))
+}
+
+// Set implements the flag.Value interface.
+func (c *CPU) Set(s string) error {
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*c = v
+	return nil
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (c CPU) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (c *CPU) UnmarshalText(text []byte) error {
+	return c.Set(string(text))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. Both strings like
+// "250m" and numbers of vCPUs like 0.25 are accepted.
+func (c *CPU) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return c.Set(s)
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return ErrInvalidCPU
+	}
+	return c.Set(n.String())
+}
+
+// MarshalYAML implements the yaml.Marshaler interface.
+func (c CPU) MarshalYAML() (interface{}, error) {
+	return c.String(), nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface. Both strings like
+// "250m" and numbers of vCPUs like 0.25 are accepted.
+func (c *CPU) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return ErrInvalidCPU
+	}
+	return c.Set(s)
+}
+
+// ceilDiv divides a by b, rounding up.
+func ceilDiv(a, b int64) int64 {
+	return (a + b - 1) / b
+}
@@ -0,0 +1,110 @@
+package cpu
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in  string
+		out CPU
+		err error
+	}{
+		{"1", VCPU, nil},
+		{"0.25", 250 * MilliCPU, nil},
+		{"1.5", 1500 * MilliCPU, nil},
+		{"250m", 250 * MilliCPU, nil},
+		{"0", 0, nil},
+		{"", 0, ErrInvalidCPU},
+		{"m", 0, ErrInvalidCPU},
+		{"-1", 0, ErrInvalidCPU},
+		{"0.0001", 0, ErrInvalidCPU},
+		{"1.5m", 0, ErrInvalidCPU},
+		{"two", 0, ErrInvalidCPU},
+	}
+
+	for i, tt := range tests {
+		out, err := Parse(tt.in)
+		if err != tt.err {
+			t.Fatalf("#%d: Parse(%q): err => %v; want %v", i, tt.in, err, tt.err)
+		}
+
+		if got, want := out, tt.out; got != want {
+			t.Errorf("#%d: Parse(%q) => %d; want %d", i, tt.in, got, want)
+		}
+	}
+}
+
+func TestCPU_Conversions(t *testing.T) {
+	tests := []struct {
+		in         CPU
+		str        string
+		milliCores int64
+		nanoCPUs   int64
+		shares     int64
+	}{
+		{VCPU, "1", 1000, 1000000000, 1024},
+		{250 * MilliCPU, "250m", 250, 250000000, 256},
+		{100 * MilliCPU, "100m", 100, 100000000, 103},
+		{2500 * MilliCPU, "2500m", 2500, 2500000000, 2560},
+	}
+
+	for i, tt := range tests {
+		if got, want := tt.in.String(), tt.str; got != want {
+			t.Errorf("#%d: String() => %s; want %s", i, got, want)
+		}
+
+		if got, want := tt.in.MilliCores(), tt.milliCores; got != want {
+			t.Errorf("#%d: MilliCores() => %d; want %d", i, got, want)
+		}
+
+		if got, want := tt.in.NanoCPUs(), tt.nanoCPUs; got != want {
+			t.Errorf("#%d: NanoCPUs() => %d; want %d", i, got, want)
+		}
+
+		if got, want := tt.in.Shares(), tt.shares; got != want {
+			t.Errorf("#%d: Shares() => %d; want %d", i, got, want)
+		}
+	}
+}
+
+func TestFromShares(t *testing.T) {
+	tests := map[int64]CPU{
+		1024: VCPU,
+		256:  250 * MilliCPU,
+		100:  98 * MilliCPU,
+		0:    0,
+	}
+
+	for in, want := range tests {
+		if got := FromShares(in); got != want {
+			t.Errorf("FromShares(%d) => %d; want %d", in, got, want)
+		}
+	}
+}
+
+func TestCPU_JSON(t *testing.T) {
+	var v struct {
+		CPU CPU
+	}
+
+	for _, in := range []string{`{"CPU":"250m"}`, `{"CPU":0.25}`, `{"CPU":"0.25"}`} {
+		if err := json.Unmarshal([]byte(in), &v); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", in, err)
+		}
+
+		if got, want := v.CPU, 250*MilliCPU; got != want {
+			t.Errorf("Unmarshal(%s) => %d; want %d", in, got, want)
+		}
+	}
+
+	out, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := string(out), `{"CPU":"250m"}`; got != want {
+		t.Errorf("Marshal => %s; want %s", got, want)
+	}
+}
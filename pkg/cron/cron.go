@@ -0,0 +1,254 @@
+// package cron is a Go package for parsing cron expressions and computing the
+// times that they fire at.
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSchedule may be returned when parsing a string that is not a valid
+// schedule expression.
+var ErrInvalidSchedule = errors.New("invalid schedule expression")
+
+// descriptors maps the predefined schedules to their cron expression.
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// field describes the bounds of a single field in a cron expression.
+type field struct {
+	name     string
+	min, max uint
+
+	// last is the largest value that can be given explicitly, when it's
+	// larger than max. It's used for day of week, where 7 is also Sunday.
+	last uint
+}
+
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 6, last: 7},
+}
+
+// sunday is the bit for Sunday when it's given as 7 in the day of week field.
+const sunday = 1 << 7
+
+// Schedule represents a parsed schedule expression. A Schedule either fires on
+// a fixed interval (Every), or when the current time matches all of the cron
+// fields.
+type Schedule struct {
+	// The raw fields of the cron expression, in the order minute, hour,
+	// day of month, month and day of week. Empty for interval schedules.
+	Fields []string
+
+	// For "@every" schedules, the interval between runs.
+	Every time.Duration
+
+	// bitsets of the values that match each field.
+	minute, hour, dom, month, dow uint64
+}
+
+// Parse parses a schedule expression. The expression can either be a standard
+// 5 field cron expression (e.g. "*/15 9-17 * * 1-5"), one of the predefined
+// schedules such as "@daily", or "@every <duration>".
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+
+	if strings.HasPrefix(expr, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil || d < time.Second {
+			return nil, ErrInvalidSchedule
+		}
+		return &Schedule{Every: d}, nil
+	}
+
+	if e, ok := descriptors[expr]; ok {
+		expr = e
+	}
+
+	p := strings.Fields(expr)
+	if len(p) != len(fields) {
+		return nil, ErrInvalidSchedule
+	}
+
+	s := &Schedule{Fields: p}
+	for i, f := range []*uint64{&s.minute, &s.hour, &s.dom, &s.month, &s.dow} {
+		bits, err := parseField(p[i], fields[i])
+		if err != nil {
+			return nil, err
+		}
+		*f = bits
+	}
+
+	if s.dow&sunday != 0 {
+		s.dow = s.dow&^sunday | 1
+	}
+
+	return s, nil
+}
+
+// parseField parses a comma separated list of values, ranges and steps into a
+// bitset.
+func parseField(s string, f field) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		step := uint(1)
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.ParseUint(part[i+1:], 10, 8)
+			if err != nil || n == 0 {
+				return 0, fmt.Errorf("invalid step in %s field: %q", f.name, s)
+			}
+			step, part = uint(n), part[:i]
+		}
+
+		min, max := f.min, f.max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			r := strings.SplitN(part, "-", 2)
+			lo, err := parseValue(r[0], f)
+			if err != nil {
+				return 0, err
+			}
+			hi, err := parseValue(r[1], f)
+			if err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range in %s field: %q", f.name, s)
+			}
+			min, max = lo, hi
+		default:
+			v, err := parseValue(part, f)
+			if err != nil {
+				return 0, err
+			}
+			min = v
+			if step == 1 {
+				max = v
+			}
+		}
+
+		for v := min; v <= max; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(s string, f field) (uint, error) {
+	last := f.max
+	if f.last > last {
+		last = f.last
+	}
+
+	v, err := strconv.ParseUint(s, 10, 8)
+	if err != nil || uint(v) < f.min || uint(v) > last {
+		return 0, fmt.Errorf("invalid value in %s field: %q", f.name, s)
+	}
+	return uint(v), nil
+}
+
+// Next returns the next time after t that the schedule fires. Cron schedules
+// are evaluated in the location of t, with minute granularity.
+func (s *Schedule) Next(t time.Time) time.Time {
+	if s.Every > 0 {
+		return t.Add(s.Every)
+	}
+
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	// If nothing matches within 5 years, the expression can never fire
+	// (e.g. "0 0 31 2 *").
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !has(s.month, uint(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !has(s.hour, uint(t.Hour())) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !has(s.minute, uint(t.Minute())) {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// Prev returns the latest time at or before t that the schedule fires, with the
+// same granularity as Next. Interval schedules don't fire at fixed times, so
+// the zero Time is returned for them.
+func (s *Schedule) Prev(t time.Time) time.Time {
+	if s.Every > 0 {
+		return time.Time{}
+	}
+
+	t = t.Truncate(time.Minute)
+
+	limit := t.AddDate(-5, 0, 0)
+	for t.After(limit) {
+		if !has(s.month, uint(t.Month())) {
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()).Add(-time.Minute)
+			continue
+		}
+
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Add(-time.Minute)
+			continue
+		}
+
+		if !has(s.hour, uint(t.Hour())) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location()).Add(-time.Minute)
+			continue
+		}
+
+		if !has(s.minute, uint(t.Minute())) {
+			t = t.Add(-time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// matchDay follows the cron convention that, when both the day of month and day
+// of week fields are restricted, a day matches if either field matches.
+func (s *Schedule) matchDay(t time.Time) bool {
+	dom, dow := has(s.dom, uint(t.Day())), has(s.dow, uint(t.Weekday()))
+	if s.Fields[2] == "*" || s.Fields[4] == "*" {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func has(bits uint64, v uint) bool {
+	return bits&(1<<v) != 0
+}
@@ -0,0 +1,100 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"@every",
+		"@every 1ms",
+		"@fortnightly",
+	}
+
+	for i, tt := range tests {
+		if _, err := Parse(tt); err == nil {
+			t.Errorf("#%d: Parse(%q): expected an error", i, tt)
+		}
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	// A Wednesday.
+	now := time.Date(2015, time.October, 14, 10, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2015, time.October, 14, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2015, time.October, 14, 10, 45, 0, 0, time.UTC)},
+		{"0 12 * * *", time.Date(2015, time.October, 14, 12, 0, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2015, time.October, 15, 9, 0, 0, 0, time.UTC)},
+		{"0 9-17/4 * * 1-5", time.Date(2015, time.October, 14, 13, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2015, time.October, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2015, time.October, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 1", time.Date(2015, time.October, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2015, time.October, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 5-7", time.Date(2015, time.October, 16, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2015, time.November, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2016, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+		{"@every 90s", time.Date(2015, time.October, 14, 10, 31, 45, 0, time.UTC)},
+	}
+
+	for i, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("#%d: Parse(%q): %v", i, tt.expr, err)
+		}
+
+		if got, want := s.Next(now), tt.next; !got.Equal(want) {
+			t.Errorf("#%d: Next(%q) => %v; want %v", i, tt.expr, got, want)
+		}
+	}
+}
+
+func TestSchedule_Prev(t *testing.T) {
+	// A Wednesday.
+	now := time.Date(2015, time.October, 14, 10, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		prev time.Time
+	}{
+		{"* * * * *", time.Date(2015, time.October, 14, 10, 30, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2015, time.October, 14, 10, 30, 0, 0, time.UTC)},
+		{"*/20 * * * *", time.Date(2015, time.October, 14, 10, 20, 0, 0, time.UTC)},
+		{"0 12 * * *", time.Date(2015, time.October, 13, 12, 0, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2015, time.October, 14, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2015, time.October, 11, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2015, time.October, 1, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2015, time.October, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2012, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+		{"@every 90s", time.Time{}},
+	}
+
+	for i, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("#%d: Parse(%q): %v", i, tt.expr, err)
+		}
+
+		if got, want := s.Prev(now), tt.prev; !got.Equal(want) {
+			t.Errorf("#%d: Prev(%q) => %v; want %v", i, tt.expr, got, want)
+		}
+	}
+}
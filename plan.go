@@ -0,0 +1,162 @@
+package twelvefactor
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// Planner is an optional interface for previewing the changes that Run would
+// make, without making them.
+type Planner interface {
+	// Plan returns the changes that calling Run with the same arguments
+	// would make.
+	Plan(app App, processes ...Process) (*Plan, error)
+}
+
+// ChangeAction is the kind of change that's made to a process or environment
+// variable.
+type ChangeAction string
+
+// The kinds of changes.
+const (
+	ChangeCreate ChangeAction = "create"
+	ChangeUpdate ChangeAction = "update"
+	ChangeDelete ChangeAction = "delete"
+)
+
+// Plan describes the changes that Run would make to an app.
+type Plan struct {
+	// The app that the plan is for.
+	App string `json:"app"`
+
+	// The processes that would change, in the order they were given.
+	// Processes that wouldn't change are left out.
+	Changes []ProcessChange `json:"changes"`
+}
+
+// ProcessChange describes the changes to a single process.
+type ProcessChange struct {
+	// The name of the process.
+	Process string `json:"process"`
+
+	// Whether the process would be created, updated or deleted.
+	Action ChangeAction `json:"action"`
+
+	// Where the change would be made, such as a region or cluster, for
+	// schedulers that run apps in more than one place.
+	Location string `json:"location,omitempty"`
+
+	// The scheduler specific fields that would change, such as the image
+	// or memory.
+	Fields []FieldChange `json:"fields,omitempty"`
+
+	// The environment variables that would be added, changed or removed.
+	Env []EnvChange `json:"env,omitempty"`
+
+	// The change to the desired count, if any.
+	Scale *ScaleChange `json:"scale,omitempty"`
+}
+
+// FieldChange describes a change to a single field. Old is empty for fields
+// that are being set for the first time, and New is empty for fields that are
+// being unset.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+// EnvChange describes a change to an environment variable. Values are left
+// out, since they often contain secrets.
+type EnvChange struct {
+	Name   string       `json:"name"`
+	Action ChangeAction `json:"action"`
+}
+
+// ScaleChange describes a change to the desired count of a process.
+type ScaleChange struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// Empty reports whether the plan has no changes.
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// String returns the plan as human readable text, like:
+//
+//	~ web (update)
+//	    image: acme-inc:v1 -> acme-inc:v2
+//	    env: + NEW_RELIC_KEY
+//	    scale: 1 -> 2
+//	+ worker (create)
+//	    image: acme-inc:v2
+//	~ worker (update in us-west-2)
+//	    scale: 2 -> 1
+func (p *Plan) String() string {
+	if p.Empty() {
+		return fmt.Sprintf("No changes to %s.\n", p.App)
+	}
+
+	var buf bytes.Buffer
+	for _, c := range p.Changes {
+		action := string(c.Action)
+		if c.Location != "" {
+			action += " in " + c.Location
+		}
+		fmt.Fprintf(&buf, "%s %s (%s)\n", symbol(c.Action), c.Process, action)
+		for _, f := range c.Fields {
+			switch {
+			case f.Old == "":
+				fmt.Fprintf(&buf, "    %s: %s\n", f.Field, f.New)
+			case f.New == "":
+				fmt.Fprintf(&buf, "    %s: %s -> (none)\n", f.Field, f.Old)
+			default:
+				fmt.Fprintf(&buf, "    %s: %s -> %s\n", f.Field, f.Old, f.New)
+			}
+		}
+		for _, e := range c.Env {
+			fmt.Fprintf(&buf, "    env: %s %s\n", symbol(e.Action), e.Name)
+		}
+		if c.Scale != nil {
+			fmt.Fprintf(&buf, "    scale: %d -> %d\n", c.Scale.From, c.Scale.To)
+		}
+	}
+	return buf.String()
+}
+
+func symbol(action ChangeAction) string {
+	switch action {
+	case ChangeCreate:
+		return "+"
+	case ChangeDelete:
+		return "-"
+	default:
+		return "~"
+	}
+}
+
+// DiffEnv returns the changes needed to go from the old environment to the new
+// one, sorted by name.
+func DiffEnv(old, new map[string]string) []EnvChange {
+	var changes []EnvChange
+	for k, v := range new {
+		prev, ok := old[k]
+		switch {
+		case !ok:
+			changes = append(changes, EnvChange{Name: k, Action: ChangeCreate})
+		case prev != v:
+			changes = append(changes, EnvChange{Name: k, Action: ChangeUpdate})
+		}
+	}
+	for k := range old {
+		if _, ok := new[k]; !ok {
+			changes = append(changes, EnvChange{Name: k, Action: ChangeDelete})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
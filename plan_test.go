@@ -0,0 +1,71 @@
+package twelvefactor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlan_String(t *testing.T) {
+	plan := &Plan{
+		App: "acme",
+		Changes: []ProcessChange{
+			{
+				Process: "web",
+				Action:  ChangeUpdate,
+				Fields: []FieldChange{
+					{Field: "image", Old: "acme-inc:v1", New: "acme-inc:v2"},
+					{Field: "memory", Old: "512MiB"},
+				},
+				Env: []EnvChange{
+					{Name: "NEW_RELIC_KEY", Action: ChangeCreate},
+					{Name: "RAILS_ENV", Action: ChangeUpdate},
+					{Name: "SECRET", Action: ChangeDelete},
+				},
+				Scale: &ScaleChange{From: 1, To: 2},
+			},
+			{
+				Process: "worker",
+				Action:  ChangeCreate,
+				Fields: []FieldChange{
+					{Field: "image", New: "acme-inc:v2"},
+				},
+			},
+			{
+				Process:  "worker",
+				Action:   ChangeUpdate,
+				Location: "us-west-2",
+				Scale:    &ScaleChange{From: 2, To: 1},
+			},
+		},
+	}
+
+	assert.Equal(t, `~ web (update)
+    image: acme-inc:v1 -> acme-inc:v2
+    memory: 512MiB -> (none)
+    env: + NEW_RELIC_KEY
+    env: ~ RAILS_ENV
+    env: - SECRET
+    scale: 1 -> 2
++ worker (create)
+    image: acme-inc:v2
+~ worker (update in us-west-2)
+    scale: 2 -> 1
+`, plan.String())
+
+	assert.Equal(t, "No changes to acme.\n", (&Plan{App: "acme"}).String())
+}
+
+func TestDiffEnv(t *testing.T) {
+	changes := DiffEnv(
+		map[string]string{"A": "1", "B": "2", "C": "3"},
+		map[string]string{"A": "1", "B": "two", "D": "4"},
+	)
+	assert.Equal(t, []EnvChange{
+		{Name: "B", Action: ChangeUpdate},
+		{Name: "C", Action: ChangeDelete},
+		{Name: "D", Action: ChangeCreate},
+	}, changes)
+
+	assert.Nil(t, DiffEnv(map[string]string{"A": "1"}, map[string]string{"A": "1"}))
+}
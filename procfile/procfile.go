@@ -0,0 +1,94 @@
+// Package procfile provides methods for parsing the Procfile manifest format,
+// which is described at https://devcenter.heroku.com/articles/procfile.
+//
+// A Procfile declares one process per line, as a name followed by a colon and
+// the command to run:
+//
+//	web: bundle exec puma -C config/puma.rb
+//	worker: bundle exec sidekiq
+package procfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/remind101/12factor"
+)
+
+// Process is a single process declared in a Procfile.
+type Process struct {
+	// The name of the process, like "web".
+	Name string
+
+	// The command to run, as a shell command line.
+	Command string
+}
+
+// SyntaxError is returned when a line of a Procfile can't be parsed.
+type SyntaxError struct {
+	// The line number, starting from 1.
+	Line int
+
+	// What's wrong with the line.
+	Message string
+}
+
+// Error implements the error interface.
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("procfile: line %d: %s", e.Line, e.Message)
+}
+
+// line matches a single process declaration.
+var line = regexp.MustCompile(`^([a-zA-Z0-9_-]+):\s*(.+)$`)
+
+// Parse parses a Procfile, returning the processes in the order that they're
+// declared. Blank lines and lines starting with "#" are ignored.
+func Parse(r io.Reader) ([]Process, error) {
+	var (
+		processes []Process
+		seen      = make(map[string]bool)
+	)
+
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		m := line.FindStringSubmatch(text)
+		if m == nil {
+			return nil, &SyntaxError{Line: n, Message: fmt.Sprintf("expected \"<process>: <command>\", got %q", text)}
+		}
+
+		name, command := m[1], strings.TrimSpace(m[2])
+		if seen[name] {
+			return nil, &SyntaxError{Line: n, Message: fmt.Sprintf("%s process is declared more than once", name)}
+		}
+		seen[name] = true
+
+		processes = append(processes, Process{Name: name, Command: command})
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return processes, nil
+}
+
+// Processes converts the processes in a Procfile into twelvefactor Processes.
+// Procfile commands are shell command lines, so they're run with "sh -c".
+func Processes(processes []Process) []twelvefactor.Process {
+	var ps []twelvefactor.Process
+	for _, p := range processes {
+		ps = append(ps, twelvefactor.Process{
+			Name:    p.Name,
+			Command: []string{"sh", "-c", p.Command},
+		})
+	}
+	return ps
+}
@@ -0,0 +1,62 @@
+package procfile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/remind101/12factor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in  string
+		out []Process
+		err error
+	}{
+		{
+			"web: bundle exec puma -C config/puma.rb\nworker: bundle exec sidekiq\n",
+			[]Process{
+				{Name: "web", Command: "bundle exec puma -C config/puma.rb"},
+				{Name: "worker", Command: "bundle exec sidekiq"},
+			},
+			nil,
+		},
+		{
+			"# The web process\n\nweb:./bin/web --port=$PORT  \n",
+			[]Process{
+				{Name: "web", Command: "./bin/web --port=$PORT"},
+			},
+			nil,
+		},
+		{
+			"web: a\nweb: b\n",
+			nil,
+			&SyntaxError{Line: 2, Message: "web process is declared more than once"},
+		},
+		{
+			"web\n",
+			nil,
+			&SyntaxError{Line: 1, Message: `expected "<process>: <command>", got "web"`},
+		},
+		{
+			"web server: a\n",
+			nil,
+			&SyntaxError{Line: 1, Message: `expected "<process>: <command>", got "web server: a"`},
+		},
+	}
+
+	for i, tt := range tests {
+		out, err := Parse(strings.NewReader(tt.in))
+		assert.Equal(t, tt.err, err, "#%d", i)
+		assert.Equal(t, tt.out, out, "#%d", i)
+	}
+}
+
+func TestProcesses(t *testing.T) {
+	assert.Equal(t, []twelvefactor.Process{
+		{Name: "web", Command: []string{"sh", "-c", "./bin/web --port=$PORT"}},
+	}, Processes([]Process{
+		{Name: "web", Command: "./bin/web --port=$PORT"},
+	}))
+}
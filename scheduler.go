@@ -1,5 +1,10 @@
 package twelvefactor
 
+import (
+	"io"
+	"time"
+)
+
 // Runner is an interface that wraps the basic Run method, providing a way to
 // run a 12factor application.
 //
@@ -20,6 +25,57 @@ type ProcessRunner interface {
 	RunProcess(app string, process Process) error
 }
 
+// LogStreamer is an optional interface for retrieving the logs of an app, one of
+// its processes, or an individual task.
+type LogStreamer interface {
+	// StreamLogs writes the log lines matching opts to w. When
+	// opts.Follow is true, StreamLogs blocks and continues writing new log
+	// lines until writing to w fails or opts.Until is reached.
+	StreamLogs(w io.Writer, opts LogsOptions) error
+}
+
+// LogsOptions are the options that can be provided to StreamLogs.
+type LogsOptions struct {
+	// The app to retrieve logs for.
+	App string
+
+	// When provided, only logs from this process will be returned.
+	Process string
+
+	// When provided, only logs from this task will be returned.
+	Task string
+
+	// When provided, only log lines within this time range will be
+	// returned.
+	Since, Until time.Time
+
+	// Whether to continue streaming new log lines as they're written.
+	Follow bool
+}
+
+// Execer is an optional interface for running a command inside of an existing
+// task, which is useful for debugging.
+type Execer interface {
+	// Exec runs command within the task, attaching stdin and stdout. A
+	// pseudo terminal is allocated when tty is not nil. Exec blocks until
+	// the command exits and returns its exit code.
+	Exec(taskID string, command []string, stdin io.Reader, stdout io.Writer, tty *TTY) (int, error)
+}
+
+// TTY configures the pseudo terminal that's allocated for Exec.
+type TTY struct {
+	// The initial size of the terminal.
+	Size TerminalSize
+
+	// Resize receives the new size of the terminal whenever it changes.
+	Resize <-chan TerminalSize
+}
+
+// TerminalSize is the size of a terminal, in characters.
+type TerminalSize struct {
+	Width, Height int
+}
+
 // ProcessScaler is an interface that wraps the basic Scale method for scaling a
 // process by name for an application.
 type ProcessScaler interface {
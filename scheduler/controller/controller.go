@@ -0,0 +1,426 @@
+// Package controller continuously converges apps on their desired state,
+// instead of apps being run imperatively. A Controller watches a Source of
+// desired state, such as a directory of manifests or a state.Store, and calls
+// Run or Remove on the Scheduler whenever an app changes or goes away:
+//
+//	c := &controller.Controller{
+//		Scheduler: ecs.NewScheduler(config),
+//		Source:    &controller.Dir{Path: "/etc/12factor/apps"},
+//	}
+//	go c.Start(ctx)
+//
+// Each app is synced by at most one worker at a time, and apps that fail to
+// sync are retried with exponential backoff. Apps that haven't changed are
+// synced again every few polls, which corrects any changes that were made to
+// them outside of the Controller.
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/state"
+)
+
+// Defaults for the Controller.
+const (
+	DefaultPollInterval = 10 * time.Second
+	DefaultResyncPolls  = 6
+	DefaultWorkers      = 4
+	DefaultBaseDelay    = time.Second
+	DefaultMaxDelay     = 5 * time.Minute
+)
+
+// Source provides the desired state of apps.
+type Source interface {
+	// Desired returns the desired state of every app. Apps that aren't
+	// returned are removed, so an error should be returned rather than a
+	// partial list.
+	Desired() ([]*state.State, error)
+}
+
+// Phase is where an app is in being synced.
+type Phase string
+
+// The phases of an app.
+const (
+	// The app has changed and is waiting to be synced.
+	PhasePending Phase = "pending"
+
+	// The app was synced successfully.
+	PhaseSynced Phase = "synced"
+
+	// The last attempt to sync the app failed, and it will be retried.
+	PhaseFailed Phase = "failed"
+
+	// The app was removed from the source, and has been removed from the
+	// scheduler.
+	PhaseRemoved Phase = "removed"
+)
+
+// Status is the status of syncing an app.
+type Status struct {
+	App   string `json:"app"`
+	Phase Phase  `json:"phase"`
+
+	// When the app was last synced successfully.
+	SyncedAt time.Time `json:"synced_at,omitempty"`
+
+	// The error from the last attempt, and the number of attempts that
+	// have failed in a row.
+	Error    string `json:"error,omitempty"`
+	Failures int    `json:"failures,omitempty"`
+}
+
+// Controller syncs the apps from a Source to a Scheduler.
+//
+// Only apps that the Controller has run itself are removed when they go away,
+// so apps that are removed from the Source while the Controller isn't running
+// are left alone.
+type Controller struct {
+	// The Scheduler to run apps on.
+	Scheduler twelvefactor.Scheduler
+
+	// Source is where the desired state of apps comes from.
+	Source Source
+
+	// The time between reads of the Source. The zero value is
+	// DefaultPollInterval.
+	PollInterval time.Duration
+
+	// ResyncPolls is the number of polls between syncs of apps that
+	// haven't changed. Schedulers that implement twelvefactor.Planner only
+	// run the app again if it has drifted from its desired state. The zero
+	// value is DefaultResyncPolls, and a negative value disables resyncs.
+	ResyncPolls int
+
+	// The number of apps that can be synced at the same time. The zero
+	// value is DefaultWorkers.
+	Workers int
+
+	// MinInterval limits how often syncs can start, across all apps. The
+	// zero value means no limit.
+	MinInterval time.Duration
+
+	// The delay before an app that failed to sync is retried, which
+	// doubles after each failure up to MaxDelay. The zero values are
+	// DefaultBaseDelay and DefaultMaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// OnStatus, when provided, is called whenever the status of an app
+	// changes.
+	OnStatus func(Status)
+
+	// OnError, when provided, is called with errors reading the Source.
+	OnError func(error)
+
+	mu sync.Mutex
+
+	// The latest desired state of each app, and the state that was last
+	// run successfully.
+	desired, applied map[string]*state.State
+
+	statuses map[string]*Status
+	queue    *queue
+
+	// The number of times that the Source has been read.
+	polls int
+}
+
+// Start syncs apps until ctx is done, reading the Source immediately and then
+// every PollInterval. When ctx is done, apps that are waiting to be synced are
+// dropped, and Start returns ctx.Err() once the syncs that are in progress have
+// finished.
+func (c *Controller) Start(ctx context.Context) error {
+	c.mu.Lock()
+	c.init()
+	q := c.queue
+	c.mu.Unlock()
+
+	l := &limiter{interval: c.MinInterval}
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.work(ctx, q, l)
+		}()
+	}
+
+	ticker := time.NewTicker(c.pollInterval())
+	defer ticker.Stop()
+
+	for {
+		if err := c.Poll(); err != nil && c.OnError != nil {
+			c.OnError(err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			q.shutdown()
+			wg.Wait()
+			return ctx.Err()
+		}
+	}
+}
+
+// Poll reads the Source, and queues any apps that have been added, changed or
+// removed since it was last read, as well as every app that's already synced
+// once every ResyncPolls polls. It's called by Start, and only needs to be
+// called directly to pick up changes before the next poll.
+func (c *Controller) Poll() error {
+	states, err := c.Source.Desired()
+	if err != nil {
+		return err
+	}
+
+	desired := make(map[string]*state.State, len(states))
+	for _, s := range states {
+		desired[s.App.ID] = s
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+
+	var changed []string
+	for app, s := range desired {
+		if prev, ok := c.desired[app]; !ok || !equal(prev, s) {
+			c.desired[app] = s
+			changed = append(changed, app)
+		}
+	}
+	for app := range c.desired {
+		if _, ok := desired[app]; !ok {
+			delete(c.desired, app)
+			changed = append(changed, app)
+		}
+	}
+
+	sort.Strings(changed)
+	for _, app := range changed {
+		// Changes reset the backoff, so they're synced right away.
+		status := c.status(app)
+		status.Phase = PhasePending
+		status.Failures = 0
+		c.notify(status)
+		c.queue.add(app)
+	}
+
+	c.polls++
+	if n := c.resyncPolls(); n > 0 && c.polls%n == 0 {
+		for app, s := range c.desired {
+			if equal(s, c.applied[app]) {
+				c.queue.add(app)
+			}
+		}
+	}
+	return nil
+}
+
+// Status returns the status of the app, or false if the Controller doesn't
+// know about it.
+func (c *Controller) Status(app string) (Status, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.statuses[app]
+	if !ok {
+		return Status{}, false
+	}
+	return *s, true
+}
+
+// Statuses returns the status of every app, sorted by app.
+func (c *Controller) Statuses() []Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	statuses := make([]Status, 0, len(c.statuses))
+	for _, s := range c.statuses {
+		statuses = append(statuses, *s)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].App < statuses[j].App })
+	return statuses
+}
+
+// work syncs apps from the queue until it's shut down.
+func (c *Controller) work(ctx context.Context, q *queue, l *limiter) {
+	for {
+		app, ok := q.get()
+		if !ok {
+			return
+		}
+
+		if l.wait(ctx.Done()) {
+			c.sync(app, q)
+		}
+		q.done(app)
+	}
+}
+
+// sync runs or removes the app so that it matches its desired state.
+func (c *Controller) sync(app string, q *queue) {
+	c.mu.Lock()
+	desired, applied := c.desired[app], c.applied[app]
+	c.mu.Unlock()
+
+	var (
+		err error
+		ran = true
+	)
+	switch {
+	case desired == nil && applied == nil:
+		// Removed before it was ever run.
+	case desired == nil:
+		err = c.Scheduler.Remove(app)
+		if errors.Is(err, twelvefactor.ErrAppNotFound) {
+			err = nil
+		}
+	case equal(desired, applied):
+		ran, err = c.resync(desired)
+	default:
+		err = c.Scheduler.Run(desired.App, desired.Processes...)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The app may have changed while it was being synced, in which case
+	// it's already queued again.
+	if c.desired[app] != desired {
+		return
+	}
+
+	status := c.status(app)
+	switch {
+	case err != nil:
+		status.Phase = PhaseFailed
+		status.Error = err.Error()
+		status.Failures++
+		q.addAfter(app, c.backoff(status.Failures))
+	case desired == nil:
+		delete(c.applied, app)
+		delete(c.statuses, app)
+		status.Phase = PhaseRemoved
+		status.Error = ""
+		status.Failures = 0
+	case !ran && status.Phase == PhaseSynced:
+		// Nothing had drifted, so nothing changed.
+		return
+	default:
+		c.applied[app] = desired
+		status.Phase = PhaseSynced
+		status.SyncedAt = time.Now()
+		status.Error = ""
+		status.Failures = 0
+	}
+	c.notify(status)
+}
+
+// resync runs an app that hasn't changed again, if it has drifted from its
+// desired state, and reports whether it was run. Apps on schedulers that can't
+// plan are always run again.
+func (c *Controller) resync(s *state.State) (bool, error) {
+	if p, ok := c.Scheduler.(twelvefactor.Planner); ok && twelvefactor.Supports(c.Scheduler, twelvefactor.CapabilityPlan) {
+		plan, err := p.Plan(s.App, s.Processes...)
+		if err != nil {
+			return false, err
+		}
+		if plan.Empty() {
+			return false, nil
+		}
+	}
+	return true, c.Scheduler.Run(s.App, s.Processes...)
+}
+
+// status returns the status of the app, creating it if needed. c.mu must be
+// held.
+func (c *Controller) status(app string) *Status {
+	s, ok := c.statuses[app]
+	if !ok {
+		s = &Status{App: app, Phase: PhasePending}
+		c.statuses[app] = s
+	}
+	return s
+}
+
+// notify calls OnStatus with a copy of the status.
+func (c *Controller) notify(s *Status) {
+	if c.OnStatus != nil {
+		c.OnStatus(*s)
+	}
+}
+
+// backoff returns the delay before retrying an app that has failed to sync
+// failures times in a row.
+func (c *Controller) backoff(failures int) time.Duration {
+	base, max := c.BaseDelay, c.MaxDelay
+	if base == 0 {
+		base = DefaultBaseDelay
+	}
+	if max == 0 {
+		max = DefaultMaxDelay
+	}
+
+	d := base << uint(failures-1)
+	if d <= 0 || d > max {
+		d = max
+	}
+	return d
+}
+
+func (c *Controller) init() {
+	if c.queue == nil {
+		c.desired = make(map[string]*state.State)
+		c.applied = make(map[string]*state.State)
+		c.statuses = make(map[string]*Status)
+		c.queue = newQueue()
+	}
+}
+
+func (c *Controller) workers() int {
+	if c.Workers == 0 {
+		return DefaultWorkers
+	}
+	return c.Workers
+}
+
+func (c *Controller) resyncPolls() int {
+	if c.ResyncPolls == 0 {
+		return DefaultResyncPolls
+	}
+	return c.ResyncPolls
+}
+
+func (c *Controller) pollInterval() time.Duration {
+	if c.PollInterval == 0 {
+		return DefaultPollInterval
+	}
+	return c.PollInterval
+}
+
+// equal reports whether two states would run the same app.
+func equal(a, b *state.State) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	ja, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	jb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return string(ja) == string(jb)
+}
@@ -0,0 +1,309 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/scheduler/memory"
+	"github.com/remind101/12factor/state"
+	"github.com/stretchr/testify/assert"
+)
+
+var testProcesses = []twelvefactor.Process{{Name: "web", DesiredCount: 2}}
+
+func TestController(t *testing.T) {
+	m := memory.NewScheduler()
+	store := state.NewMemoryStore()
+	assert.NoError(t, store.Put(&state.State{App: twelvefactor.App{ID: "acme", Image: "acme-inc:v1"}, Processes: testProcesses}))
+
+	statuses := make(chan Status, 100)
+	c := &Controller{
+		Scheduler:    m,
+		Source:       FromStore(store),
+		PollInterval: 5 * time.Millisecond,
+		OnStatus:     func(s Status) { statuses <- s },
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := start(ctx, c)
+
+	waitFor(t, statuses, "acme", PhaseSynced)
+	tasks, err := m.Tasks("acme")
+	assert.NoError(t, err)
+	assert.Len(t, tasks, 2)
+
+	// Changes are run.
+	assert.NoError(t, store.Put(&state.State{App: twelvefactor.App{ID: "acme", Image: "acme-inc:v2"}, Processes: testProcesses}))
+	waitFor(t, statuses, "acme", PhaseSynced)
+	plan, err := m.Plan(twelvefactor.App{ID: "acme", Image: "acme-inc:v2"}, testProcesses...)
+	assert.NoError(t, err)
+	assert.True(t, plan.Empty())
+
+	s, ok := c.Status("acme")
+	assert.True(t, ok)
+	assert.Equal(t, PhaseSynced, s.Phase)
+	assert.False(t, s.SyncedAt.IsZero())
+
+	// Apps that go away are removed.
+	assert.NoError(t, store.Delete("acme"))
+	waitFor(t, statuses, "acme", PhaseRemoved)
+	_, err = m.Tasks("acme")
+	assert.True(t, errors.Is(err, twelvefactor.ErrAppNotFound))
+	assert.Equal(t, []Status{}, c.Statuses())
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+}
+
+func TestController_Retry(t *testing.T) {
+	store := state.NewMemoryStore()
+	assert.NoError(t, store.Put(&state.State{App: twelvefactor.App{ID: "acme", Image: "acme-inc:v1"}, Processes: testProcesses}))
+
+	s := &flaky{Scheduler: memory.NewScheduler(), failures: 2}
+	statuses := make(chan Status, 100)
+	c := &Controller{
+		Scheduler: s,
+		Source:    FromStore(store),
+		BaseDelay: time.Millisecond,
+		OnStatus:  func(s Status) { statuses <- s },
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start(ctx, c)
+
+	status := waitFor(t, statuses, "acme", PhaseFailed)
+	assert.Equal(t, "boom", status.Error)
+	assert.Equal(t, 1, status.Failures)
+
+	status = waitFor(t, statuses, "acme", PhaseFailed)
+	assert.Equal(t, 2, status.Failures)
+
+	status = waitFor(t, statuses, "acme", PhaseSynced)
+	assert.Equal(t, "", status.Error)
+	assert.Equal(t, 0, status.Failures)
+}
+
+func TestController_Resync(t *testing.T) {
+	m := &counting{Scheduler: memory.NewScheduler()}
+	app := twelvefactor.App{ID: "acme", Image: "acme-inc:v1"}
+	store := state.NewMemoryStore()
+	assert.NoError(t, store.Put(&state.State{App: app, Processes: testProcesses}))
+
+	statuses := make(chan Status, 100)
+	c := &Controller{
+		Scheduler:    m,
+		Source:       FromStore(store),
+		PollInterval: time.Millisecond,
+		ResyncPolls:  1,
+		OnStatus:     func(s Status) { statuses <- s },
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start(ctx, c)
+
+	waitFor(t, statuses, "acme", PhaseSynced)
+
+	// Apps that haven't drifted aren't run again.
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 1, m.count())
+
+	// Changes made outside of the controller are corrected.
+	assert.NoError(t, m.Scheduler.Run(twelvefactor.App{ID: "acme", Image: "acme-inc:v0"}, testProcesses...))
+	waitFor(t, statuses, "acme", PhaseSynced)
+	assert.Equal(t, 2, m.count())
+
+	plan, err := m.Plan(app, testProcesses...)
+	assert.NoError(t, err)
+	assert.True(t, plan.Empty())
+}
+
+func TestController_Shutdown(t *testing.T) {
+	store := state.NewMemoryStore()
+	assert.NoError(t, store.Put(&state.State{App: twelvefactor.App{ID: "acme", Image: "acme-inc:v1"}, Processes: testProcesses}))
+
+	s := &blocking{Scheduler: memory.NewScheduler(), started: make(chan struct{}), release: make(chan struct{})}
+	c := &Controller{Scheduler: s, Source: FromStore(store)}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := start(ctx, c)
+
+	<-s.started
+	cancel()
+
+	// Start waits for the sync that's in progress.
+	select {
+	case <-done:
+		t.Fatal("Start returned before the sync finished")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(s.release)
+	assert.Equal(t, context.Canceled, <-done)
+
+	status, ok := c.Status("acme")
+	assert.True(t, ok)
+	assert.Equal(t, PhaseSynced, status.Phase)
+}
+
+func TestController_Backoff(t *testing.T) {
+	c := &Controller{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	assert.Equal(t, time.Second, c.backoff(1))
+	assert.Equal(t, 2*time.Second, c.backoff(2))
+	assert.Equal(t, 4*time.Second, c.backoff(3))
+	assert.Equal(t, 5*time.Second, c.backoff(4))
+	assert.Equal(t, 5*time.Second, c.backoff(100))
+}
+
+func TestQueue(t *testing.T) {
+	q := newQueue()
+	q.add("acme")
+	q.add("other")
+	q.add("acme")
+
+	app, ok := q.get()
+	assert.True(t, ok)
+	assert.Equal(t, "acme", app)
+
+	// Apps that are added while they're being synced are queued again
+	// once they're done.
+	q.add("acme")
+	app, _ = q.get()
+	assert.Equal(t, "other", app)
+	q.done("other")
+	q.done("acme")
+	app, _ = q.get()
+	assert.Equal(t, "acme", app)
+
+	q.shutdown()
+	_, ok = q.get()
+	assert.False(t, ok)
+}
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "acme.yml", `version: 1
+name: acme-inc
+image: acme-inc:v1
+processes:
+  web:
+    command: ["acme-inc", "server"]
+    scale: 2
+`)
+	writeFile(t, dir, "other.json", `{"version": "1", "image": "other:v1", "processes": {}}`)
+	writeFile(t, dir, "README.md", "Apps")
+	writeFile(t, dir, ".acme.yml.swp", "")
+
+	states, err := (&Dir{Path: dir}).Desired()
+	assert.NoError(t, err)
+	assert.Equal(t, []*state.State{
+		{
+			App: twelvefactor.App{ID: "acme-inc", Name: "acme-inc", Image: "acme-inc:v1"},
+			Processes: []twelvefactor.Process{
+				{Name: "web", Command: []string{"acme-inc", "server"}, DesiredCount: 2},
+			},
+		},
+		{App: twelvefactor.App{ID: "other", Name: "other", Image: "other:v1"}},
+	}, states)
+
+	// An app can't be defined twice.
+	writeFile(t, dir, "acme2.yml", "version: 1\nname: acme-inc\nimage: acme-inc:v2\nprocesses: {}\n")
+	_, err = (&Dir{Path: dir}).Desired()
+	assert.EqualError(t, err, "acme2.yml: app acme-inc is already defined in acme.yml")
+	assert.NoError(t, os.Remove(filepath.Join(dir, "acme2.yml")))
+
+	// A bad manifest fails the whole read, rather than removing the app.
+	writeFile(t, dir, "bad.yml", "image: [")
+	_, err = (&Dir{Path: dir}).Desired()
+	assert.Error(t, err)
+}
+
+// start runs the controller in the background, returning a channel that
+// receives the result of Start.
+func start(ctx context.Context, c *Controller) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- c.Start(ctx) }()
+	return done
+}
+
+// waitFor waits for the app to reach phase, failing the test if it doesn't
+// soon.
+func waitFor(t testing.TB, statuses <-chan Status, app string, phase Phase) Status {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case s := <-statuses:
+			if s.App == app && s.Phase == phase {
+				return s
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s to be %s", app, phase)
+			return Status{}
+		}
+	}
+}
+
+func writeFile(t testing.TB, dir, name, content string) {
+	t.Helper()
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// flaky is a scheduler that fails to run apps the first few times.
+type flaky struct {
+	twelvefactor.Scheduler
+
+	mu       sync.Mutex
+	failures int
+}
+
+func (s *flaky) Run(app twelvefactor.App, processes ...twelvefactor.Process) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("boom")
+	}
+	return s.Scheduler.Run(app, processes...)
+}
+
+// counting is a scheduler that counts the times that apps are run.
+type counting struct {
+	*memory.Scheduler
+
+	mu   sync.Mutex
+	runs int
+}
+
+func (s *counting) Run(app twelvefactor.App, processes ...twelvefactor.Process) error {
+	s.mu.Lock()
+	s.runs++
+	s.mu.Unlock()
+	return s.Scheduler.Run(app, processes...)
+}
+
+func (s *counting) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runs
+}
+
+// blocking is a scheduler that blocks running apps until it's released.
+type blocking struct {
+	twelvefactor.Scheduler
+	started, release chan struct{}
+}
+
+func (s *blocking) Run(app twelvefactor.App, processes ...twelvefactor.Process) error {
+	close(s.started)
+	<-s.release
+	return s.Scheduler.Run(app, processes...)
+}
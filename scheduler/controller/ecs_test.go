@@ -0,0 +1,95 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awsecs "github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/scheduler/controller"
+	"github.com/remind101/12factor/scheduler/ecs"
+	"github.com/remind101/12factor/scheduler/ecs/builders/raw"
+	"github.com/remind101/12factor/scheduler/ecs/ecstest"
+	"github.com/remind101/12factor/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestController_ECS(t *testing.T) {
+	srv := ecstest.NewServer()
+	defer srv.Close()
+
+	b := raw.NewStackBuilder(srv.Config())
+	b.Cluster = "cluster"
+	s := ecs.NewSchedulerWithStackBuilder(srv.Config(), b)
+	s.Cluster = "cluster"
+
+	app := twelvefactor.App{ID: "app", Image: "acme-inc:v1"}
+	processes := []twelvefactor.Process{{Name: "web", DesiredCount: 2}}
+	store := state.NewMemoryStore()
+	assert.NoError(t, store.Put(&state.State{App: app, Processes: processes}))
+
+	statuses := make(chan controller.Status, 100)
+	c := &controller.Controller{
+		Scheduler:    s,
+		Source:       controller.FromStore(store),
+		PollInterval: 5 * time.Millisecond,
+		ResyncPolls:  1,
+		OnStatus:     func(s controller.Status) { statuses <- s },
+	}
+	waitFor := func(phase controller.Phase) {
+		t.Helper()
+
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case s := <-statuses:
+				if s.App == "app" && s.Phase == phase {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("timed out waiting for app to be %s", phase)
+			}
+		}
+	}
+
+	ecsClient := awsecs.New(session.New(srv.Config()))
+	desiredCount := func() int64 {
+		resp, err := ecsClient.DescribeServices(&awsecs.DescribeServicesInput{
+			Cluster:  aws.String("cluster"),
+			Services: []*string{aws.String("app--web")},
+		})
+		assert.NoError(t, err)
+		if len(resp.Services) == 0 || aws.StringValue(resp.Services[0].Status) != "ACTIVE" {
+			return -1
+		}
+		return aws.Int64Value(resp.Services[0].DesiredCount)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.Start(ctx) }()
+
+	waitFor(controller.PhaseSynced)
+	assert.Equal(t, int64(2), desiredCount())
+
+	// The service is scaled down by hand, which is corrected when the app
+	// is synced again.
+	_, err := ecsClient.UpdateService(&awsecs.UpdateServiceInput{
+		Cluster:      aws.String("cluster"),
+		Service:      aws.String("app--web"),
+		DesiredCount: aws.Int64(1),
+	})
+	assert.NoError(t, err)
+	waitFor(controller.PhaseSynced)
+	assert.Equal(t, int64(2), desiredCount())
+
+	assert.NoError(t, store.Delete("app"))
+	waitFor(controller.PhaseRemoved)
+	assert.Equal(t, int64(-1), desiredCount())
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+}
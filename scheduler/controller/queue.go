@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"sync"
+	"time"
+)
+
+// queue is a work queue of apps to sync. An app is only ever in the queue once,
+// and is never handed to more than one worker at a time: an app that's added
+// while it's being synced is queued again once the worker is done with it.
+type queue struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	pending []string
+
+	// Apps that are in pending, being synced, or that were added while
+	// they were being synced.
+	queued, active, dirty map[string]bool
+
+	closed bool
+}
+
+func newQueue() *queue {
+	q := &queue{
+		queued: make(map[string]bool),
+		active: make(map[string]bool),
+		dirty:  make(map[string]bool),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// add queues the app, unless it's already queued.
+func (q *queue) add(app string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	switch {
+	case q.closed, q.queued[app]:
+	case q.active[app]:
+		q.dirty[app] = true
+	default:
+		q.queued[app] = true
+		q.pending = append(q.pending, app)
+		q.cond.Signal()
+	}
+}
+
+// addAfter queues the app once d has passed.
+func (q *queue) addAfter(app string, d time.Duration) {
+	time.AfterFunc(d, func() { q.add(app) })
+}
+
+// get waits for an app to sync, returning false once the queue is shut down.
+// The caller must call done with the app once it's synced.
+func (q *queue) get() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.pending) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return "", false
+	}
+
+	app := q.pending[0]
+	q.pending = q.pending[1:]
+	delete(q.queued, app)
+	q.active[app] = true
+	return app, true
+}
+
+// done marks the app as no longer being synced, queueing it again if it was
+// added in the meantime.
+func (q *queue) done(app string) {
+	q.mu.Lock()
+	delete(q.active, app)
+	dirty := q.dirty[app]
+	delete(q.dirty, app)
+	q.mu.Unlock()
+
+	if dirty {
+		q.add(app)
+	}
+}
+
+// shutdown drops any apps that are waiting to be synced, and makes get return
+// false.
+func (q *queue) shutdown() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.pending = nil
+	q.cond.Broadcast()
+}
+
+// limiter spaces out syncs so that they start at least interval apart.
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// wait blocks until the next sync is allowed to start, returning false if done
+// is closed first.
+func (l *limiter) wait(done <-chan struct{}) bool {
+	if l.interval <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	if d := at.Sub(now); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-done:
+			return false
+		}
+	}
+	return true
+}
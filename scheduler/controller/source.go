@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/manifest"
+	"github.com/remind101/12factor/state"
+)
+
+// Dir is a Source that reads a manifest for each app from a directory. Files
+// ending in .yml, .yaml or .json are read as manifests, and anything else,
+// including hidden files, is ignored.
+//
+// Apps without an id in their manifest use their name, or the name of the file
+// without its extension if they don't have one either. Two files for the same
+// app are an error, since either could be the one that's meant.
+type Dir struct {
+	Path string
+}
+
+// Desired implements the Source interface. If any manifest can't be read, an
+// error is returned, so that the app isn't removed.
+func (d *Dir) Desired() ([]*state.State, error) {
+	files, err := ioutil.ReadDir(d.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var states []*state.State
+	seen := make(map[string]string)
+	for _, f := range files {
+		ext := filepath.Ext(f.Name())
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") || (ext != ".yml" && ext != ".yaml" && ext != ".json") {
+			continue
+		}
+
+		m, err := manifest.Load(filepath.Join(d.Path, f.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name(), err)
+		}
+
+		app, processes := m.App()
+		if app.ID == "" {
+			app.ID = app.Name
+		}
+		if app.ID == "" {
+			app.ID = strings.TrimSuffix(f.Name(), ext)
+		}
+		if app.Name == "" {
+			app.Name = app.ID
+		}
+
+		if other, ok := seen[app.ID]; ok {
+			return nil, fmt.Errorf("%s: app %s is already defined in %s", f.Name(), app.ID, other)
+		}
+		seen[app.ID] = f.Name()
+
+		states = append(states, &state.State{App: app, Processes: processes})
+	}
+	return states, nil
+}
+
+// FromStore returns a Source that reads the desired state of apps from a
+// state.Store, such as a state.MemoryStore that's updated in process.
+func FromStore(store state.Store) Source {
+	return &storeSource{store}
+}
+
+type storeSource struct {
+	store state.Store
+}
+
+func (s *storeSource) Desired() ([]*state.State, error) {
+	apps, err := s.store.Apps()
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]*state.State, 0, len(apps))
+	for _, app := range apps {
+		st, err := s.store.Get(app)
+		if errors.Is(err, twelvefactor.ErrAppNotFound) {
+			// Deleted since the list of apps was read.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, st)
+	}
+	return states, nil
+}
@@ -0,0 +1,227 @@
+package docker
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/pkg/cron"
+)
+
+// cronRunner runs scheduled processes from within the current process, since
+// the Docker daemon has no native support for running containers on a
+// schedule.
+type cronRunner struct {
+	// trigger is called each time the schedule for a process fires.
+	trigger func(twelvefactor.App, twelvefactor.Process, time.Time) error
+
+	// now returns the current time. The zero value is time.Now.
+	now func() time.Time
+
+	mu sync.Mutex
+
+	// apps maps an app id to its scheduled processes.
+	apps map[string]*cronApp
+}
+
+// cronApp holds the scheduled processes for an app.
+type cronApp struct {
+	app       twelvefactor.App
+	processes []twelvefactor.Process
+
+	// stop stops the schedules for the app when it's closed.
+	stop chan struct{}
+}
+
+func newCronRunner(trigger func(twelvefactor.App, twelvefactor.Process, time.Time) error) *cronRunner {
+	return &cronRunner{
+		trigger: trigger,
+		apps:    make(map[string]*cronApp),
+	}
+}
+
+// Schedule replaces all of the schedules for the app with the given processes.
+func (r *cronRunner) Schedule(app twelvefactor.App, processes ...twelvefactor.Process) error {
+	schedules, err := parseSchedules(processes)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.schedule(app, processes, schedules)
+	return nil
+}
+
+// Scale changes the number of containers that a scheduled process starts each
+// time it's triggered. It reports whether the app has a scheduled process with
+// that name.
+func (r *cronRunner) Scale(app, process string, desired int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.apps[app]
+	if !ok {
+		return false
+	}
+
+	processes := make([]twelvefactor.Process, len(a.processes))
+	copy(processes, a.processes)
+
+	found := false
+	for i := range processes {
+		if processes[i].Name == process {
+			processes[i].DesiredCount = desired
+			found = true
+		}
+	}
+	if !found {
+		return false
+	}
+
+	// The schedules were parsed when they were first scheduled.
+	schedules, _ := parseSchedules(processes)
+	r.schedule(a.app, processes, schedules)
+	return true
+}
+
+// schedule replaces the schedules for the app. r.mu must be held.
+func (r *cronRunner) schedule(app twelvefactor.App, processes []twelvefactor.Process, schedules []*cron.Schedule) {
+	r.remove(app.ID)
+
+	if len(processes) == 0 {
+		return
+	}
+
+	a := &cronApp{app: app, processes: processes, stop: make(chan struct{})}
+	r.apps[app.ID] = a
+	for i, process := range processes {
+		go r.run(app, process, schedules[i], a.stop)
+	}
+}
+
+// parseSchedules parses the schedule of each process.
+func parseSchedules(processes []twelvefactor.Process) ([]*cron.Schedule, error) {
+	schedules := make([]*cron.Schedule, len(processes))
+	for i, process := range processes {
+		schedule, err := cron.Parse(process.Schedule)
+		if err != nil {
+			return nil, twelvefactor.NewError(twelvefactor.ErrInvalidConfig, err)
+		}
+		schedules[i] = schedule
+	}
+	return schedules, nil
+}
+
+// Has reports whether the app has any scheduled processes, or a scheduled
+// process with the given name if process isn't empty.
+func (r *cronRunner) Has(app, process string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.apps[app]
+	if !ok {
+		return false
+	}
+	if process == "" {
+		return true
+	}
+
+	for _, p := range a.processes {
+		if p.Name == process {
+			return true
+		}
+	}
+	return false
+}
+
+// App returns the app that was last scheduled with the given id, and whether
+// there is one.
+func (r *cronRunner) App(app string) (twelvefactor.App, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.apps[app]
+	if !ok {
+		return twelvefactor.App{}, false
+	}
+	return a.app, true
+}
+
+// Processes returns the scheduled processes for the app.
+func (r *cronRunner) Processes(app string) []twelvefactor.Process {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.apps[app]
+	if !ok {
+		return nil
+	}
+
+	processes := make([]twelvefactor.Process, len(a.processes))
+	copy(processes, a.processes)
+	return processes
+}
+
+// Remove stops the schedules for the app. It reports whether the app had any.
+func (r *cronRunner) Remove(app string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.remove(app)
+}
+
+// remove stops the schedules for the app. r.mu must be held.
+func (r *cronRunner) remove(app string) bool {
+	a, ok := r.apps[app]
+	if !ok {
+		return false
+	}
+
+	close(a.stop)
+	delete(r.apps, app)
+	return true
+}
+
+// Stop stops all schedules.
+func (r *cronRunner) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for app := range r.apps {
+		r.remove(app)
+	}
+}
+
+// run triggers the process each time the schedule fires, until stop is closed.
+// Schedules are evaluated in UTC, like they are by CloudWatch Events, so that a
+// process runs at the same time on every scheduler.
+func (r *cronRunner) run(app twelvefactor.App, process twelvefactor.Process, schedule *cron.Schedule, stop <-chan struct{}) {
+	for {
+		now := r.clock().UTC()
+		next := schedule.Next(now)
+		if next.IsZero() {
+			return
+		}
+
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-timer.C:
+			if err := r.trigger(app, process, next); err != nil {
+				log.Printf("error running scheduled process %s/%s: %v", app.ID, process.Name, err)
+			}
+		case <-stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+func (r *cronRunner) clock() time.Time {
+	if r.now == nil {
+		return time.Now()
+	}
+	return r.now()
+}
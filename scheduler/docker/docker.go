@@ -1,24 +1,101 @@
 package docker
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
 	"github.com/fsouza/go-dockerclient"
 	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/pkg/bytesize"
+	"github.com/remind101/12factor/pkg/cpu"
+	"github.com/remind101/12factor/pkg/cron"
 )
 
+// Labels that are attached to containers to identify the app and process that
+// they belong to.
+const (
+	AppLabel     = "com.remind101.12factor.app"
+	ProcessLabel = "com.remind101.12factor.process"
+	VersionLabel = "com.remind101.12factor.version"
+
+	// ServiceLabel uniquely identifies the process across all apps, in the
+	// form "app/process".
+	ServiceLabel = "com.remind101.12factor.service"
+
+	// TriggeredAtLabel is attached to containers that were started by a
+	// schedule, and holds the time that the schedule fired in RFC3339
+	// format.
+	TriggeredAtLabel = "com.remind101.12factor.triggered-at"
+
+	// ConfigLabel is attached to the containers for long running
+	// processes, and holds a hash of the configuration that they were
+	// created with, so that Run can tell which containers are out of date.
+	ConfigLabel = "com.remind101.12factor.config"
+
+	// SpecLabel is attached to the containers for long running processes,
+	// and holds the configuration that they were created with as JSON, so
+	// that more containers can be created by ScaleProcess and StopTask.
+	SpecLabel = "com.remind101.12factor.spec"
+)
+
+// DefaultHealthPollInterval is the default interval between checks for new
+// containers becoming healthy during a deploy.
+const DefaultHealthPollInterval = time.Second
+
 // dockerClient represents the docker Client.
-type dockerClient interface{}
+type dockerClient interface {
+	CreateContainer(docker.CreateContainerOptions) (*docker.Container, error)
+	StartContainer(string, *docker.HostConfig) error
+	StopContainer(string, uint) error
+	RestartContainer(string, uint) error
+	RemoveContainer(docker.RemoveContainerOptions) error
+	ListContainers(docker.ListContainersOptions) ([]docker.APIContainers, error)
+	InspectContainer(string) (*docker.Container, error)
+	AttachToContainer(docker.AttachToContainerOptions) error
+	WaitContainer(string) (int, error)
+	Info() (*docker.DockerInfo, error)
+	Logs(docker.LogsOptions) error
+	CreateExec(docker.CreateExecOptions) (*docker.Exec, error)
+	StartExec(string, docker.StartExecOptions) error
+	ResizeExecTTY(id string, height, width int) error
+	InspectExec(string) (*docker.ExecInspect, error)
+}
 
 // Scheduler is an implementation of the twelvefactor.Scheduler interface that
 // talks to the Docker daemon API.
 type Scheduler struct {
+	// MemoryRounding is the rounding policy used when converting a
+	// process's memory, in bytes, into whole pages of memory, which is
+	// what the kernel limits containers to. The zero value is
+	// bytesize.RoundUp, so containers never get less memory than
+	// requested.
+	MemoryRounding bytesize.Rounding
+
+	// HealthPollInterval is how often Run checks whether new containers
+	// are healthy, for processes with a HealthCheck. The zero value is
+	// DefaultHealthPollInterval.
+	HealthPollInterval time.Duration
+
 	docker dockerClient
+
+	// cron runs the processes that have a Schedule.
+	cron *cronRunner
 }
 
 // NewScheduler returns a new Scheduler instance backed by the docker client.
 func NewScheduler(c *docker.Client) *Scheduler {
-	return &Scheduler{
+	return newScheduler(c)
+}
+
+func newScheduler(c dockerClient) *Scheduler {
+	s := &Scheduler{
 		docker: c,
 	}
+	s.cron = newCronRunner(s.trigger)
+	return s
 }
 
 // NewSchedulerFromEnv returns a new Scheduler instance with a Docker client
@@ -31,7 +108,511 @@ func NewSchedulerFromEnv() (*Scheduler, error) {
 	return NewScheduler(c), nil
 }
 
-// Run runs the application with Docker.
+// Run runs the application with Docker. Long running processes are run as
+// containers that Docker restarts unless they're stopped, and containers for
+// processes that are no longer part of the app are removed. Processes with a
+// Schedule are run by an in-process cron runner, which lives for as long as the
+// Scheduler does.
 func (s *Scheduler) Run(app twelvefactor.App, processes ...twelvefactor.Process) error {
+	var services, scheduled []twelvefactor.Process
+	for _, process := range processes {
+		// Check everything up front, so that a bad process doesn't
+		// leave the app half deployed.
+		if err := validate(process); err != nil {
+			return err
+		}
+
+		if process.Schedule == "" {
+			services = append(services, process)
+		} else {
+			scheduled = append(scheduled, process)
+		}
+	}
+
+	for _, process := range services {
+		if err := s.deploy(app, process); err != nil {
+			return err
+		}
+	}
+
+	if err := s.removeStale(app.ID, services); err != nil {
+		return err
+	}
+
+	return s.cron.Schedule(app, scheduled...)
+}
+
+// Tasks returns the containers for the app, including any that have exited.
+func (s *Scheduler) Tasks(app string) ([]twelvefactor.Task, error) {
+	containers, err := s.appContainers(app)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		tasks []twelvefactor.Task
+		host  string
+	)
+	for _, c := range containers {
+		task := twelvefactor.Task{
+			ID:      c.ID,
+			Version: c.Labels[VersionLabel],
+			Process: c.Labels[ProcessLabel],
+			State:   c.State,
+			Time:    time.Unix(c.Created, 0),
+		}
+
+		if t, err := time.Parse(time.RFC3339, c.Labels[TriggeredAtLabel]); err == nil {
+			task.TriggeredAt = t
+		}
+
+		// The exit code, health and timestamps are only available by
+		// inspecting the container.
+		container, err := s.docker.InspectContainer(c.ID)
+		if err != nil {
+			return nil, translateError(err)
+		}
+		inspectTask(&task, container)
+
+		// Outside of a swarm, every container runs on the host of the
+		// daemon.
+		if task.Host == "" {
+			if host == "" {
+				info, err := s.docker.Info()
+				if err != nil {
+					return nil, translateError(err)
+				}
+				host = info.Name
+			}
+			task.Host = host
+		}
+
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
+}
+
+// Remove stops the app's schedules, and removes all of its containers,
+// including the ones that have exited.
+func (s *Scheduler) Remove(app string) error {
+	scheduled := s.cron.Remove(app)
+
+	containers, err := s.appContainers(app)
+	if err != nil {
+		return err
+	}
+
+	if len(containers) == 0 && !scheduled {
+		return &twelvefactor.AppNotFoundError{App: app}
+	}
+
+	return s.removeContainers(containers)
+}
+
+// ScaleProcess changes the number of containers for a long running process,
+// or the number of containers that a scheduled process starts each time it's
+// triggered. Scheduled processes only exist for as long as the Scheduler that
+// ran them, and a long running process can only be scaled up from 0 by Run,
+// since its configuration is kept on its containers.
+func (s *Scheduler) ScaleProcess(app, process string, desired int) error {
+	if desired < 0 {
+		return twelvefactor.NewError(twelvefactor.ErrInvalidConfig, fmt.Errorf("desired count for %s must not be negative, got %d", process, desired))
+	}
+
+	if s.cron.Scale(app, process, desired) {
+		return nil
+	}
+
+	containers, err := s.serviceContainers(app, process)
+	if err != nil {
+		return err
+	}
+
+	if len(containers) == 0 {
+		return &twelvefactor.ProcessNotFoundError{App: app, Process: process}
+	}
+
+	// Containers are listed newest first, so this is the configuration
+	// from the latest deploy.
+	config, host, err := containerSpec(containers[0].Labels)
+	if err != nil {
+		return err
+	}
+
+	return s.converge(config, host, containers, desired, newRollout(desired, twelvefactor.RollingDeployment{}, false))
+}
+
+// Restart restarts all of the containers for the app's long running
+// processes.
+func (s *Scheduler) Restart(app string) error {
+	containers, err := s.appContainers(app)
+	if err != nil {
+		return err
+	}
+
+	var services []docker.APIContainers
+	for _, c := range containers {
+		if _, ok := c.Labels[TriggeredAtLabel]; !ok {
+			services = append(services, c)
+		}
+	}
+
+	if len(services) == 0 && !s.cron.Has(app, "") {
+		return &twelvefactor.AppNotFoundError{App: app}
+	}
+
+	return s.restartContainers(services)
+}
+
+// RestartProcess restarts all of the containers for a long running process.
+// Scheduled processes start new containers each time they're triggered, so
+// there's nothing to restart.
+func (s *Scheduler) RestartProcess(app, process string) error {
+	containers, err := s.serviceContainers(app, process)
+	if err != nil {
+		return err
+	}
+
+	if len(containers) == 0 && !s.cron.Has(app, process) {
+		return &twelvefactor.ProcessNotFoundError{App: app, Process: process}
+	}
+
+	return s.restartContainers(containers)
+}
+
+// restartContainers restarts each of the containers, giving them stopTimeout
+// seconds to exit.
+func (s *Scheduler) restartContainers(containers []docker.APIContainers) error {
+	for _, c := range containers {
+		if err := s.docker.RestartContainer(c.ID, stopTimeout); err != nil {
+			return translateError(err)
+		}
+	}
+	return nil
+}
+
+// StopTask stops the container. Like ECS, containers for long running
+// processes are replaced with a new one, otherwise Docker wouldn't restart
+// them.
+func (s *Scheduler) StopTask(taskID string) error {
+	container, err := s.docker.InspectContainer(taskID)
+	if err != nil {
+		return translateError(err)
+	}
+
+	if _, ok := container.Config.Labels[SpecLabel]; !ok {
+		var notRunning *docker.ContainerNotRunning
+		if err := s.docker.StopContainer(taskID, stopTimeout); err != nil && !errors.As(err, &notRunning) {
+			return translateError(err)
+		}
+		return nil
+	}
+
+	config, host, err := containerSpec(container.Config.Labels)
+	if err != nil {
+		return err
+	}
+
+	if err := s.removeContainer(taskID); err != nil {
+		return err
+	}
+
+	_, err = s.startContainer(config, host)
+	return err
+}
+
+// appContainers returns all of the containers for the app, including any that
+// have exited.
+func (s *Scheduler) appContainers(app string) ([]docker.APIContainers, error) {
+	containers, err := s.docker.ListContainers(docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": {fmt.Sprintf("%s=%s", AppLabel, app)},
+		},
+	})
+	return containers, translateError(err)
+}
+
+// inspectTask fills in the details of a task from the inspected container.
+func inspectTask(task *twelvefactor.Task, c *docker.Container) {
+	state := c.State
+
+	task.StartedAt = state.StartedAt
+	if !state.Running && !state.FinishedAt.IsZero() {
+		task.StoppedAt = state.FinishedAt
+
+		code := state.ExitCode
+		task.ExitCode = &code
+
+		switch {
+		case state.OOMKilled:
+			task.StopReason = "out of memory"
+		case state.Error != "":
+			task.StopReason = state.Error
+		default:
+			task.StopReason = fmt.Sprintf("exited with code %d", code)
+		}
+	}
+
+	switch state.Health.Status {
+	case "starting":
+		task.Health = twelvefactor.HealthStarting
+	case "healthy":
+		task.Health = twelvefactor.HealthHealthy
+	case "unhealthy":
+		task.Health = twelvefactor.HealthUnhealthy
+	}
+
+	if c.Node != nil {
+		task.Host = c.Node.Name
+	}
+
+	if c.NetworkSettings != nil {
+		var names []string
+		for name := range c.NetworkSettings.Networks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			network := c.NetworkSettings.Networks[name]
+			for _, ip := range []string{network.IPAddress, network.GlobalIPv6Address} {
+				if ip != "" {
+					task.IPAddresses = append(task.IPAddresses, ip)
+				}
+			}
+		}
+	}
+}
+
+// translateError translates errors from the Docker API into the errors defined
+// by the twelvefactor package. Other errors are returned as is.
+func translateError(err error) error {
+	var (
+		noSuchContainer *docker.NoSuchContainer
+		apiErr          *docker.Error
+	)
+	switch {
+	case errors.As(err, &noSuchContainer):
+		return &twelvefactor.TaskNotFoundError{Task: noSuchContainer.ID}
+	case errors.Is(err, docker.ErrNoSuchImage):
+		return twelvefactor.NewError(twelvefactor.ErrInvalidConfig, err)
+	case errors.Is(err, docker.ErrContainerAlreadyExists):
+		return twelvefactor.NewError(twelvefactor.ErrConflict, err)
+	case errors.As(err, &apiErr):
+		switch apiErr.Status {
+		case http.StatusBadRequest:
+			return twelvefactor.NewError(twelvefactor.ErrInvalidConfig, err)
+		case http.StatusConflict:
+			return twelvefactor.NewError(twelvefactor.ErrConflict, err)
+		}
+	}
+	return err
+}
+
+// Capabilities implements the twelvefactor.CapabilityProvider interface.
+// Placement strategies are ignored, but constraints are honored.
+func (s *Scheduler) Capabilities() []twelvefactor.Capability {
+	return []twelvefactor.Capability{
+		twelvefactor.CapabilityRunProcess,
+		twelvefactor.CapabilityAttachedRun,
+		twelvefactor.CapabilityExec,
+		twelvefactor.CapabilityLogs,
+		twelvefactor.CapabilityHealthChecks,
+		twelvefactor.CapabilityPlacement,
+		twelvefactor.CapabilityPlan,
+	}
+}
+
+// Close stops running any scheduled processes.
+func (s *Scheduler) Close() error {
+	s.cron.Stop()
+	return nil
+}
+
+// trigger starts DesiredCount containers for a scheduled process.
+func (s *Scheduler) trigger(app twelvefactor.App, process twelvefactor.Process, at time.Time) error {
+	for i := 0; i < process.DesiredCount; i++ {
+		config := containerConfig(app, process)
+		config.Labels[TriggeredAtLabel] = at.UTC().Format(time.RFC3339)
+
+		c, err := s.docker.CreateContainer(docker.CreateContainerOptions{
+			Config:     config,
+			HostConfig: s.hostConfig(process),
+		})
+		if err != nil {
+			return translateError(err)
+		}
+
+		if err := s.docker.StartContainer(c.ID, nil); err != nil {
+			return translateError(err)
+		}
+	}
+
+	return nil
+}
+
+// containerConfig returns the docker container configuration for running an
+// instance of the process.
+func containerConfig(app twelvefactor.App, process twelvefactor.Process) *docker.Config {
+	labels := twelvefactor.MergeEnv(process.Labels, map[string]string{
+		AppLabel:     app.ID,
+		ProcessLabel: process.Name,
+		VersionLabel: app.Version,
+		ServiceLabel: service(app.ID, process.Name),
+	})
+
+	var env []string
+	for k, v := range twelvefactor.ProcessEnv(app, process) {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(env)
+
+	return &docker.Config{
+		Image:       app.Image,
+		Cmd:         process.Command,
+		Env:         append(env, placementEnv(app, process)...),
+		Labels:      labels,
+		Healthcheck: healthConfig(process.HealthCheck),
+	}
+}
+
+// healthConfig converts a process health check into a docker health check.
+func healthConfig(check *twelvefactor.HealthCheck) *docker.HealthConfig {
+	if check == nil {
+		return nil
+	}
+
+	return &docker.HealthConfig{
+		Test:        append([]string{"CMD"}, check.Command...),
+		Interval:    check.Interval,
+		Timeout:     check.Timeout,
+		StartPeriod: check.StartPeriod,
+		Retries:     check.Retries,
+	}
+}
+
+// hostConfig returns the docker host configuration for running an instance of
+// the process, which sets its resource limits.
+func (s *Scheduler) hostConfig(process twelvefactor.Process) *docker.HostConfig {
+	shares := int64(process.CPUShares)
+	if process.CPU != 0 {
+		shares = process.CPU.Shares()
+	}
+
+	return &docker.HostConfig{
+		CPUShares:         shares,
+		NanoCPUs:          process.CPULimit.NanoCPUs(),
+		Memory:            s.memoryBytes(process.Memory),
+		MemoryReservation: s.memoryBytes(process.MemoryReservation),
+	}
+}
+
+// pageSize is the granularity that the kernel limits memory in.
+const pageSize = 4 * bytesize.KiB
+
+// memoryBytes rounds a size to a whole number of pages using the
+// MemoryRounding policy.
+func (s *Scheduler) memoryBytes(size bytesize.ByteSize) int64 {
+	return int64(size.In(pageSize, s.MemoryRounding) * uint64(pageSize))
+}
+
+// validate checks that the process can be run with Docker.
+func validate(process twelvefactor.Process) error {
+	// Containers are replaced by Run itself, so only rolling deployments
+	// are supported.
+	switch strategy := process.Deployment.Strategy; strategy {
+	case "", twelvefactor.Rolling:
+	default:
+		return &twelvefactor.UnsupportedStrategyError{Strategy: strategy}
+	}
+
+	if err := process.Deployment.Validate(); err != nil {
+		return twelvefactor.NewError(twelvefactor.ErrInvalidConfig, err)
+	}
+
+	if err := validateCPU(process); err != nil {
+		return twelvefactor.NewError(twelvefactor.ErrInvalidConfig, err)
+	}
+
+	if err := validateMemory(process); err != nil {
+		return twelvefactor.NewError(twelvefactor.ErrInvalidConfig, err)
+	}
+
+	if process.Schedule != "" {
+		if _, err := cron.Parse(process.Schedule); err != nil {
+			return twelvefactor.NewError(twelvefactor.ErrInvalidConfig, err)
+		}
+	}
+
+	return nil
+}
+
+// minCPULimit is the smallest NanoCPUs limit that Docker accepts.
+const minCPULimit = 10 * cpu.MilliCPU
+
+// validateCPU checks that the CPU reservation and limit for the process are
+// within what Docker allows.
+func validateCPU(process twelvefactor.Process) error {
+	reservation, limit := process.CPUReservation(), process.CPULimit
+
+	if limit == 0 {
+		return nil
+	}
+
+	if limit < minCPULimit {
+		return fmt.Errorf("cpu limit for %s must be at least %s, got %s", process.Name, minCPULimit, limit)
+	}
+
+	if reservation > limit {
+		return fmt.Errorf("cpu reservation for %s (%s) exceeds its limit (%s)", process.Name, reservation, limit)
+	}
+
+	return nil
+}
+
+// minMemory is the smallest memory limit that Docker accepts.
+const minMemory = 6 * bytesize.MiB
+
+// validateMemory checks that the memory limit for the process is within what
+// Docker allows, and that the reservation doesn't exceed it.
+func validateMemory(process twelvefactor.Process) error {
+	reservation, limit := process.MemoryReservation, process.Memory
+
+	if limit == 0 {
+		return nil
+	}
+
+	if limit < minMemory {
+		return fmt.Errorf("memory limit for %s must be at least %s, got %s", process.Name, minMemory, limit)
+	}
+
+	if reservation > limit {
+		return fmt.Errorf("memory reservation for %s (%s) exceeds its limit (%s)", process.Name, reservation, limit)
+	}
+
 	return nil
 }
+
+// placementEnv converts the placement constraints for the process into the
+// environment variables that Docker Swarm uses for scheduling decisions.
+// Placement strategies are configured on the Swarm manager, so they're not
+// applicable to individual containers and are ignored.
+func placementEnv(app twelvefactor.App, process twelvefactor.Process) []string {
+	var env []string
+	for _, c := range process.Placement.Constraints {
+		switch c.Type {
+		case twelvefactor.DistinctInstance:
+			env = append(env, fmt.Sprintf("affinity:%s!=%s", ServiceLabel, service(app.ID, process.Name)))
+		case twelvefactor.MemberOf:
+			env = append(env, fmt.Sprintf("constraint:%s%s%s", c.Attribute, c.Op(), c.Value))
+		}
+	}
+	return env
+}
+
+// service returns the value of the ServiceLabel for the process.
+func service(app, process string) string {
+	return app + "/" + process
+}
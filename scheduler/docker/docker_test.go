@@ -0,0 +1,1134 @@
+package docker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/pkg/bytesize"
+	"github.com/remind101/12factor/pkg/cpu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ twelvefactor.Scheduler = (*Scheduler)(nil)
+
+func TestScheduler_Run(t *testing.T) {
+	c := new(mockDockerClient)
+	s := newScheduler(c)
+	defer s.Close()
+
+	app := twelvefactor.App{ID: "app", Image: "remind101/acme-inc", Version: "v2"}
+	web := twelvefactor.Process{Name: "web", Command: []string{"acme-inc", "web"}, DesiredCount: 2}
+	config, host := s.serviceConfig(app, web)
+
+	c.On("ListContainers", docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": {"com.remind101.12factor.service=app/web"},
+		},
+	}).Return([]docker.APIContainers{
+		{ID: "current", State: "running", Labels: map[string]string{ConfigLabel: config.Labels[ConfigLabel]}},
+		{ID: "old", State: "running", Labels: map[string]string{ConfigLabel: "0123456789ab"}},
+		{ID: "cron", State: "exited", Labels: map[string]string{TriggeredAtLabel: "2015-10-14T04:30:00Z"}},
+	}, nil)
+	c.On("ListContainers", docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": {"com.remind101.12factor.app=app"},
+		},
+	}).Return([]docker.APIContainers{
+		{ID: "current", Labels: map[string]string{ProcessLabel: "web"}},
+		{ID: "worker", Labels: map[string]string{ProcessLabel: "worker", ServiceLabel: "app/worker"}},
+		{ID: "cron", Labels: map[string]string{ProcessLabel: "cleanup", TriggeredAtLabel: "2015-10-14T04:30:00Z"}},
+		{ID: "migrate", Labels: map[string]string{ProcessLabel: "migrate"}},
+	}, nil)
+
+	// The current container is kept, a new one is started to make up the
+	// desired count, and then the old container and the container for the
+	// removed worker process are removed. Containers for scheduled and one
+	// off processes are kept.
+	c.On("CreateContainer", docker.CreateContainerOptions{
+		Config:     config,
+		HostConfig: host,
+	}).Return(&docker.Container{ID: "new"}, nil).Once()
+	c.On("StartContainer", "new", (*docker.HostConfig)(nil)).Return(nil).Once()
+	c.On("StopContainer", "old", uint(stopTimeout)).Return(nil).Once()
+	c.On("RemoveContainer", docker.RemoveContainerOptions{ID: "old"}).Return(nil).Once()
+	c.On("StopContainer", "worker", uint(stopTimeout)).Return(&docker.ContainerNotRunning{ID: "worker"}).Once()
+	c.On("RemoveContainer", docker.RemoveContainerOptions{ID: "worker"}).Return(nil).Once()
+
+	err := s.Run(app, web)
+	assert.NoError(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestScheduler_Run_MaximumPercent(t *testing.T) {
+	c := new(mockDockerClient)
+	s := newScheduler(c)
+	defer s.Close()
+
+	app := twelvefactor.App{ID: "app", Image: "remind101/acme-inc"}
+	web := twelvefactor.Process{
+		Name:         "web",
+		DesiredCount: 1,
+		Deployment: twelvefactor.Deployment{
+			Strategy: twelvefactor.Rolling,
+			Rolling:  twelvefactor.RollingDeployment{MaximumPercent: 100},
+		},
+	}
+
+	c.On("ListContainers", mock.Anything).Return([]docker.APIContainers{
+		{ID: "old", State: "running", Labels: map[string]string{ProcessLabel: "web", ConfigLabel: "0123456789ab"}},
+	}, nil)
+
+	// The old container is removed before the new one is started.
+	var calls []string
+	c.On("StopContainer", "old", uint(stopTimeout)).Return(nil).Once()
+	c.On("RemoveContainer", docker.RemoveContainerOptions{ID: "old"}).Return(nil).Once().Run(func(mock.Arguments) {
+		calls = append(calls, "remove")
+	})
+	c.On("CreateContainer", mock.Anything).Return(&docker.Container{ID: "new"}, nil).Once().Run(func(mock.Arguments) {
+		calls = append(calls, "create")
+	})
+	c.On("StartContainer", "new", (*docker.HostConfig)(nil)).Return(nil).Once()
+
+	err := s.Run(app, web)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"remove", "create"}, calls)
+}
+
+func TestScheduler_Run_Rolling(t *testing.T) {
+	c := new(mockDockerClient)
+	s := newScheduler(c)
+	defer s.Close()
+
+	app := twelvefactor.App{ID: "app", Image: "remind101/acme-inc"}
+	web := twelvefactor.Process{
+		Name:         "web",
+		DesiredCount: 4,
+		Deployment: twelvefactor.Deployment{
+			Rolling: twelvefactor.RollingDeployment{MinimumHealthyPercent: 50, MaximumPercent: 100},
+		},
+	}
+
+	var containers []docker.APIContainers
+	for i := 1; i <= 4; i++ {
+		containers = append(containers, docker.APIContainers{ID: fmt.Sprintf("old.%d", i), State: "running", Labels: map[string]string{ProcessLabel: "web", ConfigLabel: "0123456789ab"}})
+	}
+	c.On("ListContainers", mock.Anything).Return(containers, nil)
+
+	// Half of the containers are replaced at a time, so that at least 2
+	// are always running, and no more than 4 exist.
+	var calls []string
+	c.On("StopContainer", mock.Anything, uint(stopTimeout)).Return(nil)
+	c.On("RemoveContainer", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		calls = append(calls, "remove "+args.Get(0).(docker.RemoveContainerOptions).ID)
+	})
+	c.On("CreateContainer", mock.Anything).Return(&docker.Container{ID: "new"}, nil).Run(func(mock.Arguments) {
+		calls = append(calls, "create")
+	})
+	c.On("StartContainer", "new", (*docker.HostConfig)(nil)).Return(nil)
+
+	err := s.Run(app, web)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"remove old.1", "remove old.2",
+		"create", "create",
+		"remove old.3", "remove old.4",
+		"create", "create",
+	}, calls)
+}
+
+func TestScheduler_Run_HealthCheck(t *testing.T) {
+	app := twelvefactor.App{ID: "app", Image: "remind101/acme-inc"}
+	web := twelvefactor.Process{
+		Name:         "web",
+		DesiredCount: 1,
+		HealthCheck:  &twelvefactor.HealthCheck{Command: []string{"true"}},
+	}
+	old := docker.APIContainers{ID: "old", State: "running", Labels: map[string]string{ProcessLabel: "web", ConfigLabel: "0123456789ab"}}
+	state := func(status string) *docker.Container {
+		return &docker.Container{State: docker.State{Running: true, Health: docker.Health{Status: status}}}
+	}
+
+	// The old container is only removed once the new one is healthy.
+	c := new(mockDockerClient)
+	s := newScheduler(c)
+	s.HealthPollInterval = time.Millisecond
+	defer s.Close()
+
+	c.On("ListContainers", mock.Anything).Return([]docker.APIContainers{old}, nil)
+	c.On("CreateContainer", mock.Anything).Return(&docker.Container{ID: "new"}, nil).Once()
+	c.On("StartContainer", "new", (*docker.HostConfig)(nil)).Return(nil).Once()
+	c.On("InspectContainer", "new").Return(state("starting"), nil).Once()
+	c.On("InspectContainer", "new").Return(state("healthy"), nil).Once()
+	c.On("StopContainer", "old", uint(stopTimeout)).Return(nil).Once()
+	c.On("RemoveContainer", docker.RemoveContainerOptions{ID: "old"}).Return(nil).Once()
+
+	assert.NoError(t, s.Run(app, web))
+	c.AssertExpectations(t)
+
+	// Unhealthy containers are removed, and the old one is kept.
+	c = new(mockDockerClient)
+	s = newScheduler(c)
+	defer s.Close()
+
+	c.On("ListContainers", mock.Anything).Return([]docker.APIContainers{old}, nil)
+	c.On("CreateContainer", mock.Anything).Return(&docker.Container{ID: "new"}, nil).Once()
+	c.On("StartContainer", "new", (*docker.HostConfig)(nil)).Return(nil).Once()
+	c.On("InspectContainer", "new").Return(state("unhealthy"), nil).Once()
+	c.On("StopContainer", "new", uint(stopTimeout)).Return(nil).Once()
+	c.On("RemoveContainer", docker.RemoveContainerOptions{ID: "new"}).Return(nil).Once()
+
+	assert.EqualError(t, s.Run(app, web), "container new is unhealthy")
+	c.AssertExpectations(t)
+}
+
+func TestNewRollout(t *testing.T) {
+	tests := []struct {
+		desired int
+		rolling twelvefactor.RollingDeployment
+		out     rollout
+	}{
+		{2, twelvefactor.RollingDeployment{}, rollout{minHealthy: 2, maxTotal: 4}},
+		{3, twelvefactor.RollingDeployment{MinimumHealthyPercent: 50, MaximumPercent: 150}, rollout{minHealthy: 2, maxTotal: 4}},
+		{1, twelvefactor.RollingDeployment{MaximumPercent: 100}, rollout{minHealthy: 0, maxTotal: 1}},
+
+		// The minimum gives way when there's no room to replace a
+		// container.
+		{1, twelvefactor.RollingDeployment{MinimumHealthyPercent: 50, MaximumPercent: 150}, rollout{minHealthy: 0, maxTotal: 1}},
+		{0, twelvefactor.RollingDeployment{}, rollout{minHealthy: 0, maxTotal: 0}},
+	}
+
+	for i, tt := range tests {
+		assert.Equal(t, tt.out, newRollout(tt.desired, tt.rolling, false), "#%d", i)
+	}
+}
+
+func TestScheduler_Run_UnsupportedDeployment(t *testing.T) {
+	s := newScheduler(new(mockDockerClient))
+	defer s.Close()
+
+	err := s.Run(twelvefactor.App{ID: "app"}, twelvefactor.Process{
+		Name:       "web",
+		Deployment: twelvefactor.Deployment{Strategy: twelvefactor.BlueGreen},
+	})
+	assert.True(t, errors.Is(err, twelvefactor.ErrUnsupported))
+
+	err = s.Run(twelvefactor.App{ID: "app"}, twelvefactor.Process{
+		Name:       "web",
+		Deployment: twelvefactor.Deployment{BlueGreen: twelvefactor.BlueGreenDeployment{TerminationWait: time.Minute}},
+	})
+	assert.True(t, errors.Is(err, twelvefactor.ErrInvalidConfig))
+}
+
+func TestScheduler_Run_ScaleDown(t *testing.T) {
+	c := new(mockDockerClient)
+	s := newScheduler(c)
+	defer s.Close()
+
+	app := twelvefactor.App{ID: "app", Image: "remind101/acme-inc"}
+	web := twelvefactor.Process{Name: "web", DesiredCount: 1}
+	config, _ := s.serviceConfig(app, web)
+	labels := map[string]string{ProcessLabel: "web", ConfigLabel: config.Labels[ConfigLabel]}
+
+	containers := []docker.APIContainers{
+		{ID: "stopped", State: "exited", Labels: labels},
+		{ID: "running", State: "running", Labels: labels},
+	}
+	c.On("ListContainers", mock.Anything).Return(containers, nil)
+
+	// Running containers are kept in favor of stopped ones.
+	c.On("StopContainer", "stopped", uint(stopTimeout)).Return(nil).Once()
+	c.On("RemoveContainer", docker.RemoveContainerOptions{ID: "stopped"}).Return(nil).Once()
+
+	err := s.Run(app, web)
+	assert.NoError(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestServiceConfig(t *testing.T) {
+	s := new(Scheduler)
+	app := twelvefactor.App{ID: "app", Image: "remind101/acme-inc:v1"}
+	web := twelvefactor.Process{
+		Name:        "web",
+		Memory:      512 * bytesize.MiB,
+		HealthCheck: &twelvefactor.HealthCheck{Command: []string{"true"}},
+		Placement: twelvefactor.Placement{
+			Constraints: []twelvefactor.PlacementConstraint{{Type: twelvefactor.DistinctInstance}},
+		},
+	}
+
+	config, host := s.serviceConfig(app, web)
+	assert.Equal(t, docker.RestartUnlessStopped(), host.RestartPolicy)
+	assert.Equal(t, int64(512*bytesize.MiB), host.Memory)
+	assert.Equal(t, []string{"CMD", "true"}, config.Healthcheck.Test)
+	assert.Equal(t, []string{"affinity:com.remind101.12factor.service!=app/web"}, config.Env)
+	assert.Len(t, config.Labels[ConfigLabel], 12)
+
+	// The configuration can be recovered from the labels.
+	specConfig, specHost, err := containerSpec(config.Labels)
+	assert.NoError(t, err)
+	assert.Equal(t, config, specConfig)
+	assert.Equal(t, host, specHost)
+
+	// Any change to the configuration changes the hash.
+	same, _ := s.serviceConfig(app, web)
+	assert.Equal(t, config.Labels[ConfigLabel], same.Labels[ConfigLabel])
+
+	app.Image = "remind101/acme-inc:v2"
+	changed, _ := s.serviceConfig(app, web)
+	assert.NotEqual(t, config.Labels[ConfigLabel], changed.Labels[ConfigLabel])
+}
+
+func TestScheduler_Plan(t *testing.T) {
+	c := new(mockDockerClient)
+	s := newScheduler(c)
+	defer s.Close()
+
+	v1 := twelvefactor.App{ID: "app", Image: "remind101/acme-inc:v1", Env: map[string]string{"FOO": "bar"}}
+	v2 := twelvefactor.App{ID: "app", Image: "remind101/acme-inc:v2", Env: map[string]string{"FOO": "baz"}}
+	web := twelvefactor.Process{Name: "web", DesiredCount: 2, Memory: 512 * bytesize.MiB}
+	worker := twelvefactor.Process{Name: "worker", DesiredCount: 1}
+	api := twelvefactor.Process{Name: "api", DesiredCount: 1}
+	cleanup := twelvefactor.Process{Name: "cleanup", Schedule: "@daily", DesiredCount: 1}
+	clock := twelvefactor.Process{Name: "clock", DesiredCount: 1}
+
+	current, _ := s.serviceConfig(v2, web)
+	old, _ := s.serviceConfig(v1, worker)
+	removed, _ := s.serviceConfig(v1, api)
+	c.On("ListContainers", docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": {"com.remind101.12factor.app=app"},
+		},
+	}).Return([]docker.APIContainers{
+		// One of the web containers was stopped by hand.
+		{ID: "web.1", State: "running", Labels: current.Labels},
+		{ID: "web.2", State: "exited", Labels: current.Labels},
+		{ID: "worker.1", State: "running", Labels: old.Labels},
+		{ID: "api.1", State: "running", Labels: removed.Labels},
+		// Containers started by RunProcess aren't part of a process.
+		{ID: "run.1", State: "running", Labels: map[string]string{AppLabel: "app", ProcessLabel: "run"}},
+	}, nil)
+	assert.NoError(t, s.cron.Schedule(v1, cleanup))
+
+	hourly := cleanup
+	hourly.Schedule = "@hourly"
+	plan, err := s.Plan(v2, web, worker, hourly, clock)
+	assert.NoError(t, err)
+	assert.Equal(t, &twelvefactor.Plan{
+		App: "app",
+		Changes: []twelvefactor.ProcessChange{
+			{
+				Process: "web",
+				Action:  twelvefactor.ChangeUpdate,
+				Scale:   &twelvefactor.ScaleChange{From: 1, To: 2},
+			},
+			{
+				Process: "worker",
+				Action:  twelvefactor.ChangeUpdate,
+				Fields: []twelvefactor.FieldChange{
+					{Field: "image", Old: "remind101/acme-inc:v1", New: "remind101/acme-inc:v2"},
+				},
+				Env: []twelvefactor.EnvChange{{Name: "FOO", Action: twelvefactor.ChangeUpdate}},
+			},
+			{
+				Process: "cleanup",
+				Action:  twelvefactor.ChangeUpdate,
+				Fields: []twelvefactor.FieldChange{
+					{Field: "image", Old: "remind101/acme-inc:v1", New: "remind101/acme-inc:v2"},
+					{Field: "schedule", Old: "@daily", New: "@hourly"},
+				},
+				Env: []twelvefactor.EnvChange{{Name: "FOO", Action: twelvefactor.ChangeUpdate}},
+			},
+			{
+				Process: "clock",
+				Action:  twelvefactor.ChangeCreate,
+				Fields: []twelvefactor.FieldChange{
+					{Field: "image", New: "remind101/acme-inc:v2"},
+				},
+				Env:   []twelvefactor.EnvChange{{Name: "FOO", Action: twelvefactor.ChangeCreate}},
+				Scale: &twelvefactor.ScaleChange{From: 0, To: 1},
+			},
+			{Process: "api", Action: twelvefactor.ChangeDelete},
+		},
+	}, plan)
+
+	// Processes that are up to date are left out.
+	web.DesiredCount = 1
+	plan, err = s.Plan(v2, web)
+	assert.NoError(t, err)
+	assert.Equal(t, []twelvefactor.ProcessChange{
+		{Process: "api", Action: twelvefactor.ChangeDelete},
+		{Process: "cleanup", Action: twelvefactor.ChangeDelete},
+		{Process: "worker", Action: twelvefactor.ChangeDelete},
+	}, plan.Changes)
+
+	// Invalid processes are rejected, like they are by Run.
+	_, err = s.Plan(v2, twelvefactor.Process{Name: "web", Schedule: "bogus"})
+	assert.True(t, errors.Is(err, twelvefactor.ErrInvalidConfig))
+
+	c.AssertExpectations(t)
+}
+
+func TestScheduler_Remove(t *testing.T) {
+	c := new(mockDockerClient)
+	s := newScheduler(c)
+	defer s.Close()
+
+	appFilter := docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": {"com.remind101.12factor.app=app"},
+		},
+	}
+	c.On("ListContainers", appFilter).Return([]docker.APIContainers{
+		{ID: "web"},
+		{ID: "cron", Labels: map[string]string{TriggeredAtLabel: "2015-10-14T04:30:00Z"}},
+	}, nil).Once()
+	c.On("StopContainer", "web", uint(stopTimeout)).Return(nil).Once()
+	c.On("RemoveContainer", docker.RemoveContainerOptions{ID: "web"}).Return(nil).Once()
+	c.On("StopContainer", "cron", uint(stopTimeout)).Return(&docker.ContainerNotRunning{ID: "cron"}).Once()
+	c.On("RemoveContainer", docker.RemoveContainerOptions{ID: "cron"}).Return(nil).Once()
+
+	assert.NoError(t, s.cron.Schedule(twelvefactor.App{ID: "app"}, twelvefactor.Process{Name: "cleanup", Schedule: "@daily"}))
+	assert.NoError(t, s.Remove("app"))
+	assert.False(t, s.cron.Has("app", ""))
+
+	// Once it's gone, it can't be found.
+	c.On("ListContainers", appFilter).Return([]docker.APIContainers{}, nil).Once()
+	err := s.Remove("app")
+	assert.True(t, errors.Is(err, twelvefactor.ErrAppNotFound))
+
+	c.AssertExpectations(t)
+}
+
+func TestScheduler_ScaleProcess(t *testing.T) {
+	c := new(mockDockerClient)
+	s := newScheduler(c)
+	defer s.Close()
+
+	app := twelvefactor.App{ID: "app", Image: "remind101/acme-inc"}
+	config, host := s.serviceConfig(app, twelvefactor.Process{Name: "web", DesiredCount: 1})
+
+	serviceFilter := docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": {"com.remind101.12factor.service=app/web"},
+		},
+	}
+	c.On("ListContainers", serviceFilter).Return([]docker.APIContainers{
+		{ID: "web", State: "running", Labels: config.Labels},
+	}, nil).Once()
+
+	// New containers get the same configuration as the existing ones.
+	c.On("CreateContainer", docker.CreateContainerOptions{
+		Config:     config,
+		HostConfig: host,
+	}).Return(&docker.Container{ID: "new"}, nil).Twice()
+	c.On("StartContainer", "new", (*docker.HostConfig)(nil)).Return(nil).Twice()
+
+	assert.NoError(t, s.ScaleProcess("app", "web", 3))
+
+	// A process without any containers can't be scaled, since its
+	// configuration is unknown.
+	c.On("ListContainers", serviceFilter).Return([]docker.APIContainers{}, nil).Once()
+	err := s.ScaleProcess("app", "web", 1)
+	assert.True(t, errors.Is(err, twelvefactor.ErrProcessNotFound))
+
+	// Scheduled processes are scaled without touching any containers.
+	cleanup := twelvefactor.Process{Name: "cleanup", Schedule: "@daily", DesiredCount: 1}
+	assert.NoError(t, s.cron.Schedule(app, cleanup))
+	assert.NoError(t, s.ScaleProcess("app", "cleanup", 2))
+	assert.Equal(t, 2, s.cron.apps["app"].processes[0].DesiredCount)
+
+	c.AssertExpectations(t)
+}
+
+func TestScheduler_Restart(t *testing.T) {
+	c := new(mockDockerClient)
+	s := newScheduler(c)
+	defer s.Close()
+
+	c.On("ListContainers", docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": {"com.remind101.12factor.app=app"},
+		},
+	}).Return([]docker.APIContainers{
+		{ID: "web"},
+		{ID: "cron", Labels: map[string]string{TriggeredAtLabel: "2015-10-14T04:30:00Z"}},
+	}, nil).Once()
+	c.On("RestartContainer", "web", uint(stopTimeout)).Return(nil).Once()
+
+	assert.NoError(t, s.Restart("app"))
+
+	c.On("ListContainers", docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": {"com.remind101.12factor.app=unknown"},
+		},
+	}).Return([]docker.APIContainers{}, nil).Once()
+	err := s.Restart("unknown")
+	assert.True(t, errors.Is(err, twelvefactor.ErrAppNotFound))
+
+	c.AssertExpectations(t)
+}
+
+func TestScheduler_RestartProcess(t *testing.T) {
+	c := new(mockDockerClient)
+	s := newScheduler(c)
+	defer s.Close()
+
+	c.On("ListContainers", docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": {"com.remind101.12factor.service=app/web"},
+		},
+	}).Return([]docker.APIContainers{{ID: "web.1"}, {ID: "web.2"}}, nil).Once()
+	c.On("RestartContainer", "web.1", uint(stopTimeout)).Return(nil).Once()
+	c.On("RestartContainer", "web.2", uint(stopTimeout)).Return(nil).Once()
+
+	assert.NoError(t, s.RestartProcess("app", "web"))
+
+	c.On("ListContainers", docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": {"com.remind101.12factor.service=app/worker"},
+		},
+	}).Return([]docker.APIContainers{}, nil).Once()
+	err := s.RestartProcess("app", "worker")
+	assert.True(t, errors.Is(err, twelvefactor.ErrProcessNotFound))
+
+	c.AssertExpectations(t)
+}
+
+func TestScheduler_StopTask(t *testing.T) {
+	c := new(mockDockerClient)
+	s := newScheduler(c)
+	defer s.Close()
+
+	config, host := s.serviceConfig(twelvefactor.App{ID: "app"}, twelvefactor.Process{Name: "web"})
+
+	// Containers for long running processes are replaced.
+	c.On("InspectContainer", "web").Return(&docker.Container{ID: "web", Config: config}, nil).Once()
+	c.On("StopContainer", "web", uint(stopTimeout)).Return(nil).Once()
+	c.On("RemoveContainer", docker.RemoveContainerOptions{ID: "web"}).Return(nil).Once()
+	c.On("CreateContainer", docker.CreateContainerOptions{
+		Config:     config,
+		HostConfig: host,
+	}).Return(&docker.Container{ID: "new"}, nil).Once()
+	c.On("StartContainer", "new", (*docker.HostConfig)(nil)).Return(nil).Once()
+
+	assert.NoError(t, s.StopTask("web"))
+
+	// Other containers are only stopped.
+	c.On("InspectContainer", "run").Return(&docker.Container{ID: "run", Config: &docker.Config{}}, nil).Once()
+	c.On("StopContainer", "run", uint(stopTimeout)).Return(nil).Once()
+
+	assert.NoError(t, s.StopTask("run"))
+
+	c.On("InspectContainer", "unknown").Return((*docker.Container)(nil), &docker.NoSuchContainer{ID: "unknown"}).Once()
+	err := s.StopTask("unknown")
+	assert.True(t, errors.Is(err, twelvefactor.ErrTaskNotFound))
+
+	c.AssertExpectations(t)
+}
+
+func TestScheduler_Run_Schedule(t *testing.T) {
+	c := new(mockDockerClient)
+	s := newScheduler(c)
+	defer s.Close()
+
+	triggered := make(chan struct{})
+	c.On("ListContainers", docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": {"com.remind101.12factor.app=app"},
+		},
+	}).Return([]docker.APIContainers{}, nil)
+	c.On("CreateContainer", mock.Anything).Return(&docker.Container{ID: "abcd"}, nil)
+	c.On("StartContainer", "abcd", (*docker.HostConfig)(nil)).Return(nil).Run(func(mock.Arguments) {
+		close(triggered)
+	})
+
+	app := twelvefactor.App{ID: "app"}
+	err := s.Run(app, twelvefactor.Process{
+		Name:         "cleanup",
+		Schedule:     "@every 1s",
+		DesiredCount: 1,
+	})
+	assert.NoError(t, err)
+
+	select {
+	case <-triggered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for scheduled process to run")
+	}
+}
+
+func TestCronRunner_UTC(t *testing.T) {
+	triggered := make(chan time.Time, 1)
+	r := newCronRunner(func(app twelvefactor.App, process twelvefactor.Process, at time.Time) error {
+		select {
+		case triggered <- at:
+		default:
+		}
+		return nil
+	})
+	defer r.Stop()
+
+	// A second before 07:00 UTC, which is midnight in PDT. If the schedule
+	// was evaluated in local time, it wouldn't fire for another 7 hours.
+	pdt := time.FixedZone("PDT", -7*60*60)
+	r.now = func() time.Time { return time.Date(2015, time.October, 13, 23, 59, 59, 0, pdt) }
+
+	err := r.Schedule(twelvefactor.App{ID: "app"}, twelvefactor.Process{Name: "cleanup", Schedule: "0 7 * * *"})
+	assert.NoError(t, err)
+
+	select {
+	case at := <-triggered:
+		assert.Equal(t, time.Date(2015, time.October, 14, 7, 0, 0, 0, time.UTC), at)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for scheduled process to run")
+	}
+}
+
+func TestScheduler_Run_InvalidSchedule(t *testing.T) {
+	s := newScheduler(new(mockDockerClient))
+	defer s.Close()
+
+	err := s.Run(twelvefactor.App{ID: "app"}, twelvefactor.Process{
+		Name:     "cleanup",
+		Schedule: "bogus",
+	})
+	assert.True(t, errors.Is(err, twelvefactor.ErrInvalidConfig))
+}
+
+func TestScheduler_Run_InvalidCPU(t *testing.T) {
+	s := newScheduler(new(mockDockerClient))
+	defer s.Close()
+
+	err := s.Run(twelvefactor.App{ID: "app"}, twelvefactor.Process{
+		Name:     "web",
+		CPU:      cpu.VCPU,
+		CPULimit: 500 * cpu.MilliCPU,
+	})
+	assert.EqualError(t, err, "cpu reservation for web (1) exceeds its limit (500m)")
+}
+
+func TestScheduler_Run_InvalidMemory(t *testing.T) {
+	s := newScheduler(new(mockDockerClient))
+	defer s.Close()
+
+	err := s.Run(twelvefactor.App{ID: "app"}, twelvefactor.Process{
+		Name:              "web",
+		Memory:            256 * bytesize.MiB,
+		MemoryReservation: 512 * bytesize.MiB,
+	})
+	assert.EqualError(t, err, "memory reservation for web (512MiB) exceeds its limit (256MiB)")
+}
+
+func TestHealthConfig(t *testing.T) {
+	assert.Nil(t, healthConfig(nil))
+
+	assert.Equal(t, &docker.HealthConfig{
+		Test:     []string{"CMD", "curl", "-f", "http://localhost/health"},
+		Interval: 30 * time.Second,
+		Retries:  3,
+	}, healthConfig(&twelvefactor.HealthCheck{
+		Command:  []string{"curl", "-f", "http://localhost/health"},
+		Interval: 30 * time.Second,
+		Retries:  3,
+	}))
+}
+
+func TestHostConfig(t *testing.T) {
+	s := new(Scheduler)
+	assert.Equal(t, &docker.HostConfig{
+		CPUShares:         256,
+		NanoCPUs:          1500000000,
+		Memory:            1073741824,
+		MemoryReservation: 536870912,
+	}, s.hostConfig(twelvefactor.Process{
+		CPU:               250 * cpu.MilliCPU,
+		CPULimit:          1500 * cpu.MilliCPU,
+		Memory:            bytesize.GiB,
+		MemoryReservation: 512 * bytesize.MiB,
+	}))
+
+	assert.Equal(t, &docker.HostConfig{
+		CPUShares: 100,
+	}, s.hostConfig(twelvefactor.Process{
+		CPUShares: 100,
+	}))
+
+	// Memory is rounded to whole pages.
+	assert.Equal(t, &docker.HostConfig{
+		Memory: 500002816,
+	}, s.hostConfig(twelvefactor.Process{
+		Memory: 500 * bytesize.Megabyte,
+	}))
+
+	s.MemoryRounding = bytesize.RoundDown
+	assert.Equal(t, &docker.HostConfig{
+		Memory: 499998720,
+	}, s.hostConfig(twelvefactor.Process{
+		Memory: 500 * bytesize.Megabyte,
+	}))
+}
+
+func TestValidateMemory(t *testing.T) {
+	tests := []struct {
+		process twelvefactor.Process
+		err     string
+	}{
+		{twelvefactor.Process{Name: "web"}, ""},
+		{twelvefactor.Process{Name: "web", Memory: bytesize.GiB, MemoryReservation: 512 * bytesize.MiB}, ""},
+		{twelvefactor.Process{Name: "web", Memory: bytesize.MiB}, "memory limit for web must be at least 6MiB, got 1MiB"},
+		{twelvefactor.Process{Name: "web", Memory: bytesize.MiB, MemoryReservation: bytesize.GiB}, "memory limit for web must be at least 6MiB, got 1MiB"},
+		{twelvefactor.Process{Name: "web", Memory: 512 * bytesize.MiB, MemoryReservation: bytesize.GiB}, "memory reservation for web (1GiB) exceeds its limit (512MiB)"},
+	}
+
+	for i, tt := range tests {
+		err := validateMemory(tt.process)
+		if tt.err == "" {
+			assert.NoError(t, err, "#%d", i)
+		} else {
+			assert.EqualError(t, err, tt.err, "#%d", i)
+		}
+	}
+}
+
+func TestScheduler_trigger(t *testing.T) {
+	c := new(mockDockerClient)
+	s := newScheduler(c)
+
+	at := time.Date(2015, time.October, 14, 4, 30, 0, 0, time.UTC)
+	c.On("CreateContainer", docker.CreateContainerOptions{
+		Config: &docker.Config{
+			Labels: map[string]string{
+				"com.remind101.12factor.app":          "app",
+				"com.remind101.12factor.process":      "cleanup",
+				"com.remind101.12factor.version":      "v1",
+				"com.remind101.12factor.service":      "app/cleanup",
+				"com.remind101.12factor.triggered-at": "2015-10-14T04:30:00Z",
+			},
+		},
+		HostConfig: &docker.HostConfig{},
+	}).Return(&docker.Container{ID: "abcd"}, nil).Twice()
+	c.On("StartContainer", "abcd", (*docker.HostConfig)(nil)).Return(nil).Twice()
+
+	err := s.trigger(twelvefactor.App{ID: "app", Version: "v1"}, twelvefactor.Process{
+		Name:         "cleanup",
+		DesiredCount: 2,
+	}, at)
+	assert.NoError(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestScheduler_Tasks(t *testing.T) {
+	c := new(mockDockerClient)
+	s := newScheduler(c)
+
+	c.On("ListContainers", docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": {"com.remind101.12factor.app=app"},
+		},
+	}).Return([]docker.APIContainers{
+		{
+			ID:      "abcd",
+			State:   "running",
+			Created: 1444797000,
+			Labels: map[string]string{
+				"com.remind101.12factor.process":      "cleanup",
+				"com.remind101.12factor.version":      "v1",
+				"com.remind101.12factor.triggered-at": "2015-10-14T04:30:00Z",
+			},
+		},
+	}, nil)
+	c.On("InspectContainer", "abcd").Return(&docker.Container{
+		ID: "abcd",
+		State: docker.State{
+			Running:   true,
+			StartedAt: time.Date(2015, time.October, 14, 4, 30, 1, 0, time.UTC),
+			Health:    docker.Health{Status: "healthy"},
+		},
+		NetworkSettings: &docker.NetworkSettings{
+			Networks: map[string]docker.ContainerNetwork{
+				"bridge": {IPAddress: "172.17.0.2"},
+			},
+		},
+	}, nil)
+	c.On("Info").Return(&docker.DockerInfo{Name: "docker-host"}, nil)
+
+	tasks, err := s.Tasks("app")
+	assert.NoError(t, err)
+	assert.Equal(t, []twelvefactor.Task{
+		{
+			ID:          "abcd",
+			Version:     "v1",
+			Process:     "cleanup",
+			State:       "running",
+			Time:        time.Unix(1444797000, 0),
+			TriggeredAt: time.Date(2015, time.October, 14, 4, 30, 0, 0, time.UTC),
+			Health:      twelvefactor.HealthHealthy,
+			Host:        "docker-host",
+			IPAddresses: []string{"172.17.0.2"},
+			StartedAt:   time.Date(2015, time.October, 14, 4, 30, 1, 0, time.UTC),
+		},
+	}, tasks)
+}
+
+func TestInspectTask(t *testing.T) {
+	started := time.Date(2015, time.October, 14, 4, 30, 0, 0, time.UTC)
+	finished := started.Add(time.Minute)
+
+	tests := []struct {
+		state      docker.State
+		exitCode   int
+		stopReason string
+	}{
+		{docker.State{StartedAt: started, FinishedAt: finished, ExitCode: 1}, 1, "exited with code 1"},
+		{docker.State{StartedAt: started, FinishedAt: finished, ExitCode: 137, OOMKilled: true}, 137, "out of memory"},
+		{docker.State{StartedAt: started, FinishedAt: finished, ExitCode: 127, Error: "executable file not found"}, 127, "executable file not found"},
+	}
+
+	for i, tt := range tests {
+		var task twelvefactor.Task
+		inspectTask(&task, &docker.Container{
+			State: tt.state,
+			Node:  &docker.SwarmNode{Name: "node-1"},
+		})
+
+		if assert.NotNil(t, task.ExitCode, "#%d", i) {
+			assert.Equal(t, tt.exitCode, *task.ExitCode, "#%d", i)
+		}
+		assert.Equal(t, tt.stopReason, task.StopReason, "#%d", i)
+		assert.Equal(t, started, task.StartedAt, "#%d", i)
+		assert.Equal(t, finished, task.StoppedAt, "#%d", i)
+		assert.Equal(t, "node-1", task.Host, "#%d", i)
+	}
+}
+
+func TestTranslateError(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind error
+	}{
+		{&docker.NoSuchContainer{ID: "1234"}, twelvefactor.ErrTaskNotFound},
+		{docker.ErrNoSuchImage, twelvefactor.ErrInvalidConfig},
+		{docker.ErrContainerAlreadyExists, twelvefactor.ErrConflict},
+		{&docker.Error{Status: 400, Message: "bad request"}, twelvefactor.ErrInvalidConfig},
+		{&docker.Error{Status: 409, Message: "conflict"}, twelvefactor.ErrConflict},
+	}
+
+	for i, tt := range tests {
+		assert.True(t, errors.Is(translateError(tt.err), tt.kind), "#%d", i)
+	}
+
+	err := errors.New("boom")
+	assert.Equal(t, err, translateError(err))
+}
+
+func TestContainerConfig(t *testing.T) {
+	app := twelvefactor.App{
+		ID:      "app",
+		Image:   "remind101/acme-inc",
+		Version: "v1",
+		Env: map[string]string{
+			"RAILS_ENV": "production",
+		},
+	}
+
+	process := twelvefactor.Process{
+		Name:    "worker",
+		Command: []string{"acme-inc", "worker"},
+		Labels: map[string]string{
+			"team": "core",
+		},
+		Placement: twelvefactor.Placement{
+			Constraints: []twelvefactor.PlacementConstraint{
+				{Type: twelvefactor.DistinctInstance},
+				{Type: twelvefactor.MemberOf, Attribute: "node", Operator: "!=", Value: "db1"},
+			},
+			Strategies: []twelvefactor.PlacementStrategy{
+				{Type: twelvefactor.Spread, Field: twelvefactor.FieldAvailabilityZone},
+			},
+		},
+	}
+
+	config := containerConfig(app, process)
+	assert.Equal(t, &docker.Config{
+		Image: "remind101/acme-inc",
+		Cmd:   []string{"acme-inc", "worker"},
+		Env: []string{
+			"RAILS_ENV=production",
+			"affinity:com.remind101.12factor.service!=app/worker",
+			"constraint:node!=db1",
+		},
+		Labels: map[string]string{
+			"team":                           "core",
+			"com.remind101.12factor.app":     "app",
+			"com.remind101.12factor.process": "worker",
+			"com.remind101.12factor.version": "v1",
+			"com.remind101.12factor.service": "app/worker",
+		},
+	}, config)
+}
+
+func TestScheduler_StreamLogs(t *testing.T) {
+	c := new(mockDockerClient)
+	s := newScheduler(c)
+
+	c.On("ListContainers", docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": {"com.remind101.12factor.app=app", "com.remind101.12factor.process=web"},
+		},
+	}).Return([]docker.APIContainers{
+		{
+			ID: "0b69d5c0d6554695",
+			Labels: map[string]string{
+				"com.remind101.12factor.process": "web",
+			},
+		},
+	}, nil)
+	c.On("Logs", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		opts := args.Get(0).(docker.LogsOptions)
+		assert.Equal(t, "0b69d5c0d6554695", opts.Container)
+		assert.True(t, opts.Timestamps)
+		opts.OutputStream.Write([]byte("2015-10-14T04:30:00.5Z Started GET /\n2015-10-14T04:30:01Z Compl"))
+		opts.OutputStream.Write([]byte("eted 200 OK\n2015-10-14T04:31:00Z Started GET /health\n"))
+	})
+
+	w := new(bytes.Buffer)
+	err := s.StreamLogs(w, twelvefactor.LogsOptions{
+		App:     "app",
+		Process: "web",
+		Until:   time.Date(2015, time.October, 14, 4, 30, 30, 0, time.UTC),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "web/0b69d5c0d655: Started GET /\nweb/0b69d5c0d655: Completed 200 OK\n", w.String())
+}
+
+func TestScheduler_RunProcess_Detached(t *testing.T) {
+	c := new(mockDockerClient)
+	s := newScheduler(c)
+	defer s.Close()
+
+	app := twelvefactor.App{ID: "app", Image: "remind101/acme-inc", Version: "v2", Env: map[string]string{"RAILS_ENV": "production"}}
+	web, _ := s.serviceConfig(app, twelvefactor.Process{Name: "web", Command: []string{"acme-inc", "web"}})
+
+	c.On("ListContainers", docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": {"com.remind101.12factor.app=app"},
+		},
+	}).Return([]docker.APIContainers{{ID: "web", Labels: web.Labels}}, nil)
+	c.On("CreateContainer", mock.Anything).Return(&docker.Container{ID: "migrate"}, nil)
+	c.On("StartContainer", "migrate", (*docker.HostConfig)(nil)).Return(nil)
+
+	err := s.RunProcess("app", twelvefactor.Process{
+		Name:    "migrate",
+		Command: []string{"rake", "db:migrate"},
+		Env:     map[string]string{"VERBOSE": "1"},
+	})
+	assert.NoError(t, err)
+
+	opts := c.Calls[1].Arguments.Get(0).(docker.CreateContainerOptions)
+	assert.Equal(t, "remind101/acme-inc", opts.Config.Image)
+	assert.Equal(t, []string{"rake", "db:migrate"}, opts.Config.Cmd)
+	assert.Equal(t, []string{"RAILS_ENV=production", "VERBOSE=1"}, opts.Config.Env)
+	assert.Equal(t, "migrate", opts.Config.Labels[ProcessLabel])
+	assert.Equal(t, "v2", opts.Config.Labels[VersionLabel])
+	assert.NotContains(t, opts.Config.Labels, ServiceLabel)
+	assert.NotContains(t, opts.Config.Labels, SpecLabel)
+	assert.Equal(t, docker.RestartPolicy{}, opts.HostConfig.RestartPolicy)
+	assert.False(t, opts.Config.AttachStdout)
+}
+
+func TestScheduler_RunProcess_Attached(t *testing.T) {
+	c := new(mockDockerClient)
+	s := newScheduler(c)
+	defer s.Close()
+
+	app := twelvefactor.App{ID: "app", Image: "remind101/acme-inc"}
+	web, _ := s.serviceConfig(app, twelvefactor.Process{Name: "web"})
+
+	stdin, stdout := new(bytes.Buffer), new(bytes.Buffer)
+	c.On("ListContainers", mock.Anything).Return([]docker.APIContainers{{ID: "web", Labels: web.Labels}}, nil)
+	c.On("CreateContainer", mock.Anything).Return(&docker.Container{ID: "console"}, nil)
+	c.On("AttachToContainer", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		opts := args.Get(0).(docker.AttachToContainerOptions)
+		assert.Equal(t, "console", opts.Container)
+		assert.Equal(t, stdin, opts.InputStream)
+		assert.True(t, opts.Stdin)
+
+		// Simulate the hijacked connection being established.
+		opts.Success <- struct{}{}
+		<-opts.Success
+
+		opts.OutputStream.Write([]byte("Loading production environment\n"))
+	})
+	c.On("StartContainer", "console", (*docker.HostConfig)(nil)).Return(nil)
+	c.On("WaitContainer", "console").Return(1, nil)
+	c.On("RemoveContainer", docker.RemoveContainerOptions{ID: "console", Force: true}).Return(nil)
+
+	err := s.RunProcess("app", twelvefactor.Process{
+		Name:    "run",
+		Command: []string{"rails", "console"},
+		Stdin:   stdin,
+		Stdout:  stdout,
+	})
+	assert.EqualError(t, err, "run exited with code 1")
+	assert.Equal(t, "Loading production environment\n", stdout.String())
+
+	opts := c.Calls[1].Arguments.Get(0).(docker.CreateContainerOptions)
+	assert.True(t, opts.Config.AttachStdout)
+	assert.True(t, opts.Config.OpenStdin)
+	c.AssertExpectations(t)
+}
+
+func TestScheduler_RunProcess_AppNotFound(t *testing.T) {
+	c := new(mockDockerClient)
+	s := newScheduler(c)
+	defer s.Close()
+
+	c.On("ListContainers", mock.Anything).Return([]docker.APIContainers{}, nil)
+
+	err := s.RunProcess("app", twelvefactor.Process{Name: "run"})
+	assert.True(t, errors.Is(err, twelvefactor.ErrAppNotFound))
+}
+
+func TestScheduler_Exec(t *testing.T) {
+	c := new(mockDockerClient)
+	s := newScheduler(c)
+
+	resize := make(chan twelvefactor.TerminalSize, 1)
+	resize <- twelvefactor.TerminalSize{Width: 120, Height: 40}
+
+	stdin, stdout := new(bytes.Buffer), new(bytes.Buffer)
+	c.On("CreateExec", docker.CreateExecOptions{
+		Container:    "abcd",
+		Cmd:          []string{"bash"},
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          true,
+	}).Return(&docker.Exec{ID: "exec"}, nil)
+	c.On("ResizeExecTTY", "exec", 24, 80).Return(nil)
+	c.On("ResizeExecTTY", "exec", 40, 120).Return(nil)
+	c.On("StartExec", "exec", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		opts := args.Get(1).(docker.StartExecOptions)
+		assert.Equal(t, stdin, opts.InputStream)
+		assert.Equal(t, stdout, opts.OutputStream)
+		assert.True(t, opts.RawTerminal)
+
+		// Simulate the hijacked connection being established.
+		opts.Success <- struct{}{}
+		<-opts.Success
+
+		// Wait for the resize to be processed.
+		for len(resize) > 0 {
+			time.Sleep(time.Millisecond)
+		}
+	})
+	c.On("InspectExec", "exec").Return(&docker.ExecInspect{ExitCode: 3}, nil)
+
+	code, err := s.Exec("abcd", []string{"bash"}, stdin, stdout, &twelvefactor.TTY{
+		Size:   twelvefactor.TerminalSize{Width: 80, Height: 24},
+		Resize: resize,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, code)
+	c.AssertCalled(t, "ResizeExecTTY", "exec", 24, 80)
+}
+
+// mockDockerClient is an implementation of the dockerClient interface for
+// testing.
+type mockDockerClient struct {
+	mock.Mock
+}
+
+func (c *mockDockerClient) CreateContainer(opts docker.CreateContainerOptions) (*docker.Container, error) {
+	args := c.Called(opts)
+	return args.Get(0).(*docker.Container), args.Error(1)
+}
+
+func (c *mockDockerClient) StartContainer(id string, hostConfig *docker.HostConfig) error {
+	args := c.Called(id, hostConfig)
+	return args.Error(0)
+}
+
+func (c *mockDockerClient) StopContainer(id string, timeout uint) error {
+	args := c.Called(id, timeout)
+	return args.Error(0)
+}
+
+func (c *mockDockerClient) RestartContainer(id string, timeout uint) error {
+	args := c.Called(id, timeout)
+	return args.Error(0)
+}
+
+func (c *mockDockerClient) RemoveContainer(opts docker.RemoveContainerOptions) error {
+	args := c.Called(opts)
+	return args.Error(0)
+}
+
+func (c *mockDockerClient) InspectContainer(id string) (*docker.Container, error) {
+	args := c.Called(id)
+	return args.Get(0).(*docker.Container), args.Error(1)
+}
+
+func (c *mockDockerClient) AttachToContainer(opts docker.AttachToContainerOptions) error {
+	args := c.Called(opts)
+	return args.Error(0)
+}
+
+func (c *mockDockerClient) WaitContainer(id string) (int, error) {
+	args := c.Called(id)
+	return args.Int(0), args.Error(1)
+}
+
+func (c *mockDockerClient) Info() (*docker.DockerInfo, error) {
+	args := c.Called()
+	return args.Get(0).(*docker.DockerInfo), args.Error(1)
+}
+
+func (c *mockDockerClient) ListContainers(opts docker.ListContainersOptions) ([]docker.APIContainers, error) {
+	args := c.Called(opts)
+	return args.Get(0).([]docker.APIContainers), args.Error(1)
+}
+
+func (c *mockDockerClient) Logs(opts docker.LogsOptions) error {
+	args := c.Called(opts)
+	return args.Error(0)
+}
+
+func (c *mockDockerClient) CreateExec(opts docker.CreateExecOptions) (*docker.Exec, error) {
+	args := c.Called(opts)
+	return args.Get(0).(*docker.Exec), args.Error(1)
+}
+
+func (c *mockDockerClient) StartExec(id string, opts docker.StartExecOptions) error {
+	args := c.Called(id, opts)
+	return args.Error(0)
+}
+
+func (c *mockDockerClient) ResizeExecTTY(id string, height, width int) error {
+	args := c.Called(id, height, width)
+	return args.Error(0)
+}
+
+func (c *mockDockerClient) InspectExec(id string) (*docker.ExecInspect, error) {
+	args := c.Called(id)
+	return args.Get(0).(*docker.ExecInspect), args.Error(1)
+}
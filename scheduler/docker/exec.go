@@ -0,0 +1,74 @@
+package docker
+
+import (
+	"io"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/remind101/12factor"
+)
+
+// Exec runs the command inside of the container using the exec API, and
+// returns its exit code.
+func (s *Scheduler) Exec(taskID string, command []string, stdin io.Reader, stdout io.Writer, tty *twelvefactor.TTY) (int, error) {
+	exec, err := s.docker.CreateExec(docker.CreateExecOptions{
+		Container:    taskID,
+		Cmd:          command,
+		AttachStdin:  stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          tty != nil,
+	})
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	opts := docker.StartExecOptions{
+		InputStream:  stdin,
+		OutputStream: stdout,
+		ErrorStream:  stdout,
+		Tty:          tty != nil,
+		RawTerminal:  tty != nil,
+	}
+
+	done := make(chan struct{})
+	if tty != nil {
+		// The exec can only be resized once it's running, which is
+		// signaled on the Success channel.
+		opts.Success = make(chan struct{})
+		go func() {
+			select {
+			case <-opts.Success:
+			case <-done:
+				return
+			}
+
+			s.docker.ResizeExecTTY(exec.ID, tty.Size.Height, tty.Size.Width)
+			opts.Success <- struct{}{}
+
+			for {
+				select {
+				case size, ok := <-tty.Resize:
+					if !ok {
+						return
+					}
+					s.docker.ResizeExecTTY(exec.ID, size.Height, size.Width)
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	err = s.docker.StartExec(exec.ID, opts)
+	close(done)
+	if err != nil {
+		return 0, err
+	}
+
+	inspect, err := s.docker.InspectExec(exec.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	return inspect.ExitCode, nil
+}
@@ -0,0 +1,168 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/remind101/12factor"
+)
+
+// StreamLogs writes the logs for the app, process or task to w, using the
+// container logs API. Each line is prefixed with the process and container
+// that it came from.
+func (s *Scheduler) StreamLogs(w io.Writer, opts twelvefactor.LogsOptions) error {
+	labels := []string{fmt.Sprintf("%s=%s", AppLabel, opts.App)}
+	if opts.Process != "" {
+		labels = append(labels, fmt.Sprintf("%s=%s", ProcessLabel, opts.Process))
+	}
+
+	filters := map[string][]string{"label": labels}
+	if opts.Task != "" {
+		filters["id"] = []string{opts.Task}
+	}
+
+	containers, err := s.docker.ListContainers(docker.ListContainersOptions{
+		All:     true,
+		Filters: filters,
+	})
+	if err != nil {
+		return translateError(err)
+	}
+
+	// The logs API has no way to stop following at a point in time, so we
+	// cancel the request instead.
+	ctx := context.Background()
+	if opts.Follow && !opts.Until.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, opts.Until)
+		defer cancel()
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make(chan error, len(containers))
+	)
+	for _, c := range containers {
+		lw := &logWriter{
+			mu:     &mu,
+			w:      w,
+			prefix: fmt.Sprintf("%s/%s", c.Labels[ProcessLabel], shortID(c.ID)),
+			until:  opts.Until,
+		}
+
+		o := docker.LogsOptions{
+			Context:      ctx,
+			Container:    c.ID,
+			OutputStream: lw,
+			ErrorStream:  lw,
+			Stdout:       true,
+			Stderr:       true,
+			Follow:       opts.Follow,
+			Timestamps:   !opts.Until.IsZero(),
+		}
+		if !opts.Since.IsZero() {
+			o.Since = opts.Since.Unix()
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.docker.Logs(o)
+			if ctx.Err() == context.DeadlineExceeded {
+				err = nil
+			}
+			if ferr := lw.Flush(); err == nil {
+				err = ferr
+			}
+			errs <- err
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// logWriter is an io.Writer that writes complete lines to w, prefixed with
+// prefix. When until is set, each line is expected to start with a timestamp,
+// which is used to drop lines after until and then stripped.
+type logWriter struct {
+	// mu serializes writes to w between containers.
+	mu *sync.Mutex
+	w  io.Writer
+
+	prefix string
+	until  time.Time
+
+	// buf holds the trailing partial line from previous writes.
+	buf []byte
+}
+
+// Write implements the io.Writer interface.
+func (lw *logWriter) Write(p []byte) (int, error) {
+	lw.buf = append(lw.buf, p...)
+	for {
+		i := bytes.IndexByte(lw.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		line := lw.buf[:i]
+		lw.buf = lw.buf[i+1:]
+		if err := lw.writeLine(line); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes out any partial line that's been buffered.
+func (lw *logWriter) Flush() error {
+	if len(lw.buf) == 0 {
+		return nil
+	}
+
+	line := lw.buf
+	lw.buf = nil
+	return lw.writeLine(line)
+}
+
+func (lw *logWriter) writeLine(line []byte) error {
+	if !lw.until.IsZero() {
+		if i := bytes.IndexByte(line, ' '); i >= 0 {
+			if t, err := time.Parse(time.RFC3339Nano, string(line[:i])); err == nil {
+				if t.After(lw.until) {
+					return nil
+				}
+				line = line[i+1:]
+			}
+		}
+	}
+
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	_, err := fmt.Fprintf(lw.w, "%s: %s\n", lw.prefix, line)
+	return err
+}
+
+// shortID returns the truncated form of a container id.
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,314 @@
+package docker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/pkg/bytesize"
+	"github.com/remind101/12factor/pkg/cpu"
+)
+
+// field is the value of a field that's compared when planning.
+type field struct {
+	name, value string
+}
+
+// deployed is the live state of a process.
+type deployed struct {
+	// The configuration that the containers for the process were created
+	// with, or that a scheduled process creates them with.
+	config *docker.Config
+	host   *docker.HostConfig
+
+	// The number of containers for a long running process, or the number
+	// that a scheduled process starts each time it's triggered.
+	count int
+
+	// The schedule of a scheduled process.
+	schedule string
+}
+
+// Plan implements the twelvefactor.Planner interface. Long running processes
+// are compared against the configuration that their containers were created
+// with, which is held by the SpecLabel, and scheduled processes against the
+// processes that the cron runner was last given.
+//
+// Like Run, Plan treats the app's processes that aren't given as removed, so
+// they're included as deletions.
+func (s *Scheduler) Plan(app twelvefactor.App, processes ...twelvefactor.Process) (*twelvefactor.Plan, error) {
+	for _, process := range processes {
+		if err := validate(process); err != nil {
+			return nil, err
+		}
+	}
+
+	services, err := s.processContainers(app.ID)
+	if err != nil {
+		return nil, err
+	}
+	scheduled := s.deployedSchedules(app.ID)
+
+	plan := &twelvefactor.Plan{App: app.ID, Changes: []twelvefactor.ProcessChange{}}
+	for _, process := range processes {
+		var (
+			config *docker.Config
+			host   *docker.HostConfig
+			live   *deployed
+		)
+		if process.Schedule != "" {
+			config, host = containerConfig(app, process), s.hostConfig(process)
+			live = scheduled[process.Name]
+		} else {
+			config, host = s.serviceConfig(app, process)
+			live = deployedService(services[process.Name], config.Labels[ConfigLabel])
+		}
+
+		if change := planChange(process, config, host, live); change != nil {
+			plan.Changes = append(plan.Changes, *change)
+		}
+	}
+
+	given := make(map[string]bool, len(processes))
+	for _, process := range processes {
+		given[process.Name] = true
+	}
+
+	var deleted []string
+	for name := range services {
+		if !given[name] {
+			deleted = append(deleted, name)
+		}
+	}
+	for name := range scheduled {
+		if _, ok := services[name]; !ok && !given[name] {
+			deleted = append(deleted, name)
+		}
+	}
+	sort.Strings(deleted)
+	for _, process := range deleted {
+		plan.Changes = append(plan.Changes, twelvefactor.ProcessChange{Process: process, Action: twelvefactor.ChangeDelete})
+	}
+
+	return plan, nil
+}
+
+// processContainers returns the containers for the long running processes of
+// the app, by process name.
+func (s *Scheduler) processContainers(app string) (map[string][]docker.APIContainers, error) {
+	containers, err := s.appContainers(app)
+	if err != nil {
+		return nil, err
+	}
+
+	byProcess := make(map[string][]docker.APIContainers)
+	for _, c := range containers {
+		if _, ok := c.Labels[TriggeredAtLabel]; ok {
+			continue
+		}
+		if _, ok := c.Labels[ServiceLabel]; !ok {
+			continue
+		}
+		process := c.Labels[ProcessLabel]
+		byProcess[process] = append(byProcess[process], c)
+	}
+	return byProcess, nil
+}
+
+// deployedService returns the live state of a long running process from its
+// containers, or nil if it has none. It's compared against a container that
+// doesn't have the desired ConfigLabel hash, if there is one, since that's
+// what Run would replace. Only running containers are counted.
+func deployedService(containers []docker.APIContainers, hash string) *deployed {
+	if len(containers) == 0 {
+		return nil
+	}
+
+	compare := containers[0]
+	for _, c := range containers {
+		if c.Labels[ConfigLabel] != hash {
+			compare = c
+			break
+		}
+	}
+
+	d := &deployed{config: new(docker.Config), host: new(docker.HostConfig)}
+	if config, host, err := containerSpec(compare.Labels); err == nil {
+		d.config, d.host = config, host
+	} else {
+		// Containers that were created before the SpecLabel was added
+		// can't be compared field by field.
+		d.config.Labels = map[string]string{ConfigLabel: compare.Labels[ConfigLabel]}
+	}
+
+	for _, c := range containers {
+		if running(c) {
+			d.count++
+		}
+	}
+	return d
+}
+
+// deployedSchedules returns the live state of the scheduled processes for the
+// app, by process name.
+func (s *Scheduler) deployedSchedules(app string) map[string]*deployed {
+	a, _ := s.cron.App(app)
+
+	scheduled := make(map[string]*deployed)
+	for _, process := range s.cron.Processes(app) {
+		scheduled[process.Name] = &deployed{
+			config:   containerConfig(a, process),
+			host:     s.hostConfig(process),
+			count:    process.DesiredCount,
+			schedule: process.Schedule,
+		}
+	}
+	return scheduled
+}
+
+// planChange compares the desired configuration for a process against what's
+// deployed, returning nil if nothing would change.
+func planChange(process twelvefactor.Process, config *docker.Config, host *docker.HostConfig, live *deployed) *twelvefactor.ProcessChange {
+	change := &twelvefactor.ProcessChange{
+		Process: process.Name,
+		Action:  twelvefactor.ChangeUpdate,
+	}
+	if live == nil {
+		change.Action = twelvefactor.ChangeCreate
+		live = &deployed{config: new(docker.Config), host: new(docker.HostConfig)}
+	}
+
+	oldFields := containerFields(live.config, live.host)
+	newFields := containerFields(config, host)
+	if process.Schedule != "" {
+		oldFields = append(oldFields, field{"schedule", live.schedule})
+		newFields = append(newFields, field{"schedule", process.Schedule})
+	}
+
+	for i, f := range newFields {
+		if old := oldFields[i]; old.value != f.value {
+			change.Fields = append(change.Fields, twelvefactor.FieldChange{
+				Field: f.name,
+				Old:   old.value,
+				New:   f.value,
+			})
+		}
+	}
+
+	oldEnv, _ := environment(live.config)
+	newEnv, _ := environment(config)
+	change.Env = twelvefactor.DiffEnv(oldEnv, newEnv)
+
+	// Run replaces containers whenever the hash changes, such as for a
+	// change to the labels, even if none of the compared fields did.
+	if oldHash, newHash := live.config.Labels[ConfigLabel], config.Labels[ConfigLabel]; change.Action == twelvefactor.ChangeUpdate &&
+		len(change.Fields) == 0 && len(change.Env) == 0 && oldHash != newHash {
+		change.Fields = append(change.Fields, twelvefactor.FieldChange{Field: "config", Old: oldHash, New: newHash})
+	}
+
+	if live.count != process.DesiredCount {
+		change.Scale = &twelvefactor.ScaleChange{From: live.count, To: process.DesiredCount}
+	}
+
+	if change.Action == twelvefactor.ChangeUpdate && len(change.Fields) == 0 && len(change.Env) == 0 && change.Scale == nil {
+		return nil
+	}
+	return change
+}
+
+// containerFields returns the fields of a container's configuration that are
+// compared when planning, using the same units as a manifest.
+func containerFields(config *docker.Config, host *docker.HostConfig) []field {
+	_, placement := environment(config)
+
+	return []field{
+		{"image", config.Image},
+		{"command", strings.Join(config.Cmd, " ")},
+		{"cpu", formatShares(host.CPUShares)},
+		{"cpu_limit", formatNanoCPUs(host.NanoCPUs)},
+		{"memory", formatBytes(host.Memory)},
+		{"memory_reservation", formatBytes(host.MemoryReservation)},
+		{"health_check", formatHealthCheck(config.Healthcheck)},
+		{"placement_constraints", strings.Join(placement, ", ")},
+	}
+}
+
+// environment splits the environment of a container into the variables for
+// the process and the placement constraints that placementEnv added.
+func environment(config *docker.Config) (map[string]string, []string) {
+	env := make(map[string]string, len(config.Env))
+	var placement []string
+	for _, kv := range config.Env {
+		if strings.HasPrefix(kv, "affinity:") || strings.HasPrefix(kv, "constraint:") {
+			placement = append(placement, kv)
+			continue
+		}
+
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 1 {
+			parts = append(parts, "")
+		}
+		env[parts[0]] = parts[1]
+	}
+	return env, placement
+}
+
+func formatShares(shares int64) string {
+	if shares == 0 {
+		return ""
+	}
+	return cpu.FromShares(shares).String()
+}
+
+func formatNanoCPUs(nanos int64) string {
+	if nanos == 0 {
+		return ""
+	}
+	return cpu.CPU(nanos / cpu.MilliCPU.NanoCPUs()).String()
+}
+
+func formatBytes(b int64) string {
+	if b == 0 {
+		return ""
+	}
+	return bytesize.ByteSize(b).String()
+}
+
+// formatHealthCheck formats a docker health check, such as
+// "curl -f http://localhost/ (interval 30s, retries 3)".
+func formatHealthCheck(check *docker.HealthConfig) string {
+	if check == nil {
+		return ""
+	}
+
+	command := check.Test
+	if len(command) > 0 && command[0] == "CMD" {
+		command = command[1:]
+	}
+
+	var opts []string
+	for _, opt := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"interval", check.Interval},
+		{"timeout", check.Timeout},
+		{"start period", check.StartPeriod},
+	} {
+		if opt.value != 0 {
+			opts = append(opts, fmt.Sprintf("%s %s", opt.name, opt.value))
+		}
+	}
+	if check.Retries != 0 {
+		opts = append(opts, fmt.Sprintf("retries %d", check.Retries))
+	}
+
+	s := strings.Join(command, " ")
+	if len(opts) > 0 {
+		s += " (" + strings.Join(opts, ", ") + ")"
+	}
+	return s
+}
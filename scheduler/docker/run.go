@@ -0,0 +1,167 @@
+package docker
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/remind101/12factor"
+)
+
+// RunProcess runs a one off container for the process, with the image and
+// environment that the app was last deployed with.
+//
+// Attached processes are attached to before their container starts, so that
+// none of the output is missed, and the container is removed once it exits. An
+// error is returned if it exits with a non-zero code. Detached containers are
+// kept until the app is removed, so that Tasks can report on them.
+func (s *Scheduler) RunProcess(app string, process twelvefactor.Process) error {
+	config, host, err := s.runConfig(app, process)
+	if err != nil {
+		return err
+	}
+
+	if process.Stdout == nil {
+		_, err := s.startContainer(config, host)
+		return err
+	}
+
+	stdout, ok := process.Stdout.(io.Writer)
+	if !ok {
+		return twelvefactor.NewError(twelvefactor.ErrInvalidConfig, fmt.Errorf("stdout for %s must be an io.Writer, got %T", process.Name, process.Stdout))
+	}
+
+	var stdin io.Reader
+	if process.Stdin != nil {
+		if stdin, ok = process.Stdin.(io.Reader); !ok {
+			return twelvefactor.NewError(twelvefactor.ErrInvalidConfig, fmt.Errorf("stdin for %s must be an io.Reader, got %T", process.Name, process.Stdin))
+		}
+	}
+
+	code, err := s.runAttached(config, host, stdin, stdout)
+	if err != nil {
+		return err
+	}
+
+	if code != 0 {
+		return fmt.Errorf("%s exited with code %d", process.Name, code)
+	}
+	return nil
+}
+
+// runConfig returns the docker container and host configuration for a one off
+// container. It's based on the configuration of the app's long running
+// processes from the latest deploy, or on the app's scheduled processes when it
+// only has those.
+func (s *Scheduler) runConfig(app string, process twelvefactor.Process) (*docker.Config, *docker.HostConfig, error) {
+	containers, err := s.appContainers(app)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Containers are listed newest first.
+	var (
+		config *docker.Config
+		host   *docker.HostConfig
+	)
+	for _, c := range containers {
+		if _, ok := c.Labels[SpecLabel]; !ok {
+			continue
+		}
+
+		if config, host, err = containerSpec(c.Labels); err != nil {
+			return nil, nil, err
+		}
+		break
+	}
+
+	if config == nil {
+		a, ok := s.cron.App(app)
+		if !ok {
+			return nil, nil, &twelvefactor.AppNotFoundError{App: app}
+		}
+		config, host = containerConfig(a, twelvefactor.Process{}), s.hostConfig(process)
+	}
+
+	// Without the service label, the container isn't mistaken for one
+	// of the app's long running processes.
+	for _, label := range []string{ServiceLabel, ConfigLabel, SpecLabel} {
+		delete(config.Labels, label)
+	}
+	config.Labels = twelvefactor.MergeEnv(config.Labels, process.Labels)
+	config.Labels[ProcessLabel] = process.Name
+
+	var env []string
+	for k, v := range process.Env {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(env)
+
+	config.Cmd = process.Command
+	config.Env = append(config.Env, env...)
+	config.Healthcheck = nil
+	host.RestartPolicy = docker.RestartPolicy{}
+
+	return config, host, nil
+}
+
+// runAttached runs a container with stdin and stdout attached to it, and
+// returns its exit code once it exits. The container is always removed.
+func (s *Scheduler) runAttached(config *docker.Config, host *docker.HostConfig, stdin io.Reader, stdout io.Writer) (int, error) {
+	config.AttachStdout = true
+	config.AttachStderr = true
+	if stdin != nil {
+		config.AttachStdin = true
+		config.OpenStdin = true
+		config.StdinOnce = true
+	}
+
+	c, err := s.docker.CreateContainer(docker.CreateContainerOptions{
+		Config:     config,
+		HostConfig: host,
+	})
+	if err != nil {
+		return 0, translateError(err)
+	}
+	defer s.docker.RemoveContainer(docker.RemoveContainerOptions{ID: c.ID, Force: true})
+
+	attached := make(chan struct{})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.docker.AttachToContainer(docker.AttachToContainerOptions{
+			Container:    c.ID,
+			InputStream:  stdin,
+			OutputStream: stdout,
+			ErrorStream:  stdout,
+			Stdin:        stdin != nil,
+			Stdout:       true,
+			Stderr:       true,
+			Stream:       true,
+			Success:      attached,
+		})
+	}()
+
+	select {
+	case <-attached:
+		attached <- struct{}{}
+	case err := <-errc:
+		return 0, translateError(err)
+	}
+
+	if err := s.docker.StartContainer(c.ID, nil); err != nil {
+		return 0, translateError(err)
+	}
+
+	code, err := s.docker.WaitContainer(c.ID)
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	// The output is done once the container exits.
+	if err := <-errc; err != nil {
+		return 0, err
+	}
+
+	return code, nil
+}
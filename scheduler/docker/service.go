@@ -0,0 +1,374 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/remind101/12factor"
+)
+
+// stopTimeout is the number of seconds that containers are given to exit after
+// being sent a SIGTERM, before they're killed.
+const stopTimeout = 10
+
+// deploy converges the containers for a long running process on its desired
+// count. Containers that were created with a different configuration are
+// replaced in batches, following the process's Rolling deployment options.
+func (s *Scheduler) deploy(app twelvefactor.App, process twelvefactor.Process) error {
+	config, host := s.serviceConfig(app, process)
+
+	containers, err := s.serviceContainers(app.ID, process.Name)
+	if err != nil {
+		return err
+	}
+
+	r := newRollout(process.DesiredCount, process.Deployment.Rolling, process.HealthCheck != nil)
+	return s.converge(config, host, containers, process.DesiredCount, r)
+}
+
+// Defaults for the Rolling deployment percentages, which match ECS.
+const (
+	defaultMinimumHealthyPercent = 100
+	defaultMaximumPercent        = 200
+)
+
+// rollout bounds the number of containers while they're being replaced.
+type rollout struct {
+	// The number of containers that must be kept running, and the most
+	// that can exist at once.
+	minHealthy, maxTotal int
+
+	// Whether to wait for new containers to be healthy before removing
+	// old ones.
+	waitHealthy bool
+}
+
+// newRollout returns the rollout for the Rolling deployment options of a
+// process. Like ECS, the minimum is rounded up and the maximum down. When that
+// leaves no room to replace a container, the minimum gives way, so that the
+// deploy can make progress without going over the maximum.
+func newRollout(desired int, d twelvefactor.RollingDeployment, waitHealthy bool) rollout {
+	min, max := d.MinimumHealthyPercent, d.MaximumPercent
+	if min == 0 && max == 0 {
+		min = defaultMinimumHealthyPercent
+	}
+	if max == 0 {
+		max = defaultMaximumPercent
+	}
+
+	r := rollout{
+		minHealthy:  (desired*min + 99) / 100,
+		maxTotal:    desired * max / 100,
+		waitHealthy: waitHealthy,
+	}
+	if r.minHealthy >= r.maxTotal {
+		r.minHealthy = r.maxTotal - 1
+	}
+	if r.minHealthy < 0 {
+		r.minHealthy = 0
+	}
+	return r
+}
+
+// converge converges containers on desired instances of the configuration.
+// Containers with a different configuration are replaced in batches: as many
+// new containers are started as the rollout allows, then as many old ones are
+// removed as keeps the minimum running, until none of the old ones are left.
+func (s *Scheduler) converge(config *docker.Config, host *docker.HostConfig, containers []docker.APIContainers, desired int, r rollout) error {
+	var current, old []docker.APIContainers
+	for _, c := range containers {
+		switch {
+		case c.Labels[ConfigLabel] == config.Labels[ConfigLabel]:
+			current = append(current, c)
+		case running(c):
+			old = append(old, c)
+		default:
+			// Old containers that have exited aren't serving
+			// anything, so they don't count towards the rollout.
+			if err := s.removeContainer(c.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Prefer keeping the containers that are already running.
+	sort.SliceStable(current, func(i, j int) bool {
+		return running(current[i]) && !running(current[j])
+	})
+
+	if len(current) > desired {
+		if err := s.removeContainers(current[desired:]); err != nil {
+			return err
+		}
+		current = current[:desired]
+	}
+
+	for _, c := range current {
+		if !running(c) {
+			if err := s.docker.StartContainer(c.ID, nil); err != nil {
+				return translateError(err)
+			}
+		}
+	}
+
+	n := len(current)
+	for n < desired || len(old) > 0 {
+		batch := desired - n
+		if room := r.maxTotal - n - len(old); room < batch {
+			batch = room
+		}
+
+		var started []string
+		for i := 0; i < batch; i++ {
+			id, err := s.startContainer(config, host)
+			if err != nil {
+				return err
+			}
+			started = append(started, id)
+		}
+
+		if r.waitHealthy && len(started) > 0 {
+			if err := s.waitHealthy(started); err != nil {
+				// Leave the old containers running.
+				s.removeContainers(apiContainers(started))
+				return err
+			}
+		}
+		n += len(started)
+
+		remove := n + len(old) - r.minHealthy
+		if remove > len(old) {
+			remove = len(old)
+		}
+		if err := s.removeContainers(old[:remove]); err != nil {
+			return err
+		}
+		old = old[remove:]
+	}
+
+	return nil
+}
+
+// waitHealthy waits for the containers to pass their health checks, returning
+// an error if any of them become unhealthy, exit, or take longer than the
+// health check allows to become healthy.
+func (s *Scheduler) waitHealthy(ids []string) error {
+	var deadline time.Time
+	for _, id := range ids {
+		for {
+			c, err := s.docker.InspectContainer(id)
+			if err != nil {
+				return translateError(err)
+			}
+
+			if !c.State.Running {
+				return fmt.Errorf("container %s exited before it was healthy", id)
+			}
+
+			switch c.State.Health.Status {
+			case "healthy":
+			case "unhealthy":
+				return fmt.Errorf("container %s is unhealthy", id)
+			default:
+				if deadline.IsZero() {
+					var check *docker.HealthConfig
+					if c.Config != nil {
+						check = c.Config.Healthcheck
+					}
+					deadline = time.Now().Add(healthTimeout(check))
+				}
+				if time.Now().After(deadline) {
+					return fmt.Errorf("timed out waiting for container %s to be healthy", id)
+				}
+
+				time.Sleep(s.healthPollInterval())
+				continue
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// Docker's defaults for health checks.
+const (
+	defaultHealthInterval = 30 * time.Second
+	defaultHealthTimeout  = 30 * time.Second
+	defaultHealthRetries  = 3
+)
+
+// healthTimeout returns how long a container can take to become healthy,
+// which is the start period plus every retry timing out.
+func healthTimeout(check *docker.HealthConfig) time.Duration {
+	interval, timeout, retries := defaultHealthInterval, defaultHealthTimeout, defaultHealthRetries
+	var startPeriod time.Duration
+	if check != nil {
+		if check.Interval > 0 {
+			interval = check.Interval
+		}
+		if check.Timeout > 0 {
+			timeout = check.Timeout
+		}
+		if check.Retries > 0 {
+			retries = check.Retries
+		}
+		startPeriod = check.StartPeriod
+	}
+	return startPeriod + time.Duration(retries+1)*(interval+timeout)
+}
+
+func (s *Scheduler) healthPollInterval() time.Duration {
+	if s.HealthPollInterval == 0 {
+		return DefaultHealthPollInterval
+	}
+	return s.HealthPollInterval
+}
+
+// apiContainers returns the containers with the given ids.
+func apiContainers(ids []string) []docker.APIContainers {
+	containers := make([]docker.APIContainers, len(ids))
+	for i, id := range ids {
+		containers[i] = docker.APIContainers{ID: id}
+	}
+	return containers
+}
+
+// startContainer creates and starts a container, returning its id.
+func (s *Scheduler) startContainer(config *docker.Config, host *docker.HostConfig) (string, error) {
+	c, err := s.docker.CreateContainer(docker.CreateContainerOptions{
+		Config:     config,
+		HostConfig: host,
+	})
+	if err != nil {
+		return "", translateError(err)
+	}
+
+	return c.ID, translateError(s.docker.StartContainer(c.ID, nil))
+}
+
+// removeContainers removes each of the containers.
+func (s *Scheduler) removeContainers(containers []docker.APIContainers) error {
+	for _, c := range containers {
+		if err := s.removeContainer(c.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeStale removes the containers for long running processes of the app
+// that aren't in services. Containers that were started by a schedule or by
+// RunProcess are kept, so that their exit status can still be seen.
+func (s *Scheduler) removeStale(app string, services []twelvefactor.Process) error {
+	keep := make(map[string]bool, len(services))
+	for _, process := range services {
+		keep[process.Name] = true
+	}
+
+	containers, err := s.appContainers(app)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range containers {
+		if _, ok := c.Labels[TriggeredAtLabel]; ok || keep[c.Labels[ProcessLabel]] {
+			continue
+		}
+
+		if _, ok := c.Labels[ServiceLabel]; !ok {
+			continue
+		}
+
+		if err := s.removeContainer(c.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// serviceContainers returns the containers for a long running process,
+// including any that have exited.
+func (s *Scheduler) serviceContainers(app, process string) ([]docker.APIContainers, error) {
+	containers, err := s.docker.ListContainers(docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": {fmt.Sprintf("%s=%s", ServiceLabel, service(app, process))},
+		},
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	var services []docker.APIContainers
+	for _, c := range containers {
+		if _, ok := c.Labels[TriggeredAtLabel]; !ok {
+			services = append(services, c)
+		}
+	}
+	return services, nil
+}
+
+// removeContainer stops the container, giving it stopTimeout seconds to exit,
+// and then removes it.
+func (s *Scheduler) removeContainer(id string) error {
+	var notRunning *docker.ContainerNotRunning
+	if err := s.docker.StopContainer(id, stopTimeout); err != nil && !errors.As(err, &notRunning) {
+		return translateError(err)
+	}
+
+	return translateError(s.docker.RemoveContainer(docker.RemoveContainerOptions{ID: id}))
+}
+
+// spec is the configuration that a container for a long running process is
+// created with.
+type spec struct {
+	Config     *docker.Config
+	HostConfig *docker.HostConfig
+}
+
+// serviceConfig returns the docker container and host configuration for an
+// instance of a long running process. The container is labeled with the
+// configuration and a hash of it, and Docker restarts it unless it's
+// explicitly stopped.
+func (s *Scheduler) serviceConfig(app twelvefactor.App, process twelvefactor.Process) (*docker.Config, *docker.HostConfig) {
+	config := containerConfig(app, process)
+	host := s.hostConfig(process)
+	host.RestartPolicy = docker.RestartUnlessStopped()
+
+	raw, _ := json.Marshal(spec{config, host})
+	sum := sha256.Sum256(raw)
+	config.Labels[ConfigLabel] = hex.EncodeToString(sum[:])[:12]
+	config.Labels[SpecLabel] = string(raw)
+
+	return config, host
+}
+
+// containerSpec returns the configuration that a container for a long running
+// process was created with, from its labels.
+func containerSpec(labels map[string]string) (*docker.Config, *docker.HostConfig, error) {
+	raw, ok := labels[SpecLabel]
+	if !ok {
+		return nil, nil, fmt.Errorf("container has no %s label, deploy the app again to add it", SpecLabel)
+	}
+
+	var sp spec
+	if err := json.Unmarshal([]byte(raw), &sp); err != nil {
+		return nil, nil, err
+	}
+
+	sp.Config.Labels[ConfigLabel] = labels[ConfigLabel]
+	sp.Config.Labels[SpecLabel] = raw
+	return sp.Config, sp.HostConfig, nil
+}
+
+// running reports whether the container is running.
+func running(c docker.APIContainers) bool {
+	return c.State == "running"
+}
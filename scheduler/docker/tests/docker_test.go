@@ -3,6 +3,7 @@ package docker_test
 import (
 	"testing"
 
+	dockerclient "github.com/fsouza/go-dockerclient"
 	"github.com/remind101/12factor"
 	"github.com/remind101/12factor/scheduler/docker"
 )
@@ -21,8 +22,9 @@ var app = twelvefactor.App{
 
 var processes = []twelvefactor.Process{
 	{
-		Name:    "web",
-		Command: []string{"acme-inc", "web"},
+		Name:         "web",
+		Command:      []string{"acme-inc", "web"},
+		DesiredCount: 1,
 	},
 }
 
@@ -32,12 +34,28 @@ func TestScheduler_Run(t *testing.T) {
 	if err := s.Run(app, processes...); err != nil {
 		t.Fatal(err)
 	}
+
+	tasks, err := s.Tasks(app.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 {
+		t.Fatalf("Expected 1 task, got %d", len(tasks))
+	}
+
+	// Running the app without any processes removes its containers.
+	if err := s.Run(app); err != nil {
+		t.Fatal(err)
+	}
 }
 
 func newScheduler(t testing.TB) *docker.Scheduler {
-	s, err := docker.NewSchedulerFromEnv()
+	c, err := dockerclient.NewClientFromEnv()
 	if err != nil {
-		t.Fatalf("Could not build docker scheduler: %v", err)
+		t.Fatalf("Could not build docker client: %v", err)
+	}
+	if err := c.Ping(); err != nil {
+		t.Skip("Skipping Docker test because the Docker daemon is not reachable.")
 	}
-	return s
+	return docker.NewScheduler(c)
 }
@@ -0,0 +1,221 @@
+// Package drift detects when apps have drifted from the state they were last
+// run with, such as when a service is scaled by hand outside of twelvefactor,
+// and optionally corrects them.
+//
+// The desired state of apps comes from a state.Store, which is usually kept up
+// to date by wrapping the Scheduler with state.Middleware:
+//
+//	store := &state.FileStore{Dir: "/var/lib/12factor"}
+//	s := middleware.Chain(ecs.NewScheduler(config), state.Middleware(store))
+//
+//	r := &drift.Reconciler{Scheduler: s, Store: store, Correct: true}
+//	go r.Start(ctx)
+package drift
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/state"
+)
+
+// DefaultInterval is the default interval between checks for drift.
+const DefaultInterval = 5 * time.Minute
+
+// Report is the result of checking a single app for drift.
+type Report struct {
+	// The app that was checked.
+	App string
+
+	// The changes that are needed to bring the app back to its desired
+	// state. This is nil if the check failed.
+	Plan *twelvefactor.Plan
+
+	// Whether the drift was corrected.
+	Corrected bool
+
+	// The error checking or correcting the app, if any.
+	Err error
+}
+
+// Drifted reports whether the app has drifted from its desired state.
+func (r *Report) Drifted() bool {
+	return r.Plan != nil && !r.Plan.Empty()
+}
+
+// Reconciler compares the desired state of apps against what's running.
+//
+// Schedulers that implement twelvefactor.Planner are compared in full, which
+// covers the image, environment, resources and desired count of each process.
+// Other schedulers can only be compared by the number of running or pending
+// tasks for each process.
+type Reconciler struct {
+	// The Scheduler that the apps are running on.
+	Scheduler twelvefactor.Scheduler
+
+	// Store is where the desired state of apps is read from.
+	Store state.Store
+
+	// Correct makes Reconcile correct any drift that it finds. Drift in
+	// the desired count alone is corrected with ScaleProcess, and anything
+	// else by running the app again. So is drift in a single location,
+	// such as a target of a federated scheduler, since ScaleProcess scales
+	// the process everywhere.
+	Correct bool
+
+	// Interval is the time between checks when running with Start. The
+	// zero value is DefaultInterval.
+	Interval time.Duration
+
+	// Report, when provided, is called by Start with the report for each
+	// app that has drifted or couldn't be checked.
+	Report func(Report)
+}
+
+// Check returns the changes that are needed to bring the app back to its
+// desired state.
+func (r *Reconciler) Check(app string) (*twelvefactor.Plan, error) {
+	s, err := r.Store.Get(app)
+	if err != nil {
+		return nil, err
+	}
+	return r.check(s)
+}
+
+func (r *Reconciler) check(s *state.State) (*twelvefactor.Plan, error) {
+	if p, ok := r.Scheduler.(twelvefactor.Planner); ok && twelvefactor.Supports(r.Scheduler, twelvefactor.CapabilityPlan) {
+		return p.Plan(s.App, s.Processes...)
+	}
+
+	// An app that the scheduler doesn't know about has drifted completely.
+	tasks, err := r.Scheduler.Tasks(s.App.ID)
+	if err != nil && !errors.Is(err, twelvefactor.ErrAppNotFound) {
+		return nil, err
+	}
+
+	// Tasks that are still starting count, so that a process that's
+	// being scaled up isn't scaled up again.
+	running := make(map[string]int)
+	for _, t := range tasks {
+		switch strings.ToLower(t.State) {
+		case "running", "pending":
+			running[t.Process]++
+		}
+	}
+
+	plan := &twelvefactor.Plan{App: s.App.ID, Changes: []twelvefactor.ProcessChange{}}
+	for _, p := range s.Processes {
+		// Scheduled processes only have tasks while they're running.
+		if p.Schedule != "" || running[p.Name] == p.DesiredCount {
+			continue
+		}
+
+		plan.Changes = append(plan.Changes, twelvefactor.ProcessChange{
+			Process: p.Name,
+			Action:  twelvefactor.ChangeUpdate,
+			Scale:   &twelvefactor.ScaleChange{From: running[p.Name], To: p.DesiredCount},
+		})
+	}
+	return plan, nil
+}
+
+// Reconcile checks every app in the Store for drift, correcting it when
+// Correct is set, and returns a report for each app.
+func (r *Reconciler) Reconcile() ([]Report, error) {
+	apps, err := r.Store.Apps()
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]Report, 0, len(apps))
+	for _, app := range apps {
+		reports = append(reports, r.reconcile(app))
+	}
+	return reports, nil
+}
+
+func (r *Reconciler) reconcile(app string) Report {
+	report := Report{App: app}
+
+	s, err := r.Store.Get(app)
+	if err != nil {
+		report.Err = err
+		return report
+	}
+
+	if report.Plan, report.Err = r.check(s); report.Err != nil || !report.Drifted() || !r.Correct {
+		return report
+	}
+
+	if report.Err = r.correct(s, report.Plan); report.Err == nil {
+		report.Corrected = true
+	}
+	return report
+}
+
+// correct brings the app back to its desired state.
+func (r *Reconciler) correct(s *state.State, plan *twelvefactor.Plan) error {
+	if !scaleOnly(plan) {
+		return r.Scheduler.Run(s.App, s.Processes...)
+	}
+
+	for _, c := range plan.Changes {
+		if err := r.Scheduler.ScaleProcess(s.App.ID, c.Process, c.Scale.To); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scaleOnly reports whether the only changes in the plan are to the desired
+// count of existing processes, in every location.
+func scaleOnly(plan *twelvefactor.Plan) bool {
+	for _, c := range plan.Changes {
+		if c.Action != twelvefactor.ChangeUpdate || c.Scale == nil || len(c.Fields) > 0 || len(c.Env) > 0 || c.Location != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Start calls Reconcile immediately, and then every Interval, until ctx is
+// done. Reports for apps that have drifted or couldn't be checked are passed to
+// Report.
+func (r *Reconciler) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval())
+	defer ticker.Stop()
+
+	for {
+		reports, err := r.Reconcile()
+		if err != nil {
+			r.report(Report{Err: err})
+		}
+		for _, report := range reports {
+			if report.Drifted() || report.Err != nil {
+				r.report(report)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (r *Reconciler) report(report Report) {
+	if r.Report != nil {
+		r.Report(report)
+	}
+}
+
+func (r *Reconciler) interval() time.Duration {
+	if r.Interval == 0 {
+		return DefaultInterval
+	}
+	return r.Interval
+}
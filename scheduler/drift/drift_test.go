@@ -0,0 +1,166 @@
+package drift
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/scheduler/memory"
+	"github.com/remind101/12factor/scheduler/middleware"
+	"github.com/remind101/12factor/state"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testApp       = twelvefactor.App{ID: "acme", Image: "acme-inc:v1"}
+	testProcesses = []twelvefactor.Process{{Name: "web", DesiredCount: 2}}
+)
+
+func TestReconciler_Check(t *testing.T) {
+	m := memory.NewScheduler()
+	store := state.NewMemoryStore()
+	s := middleware.Chain(m, state.Middleware(store))
+	assert.NoError(t, s.Run(testApp, testProcesses...))
+
+	r := &Reconciler{Scheduler: s, Store: store}
+
+	plan, err := r.Check("acme")
+	assert.NoError(t, err)
+	assert.True(t, plan.Empty())
+
+	// Someone changes the app without going through the store.
+	assert.NoError(t, m.Run(twelvefactor.App{ID: "acme", Image: "acme-inc:v0"}, twelvefactor.Process{Name: "web", DesiredCount: 1}))
+
+	plan, err = r.Check("acme")
+	assert.NoError(t, err)
+	assert.Equal(t, []twelvefactor.ProcessChange{
+		{
+			Process: "web",
+			Action:  twelvefactor.ChangeUpdate,
+			Fields:  []twelvefactor.FieldChange{{Field: "image", Old: "acme-inc:v0", New: "acme-inc:v1"}},
+			Scale:   &twelvefactor.ScaleChange{From: 1, To: 2},
+		},
+	}, plan.Changes)
+
+	_, err = r.Check("other")
+	assert.True(t, errors.Is(err, twelvefactor.ErrAppNotFound))
+}
+
+func TestReconciler_Check_Tasks(t *testing.T) {
+	m := memory.NewScheduler()
+	store := state.NewMemoryStore()
+	assert.NoError(t, store.Put(&state.State{App: testApp, Processes: testProcesses}))
+	assert.NoError(t, m.Run(testApp, twelvefactor.Process{Name: "web", DesiredCount: 1}))
+
+	// Schedulers that can't plan are compared by their running tasks.
+	r := &Reconciler{Scheduler: tasksOnly{m}, Store: store}
+
+	plan, err := r.Check("acme")
+	assert.NoError(t, err)
+	assert.Equal(t, []twelvefactor.ProcessChange{
+		{Process: "web", Action: twelvefactor.ChangeUpdate, Scale: &twelvefactor.ScaleChange{From: 1, To: 2}},
+	}, plan.Changes)
+
+	// Apps that were removed from the scheduler need to be created again.
+	assert.NoError(t, m.Remove("acme"))
+	plan, err = r.Check("acme")
+	assert.NoError(t, err)
+	assert.Equal(t, []twelvefactor.ProcessChange{
+		{Process: "web", Action: twelvefactor.ChangeUpdate, Scale: &twelvefactor.ScaleChange{From: 0, To: 2}},
+	}, plan.Changes)
+}
+
+func TestReconciler_Check_PendingTasks(t *testing.T) {
+	store := state.NewMemoryStore()
+	assert.NoError(t, store.Put(&state.State{App: testApp, Processes: testProcesses}))
+
+	// A task that's still starting counts towards the desired count.
+	r := &Reconciler{Scheduler: fixedTasks{tasks: []twelvefactor.Task{
+		{Process: "web", State: "RUNNING"},
+		{Process: "web", State: "PENDING"},
+		{Process: "web", State: "STOPPED"},
+	}}, Store: store}
+
+	plan, err := r.Check("acme")
+	assert.NoError(t, err)
+	assert.True(t, plan.Empty())
+}
+
+func TestReconciler_Reconcile(t *testing.T) {
+	m := memory.NewScheduler()
+	store := state.NewMemoryStore()
+	s := middleware.Chain(m, state.Middleware(store))
+	assert.NoError(t, s.Run(testApp, testProcesses...))
+	assert.NoError(t, s.Run(twelvefactor.App{ID: "other", Image: "other:v1"}, testProcesses...))
+
+	r := &Reconciler{Scheduler: s, Store: store, Correct: true}
+
+	// Drift in the desired count alone is scaled back.
+	assert.NoError(t, m.ScaleProcess("acme", "web", 5))
+	// Anything else is run again.
+	assert.NoError(t, m.Run(twelvefactor.App{ID: "other", Image: "other:v0"}, testProcesses...))
+
+	reports, err := r.Reconcile()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(reports))
+	for _, report := range reports {
+		assert.NoError(t, report.Err)
+		assert.True(t, report.Drifted(), report.App)
+		assert.True(t, report.Corrected, report.App)
+	}
+
+	reports, err = r.Reconcile()
+	assert.NoError(t, err)
+	for _, report := range reports {
+		assert.False(t, report.Drifted(), report.App)
+	}
+
+	tasks, _ := m.Tasks("acme")
+	assert.Equal(t, 2, len(tasks))
+}
+
+func TestReconciler_Start(t *testing.T) {
+	m := memory.NewScheduler()
+	store := state.NewMemoryStore()
+	assert.NoError(t, store.Put(&state.State{App: testApp, Processes: testProcesses}))
+
+	reports := make(chan Report, 10)
+	r := &Reconciler{
+		Scheduler: m,
+		Store:     store,
+		Correct:   true,
+		Interval:  time.Millisecond,
+		Report:    func(report Report) { reports <- report },
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Start(ctx) }()
+
+	report := <-reports
+	assert.Equal(t, "acme", report.App)
+	assert.True(t, report.Corrected)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+
+	tasks, _ := m.Tasks("acme")
+	assert.Equal(t, 2, len(tasks))
+}
+
+// fixedTasks is a Scheduler that always has the same tasks.
+type fixedTasks struct {
+	twelvefactor.Scheduler
+	tasks []twelvefactor.Task
+}
+
+func (s fixedTasks) Tasks(app string) ([]twelvefactor.Task, error) {
+	return s.tasks, nil
+}
+
+// tasksOnly hides any optional interfaces of the Scheduler.
+type tasksOnly struct {
+	twelvefactor.Scheduler
+}
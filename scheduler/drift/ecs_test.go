@@ -0,0 +1,105 @@
+package drift_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awsecs "github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/scheduler/drift"
+	"github.com/remind101/12factor/scheduler/ecs"
+	"github.com/remind101/12factor/scheduler/ecs/builders/raw"
+	"github.com/remind101/12factor/scheduler/ecs/ecstest"
+	"github.com/remind101/12factor/scheduler/middleware"
+	"github.com/remind101/12factor/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconciler_ECS(t *testing.T) {
+	srv := ecstest.NewServer()
+	defer srv.Close()
+
+	b := raw.NewStackBuilder(srv.Config())
+	b.Cluster = "cluster"
+	s := ecs.NewSchedulerWithStackBuilder(srv.Config(), b)
+	s.Cluster = "cluster"
+
+	app := twelvefactor.App{ID: "app", Image: "acme-inc:v1"}
+	store := state.NewMemoryStore()
+	assert.NoError(t, middleware.Chain(s, state.Middleware(store)).Run(app, twelvefactor.Process{Name: "web", DesiredCount: 2}))
+
+	r := &drift.Reconciler{Scheduler: s, Store: store, Correct: true}
+
+	plan, err := r.Check("app")
+	assert.NoError(t, err)
+	assert.True(t, plan.Empty(), "plan: %v", plan)
+
+	// The web service is scaled down to 1 by hand, which is corrected by
+	// scaling it back up.
+	c := awsecs.New(session.New(srv.Config()))
+	_, err = c.UpdateService(&awsecs.UpdateServiceInput{
+		Cluster:      aws.String("cluster"),
+		Service:      aws.String("app--web"),
+		DesiredCount: aws.Int64(1),
+	})
+	assert.NoError(t, err)
+
+	reports, err := r.Reconcile()
+	assert.NoError(t, err)
+	assert.Equal(t, []drift.Report{{
+		App: "app",
+		Plan: &twelvefactor.Plan{App: "app", Changes: []twelvefactor.ProcessChange{
+			{Process: "web", Action: twelvefactor.ChangeUpdate, Scale: &twelvefactor.ScaleChange{From: 1, To: 2}},
+		}},
+		Corrected: true,
+	}}, reports)
+
+	plan, err = r.Check("app")
+	assert.NoError(t, err)
+	assert.True(t, plan.Empty(), "plan: %v", plan)
+
+	// The web service is moved to another image by hand, which can't be
+	// seen from its tasks alone.
+	service, err := c.DescribeServices(&awsecs.DescribeServicesInput{
+		Cluster:  aws.String("cluster"),
+		Services: []*string{aws.String("app--web")},
+	})
+	assert.NoError(t, err)
+	current, err := c.DescribeTaskDefinition(&awsecs.DescribeTaskDefinitionInput{
+		TaskDefinition: service.Services[0].TaskDefinition,
+	})
+	assert.NoError(t, err)
+
+	containers := current.TaskDefinition.ContainerDefinitions
+	containers[0].Image = aws.String("acme-inc:v0")
+	registered, err := c.RegisterTaskDefinition(&awsecs.RegisterTaskDefinitionInput{
+		Family:               current.TaskDefinition.Family,
+		ContainerDefinitions: containers,
+	})
+	assert.NoError(t, err)
+	_, err = c.UpdateService(&awsecs.UpdateServiceInput{
+		Cluster:        aws.String("cluster"),
+		Service:        aws.String("app--web"),
+		TaskDefinition: registered.TaskDefinition.TaskDefinitionArn,
+	})
+	assert.NoError(t, err)
+
+	reports, err = r.Reconcile()
+	assert.NoError(t, err)
+	assert.Equal(t, []drift.Report{{
+		App: "app",
+		Plan: &twelvefactor.Plan{App: "app", Changes: []twelvefactor.ProcessChange{
+			{
+				Process: "web",
+				Action:  twelvefactor.ChangeUpdate,
+				Fields:  []twelvefactor.FieldChange{{Field: "image", Old: "acme-inc:v0", New: "acme-inc:v1"}},
+			},
+		}},
+		Corrected: true,
+	}}, reports)
+
+	plan, err = r.Check("app")
+	assert.NoError(t, err)
+	assert.True(t, plan.Empty(), "plan: %v", plan)
+}
@@ -0,0 +1,67 @@
+package drift_test
+
+import (
+	"testing"
+
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/scheduler/drift"
+	"github.com/remind101/12factor/scheduler/federated"
+	"github.com/remind101/12factor/scheduler/memory"
+	"github.com/remind101/12factor/scheduler/middleware"
+	"github.com/remind101/12factor/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconciler_Federated(t *testing.T) {
+	east, west := memory.NewScheduler(), memory.NewScheduler()
+	s := federated.NewScheduler(
+		federated.Target{Name: "us-east-1", Scheduler: east},
+		federated.Target{Name: "us-west-2", Scheduler: west, Scale: map[string]int{"web": 1}},
+	)
+
+	app := twelvefactor.App{ID: "acme", Image: "acme-inc:v1"}
+	store := state.NewMemoryStore()
+	assert.NoError(t, middleware.Chain(s, state.Middleware(store)).Run(app, twelvefactor.Process{Name: "web", DesiredCount: 2}))
+
+	r := &drift.Reconciler{Scheduler: s, Store: store, Correct: true}
+
+	// The Scale override isn't drift.
+	plan, err := r.Check("acme")
+	assert.NoError(t, err)
+	assert.True(t, plan.Empty(), "plan: %v", plan)
+
+	// Drift in one target is corrected without losing the override.
+	assert.NoError(t, west.ScaleProcess("acme", "web", 3))
+	reports, err := r.Reconcile()
+	assert.NoError(t, err)
+	assert.Equal(t, []drift.Report{{
+		App: "acme",
+		Plan: &twelvefactor.Plan{App: "acme", Changes: []twelvefactor.ProcessChange{
+			{
+				Process:  "web",
+				Action:   twelvefactor.ChangeUpdate,
+				Location: "us-west-2",
+				Scale:    &twelvefactor.ScaleChange{From: 3, To: 1},
+			},
+		}},
+		Corrected: true,
+	}}, reports)
+
+	assert.Len(t, tasks(t, east), 2)
+	assert.Len(t, tasks(t, west), 1)
+
+	plan, err = r.Check("acme")
+	assert.NoError(t, err)
+	assert.True(t, plan.Empty(), "plan: %v", plan)
+}
+
+// tasks returns the tasks for the acme app.
+func tasks(t testing.TB, s twelvefactor.Scheduler) []twelvefactor.Task {
+	t.Helper()
+
+	tasks, err := s.Tasks("acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tasks
+}
@@ -0,0 +1,301 @@
+package raw
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/remind101/12factor"
+)
+
+const (
+	// AppTag is the ECS resource tag that identifies the app a service
+	// belongs to.
+	AppTag = "twelvefactor:app"
+
+	// ProcessTag is the ECS resource tag that identifies the process a
+	// service runs.
+	ProcessTag = "twelvefactor:process"
+)
+
+// DefaultCacheTTL is the default amount of time that the index of services in
+// the cluster is cached for.
+const DefaultCacheTTL = time.Minute
+
+// maxDescribeServices is the maximum number of services that can be described
+// in a single DescribeServices call.
+const maxDescribeServices = 10
+
+// maxListServices is the maximum number of services that ListServices returns
+// in a page.
+const maxListServices = 100
+
+// serviceIndex caches the services in a cluster, keyed by app and then
+// process. A nil index caches nothing.
+type serviceIndex struct {
+	mu      sync.Mutex
+	apps    map[string]map[string]string
+	expires time.Time
+}
+
+// get returns a copy of the services for the app, and false if the index
+// hasn't been loaded or has expired.
+func (i *serviceIndex) get(app string, now time.Time) (map[string]string, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.apps == nil || !now.Before(i.expires) {
+		return nil, false
+	}
+
+	services := make(map[string]string, len(i.apps[app]))
+	for process, service := range i.apps[app] {
+		services[process] = service
+	}
+	return services, true
+}
+
+// set replaces the index.
+func (i *serviceIndex) set(apps map[string]map[string]string, expires time.Time) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.apps, i.expires = apps, expires
+}
+
+// put adds a service to the index, if it's loaded.
+func (i *serviceIndex) put(app, process, service string) {
+	if i == nil {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.apps == nil {
+		return
+	}
+
+	if i.apps[app] == nil {
+		i.apps[app] = make(map[string]string)
+	}
+	i.apps[app][process] = service
+}
+
+// delete removes a service from the index. If process is empty, all of the
+// app's services are removed.
+func (i *serviceIndex) delete(app, process string) {
+	if i == nil {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if process == "" {
+		delete(i.apps, app)
+		return
+	}
+	delete(i.apps[app], process)
+}
+
+// invalidate empties the index, so that it's loaded again on next use.
+func (i *serviceIndex) invalidate() {
+	if i == nil {
+		return
+	}
+
+	i.set(nil, time.Time{})
+}
+
+// Invalidate drops the cached index of services, so that the next call to
+// Services sees any changes made outside of this StackBuilder.
+func (b *StackBuilder) Invalidate() {
+	b.index.invalidate()
+}
+
+// Services returns a mapping of process name to service name for the services
+// that are members of the given app.
+//
+// Services are found using an index of the whole cluster, which is cached for
+// CacheTTL. Services that this StackBuilder creates or removes are reflected in
+// the index immediately.
+func (b *StackBuilder) Services(app string) (map[string]string, error) {
+	ttl := b.cacheTTL()
+	if b.index == nil {
+		ttl = 0
+	}
+	if ttl > 0 {
+		if services, ok := b.index.get(app, b.clock()); ok {
+			return services, nil
+		}
+	}
+
+	apps, err := b.discoverServices()
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl > 0 {
+		b.index.set(apps, b.clock().Add(ttl))
+		services, _ := b.index.get(app, b.clock())
+		return services, nil
+	}
+
+	services := apps[app]
+	if services == nil {
+		services = make(map[string]string)
+	}
+	return services, nil
+}
+
+// Service returns the name of the service that runs the process. The service is
+// looked up by name, "<app><delimiter><process>", and the index of the whole
+// cluster is only used when there's no such service, or it belongs to another
+// app or process by its tags. A ProcessNotFoundError is returned when the app
+// has no service for the process.
+func (b *StackBuilder) Service(app, process string) (string, error) {
+	if b.index != nil && b.cacheTTL() > 0 {
+		if services, ok := b.index.get(app, b.clock()); ok {
+			return found(app, process, services)
+		}
+	}
+
+	name := strings.Join([]string{app, process}, b.delimiter())
+	resp, err := b.ecs.DescribeServices(&ecs.DescribeServicesInput{
+		Cluster:  aws.String(b.Cluster),
+		Services: []*string{aws.String(name)},
+		Include:  []*string{aws.String(ecs.ServiceFieldTags)},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, service := range resp.Services {
+		if aws.StringValue(service.Status) == "INACTIVE" {
+			continue
+		}
+
+		a, p, ok := serviceTags(service.Tags)
+		if !ok || (a == app && p == process) {
+			return aws.StringValue(service.ServiceName), nil
+		}
+	}
+
+	services, err := b.Services(app)
+	if err != nil {
+		return "", err
+	}
+	return found(app, process, services)
+}
+
+// found returns the service for the process from services.
+func found(app, process string, services map[string]string) (string, error) {
+	service, ok := services[process]
+	if !ok {
+		return "", &twelvefactor.ProcessNotFoundError{App: app, Process: process}
+	}
+	return service, nil
+}
+
+// discoverServices iterates through all of the ECS services in the cluster,
+// and returns those that belong to an app, keyed by app and then process.
+// Services are identified by their AppTag and ProcessTag tags, falling back to
+// parsing the service name for services that were created before they were
+// tagged.
+func (b *StackBuilder) discoverServices() (map[string]map[string]string, error) {
+	var arns []*string
+	if err := b.ecs.ListServicesPages(&ecs.ListServicesInput{
+		Cluster:    aws.String(b.Cluster),
+		MaxResults: aws.Int64(maxListServices),
+	}, func(resp *ecs.ListServicesOutput, lastPage bool) bool {
+		for _, serviceArn := range resp.ServiceArns {
+			if serviceArn != nil {
+				arns = append(arns, serviceArn)
+			}
+		}
+		return true
+	}); err != nil {
+		return nil, err
+	}
+
+	apps := make(map[string]map[string]string)
+	for len(arns) > 0 {
+		n := len(arns)
+		if n > maxDescribeServices {
+			n = maxDescribeServices
+		}
+
+		resp, err := b.ecs.DescribeServices(&ecs.DescribeServicesInput{
+			Cluster:  aws.String(b.Cluster),
+			Services: arns[:n],
+			Include:  []*string{aws.String(ecs.ServiceFieldTags)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		arns = arns[n:]
+
+		for _, service := range resp.Services {
+			if aws.StringValue(service.Status) == "INACTIVE" {
+				continue
+			}
+
+			name := aws.StringValue(service.ServiceName)
+			app, process, ok := serviceTags(service.Tags)
+			if !ok {
+				app, process, ok = b.split(name)
+			}
+			if !ok {
+				continue
+			}
+
+			if apps[app] == nil {
+				apps[app] = make(map[string]string)
+			}
+			apps[app][process] = name
+		}
+	}
+
+	return apps, nil
+}
+
+// serviceTags returns the app and process from a service's tags.
+func serviceTags(tags []*ecs.Tag) (app, process string, ok bool) {
+	for _, tag := range tags {
+		switch aws.StringValue(tag.Key) {
+		case AppTag:
+			app = aws.StringValue(tag.Value)
+		case ProcessTag:
+			process = aws.StringValue(tag.Value)
+		}
+	}
+	ok = app != "" && process != ""
+	return
+}
+
+// tags returns the tags for the service that runs a process.
+func tags(app, process string) []*ecs.Tag {
+	return []*ecs.Tag{
+		{Key: aws.String(AppTag), Value: aws.String(app)},
+		{Key: aws.String(ProcessTag), Value: aws.String(process)},
+	}
+}
+
+func (b *StackBuilder) cacheTTL() time.Duration {
+	if b.CacheTTL == 0 {
+		return DefaultCacheTTL
+	}
+
+	return b.CacheTTL
+}
+
+func (b *StackBuilder) clock() time.Time {
+	if b.now == nil {
+		return time.Now()
+	}
+
+	return b.now()
+}
@@ -0,0 +1,432 @@
+package raw
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/pkg/bytesize"
+	"github.com/remind101/12factor/pkg/cpu"
+	"github.com/remind101/12factor/scheduler/ecs/internal/ecserr"
+)
+
+// deployed is the live state of a process, as deployed by Build.
+type deployed struct {
+	// The task definition that the service or schedule runs.
+	taskDefinition *ecs.TaskDefinition
+
+	// The desired count of the service, or the number of tasks that the
+	// schedule runs. This is 0 for disabled schedules.
+	count int
+
+	// The schedule expression of the rule, for scheduled processes.
+	schedule string
+
+	// The placement and deployment fields of the service, for processes
+	// that run as services.
+	fields []field
+}
+
+// field is the value of a field that's compared when planning.
+type field struct {
+	name, value string
+}
+
+// Plan implements the twelvefactor.Planner interface. It compares the
+// processes against the live services, schedules and task definitions for the
+// app, without changing anything.
+//
+// Like Build, Plan treats the app's services and schedules for processes that
+// aren't given as removed, so they're included as deletions.
+func (b *StackBuilder) Plan(app twelvefactor.App, processes ...twelvefactor.Process) (*twelvefactor.Plan, error) {
+	plan, err := b.plan(app, processes...)
+	if err != nil {
+		return nil, ecserr.Translate(err)
+	}
+	return plan, nil
+}
+
+func (b *StackBuilder) plan(app twelvefactor.App, processes ...twelvefactor.Process) (*twelvefactor.Plan, error) {
+	services, err := b.Services(app.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	schedules, err := b.Schedules(app.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &twelvefactor.Plan{App: app.ID, Changes: []twelvefactor.ProcessChange{}}
+	for _, process := range processes {
+		desired, err := b.taskDefinitionInput(app, process)
+		if err != nil {
+			return nil, err
+		}
+
+		var (
+			schedule string
+			fields   []field
+			live     *deployed
+		)
+		if process.Schedule != "" {
+			if schedule, err = scheduleExpression(process.Schedule); err != nil {
+				return nil, twelvefactor.NewError(twelvefactor.ErrInvalidConfig, err)
+			}
+			live, err = b.deployedSchedule(schedules[process.Name])
+		} else {
+			var deployment *ecs.DeploymentConfiguration
+			if deployment, err = deploymentConfiguration(process.Deployment); err != nil {
+				return nil, err
+			}
+			fields = b.desiredServiceFields(process, deployment)
+			live, err = b.deployedService(services[process.Name])
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if change := planChange(process, desired, schedule, fields, live); change != nil {
+			plan.Changes = append(plan.Changes, *change)
+		}
+	}
+
+	given := make(map[string]bool, len(processes))
+	for _, process := range processes {
+		given[process.Name] = true
+	}
+
+	// A process only has a service or a schedule, unless a switch between
+	// them was interrupted.
+	deleted := removed(services, given)
+	for _, process := range removed(schedules, given) {
+		if _, ok := services[process]; !ok {
+			deleted = append(deleted, process)
+		}
+	}
+	sort.Strings(deleted)
+	for _, process := range deleted {
+		plan.Changes = append(plan.Changes, twelvefactor.ProcessChange{Process: process, Action: twelvefactor.ChangeDelete})
+	}
+
+	return plan, nil
+}
+
+// desiredServiceFields returns the placement and deployment fields that the
+// service for the process would have. Deployment percentages that aren't set
+// are left as they are by UpdateService, so they're left out, and so is
+// placement on Fargate, which doesn't support it.
+func (b *StackBuilder) desiredServiceFields(process twelvefactor.Process, deployment *ecs.DeploymentConfiguration) []field {
+	var fields []field
+	if !b.Fargate {
+		fields = append(fields,
+			field{"placement_constraints", formatConstraints(placementConstraints(process.Placement))},
+			field{"placement_strategy", formatStrategies(placementStrategy(process.Placement))},
+		)
+	}
+
+	if deployment != nil {
+		for _, f := range deploymentFields(deployment) {
+			if f.value != "" {
+				fields = append(fields, f)
+			}
+		}
+	}
+	return fields
+}
+
+// deploymentFields returns the fields of a service's deployment configuration
+// that are compared when planning.
+func deploymentFields(d *ecs.DeploymentConfiguration) []field {
+	if d == nil {
+		d = new(ecs.DeploymentConfiguration)
+	}
+	return []field{
+		{"minimum_healthy_percent", formatPercent(d.MinimumHealthyPercent)},
+		{"maximum_percent", formatPercent(d.MaximumPercent)},
+	}
+}
+
+// deployedService returns the live state of a service, or nil if it doesn't
+// exist.
+func (b *StackBuilder) deployedService(name string) (*deployed, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	resp, err := b.ecs.DescribeServices(&ecs.DescribeServicesInput{
+		Cluster:  aws.String(b.Cluster),
+		Services: []*string{aws.String(name)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Services) == 0 || aws.StringValue(resp.Services[0].Status) == "INACTIVE" {
+		return nil, nil
+	}
+	service := resp.Services[0]
+
+	taskDefinition, err := b.describeTaskDefinition(service.TaskDefinition)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []field{
+		{"placement_constraints", formatConstraints(service.PlacementConstraints)},
+		{"placement_strategy", formatStrategies(service.PlacementStrategy)},
+	}
+	fields = append(fields, deploymentFields(service.DeploymentConfiguration)...)
+
+	return &deployed{
+		taskDefinition: taskDefinition,
+		count:          int(aws.Int64Value(service.DesiredCount)),
+		fields:         fields,
+	}, nil
+}
+
+// deployedSchedule returns the live state of a schedule, or nil if it doesn't
+// exist.
+func (b *StackBuilder) deployedSchedule(rule string) (*deployed, error) {
+	if rule == "" {
+		return nil, nil
+	}
+
+	resp, err := b.events.DescribeRule(&cloudwatchevents.DescribeRuleInput{
+		Name: aws.String(rule),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := b.events.ListTargetsByRule(&cloudwatchevents.ListTargetsByRuleInput{
+		Rule: aws.String(rule),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d := &deployed{
+		taskDefinition: new(ecs.TaskDefinition),
+		schedule:       aws.StringValue(resp.ScheduleExpression),
+	}
+
+	// Rules created by CreateSchedule only ever have a single target.
+	if len(targets.Targets) == 0 || targets.Targets[0].EcsParameters == nil {
+		return d, nil
+	}
+	params := targets.Targets[0].EcsParameters
+
+	if d.taskDefinition, err = b.describeTaskDefinition(params.TaskDefinitionArn); err != nil {
+		return nil, err
+	}
+
+	if aws.StringValue(resp.State) != cloudwatchevents.RuleStateDisabled {
+		d.count = int(aws.Int64Value(params.TaskCount))
+	}
+
+	return d, nil
+}
+
+func (b *StackBuilder) describeTaskDefinition(arn *string) (*ecs.TaskDefinition, error) {
+	resp, err := b.ecs.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: arn,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.TaskDefinition, nil
+}
+
+// planChange compares the desired task definition and service fields for a
+// process against what's deployed, returning nil if nothing would change.
+func planChange(process twelvefactor.Process, desired *ecs.RegisterTaskDefinitionInput, schedule string, fields []field, live *deployed) *twelvefactor.ProcessChange {
+	change := &twelvefactor.ProcessChange{
+		Process: process.Name,
+		Action:  twelvefactor.ChangeUpdate,
+	}
+	if live == nil {
+		change.Action = twelvefactor.ChangeCreate
+		live = &deployed{taskDefinition: new(ecs.TaskDefinition)}
+	}
+
+	current := container(live.taskDefinition.ContainerDefinitions, process.Name)
+	want := container(desired.ContainerDefinitions, process.Name)
+
+	oldFields := containerFields(live.taskDefinition.Cpu, current)
+	newFields := containerFields(desired.Cpu, want)
+	if process.Schedule != "" {
+		oldFields = append(oldFields, field{"schedule", live.schedule})
+		newFields = append(newFields, field{"schedule", schedule})
+	}
+	oldFields = append(oldFields, live.fields...)
+	newFields = append(newFields, fields...)
+
+	old := make(map[string]string, len(oldFields))
+	for _, f := range oldFields {
+		old[f.name] = f.value
+	}
+
+	for _, f := range newFields {
+		if old[f.name] != f.value {
+			change.Fields = append(change.Fields, twelvefactor.FieldChange{
+				Field: f.name,
+				Old:   old[f.name],
+				New:   f.value,
+			})
+		}
+	}
+
+	change.Env = twelvefactor.DiffEnv(environment(current), environment(want))
+
+	if live.count != process.DesiredCount {
+		change.Scale = &twelvefactor.ScaleChange{From: live.count, To: process.DesiredCount}
+	}
+
+	if change.Action == twelvefactor.ChangeUpdate && len(change.Fields) == 0 && len(change.Env) == 0 && change.Scale == nil {
+		return nil
+	}
+	return change
+}
+
+// container returns the container definition with the given name, falling back
+// to the first one.
+func container(definitions []*ecs.ContainerDefinition, name string) *ecs.ContainerDefinition {
+	for _, c := range definitions {
+		if aws.StringValue(c.Name) == name {
+			return c
+		}
+	}
+	if len(definitions) > 0 {
+		return definitions[0]
+	}
+	return new(ecs.ContainerDefinition)
+}
+
+// containerFields returns the fields of a task definition that are compared
+// when planning, using the same units as a manifest.
+func containerFields(limit *string, c *ecs.ContainerDefinition) []field {
+	var cpuLimit string
+	if n, err := strconv.ParseInt(aws.StringValue(limit), 10, 64); err == nil {
+		cpuLimit = cpu.FromShares(n).String()
+	}
+
+	var logGroup string
+	if c.LogConfiguration != nil {
+		logGroup = aws.StringValue(c.LogConfiguration.Options["awslogs-group"])
+	}
+
+	return []field{
+		{"image", aws.StringValue(c.Image)},
+		{"command", strings.Join(aws.StringValueSlice(c.Command), " ")},
+		{"cpu", formatShares(c.Cpu)},
+		{"cpu_limit", cpuLimit},
+		{"memory", formatMiB(c.Memory)},
+		{"memory_reservation", formatMiB(c.MemoryReservation)},
+		{"health_check", formatHealthCheck(c.HealthCheck)},
+		{"log_group", logGroup},
+	}
+}
+
+// environment returns the environment variables of a container definition.
+func environment(c *ecs.ContainerDefinition) map[string]string {
+	env := make(map[string]string, len(c.Environment))
+	for _, kv := range c.Environment {
+		env[aws.StringValue(kv.Name)] = aws.StringValue(kv.Value)
+	}
+	return env
+}
+
+// formatConstraints formats ECS placement constraints, such as
+// "distinctInstance, memberOf(attribute:ecs.instance-type == t2.small)".
+func formatConstraints(constraints []*ecs.PlacementConstraint) string {
+	var s []string
+	for _, c := range constraints {
+		if c.Expression == nil {
+			s = append(s, aws.StringValue(c.Type))
+			continue
+		}
+		s = append(s, fmt.Sprintf("%s(%s)", aws.StringValue(c.Type), aws.StringValue(c.Expression)))
+	}
+	return strings.Join(s, ", ")
+}
+
+// formatStrategies formats ECS placement strategies, such as
+// "spread(attribute:ecs.availability-zone), binpack(memory)".
+func formatStrategies(strategies []*ecs.PlacementStrategy) string {
+	var s []string
+	for _, strategy := range strategies {
+		if strategy.Field == nil {
+			s = append(s, aws.StringValue(strategy.Type))
+			continue
+		}
+		s = append(s, fmt.Sprintf("%s(%s)", aws.StringValue(strategy.Type), aws.StringValue(strategy.Field)))
+	}
+	return strings.Join(s, ", ")
+}
+
+func formatPercent(percent *int64) string {
+	if percent == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d%%", *percent)
+}
+
+func formatShares(shares *int64) string {
+	if aws.Int64Value(shares) == 0 {
+		return ""
+	}
+	return cpu.FromShares(*shares).String()
+}
+
+func formatMiB(mib *int64) string {
+	if mib == nil {
+		return ""
+	}
+	return (bytesize.ByteSize(*mib) * bytesize.MiB).String()
+}
+
+// formatHealthCheck formats an ECS health check, such as
+// "curl -f http://localhost/ (interval 30s, retries 3)".
+func formatHealthCheck(check *ecs.HealthCheck) string {
+	if check == nil {
+		return ""
+	}
+
+	command := aws.StringValueSlice(check.Command)
+	if len(command) > 0 && command[0] == "CMD" {
+		command = command[1:]
+	}
+
+	var opts []string
+	for _, opt := range []struct {
+		name  string
+		value *int64
+		unit  time.Duration
+	}{
+		{"interval", check.Interval, time.Second},
+		{"timeout", check.Timeout, time.Second},
+		{"retries", check.Retries, 0},
+		{"start period", check.StartPeriod, time.Second},
+	} {
+		switch {
+		case opt.value == nil:
+		case opt.unit == 0:
+			opts = append(opts, fmt.Sprintf("%s %d", opt.name, *opt.value))
+		default:
+			opts = append(opts, fmt.Sprintf("%s %s", opt.name, time.Duration(*opt.value)*opt.unit))
+		}
+	}
+
+	s := strings.Join(command, " ")
+	if len(opts) > 0 {
+		s += " (" + strings.Join(opts, ", ") + ")"
+	}
+	return s
+}
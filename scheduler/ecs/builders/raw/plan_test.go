@@ -0,0 +1,318 @@
+package raw
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/pkg/bytesize"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestStackBuilder_Plan(t *testing.T) {
+	c := new(mockECSClient)
+	e := new(mockEventsClient)
+	b := &StackBuilder{
+		Cluster: "cluster",
+		ecs:     c,
+		events:  e,
+	}
+
+	app := twelvefactor.App{
+		ID:    "app",
+		Image: "acme-inc:v2",
+		Env: map[string]string{
+			"RAILS_ENV":     "production",
+			"NEW_RELIC_KEY": "secret",
+		},
+	}
+
+	processes := []twelvefactor.Process{
+		{Name: "web", Command: []string{"acme-inc", "web"}, DesiredCount: 2, Memory: 512 * bytesize.MiB},
+		{Name: "api", Command: []string{"acme-inc", "api"}, DesiredCount: 1, Memory: 512 * bytesize.MiB},
+		{Name: "worker", Command: []string{"acme-inc", "worker"}, DesiredCount: 1, Memory: 256 * bytesize.MiB},
+		{Name: "cleanup", Command: []string{"acme-inc", "cleanup"}, Schedule: "@every 1h", DesiredCount: 1, Memory: 256 * bytesize.MiB},
+	}
+
+	c.On("ListServicesPages", &ecs.ListServicesInput{
+		Cluster:    aws.String("cluster"),
+		MaxResults: aws.Int64(100),
+	}).Return(nil, []*ecs.ListServicesOutput{
+		{ServiceArns: []*string{aws.String("app--web"), aws.String("app--api")}},
+	})
+	c.On("DescribeServices", &ecs.DescribeServicesInput{
+		Cluster:  aws.String("cluster"),
+		Services: []*string{aws.String("app--web"), aws.String("app--api")},
+		Include:  []*string{aws.String("TAGS")},
+	}).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{ServiceName: aws.String("app--web")},
+			{ServiceName: aws.String("app--api")},
+		},
+	}, nil)
+	e.On("ListRules", &cloudwatchevents.ListRulesInput{
+		NamePrefix: aws.String("app--"),
+	}).Return(&cloudwatchevents.ListRulesOutput{
+		Rules: []*cloudwatchevents.Rule{{Name: aws.String("app--cleanup")}},
+	}, nil)
+
+	// web is running an old image and environment, with one instance.
+	c.On("DescribeServices", &ecs.DescribeServicesInput{
+		Cluster:  aws.String("cluster"),
+		Services: []*string{aws.String("app--web")},
+	}).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{ServiceName: aws.String("app--web"), TaskDefinition: aws.String("app--web:1"), DesiredCount: aws.Int64(1)},
+		},
+	}, nil)
+	c.On("DescribeTaskDefinition", &ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: aws.String("app--web:1"),
+	}).Return(&ecs.DescribeTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			ContainerDefinitions: []*ecs.ContainerDefinition{
+				{
+					Name:    aws.String("web"),
+					Image:   aws.String("acme-inc:v1"),
+					Command: aws.StringSlice([]string{"acme-inc", "web"}),
+					Cpu:     aws.Int64(0),
+					Memory:  aws.Int64(512),
+					Environment: []*ecs.KeyValuePair{
+						{Name: aws.String("RAILS_ENV"), Value: aws.String("staging")},
+						{Name: aws.String("DEBUG"), Value: aws.String("1")},
+					},
+				},
+			},
+		},
+	}, nil)
+
+	// api is up to date.
+	c.On("DescribeServices", &ecs.DescribeServicesInput{
+		Cluster:  aws.String("cluster"),
+		Services: []*string{aws.String("app--api")},
+	}).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{ServiceName: aws.String("app--api"), TaskDefinition: aws.String("app--api:3"), DesiredCount: aws.Int64(1)},
+		},
+	}, nil)
+	c.On("DescribeTaskDefinition", &ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: aws.String("app--api:3"),
+	}).Return(&ecs.DescribeTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			ContainerDefinitions: []*ecs.ContainerDefinition{
+				{
+					Name:    aws.String("api"),
+					Image:   aws.String("acme-inc:v2"),
+					Command: aws.StringSlice([]string{"acme-inc", "api"}),
+					Memory:  aws.Int64(512),
+					Environment: []*ecs.KeyValuePair{
+						{Name: aws.String("RAILS_ENV"), Value: aws.String("production")},
+						{Name: aws.String("NEW_RELIC_KEY"), Value: aws.String("secret")},
+					},
+				},
+			},
+		},
+	}, nil)
+
+	// cleanup is disabled, and runs daily.
+	e.On("DescribeRule", &cloudwatchevents.DescribeRuleInput{
+		Name: aws.String("app--cleanup"),
+	}).Return(&cloudwatchevents.DescribeRuleOutput{
+		ScheduleExpression: aws.String("rate(1 day)"),
+		State:              aws.String("DISABLED"),
+	}, nil)
+	e.On("ListTargetsByRule", &cloudwatchevents.ListTargetsByRuleInput{
+		Rule: aws.String("app--cleanup"),
+	}).Return(&cloudwatchevents.ListTargetsByRuleOutput{
+		Targets: []*cloudwatchevents.Target{
+			{EcsParameters: &cloudwatchevents.EcsParameters{
+				TaskDefinitionArn: aws.String("app--cleanup:2"),
+				TaskCount:         aws.Int64(1),
+			}},
+		},
+	}, nil)
+	c.On("DescribeTaskDefinition", &ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: aws.String("app--cleanup:2"),
+	}).Return(&ecs.DescribeTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			ContainerDefinitions: []*ecs.ContainerDefinition{
+				{
+					Name:    aws.String("cleanup"),
+					Image:   aws.String("acme-inc:v2"),
+					Command: aws.StringSlice([]string{"acme-inc", "cleanup"}),
+					Memory:  aws.Int64(256),
+					Environment: []*ecs.KeyValuePair{
+						{Name: aws.String("RAILS_ENV"), Value: aws.String("production")},
+						{Name: aws.String("NEW_RELIC_KEY"), Value: aws.String("secret")},
+					},
+				},
+			},
+		},
+	}, nil)
+
+	plan, err := b.Plan(app, processes...)
+	assert.NoError(t, err)
+	assert.Equal(t, &twelvefactor.Plan{
+		App: "app",
+		Changes: []twelvefactor.ProcessChange{
+			{
+				Process: "web",
+				Action:  twelvefactor.ChangeUpdate,
+				Fields: []twelvefactor.FieldChange{
+					{Field: "image", Old: "acme-inc:v1", New: "acme-inc:v2"},
+				},
+				Env: []twelvefactor.EnvChange{
+					{Name: "DEBUG", Action: twelvefactor.ChangeDelete},
+					{Name: "NEW_RELIC_KEY", Action: twelvefactor.ChangeCreate},
+					{Name: "RAILS_ENV", Action: twelvefactor.ChangeUpdate},
+				},
+				Scale: &twelvefactor.ScaleChange{From: 1, To: 2},
+			},
+			{
+				Process: "worker",
+				Action:  twelvefactor.ChangeCreate,
+				Fields: []twelvefactor.FieldChange{
+					{Field: "image", New: "acme-inc:v2"},
+					{Field: "command", New: "acme-inc worker"},
+					{Field: "memory", New: "256MiB"},
+				},
+				Env: []twelvefactor.EnvChange{
+					{Name: "NEW_RELIC_KEY", Action: twelvefactor.ChangeCreate},
+					{Name: "RAILS_ENV", Action: twelvefactor.ChangeCreate},
+				},
+				Scale: &twelvefactor.ScaleChange{From: 0, To: 1},
+			},
+			{
+				Process: "cleanup",
+				Action:  twelvefactor.ChangeUpdate,
+				Fields: []twelvefactor.FieldChange{
+					{Field: "schedule", Old: "rate(1 day)", New: "rate(60 minutes)"},
+				},
+				Scale: &twelvefactor.ScaleChange{From: 0, To: 1},
+			},
+		},
+	}, plan)
+	c.AssertNotCalled(t, "RegisterTaskDefinition")
+	c.AssertNotCalled(t, "CreateService")
+	e.AssertNotCalled(t, "PutRule")
+}
+
+func TestStackBuilder_Plan_Invalid(t *testing.T) {
+	c := new(mockECSClient)
+	e := new(mockEventsClient)
+	b := &StackBuilder{
+		Cluster:  "cluster",
+		CacheTTL: -1,
+		ecs:      c,
+		events:   e,
+	}
+
+	c.On("ListServicesPages", &ecs.ListServicesInput{
+		Cluster:    aws.String("cluster"),
+		MaxResults: aws.Int64(100),
+	}).Return(nil, []*ecs.ListServicesOutput{})
+	e.On("ListRules", &cloudwatchevents.ListRulesInput{
+		NamePrefix: aws.String("app--"),
+	}).Return(&cloudwatchevents.ListRulesOutput{}, nil)
+
+	_, err := b.Plan(twelvefactor.App{ID: "app"}, twelvefactor.Process{Name: "cleanup", Schedule: "bogus"})
+	assert.ErrorIs(t, err, twelvefactor.ErrInvalidConfig)
+
+	_, err = b.Plan(twelvefactor.App{ID: "app"}, twelvefactor.Process{Name: "web", Deployment: twelvefactor.Deployment{Strategy: twelvefactor.Canary}})
+	assert.ErrorIs(t, err, twelvefactor.ErrUnsupported)
+}
+
+func TestStackBuilder_Plan_ServiceFields(t *testing.T) {
+	c := new(mockECSClient)
+	e := new(mockEventsClient)
+	b := &StackBuilder{
+		Cluster:  "cluster",
+		CacheTTL: -1,
+		ecs:      c,
+		events:   e,
+	}
+
+	app := twelvefactor.App{ID: "app", Image: "acme-inc:v1"}
+
+	c.On("ListServicesPages", mock.Anything).Return(nil, []*ecs.ListServicesOutput{
+		{ServiceArns: []*string{aws.String("app--web"), aws.String("app--api")}},
+	})
+	c.On("DescribeServices", &ecs.DescribeServicesInput{
+		Cluster:  aws.String("cluster"),
+		Services: []*string{aws.String("app--web"), aws.String("app--api")},
+		Include:  []*string{aws.String("TAGS")},
+	}).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{ServiceName: aws.String("app--web")},
+			{ServiceName: aws.String("app--api")},
+		},
+	}, nil)
+	e.On("ListRules", mock.Anything).Return(&cloudwatchevents.ListRulesOutput{
+		Rules: []*cloudwatchevents.Rule{{Name: aws.String("app--cleanup")}},
+	}, nil)
+
+	// web spreads across availability zones, and deploys with the ECS
+	// defaults.
+	c.On("DescribeServices", &ecs.DescribeServicesInput{
+		Cluster:  aws.String("cluster"),
+		Services: []*string{aws.String("app--web")},
+	}).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{
+				ServiceName:    aws.String("app--web"),
+				TaskDefinition: aws.String("app--web:1"),
+				DesiredCount:   aws.Int64(1),
+				PlacementStrategy: []*ecs.PlacementStrategy{
+					{Type: aws.String("spread"), Field: aws.String("attribute:ecs.availability-zone")},
+				},
+				DeploymentConfiguration: &ecs.DeploymentConfiguration{
+					MinimumHealthyPercent: aws.Int64(100),
+					MaximumPercent:        aws.Int64(200),
+				},
+			},
+		},
+	}, nil)
+	c.On("DescribeTaskDefinition", &ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: aws.String("app--web:1"),
+	}).Return(&ecs.DescribeTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			ContainerDefinitions: []*ecs.ContainerDefinition{
+				{Name: aws.String("web"), Image: aws.String("acme-inc:v1"), Memory: aws.Int64(512)},
+			},
+		},
+	}, nil)
+
+	plan, err := b.Plan(app, twelvefactor.Process{
+		Name:         "web",
+		DesiredCount: 1,
+		Memory:       512 * bytesize.MiB,
+		Placement: twelvefactor.Placement{
+			Constraints: []twelvefactor.PlacementConstraint{{Type: twelvefactor.DistinctInstance}},
+		},
+		Deployment: twelvefactor.Deployment{
+			// The maximum isn't set, so it's left as it is.
+			Rolling: twelvefactor.RollingDeployment{MinimumHealthyPercent: 50},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &twelvefactor.Plan{
+		App: "app",
+		Changes: []twelvefactor.ProcessChange{
+			{
+				Process: "web",
+				Action:  twelvefactor.ChangeUpdate,
+				Fields: []twelvefactor.FieldChange{
+					{Field: "placement_constraints", New: "distinctInstance"},
+					{Field: "placement_strategy", Old: "spread(attribute:ecs.availability-zone)"},
+					{Field: "minimum_healthy_percent", Old: "100%", New: "50%"},
+				},
+			},
+			// api and cleanup aren't given, so Build would remove
+			// them.
+			{Process: "api", Action: twelvefactor.ChangeDelete},
+			{Process: "cleanup", Action: twelvefactor.ChangeDelete},
+		},
+	}, plan)
+}
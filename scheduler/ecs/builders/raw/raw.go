@@ -2,25 +2,58 @@
 package raw
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/remind101/12factor"
 	"github.com/remind101/12factor/pkg/aws/arn"
+	"github.com/remind101/12factor/pkg/aws/retry"
 	"github.com/remind101/12factor/pkg/bytesize"
+	"github.com/remind101/12factor/pkg/cpu"
+	"github.com/remind101/12factor/pkg/cron"
+	"github.com/remind101/12factor/scheduler/ecs/internal/ecserr"
 )
 
 // DefaultDelimiter is the default delimiter used to delineate between app and
 // process in service names.
 const DefaultDelimiter = "--"
 
+// DefaultConcurrency is the default number of processes that Build deploys at
+// once.
+const DefaultConcurrency = 4
+
 type ecsClient interface {
 	ListServicesPages(*ecs.ListServicesInput, func(*ecs.ListServicesOutput, bool) bool) error
+	ListServices(*ecs.ListServicesInput) (*ecs.ListServicesOutput, error)
 	DeleteService(*ecs.DeleteServiceInput) (*ecs.DeleteServiceOutput, error)
 	RegisterTaskDefinition(*ecs.RegisterTaskDefinitionInput) (*ecs.RegisterTaskDefinitionOutput, error)
 	CreateService(*ecs.CreateServiceInput) (*ecs.CreateServiceOutput, error)
+	UpdateService(*ecs.UpdateServiceInput) (*ecs.UpdateServiceOutput, error)
+	DescribeClusters(*ecs.DescribeClustersInput) (*ecs.DescribeClustersOutput, error)
+	DescribeServices(*ecs.DescribeServicesInput) (*ecs.DescribeServicesOutput, error)
+	DescribeTaskDefinition(*ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error)
+	RunTask(*ecs.RunTaskInput) (*ecs.RunTaskOutput, error)
+}
+
+// eventsClient represents a client for interacting with CloudWatch Events,
+// which is used to trigger scheduled processes.
+type eventsClient interface {
+	PutRule(*cloudwatchevents.PutRuleInput) (*cloudwatchevents.PutRuleOutput, error)
+	PutTargets(*cloudwatchevents.PutTargetsInput) (*cloudwatchevents.PutTargetsOutput, error)
+	RemoveTargets(*cloudwatchevents.RemoveTargetsInput) (*cloudwatchevents.RemoveTargetsOutput, error)
+	DeleteRule(*cloudwatchevents.DeleteRuleInput) (*cloudwatchevents.DeleteRuleOutput, error)
+	ListRules(*cloudwatchevents.ListRulesInput) (*cloudwatchevents.ListRulesOutput, error)
+	DescribeRule(*cloudwatchevents.DescribeRuleInput) (*cloudwatchevents.DescribeRuleOutput, error)
+	ListTargetsByRule(*cloudwatchevents.ListTargetsByRuleInput) (*cloudwatchevents.ListTargetsByRuleOutput, error)
 }
 
 // StackBuilder implements the StackBuilder interface for the ECS scheduler.
@@ -36,48 +69,943 @@ type StackBuilder struct {
 	// have ELB's attached.
 	ServiceRole string
 
-	ecs ecsClient
+	// EventsRole is the ARN of an IAM role that CloudWatch Events will
+	// assume to run tasks for scheduled processes.
+	EventsRole string
+
+	// LogGroup is the name of a CloudWatch Logs log group to send container
+	// logs to. The zero value leaves logging to the ECS agent's default
+	// log driver.
+	LogGroup string
+
+	// MemoryRounding is the rounding policy used when converting a
+	// process's memory, in bytes, into the MiB that ECS expects. The zero
+	// value is bytesize.RoundUp, so containers never get less memory than
+	// requested.
+	MemoryRounding bytesize.Rounding
+
+	// EnableExecuteCommand enables ECS Exec on services, which is required
+	// to Exec into their tasks.
+	EnableExecuteCommand bool
+
+	// Fargate runs processes on AWS Fargate, rather than on the container
+	// instances in the cluster. Processes must have a CPULimit that
+	// Fargate supports and a Memory limit, and can't have Placement.
+	Fargate bool
+
+	// Subnets and SecurityGroups are attached to the network interfaces
+	// of Fargate tasks, which use the awsvpc network mode.
+	Subnets        []string
+	SecurityGroups []string
+
+	// Concurrency is the maximum number of processes that Build deploys at
+	// once. The zero value is DefaultConcurrency.
+	Concurrency int
+
+	// Atomic makes Build all or nothing. When any process fails to deploy,
+	// the processes that were deployed are reverted: services that Build
+	// created are removed, services that it updated go back to the task
+	// definition and desired count they had before, and schedules are
+	// restored to the rule and targets they had before.
+	Atomic bool
+
+	// CacheTTL is how long the index of services in the cluster is cached
+	// for, which saves listing every service in the cluster each time
+	// Services is called. Changes that this StackBuilder makes are
+	// reflected immediately, so the TTL only bounds how long changes made
+	// elsewhere go unnoticed. The zero value is DefaultCacheTTL, and a
+	// negative value disables caching.
+	CacheTTL time.Duration
+
+	// RetryPolicy is how calls to ECS and CloudWatch Events that fail
+	// because of throttling or transient server errors are retried. The
+	// zero value is retry.DefaultPolicy.
+	RetryPolicy *retry.Policy
+
+	// index caches the services in the cluster. It's shared with the
+	// copies that WithContext makes.
+	index *serviceIndex
+
+	// now returns the current time. The zero value is time.Now.
+	now func() time.Time
+
+	// region is the AWS region that the awslogs log driver sends logs to.
+	region string
+
+	ecs    ecsClient
+	events eventsClient
 }
 
 // NewStackBuilder returns a new StackBuilder instance with an ecs client
 // configured from config.
 func NewStackBuilder(config *aws.Config) *StackBuilder {
-	return &StackBuilder{
-		ecs: ecs.New(config),
+	sess := session.New(config)
+
+	// Calls are retried with the RetryPolicy, so the SDK doesn't retry
+	// them as well.
+	noRetries := aws.NewConfig().WithMaxRetries(0)
+
+	b := &StackBuilder{
+		region: aws.StringValue(sess.Config.Region),
+		index:  new(serviceIndex),
+	}
+	b.ecs = &retryingECSClient{ecsClient: ecs.New(sess, noRetries), retrier: retrier{policy: b.retryPolicy}}
+	b.events = &retryingEventsClient{eventsClient: cloudwatchevents.New(sess, noRetries), retrier: retrier{policy: b.retryPolicy}}
+	return b
+}
+
+// WithContext returns a copy of b whose calls to AWS are made with ctx, so that
+// they're no longer retried once ctx is done. The copy shares b's index of
+// services.
+func (b *StackBuilder) WithContext(ctx context.Context) *StackBuilder {
+	c := *b
+	if r, ok := b.ecs.(*retryingECSClient); ok {
+		c.ecs = &retryingECSClient{ecsClient: r.ecsClient, retrier: retrier{policy: c.retryPolicy, ctx: ctx}}
+	}
+	if r, ok := b.events.(*retryingEventsClient); ok {
+		c.events = &retryingEventsClient{eventsClient: r.eventsClient, retrier: retrier{policy: c.retryPolicy, ctx: ctx}}
+	}
+	return &c
+}
+
+func (b *StackBuilder) retryPolicy() *retry.Policy {
+	if b.RetryPolicy == nil {
+		return retry.DefaultPolicy
 	}
+
+	return b.RetryPolicy
+}
+
+// ProcessError is an error deploying, or reverting, a single process.
+type ProcessError struct {
+	Process string
+	Err     error
+}
+
+// Error implements the error interface.
+func (e *ProcessError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Process, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ProcessError) Unwrap() error {
+	return e.Err
 }
 
-// Build creates or updates ECS services for the app.
+// BuildError is returned by Build when one or more processes fail to deploy.
+type BuildError struct {
+	// The processes that failed to deploy, in the order they were given
+	// to Build.
+	Errors []*ProcessError
+
+	// When Build is atomic, the processes that were deployed and then
+	// reverted, and the processes that couldn't be reverted.
+	Reverted     []string
+	RevertErrors []*ProcessError
+}
+
+// Error implements the error interface.
+func (e *BuildError) Error() string {
+	msg := "failed to deploy " + joinErrors(e.Errors)
+	if len(e.Reverted) > 0 {
+		msg += "; reverted " + strings.Join(e.Reverted, ", ")
+	}
+	if len(e.RevertErrors) > 0 {
+		msg += "; failed to revert " + joinErrors(e.RevertErrors)
+	}
+	return msg
+}
+
+// Unwrap returns the errors for each process that failed to deploy, so that
+// errors.Is and errors.As can inspect them.
+func (e *BuildError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func joinErrors(errs []*ProcessError) string {
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Build creates or updates ECS services for the app. Processes with a Schedule
+// are created as CloudWatch Events rules that run the task instead. Once the
+// processes are deployed, the services and rules of the app's processes that
+// aren't given are removed.
+//
+// Processes are deployed concurrently, up to Concurrency at a time, and every
+// process is attempted even if some fail. If any fail, the returned error is a
+// *BuildError describing each failure.
 func (b *StackBuilder) Build(app twelvefactor.App, processes ...twelvefactor.Process) error {
+	// The existing services are updated rather than created, and the
+	// existing schedules are needed to know what a revert should go back
+	// to. Both are also needed to clean up after a process that switched
+	// between running as a service and running on a schedule.
+	services, err := b.Services(app.ID)
+	if err != nil {
+		return err
+	}
+
+	schedules, err := b.Schedules(app.ID)
+	if err != nil {
+		return err
+	}
+
+	var (
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, b.concurrency())
+		reverts  = make([]func() error, len(processes))
+		cleanups = make([]func() error, len(processes))
+		errs     = make([]error, len(processes))
+	)
+	for i, process := range processes {
+		wg.Add(1)
+		go func(i int, process twelvefactor.Process) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			reverts[i], cleanups[i], errs[i] = b.deploy(app, process, services, schedules)
+		}(i, process)
+	}
+	wg.Wait()
+
+	buildErr := new(BuildError)
+	for i, err := range errs {
+		if err != nil {
+			buildErr.Errors = append(buildErr.Errors, &ProcessError{Process: processes[i].Name, Err: ecserr.Translate(err)})
+		}
+	}
+
+	// When Build is atomic and something failed, everything is reverted,
+	// so nothing is pruned.
+	if len(buildErr.Errors) == 0 || !b.Atomic {
+		for _, p := range b.prunes(app, processes, services, schedules, cleanups, errs) {
+			if err := p.remove(); err != nil {
+				buildErr.Errors = append(buildErr.Errors, &ProcessError{Process: p.process, Err: ecserr.Translate(err)})
+			}
+		}
+	}
+
+	if len(buildErr.Errors) == 0 {
+		return nil
+	}
+
+	if !b.Atomic {
+		return buildErr
+	}
+
+	for i, revert := range reverts {
+		if errs[i] != nil || revert == nil {
+			continue
+		}
+
+		if err := revert(); err != nil {
+			buildErr.RevertErrors = append(buildErr.RevertErrors, &ProcessError{Process: processes[i].Name, Err: ecserr.Translate(err)})
+			continue
+		}
+		buildErr.Reverted = append(buildErr.Reverted, processes[i].Name)
+	}
+
+	return buildErr
+}
+
+// prune is something that Build removes once processes are deployed.
+type prune struct {
+	process string
+	remove  func() error
+}
+
+// prunes returns what Build removes after deploying the processes: the old
+// service or rule of processes that switched between running as a service and
+// running on a schedule, and the services and rules of processes that are no
+// longer part of the app.
+func (b *StackBuilder) prunes(app twelvefactor.App, processes []twelvefactor.Process, services, schedules map[string]string, cleanups []func() error, errs []error) []prune {
+	var prunes []prune
+	for i, cleanup := range cleanups {
+		if errs[i] == nil && cleanup != nil {
+			prunes = append(prunes, prune{processes[i].Name, cleanup})
+		}
+	}
+
+	given := make(map[string]bool, len(processes))
 	for _, process := range processes {
-		if err := b.CreateService(app, process); err != nil {
-			return err
+		given[process.Name] = true
+	}
+
+	for _, process := range removed(services, given) {
+		process, service := process, services[process]
+		prunes = append(prunes, prune{process, func() error {
+			return b.deleteService(app.ID, process, service)
+		}})
+	}
+
+	for _, process := range removed(schedules, given) {
+		process, rule := process, schedules[process]
+		prunes = append(prunes, prune{process, func() error {
+			return b.removeSchedule(process, rule)
+		}})
+	}
+
+	return prunes
+}
+
+// removed returns the sorted names of the processes in deployed that aren't
+// given.
+func removed(deployed map[string]string, given map[string]bool) []string {
+	var names []string
+	for process := range deployed {
+		if !given[process] {
+			names = append(names, process)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// deploy deploys a single process. When Build is atomic, it also returns a
+// function that reverts the process to how it was before, or nil if there's
+// nothing to revert. If the process switched between running as a service
+// and running on a schedule, the returned cleanup function removes what it
+// ran as before.
+func (b *StackBuilder) deploy(app twelvefactor.App, process twelvefactor.Process, services, schedules map[string]string) (revert, cleanup func() error, err error) {
+	if process.Schedule != "" {
+		if b.Atomic {
+			if revert, err = b.scheduleReverter(app, process, schedules); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		if err := b.CreateSchedule(app, process); err != nil {
+			return nil, nil, err
+		}
+
+		if service, ok := services[process.Name]; ok {
+			cleanup = func() error {
+				return b.deleteService(app.ID, process.Name, service)
+			}
+		}
+		return revert, cleanup, nil
+	}
+
+	if rule, ok := schedules[process.Name]; ok {
+		cleanup = func() error {
+			return b.removeSchedule(process.Name, rule)
+		}
+	}
+
+	if service, ok := services[process.Name]; ok {
+		if b.Atomic {
+			if revert, err = b.serviceReverter(service); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		if err := b.UpdateService(app, process, service); err != nil {
+			return nil, nil, err
 		}
+		return revert, cleanup, nil
+	}
+
+	if err := b.CreateService(app, process); err != nil {
+		return nil, nil, err
 	}
 
+	if !b.Atomic {
+		return nil, cleanup, nil
+	}
+
+	name := strings.Join([]string{app.ID, process.Name}, b.delimiter())
+	return func() error {
+		return b.deleteService(app.ID, process.Name, name)
+	}, cleanup, nil
+}
+
+// deleteService deletes the service for a process, stopping any tasks that it
+// still runs, and forgets it in the index.
+func (b *StackBuilder) deleteService(app, process, service string) error {
+	if _, err := b.ecs.DeleteService(&ecs.DeleteServiceInput{
+		Cluster: aws.String(b.Cluster),
+		Service: aws.String(service),
+		Force:   aws.Bool(true),
+	}); err != nil {
+		return err
+	}
+
+	b.index.delete(app, process)
 	return nil
 }
 
+// serviceReverter returns a function that updates the service back to its
+// current task definition and desired count.
+func (b *StackBuilder) serviceReverter(service string) (func() error, error) {
+	resp, err := b.ecs.DescribeServices(&ecs.DescribeServicesInput{
+		Cluster:  aws.String(b.Cluster),
+		Services: []*string{aws.String(service)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Services) == 0 {
+		return nil, fmt.Errorf("service not found: %s", service)
+	}
+	prev := resp.Services[0]
+
+	return func() error {
+		_, err := b.ecs.UpdateService(&ecs.UpdateServiceInput{
+			Cluster:        aws.String(b.Cluster),
+			Service:        aws.String(service),
+			TaskDefinition: prev.TaskDefinition,
+			DesiredCount:   prev.DesiredCount,
+		})
+		return err
+	}, nil
+}
+
+// scheduleReverter returns a function that restores the schedule for a process
+// to its current state, or removes it if there isn't one yet.
+func (b *StackBuilder) scheduleReverter(app twelvefactor.App, process twelvefactor.Process, schedules map[string]string) (func() error, error) {
+	rule, ok := schedules[process.Name]
+	if !ok {
+		rule = strings.Join([]string{app.ID, process.Name}, b.delimiter())
+		return func() error {
+			return b.removeSchedule(process.Name, rule)
+		}, nil
+	}
+
+	prev, err := b.events.DescribeRule(&cloudwatchevents.DescribeRuleInput{
+		Name: aws.String(rule),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Rules created by CreateSchedule only ever have a single target.
+	targets, err := b.events.ListTargetsByRule(&cloudwatchevents.ListTargetsByRuleInput{
+		Rule: aws.String(rule),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		if _, err := b.events.PutRule(&cloudwatchevents.PutRuleInput{
+			Name:               aws.String(rule),
+			Description:        prev.Description,
+			ScheduleExpression: prev.ScheduleExpression,
+			State:              prev.State,
+		}); err != nil {
+			return err
+		}
+
+		if len(targets.Targets) == 0 {
+			return nil
+		}
+
+		resp, err := b.events.PutTargets(&cloudwatchevents.PutTargetsInput{
+			Rule:    aws.String(rule),
+			Targets: targets.Targets,
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(resp.FailedEntries) > 0 {
+			return fmt.Errorf("error restoring target of %s rule: %s", rule, aws.StringValue(resp.FailedEntries[0].ErrorMessage))
+		}
+
+		return nil
+	}, nil
+}
+
+func (b *StackBuilder) concurrency() int {
+	if b.Concurrency <= 0 {
+		return DefaultConcurrency
+	}
+
+	return b.Concurrency
+}
+
 // CreateService creates an ECS service for the Process.
 func (b *StackBuilder) CreateService(app twelvefactor.App, process twelvefactor.Process) error {
 	name := strings.Join([]string{app.ID, process.Name}, b.delimiter())
 
+	deploymentConfiguration, err := deploymentConfiguration(process.Deployment)
+	if err != nil {
+		return err
+	}
+
 	taskDefinition, err := b.RegisterTaskDefinition(app, process)
 	if err != nil {
 		return err
 	}
 
-	_, err = b.ecs.CreateService(&ecs.CreateServiceInput{
-		Cluster:        aws.String(b.Cluster),
-		DesiredCount:   aws.Int64(int64(process.DesiredCount)),
-		Role:           aws.String(b.ServiceRole),
-		ServiceName:    aws.String(name),
-		TaskDefinition: aws.String(taskDefinition),
-	})
+	input := &ecs.CreateServiceInput{
+		Cluster:                 aws.String(b.Cluster),
+		DesiredCount:            aws.Int64(int64(process.DesiredCount)),
+		Role:                    aws.String(b.ServiceRole),
+		ServiceName:             aws.String(name),
+		TaskDefinition:          aws.String(taskDefinition),
+		PlacementConstraints:    placementConstraints(process.Placement),
+		PlacementStrategy:       placementStrategy(process.Placement),
+		DeploymentConfiguration: deploymentConfiguration,
+		Tags:                    tags(app.ID, process.Name),
+	}
+	if b.EnableExecuteCommand {
+		input.EnableExecuteCommand = aws.Bool(true)
+	}
+	if b.Fargate {
+		input.LaunchType = aws.String(ecs.LaunchTypeFargate)
+		input.NetworkConfiguration = b.networkConfiguration()
+	}
+
+	if _, err := b.ecs.CreateService(input); err != nil {
+		return err
+	}
+
+	b.index.put(app.ID, process.Name, name)
+	return nil
+}
+
+// UpdateService updates the existing ECS service for the Process to a new task
+// definition, desired count and configuration. The launch type and role of a
+// service can't be changed, so a service only moves to or from Fargate once
+// it's removed and created again.
+func (b *StackBuilder) UpdateService(app twelvefactor.App, process twelvefactor.Process, service string) error {
+	deploymentConfiguration, err := deploymentConfiguration(process.Deployment)
+	if err != nil {
+		return err
+	}
+
+	taskDefinition, err := b.RegisterTaskDefinition(app, process)
+	if err != nil {
+		return err
+	}
+
+	input := &ecs.UpdateServiceInput{
+		Cluster:                 aws.String(b.Cluster),
+		Service:                 aws.String(service),
+		DesiredCount:            aws.Int64(int64(process.DesiredCount)),
+		TaskDefinition:          aws.String(taskDefinition),
+		DeploymentConfiguration: deploymentConfiguration,
+		EnableExecuteCommand:    aws.Bool(b.EnableExecuteCommand),
+	}
+	if b.Fargate {
+		input.NetworkConfiguration = b.networkConfiguration()
+	} else {
+		// Unlike CreateService, leaving these out keeps what the service
+		// already has, so an empty list is needed to remove them.
+		input.PlacementConstraints = append([]*ecs.PlacementConstraint{}, placementConstraints(process.Placement)...)
+		input.PlacementStrategy = append([]*ecs.PlacementStrategy{}, placementStrategy(process.Placement)...)
+	}
+
+	_, err = b.ecs.UpdateService(input)
 	return err
 }
 
+// deploymentConfiguration converts the deployment options for a process into
+// an ECS deployment configuration. ECS services only support rolling
+// deployments natively, so any other strategy results in an
+// UnsupportedStrategyError. Percentages that aren't set are left for ECS to
+// default.
+func deploymentConfiguration(d twelvefactor.Deployment) (*ecs.DeploymentConfiguration, error) {
+	switch d.Strategy {
+	case "", twelvefactor.Rolling:
+	default:
+		return nil, &twelvefactor.UnsupportedStrategyError{Strategy: d.Strategy}
+	}
+
+	if err := d.Validate(); err != nil {
+		return nil, twelvefactor.NewError(twelvefactor.ErrInvalidConfig, err)
+	}
+
+	if d.Rolling == (twelvefactor.RollingDeployment{}) {
+		return nil, nil
+	}
+
+	config := new(ecs.DeploymentConfiguration)
+	if d.Rolling.MinimumHealthyPercent != 0 {
+		config.MinimumHealthyPercent = aws.Int64(int64(d.Rolling.MinimumHealthyPercent))
+	}
+	if d.Rolling.MaximumPercent != 0 {
+		config.MaximumPercent = aws.Int64(int64(d.Rolling.MaximumPercent))
+	}
+	return config, nil
+}
+
+// placementConstraints converts the placement constraints for a process into
+// ECS placement constraints.
+func placementConstraints(p twelvefactor.Placement) []*ecs.PlacementConstraint {
+	var constraints []*ecs.PlacementConstraint
+	for _, c := range p.Constraints {
+		switch c.Type {
+		case twelvefactor.DistinctInstance:
+			constraints = append(constraints, &ecs.PlacementConstraint{
+				Type: aws.String(ecs.PlacementConstraintTypeDistinctInstance),
+			})
+		case twelvefactor.MemberOf:
+			constraints = append(constraints, &ecs.PlacementConstraint{
+				Type:       aws.String(ecs.PlacementConstraintTypeMemberOf),
+				Expression: aws.String(fmt.Sprintf("attribute:%s %s %s", c.Attribute, c.Op(), c.Value)),
+			})
+		}
+	}
+	return constraints
+}
+
+// placementStrategy converts the placement strategies for a process into ECS
+// placement strategies.
+func placementStrategy(p twelvefactor.Placement) []*ecs.PlacementStrategy {
+	var strategies []*ecs.PlacementStrategy
+	for _, s := range p.Strategies {
+		strategy := &ecs.PlacementStrategy{
+			Type: aws.String(string(s.Type)),
+		}
+
+		switch s.Field {
+		case "":
+		case twelvefactor.FieldAvailabilityZone:
+			strategy.Field = aws.String("attribute:ecs.availability-zone")
+		case twelvefactor.FieldInstance:
+			strategy.Field = aws.String("instanceId")
+		case twelvefactor.FieldMemory, twelvefactor.FieldCPU:
+			strategy.Field = aws.String(s.Field)
+		default:
+			strategy.Field = aws.String("attribute:" + s.Field)
+		}
+
+		strategies = append(strategies, strategy)
+	}
+	return strategies
+}
+
+// CreateSchedule creates a CloudWatch Events rule that runs the task for the
+// Process each time its Schedule fires. The rule is disabled when the desired
+// count is 0.
+func (b *StackBuilder) CreateSchedule(app twelvefactor.App, process twelvefactor.Process) error {
+	name := strings.Join([]string{app.ID, process.Name}, b.delimiter())
+
+	expression, err := scheduleExpression(process.Schedule)
+	if err != nil {
+		return twelvefactor.NewError(twelvefactor.ErrInvalidConfig, err)
+	}
+
+	taskDefinition, err := b.registerTaskDefinition(app, process)
+	if err != nil {
+		return err
+	}
+
+	cluster, err := b.clusterARN()
+	if err != nil {
+		return err
+	}
+
+	state, count := cloudwatchevents.RuleStateEnabled, process.DesiredCount
+	if count == 0 {
+		state, count = cloudwatchevents.RuleStateDisabled, 1
+	}
+
+	// The cron expression is kept in the description of the rule, since
+	// the schedule expression can't be converted back into it.
+	if _, err := b.events.PutRule(&cloudwatchevents.PutRuleInput{
+		Name:               aws.String(name),
+		Description:        aws.String(process.Schedule),
+		ScheduleExpression: aws.String(expression),
+		State:              aws.String(state),
+	}); err != nil {
+		return err
+	}
+
+	params := &cloudwatchevents.EcsParameters{
+		TaskDefinitionArn: taskDefinition.TaskDefinitionArn,
+		TaskCount:         aws.Int64(int64(count)),
+	}
+	if b.Fargate {
+		params.LaunchType = aws.String(cloudwatchevents.LaunchTypeFargate)
+		params.NetworkConfiguration = &cloudwatchevents.NetworkConfiguration{
+			AwsvpcConfiguration: &cloudwatchevents.AwsVpcConfiguration{
+				Subnets:        aws.StringSlice(b.Subnets),
+				SecurityGroups: aws.StringSlice(b.SecurityGroups),
+			},
+		}
+	}
+
+	resp, err := b.events.PutTargets(&cloudwatchevents.PutTargetsInput{
+		Rule: aws.String(name),
+		Targets: []*cloudwatchevents.Target{
+			{
+				Id:            aws.String(process.Name),
+				Arn:           aws.String(cluster),
+				RoleArn:       aws.String(b.EventsRole),
+				EcsParameters: params,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(resp.FailedEntries) > 0 {
+		return fmt.Errorf("error adding target to %s rule: %s", name, aws.StringValue(resp.FailedEntries[0].ErrorMessage))
+	}
+
+	return nil
+}
+
+// RunTask starts a one off task for the process from the task definition of one
+// of the app's services, the process's own when it has one. The command is
+// overridden with the process's command, and the process's environment is
+// added to the container's.
+func (b *StackBuilder) RunTask(app string, process twelvefactor.Process) (string, error) {
+	services, err := b.Services(app)
+	if err != nil {
+		return "", err
+	}
+
+	if len(services) == 0 {
+		return "", &twelvefactor.AppNotFoundError{App: app}
+	}
+
+	service, ok := services[process.Name]
+	if !ok {
+		names := make([]string, 0, len(services))
+		for name := range services {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		service = services[names[0]]
+	}
+
+	resp, err := b.ecs.DescribeServices(&ecs.DescribeServicesInput{
+		Cluster:  aws.String(b.Cluster),
+		Services: []*string{aws.String(service)},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(resp.Services) == 0 {
+		return "", fmt.Errorf("service not found: %s", service)
+	}
+
+	taskDefinition, err := b.describeTaskDefinition(resp.Services[0].TaskDefinition)
+	if err != nil {
+		return "", err
+	}
+
+	if len(taskDefinition.ContainerDefinitions) == 0 {
+		return "", fmt.Errorf("task definition %s has no containers", aws.StringValue(taskDefinition.TaskDefinitionArn))
+	}
+
+	override := &ecs.ContainerOverride{
+		Name:    taskDefinition.ContainerDefinitions[0].Name,
+		Command: aws.StringSlice(process.Command),
+	}
+
+	var names []string
+	for name := range process.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		override.Environment = append(override.Environment, &ecs.KeyValuePair{
+			Name:  aws.String(name),
+			Value: aws.String(process.Env[name]),
+		})
+	}
+
+	input := &ecs.RunTaskInput{
+		Cluster:              aws.String(b.Cluster),
+		TaskDefinition:       taskDefinition.TaskDefinitionArn,
+		Count:                aws.Int64(1),
+		EnableExecuteCommand: aws.Bool(b.EnableExecuteCommand),
+		Overrides: &ecs.TaskOverride{
+			ContainerOverrides: []*ecs.ContainerOverride{override},
+		},
+	}
+	if b.Fargate {
+		input.LaunchType = aws.String(ecs.LaunchTypeFargate)
+		input.NetworkConfiguration = b.networkConfiguration()
+	}
+
+	run, err := b.ecs.RunTask(input)
+	if err != nil {
+		return "", err
+	}
+
+	if len(run.Failures) > 0 {
+		return "", fmt.Errorf("error running task for %s: %s", process.Name, aws.StringValue(run.Failures[0].Reason))
+	}
+
+	if len(run.Tasks) == 0 {
+		return "", fmt.Errorf("no task was started for %s", process.Name)
+	}
+
+	return arn.ResourceID(aws.StringValue(run.Tasks[0].TaskArn))
+}
+
+// clusterARN returns the full ARN of the cluster, which is required when
+// targeting it from CloudWatch Events.
+func (b *StackBuilder) clusterARN() (string, error) {
+	resp, err := b.ecs.DescribeClusters(&ecs.DescribeClustersInput{
+		Clusters: []*string{aws.String(b.Cluster)},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(resp.Clusters) == 0 {
+		return "", twelvefactor.NewError(twelvefactor.ErrInvalidConfig, fmt.Errorf("cluster not found: %s", b.Cluster))
+	}
+
+	return *resp.Clusters[0].ClusterArn, nil
+}
+
+// scheduleExpression converts a cron expression into a CloudWatch Events
+// schedule expression.
+func scheduleExpression(expr string) (string, error) {
+	schedule, err := cron.Parse(expr)
+	if err != nil {
+		return "", err
+	}
+
+	if schedule.Every > 0 {
+		if schedule.Every%time.Minute != 0 {
+			return "", fmt.Errorf("schedule interval must be a whole number of minutes: %s", expr)
+		}
+
+		minutes := int(schedule.Every / time.Minute)
+		if minutes == 1 {
+			return "rate(1 minute)", nil
+		}
+		return fmt.Sprintf("rate(%d minutes)", minutes), nil
+	}
+
+	minute, hour, dom, month, dow := schedule.Fields[0], schedule.Fields[1], schedule.Fields[2], schedule.Fields[3], schedule.Fields[4]
+
+	// CloudWatch Events doesn't allow both the day of month and day of week
+	// fields to be specified, and one of them must be "?".
+	switch {
+	case dow == "*":
+		dow = "?"
+	case dom == "*":
+		dom = "?"
+		dow = shiftWeekdays(dow)
+	default:
+		return "", fmt.Errorf("schedule cannot restrict both day of month and day of week: %s", expr)
+	}
+
+	return fmt.Sprintf("cron(%s %s %s %s %s *)", minute, hour, dom, month, dow), nil
+}
+
+// shiftWeekdays converts a cron day of week field (0-7, starting on Sunday) into
+// the CloudWatch Events equivalent (1-7, starting on Sunday). CloudWatch Events
+// has no day after Saturday, so values and ranges that include Sunday as 7 are
+// written out as a list of days.
+func shiftWeekdays(field string) string {
+	parts := strings.Split(field, ",")
+	for i, part := range parts {
+		var step string
+		if j := strings.Index(part, "/"); j >= 0 {
+			part, step = part[:j], part[j:]
+		}
+
+		if part == "*" {
+			parts[i] = part + step
+			continue
+		}
+
+		// The field has already been validated by cron.Parse.
+		r := strings.Split(part, "-")
+		lo, _ := strconv.Atoi(r[0])
+		hi := lo
+		if len(r) == 2 {
+			hi, _ = strconv.Atoi(r[1])
+		}
+
+		switch {
+		case hi == 7:
+			n := 1
+			if step != "" {
+				n, _ = strconv.Atoi(step[1:])
+			}
+
+			var days []string
+			for d := lo; d <= hi; d += n {
+				days = append(days, strconv.Itoa(d%7+1))
+			}
+			parts[i] = strings.Join(days, ",")
+		case len(r) == 2:
+			parts[i] = fmt.Sprintf("%d-%d%s", lo+1, hi+1, step)
+		default:
+			parts[i] = fmt.Sprintf("%d%s", lo+1, step)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// RegisterTaskDefinition registers a new revision of the task definition for
+// the Process, returning it in the form "family:revision".
 func (b *StackBuilder) RegisterTaskDefinition(app twelvefactor.App, process twelvefactor.Process) (string, error) {
+	taskDefinition, err := b.registerTaskDefinition(app, process)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s:%d", *taskDefinition.Family, *taskDefinition.Revision), nil
+}
+
+func (b *StackBuilder) registerTaskDefinition(app twelvefactor.App, process twelvefactor.Process) (*ecs.TaskDefinition, error) {
+	input, err := b.taskDefinitionInput(app, process)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.ecs.RegisterTaskDefinition(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.TaskDefinition, nil
+}
+
+// taskDefinitionInput returns the task definition to register for the Process.
+func (b *StackBuilder) taskDefinitionInput(app twelvefactor.App, process twelvefactor.Process) (*ecs.RegisterTaskDefinitionInput, error) {
+	if err := validateCPU(process); err != nil {
+		return nil, twelvefactor.NewError(twelvefactor.ErrInvalidConfig, err)
+	}
+
+	if err := validateMemory(process); err != nil {
+		return nil, twelvefactor.NewError(twelvefactor.ErrInvalidConfig, err)
+	}
+
+	if err := validateHealthCheck(process); err != nil {
+		return nil, twelvefactor.NewError(twelvefactor.ErrInvalidConfig, err)
+	}
+
+	if b.Fargate {
+		if err := validateFargate(process); err != nil {
+			return nil, twelvefactor.NewError(twelvefactor.ErrInvalidConfig, err)
+		}
+	}
+
+	memory, err := b.containerMemory(process)
+	if err != nil {
+		return nil, twelvefactor.NewError(twelvefactor.ErrInvalidConfig, err)
+	}
+
+	memoryReservation, err := b.containerMemoryReservation(process)
+	if err != nil {
+		return nil, twelvefactor.NewError(twelvefactor.ErrInvalidConfig, err)
+	}
+
 	family := strings.Join([]string{app.ID, process.Name}, b.delimiter())
 
 	var command []*string
@@ -94,39 +1022,327 @@ func (b *StackBuilder) RegisterTaskDefinition(app twelvefactor.App, process twel
 		})
 	}
 
-	resp, err := b.ecs.RegisterTaskDefinition(&ecs.RegisterTaskDefinitionInput{
+	input := &ecs.RegisterTaskDefinitionInput{
 		Family: aws.String(family),
+		Cpu:    taskCPU(process),
 		ContainerDefinitions: []*ecs.ContainerDefinition{
 			{
-				Name:        aws.String(process.Name),
-				Cpu:         aws.Int64(int64(process.CPUShares)),
-				Command:     command,
-				Image:       aws.String(app.Image),
-				Essential:   aws.Bool(true),
-				Memory:      aws.Int64(int64(process.Memory / int(bytesize.MB))),
-				Environment: environment,
+				Name:              aws.String(process.Name),
+				Cpu:               aws.Int64(containerCPU(process)),
+				Command:           command,
+				Image:             aws.String(app.Image),
+				Essential:         aws.Bool(true),
+				Memory:            memory,
+				MemoryReservation: memoryReservation,
+				Environment:       environment,
+				LogConfiguration:  b.logConfiguration(app),
+				HealthCheck:       healthCheck(process.HealthCheck),
 			},
 		},
-	})
+	}
+
+	// Fargate requires task level memory, and tasks to use the awsvpc
+	// network mode.
+	if b.Fargate {
+		input.RequiresCompatibilities = aws.StringSlice([]string{ecs.CompatibilityFargate})
+		input.NetworkMode = aws.String(ecs.NetworkModeAwsvpc)
+		input.Memory = aws.String(strconv.FormatInt(*memory, 10))
+	}
+
+	return input, nil
+}
+
+// The range of task level CPU that ECS allows, which is what a process's
+// CPULimit is mapped to.
+const (
+	minTaskCPU = 125 * cpu.MilliCPU // 128 CPU units
+	maxTaskCPU = 192 * cpu.VCPU
+)
+
+// validateCPU checks that the CPU reservation and limit for the process are
+// within what ECS allows.
+func validateCPU(process twelvefactor.Process) error {
+	reservation, limit := process.CPUReservation(), process.CPULimit
+
+	if reservation > maxTaskCPU {
+		return fmt.Errorf("cpu reservation for %s must be at most %s, got %s", process.Name, maxTaskCPU, reservation)
+	}
+
+	if limit == 0 {
+		return nil
+	}
+
+	if limit < minTaskCPU || limit > maxTaskCPU {
+		return fmt.Errorf("cpu limit for %s must be between %s and %s, got %s", process.Name, minTaskCPU, maxTaskCPU, limit)
+	}
+
+	if reservation > limit {
+		return fmt.Errorf("cpu reservation for %s (%s) exceeds its limit (%s)", process.Name, reservation, limit)
+	}
+
+	return nil
+}
+
+// fargateCPU is the task level CPU that Fargate supports.
+var fargateCPU = []cpu.CPU{
+	250 * cpu.MilliCPU, // 256 CPU units
+	500 * cpu.MilliCPU, // 512 CPU units
+	1 * cpu.VCPU,
+	2 * cpu.VCPU,
+	4 * cpu.VCPU,
+	8 * cpu.VCPU,
+	16 * cpu.VCPU,
+}
+
+// validateFargate checks that the process can be run on Fargate, which only
+// supports a fixed set of task CPU sizes, and requires a memory limit.
+func validateFargate(process twelvefactor.Process) error {
+	if !validFargateCPU(process.CPULimit) {
+		sizes := make([]string, len(fargateCPU))
+		for i, c := range fargateCPU {
+			sizes[i] = c.String()
+		}
+		return fmt.Errorf("cpu limit for %s must be one of %s on Fargate, got %s", process.Name, strings.Join(sizes, ", "), process.CPULimit)
+	}
+
+	if process.Memory == 0 {
+		return fmt.Errorf("memory limit for %s is required on Fargate", process.Name)
+	}
+
+	if len(process.Placement.Constraints) > 0 || len(process.Placement.Strategies) > 0 {
+		return fmt.Errorf("placement for %s isn't supported on Fargate", process.Name)
+	}
+
+	return nil
+}
+
+// validFargateCPU reports whether c is one of the task CPU sizes that Fargate
+// supports.
+func validFargateCPU(c cpu.CPU) bool {
+	for _, size := range fargateCPU {
+		if c == size {
+			return true
+		}
+	}
+	return false
+}
+
+// containerCPU returns the number of CPU units to reserve for the container,
+// using the legacy CPUShares as is when CPU isn't set.
+func containerCPU(process twelvefactor.Process) int64 {
+	if process.CPU != 0 {
+		return process.CPU.Shares()
+	}
+	return int64(process.CPUShares)
+}
+
+// taskCPU returns the task level CPU, which ECS enforces as a hard limit, or
+// nil if the process has no CPULimit.
+func taskCPU(process twelvefactor.Process) *string {
+	if process.CPULimit == 0 {
+		return nil
+	}
+	return aws.String(strconv.FormatInt(process.CPULimit.Shares(), 10))
+}
+
+// validateMemory checks that the memory reservation for the process doesn't
+// exceed its hard limit.
+func validateMemory(process twelvefactor.Process) error {
+	if process.Memory != 0 && process.MemoryReservation > process.Memory {
+		return fmt.Errorf("memory reservation for %s (%s) exceeds its limit (%s)", process.Name, process.MemoryReservation, process.Memory)
+	}
+	return nil
+}
+
+// The ranges that ECS allows for the settings of a container health check.
+const (
+	minHealthCheckInterval    = 5 * time.Second
+	maxHealthCheckInterval    = 300 * time.Second
+	minHealthCheckTimeout     = 2 * time.Second
+	maxHealthCheckTimeout     = 60 * time.Second
+	minHealthCheckRetries     = 1
+	maxHealthCheckRetries     = 10
+	maxHealthCheckStartPeriod = 300 * time.Second
+)
+
+// validateHealthCheck checks that the health check for the process, once its
+// durations are rounded up to whole seconds, is within what ECS allows. Zero
+// values aren't checked, since ECS uses its defaults for them.
+func validateHealthCheck(process twelvefactor.Process) error {
+	check := process.HealthCheck
+	if check == nil {
+		return nil
+	}
+
+	if interval := ceilSeconds(check.Interval); interval != 0 && (interval < minHealthCheckInterval || interval > maxHealthCheckInterval) {
+		return fmt.Errorf("health check interval for %s must be between %s and %s, got %s", process.Name, minHealthCheckInterval, maxHealthCheckInterval, interval)
+	}
+
+	if timeout := ceilSeconds(check.Timeout); timeout != 0 && (timeout < minHealthCheckTimeout || timeout > maxHealthCheckTimeout) {
+		return fmt.Errorf("health check timeout for %s must be between %s and %s, got %s", process.Name, minHealthCheckTimeout, maxHealthCheckTimeout, timeout)
+	}
+
+	if retries := check.Retries; retries != 0 && (retries < minHealthCheckRetries || retries > maxHealthCheckRetries) {
+		return fmt.Errorf("health check retries for %s must be between %d and %d, got %d", process.Name, minHealthCheckRetries, maxHealthCheckRetries, retries)
+	}
+
+	if startPeriod := ceilSeconds(check.StartPeriod); startPeriod > maxHealthCheckStartPeriod {
+		return fmt.Errorf("health check start period for %s must be at most %s, got %s", process.Name, maxHealthCheckStartPeriod, startPeriod)
+	}
+
+	return nil
+}
+
+// containerMemory returns the hard memory limit for the container in MiB. ECS
+// requires either a hard limit or a reservation, so it's only omitted when the
+// process has a reservation and no limit.
+func (b *StackBuilder) containerMemory(process twelvefactor.Process) (*int64, error) {
+	if process.Memory == 0 && process.MemoryReservation != 0 {
+		return nil, nil
+	}
+
+	mib, err := b.memoryMiB(process.Memory)
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("memory limit for %s: %v", process.Name, err)
+	}
+	return aws.Int64(mib), nil
+}
+
+// containerMemoryReservation returns the soft memory limit for the container in
+// MiB, or nil if the process has no reservation.
+func (b *StackBuilder) containerMemoryReservation(process twelvefactor.Process) (*int64, error) {
+	if process.MemoryReservation == 0 {
+		return nil, nil
+	}
+
+	mib, err := b.memoryMiB(process.MemoryReservation)
+	if err != nil {
+		return nil, fmt.Errorf("memory reservation for %s: %v", process.Name, err)
+	}
+	return aws.Int64(mib), nil
+}
+
+// networkConfiguration returns the awsvpc network configuration for services
+// that run on Fargate.
+func (b *StackBuilder) networkConfiguration() *ecs.NetworkConfiguration {
+	return &ecs.NetworkConfiguration{
+		AwsvpcConfiguration: &ecs.AwsVpcConfiguration{
+			Subnets:        aws.StringSlice(b.Subnets),
+			SecurityGroups: aws.StringSlice(b.SecurityGroups),
+		},
+	}
+}
+
+// healthCheck converts a process health check into an ECS container health
+// check, which measures durations in whole seconds. Durations are rounded up,
+// so that something like 500ms doesn't become 0, which means the default.
+func healthCheck(check *twelvefactor.HealthCheck) *ecs.HealthCheck {
+	if check == nil {
+		return nil
+	}
+
+	command := []*string{aws.String("CMD")}
+	for _, s := range check.Command {
+		command = append(command, aws.String(s))
+	}
+
+	seconds := func(d time.Duration) *int64 {
+		if d == 0 {
+			return nil
+		}
+		return aws.Int64(int64(ceilSeconds(d) / time.Second))
+	}
+
+	var retries *int64
+	if check.Retries != 0 {
+		retries = aws.Int64(int64(check.Retries))
+	}
+
+	return &ecs.HealthCheck{
+		Command:     command,
+		Interval:    seconds(check.Interval),
+		Timeout:     seconds(check.Timeout),
+		Retries:     retries,
+		StartPeriod: seconds(check.StartPeriod),
+	}
+}
+
+// ceilSeconds rounds d up to a whole number of seconds.
+func ceilSeconds(d time.Duration) time.Duration {
+	if r := d % time.Second; r > 0 {
+		d += time.Second - r
 	}
+	return d
+}
+
+// memoryMiB converts a size into MiB using the MemoryRounding policy. Sizes
+// that round down to nothing are errors.
+func (b *StackBuilder) memoryMiB(size bytesize.ByteSize) (int64, error) {
+	mib := size.In(bytesize.MiB, b.MemoryRounding)
+	if mib == 0 && size != 0 {
+		return 0, fmt.Errorf("%s rounds down to 0 MiB", size)
+	}
+	return int64(mib), nil
+}
 
-	return fmt.Sprintf("%s:%d", *resp.TaskDefinition.Family, *resp.TaskDefinition.Revision), nil
+// logConfiguration returns the awslogs log configuration for containers in the
+// app. Log streams are named "<app>/<process>/<task id>".
+func (b *StackBuilder) logConfiguration(app twelvefactor.App) *ecs.LogConfiguration {
+	if b.LogGroup == "" {
+		return nil
+	}
+
+	return &ecs.LogConfiguration{
+		LogDriver: aws.String(ecs.LogDriverAwslogs),
+		Options: map[string]*string{
+			"awslogs-group":         aws.String(b.LogGroup),
+			"awslogs-region":        aws.String(b.region),
+			"awslogs-stream-prefix": aws.String(app.ID),
+		},
+	}
 }
 
-// Iterates through all of the ECS services for this app and removes them.
+// Capabilities implements the twelvefactor.CapabilityProvider interface.
+func (b *StackBuilder) Capabilities() []twelvefactor.Capability {
+	var capabilities []twelvefactor.Capability
+	if b.EnableExecuteCommand {
+		capabilities = append(capabilities, twelvefactor.CapabilityExec)
+	}
+	capabilities = append(capabilities, twelvefactor.CapabilityHealthChecks)
+	if !b.Fargate {
+		capabilities = append(capabilities, twelvefactor.CapabilityPlacement)
+	}
+	return append(capabilities,
+		twelvefactor.CapabilityPlan,
+		twelvefactor.CapabilitySchedules,
+	)
+}
+
+// Iterates through all of the ECS services and schedules for this app and
+// removes them. Services are deleted even if they're still running tasks.
 func (b *StackBuilder) Remove(app string) error {
+	return ecserr.Translate(b.remove(app))
+}
+
+func (b *StackBuilder) remove(app string) error {
 	services, err := b.Services(app)
 	if err != nil {
 		return err
 	}
 
-	for _, service := range services {
-		if _, err := b.ecs.DeleteService(&ecs.DeleteServiceInput{
-			Cluster: aws.String(b.Cluster),
-			Service: aws.String(service),
-		}); err != nil {
+	for process, service := range services {
+		if err := b.deleteService(app, process, service); err != nil {
+			return err
+		}
+	}
+
+	schedules, err := b.Schedules(app)
+	if err != nil {
+		return err
+	}
+
+	for process, rule := range schedules {
+		if err := b.removeSchedule(process, rule); err != nil {
 			return err
 		}
 	}
@@ -134,40 +1350,87 @@ func (b *StackBuilder) Remove(app string) error {
 	return nil
 }
 
-// Services iterates through all of the ECS services in this cluster, and
-// returns the services that are members of the given app.
-func (b *StackBuilder) Services(app string) (map[string]string, error) {
-	services := make(map[string]string)
+// removeSchedule removes the target for the process from the rule, and then
+// deletes the rule.
+func (b *StackBuilder) removeSchedule(process, rule string) error {
+	if _, err := b.events.RemoveTargets(&cloudwatchevents.RemoveTargetsInput{
+		Rule: aws.String(rule),
+		Ids:  []*string{aws.String(process)},
+	}); err != nil {
+		return err
+	}
 
-	if err := b.ecs.ListServicesPages(&ecs.ListServicesInput{
-		Cluster: aws.String(b.Cluster),
-	}, func(resp *ecs.ListServicesOutput, lastPage bool) bool {
-		for _, serviceArn := range resp.ServiceArns {
-			if serviceArn == nil {
-				continue
-			}
+	_, err := b.events.DeleteRule(&cloudwatchevents.DeleteRuleInput{
+		Name: aws.String(rule),
+	})
+	return err
+}
 
-			id, err := arn.ResourceID(*serviceArn)
-			if err != nil {
-				return false
-			}
+// Schedules iterates through the CloudWatch Events rules for this app, and
+// returns a mapping of process name to rule name. The rule name is also the
+// task definition family for the process.
+func (b *StackBuilder) Schedules(app string) (map[string]string, error) {
+	rules, err := b.rules(app)
+	if err != nil {
+		return nil, err
+	}
+
+	schedules := make(map[string]string, len(rules))
+	for process, rule := range rules {
+		schedules[process] = aws.StringValue(rule.Name)
+	}
+	return schedules, nil
+}
 
-			appName, process, ok := b.split(id)
+// ScheduleExpressions returns a mapping of process name to the cron expression
+// that the process runs on. Rules that were created before the expression was
+// kept in their description are left out.
+func (b *StackBuilder) ScheduleExpressions(app string) (map[string]string, error) {
+	rules, err := b.rules(app)
+	if err != nil {
+		return nil, err
+	}
+
+	expressions := make(map[string]string, len(rules))
+	for process, rule := range rules {
+		if expr := aws.StringValue(rule.Description); expr != "" {
+			expressions[process] = expr
+		}
+	}
+	return expressions, nil
+}
+
+// rules returns the CloudWatch Events rules for the app, by process name.
+func (b *StackBuilder) rules(app string) (map[string]*cloudwatchevents.Rule, error) {
+	rules := make(map[string]*cloudwatchevents.Rule)
+
+	input := &cloudwatchevents.ListRulesInput{
+		NamePrefix: aws.String(app + b.delimiter()),
+	}
+	for {
+		resp, err := b.events.ListRules(input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rule := range resp.Rules {
+			appName, process, ok := b.split(aws.StringValue(rule.Name))
 			if !ok {
 				continue
 			}
 
 			if appName == app {
-				services[process] = id
+				rules[process] = rule
 			}
 		}
 
-		return true
-	}); err != nil {
-		return nil, err
+		if resp.NextToken == nil {
+			break
+		}
+		input.NextToken = resp.NextToken
 	}
 
-	return services, nil
+	return rules, nil
 }
 
 func (b *StackBuilder) split(service string) (app, process string, ok bool) {
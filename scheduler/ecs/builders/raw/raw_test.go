@@ -1,20 +1,31 @@
 package raw
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/pkg/aws/retry"
 	"github.com/remind101/12factor/pkg/bytesize"
+	"github.com/remind101/12factor/pkg/cpu"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
 func TestStackBuilder_Build(t *testing.T) {
 	c := new(mockECSClient)
+	e := new(mockEventsClient)
 	b := &StackBuilder{
 		Cluster: "cluster",
+		events:  e,
 		ecs:     c,
 	}
 
@@ -30,7 +41,7 @@ func TestStackBuilder_Build(t *testing.T) {
 		{
 			Name:      "web",
 			CPUShares: 256,
-			Memory:    int(1 * bytesize.GB),
+			Memory:    bytesize.GiB,
 		},
 	}
 
@@ -63,20 +74,1346 @@ func TestStackBuilder_Build(t *testing.T) {
 		Role:           aws.String(""),
 		ServiceName:    aws.String("app--web"),
 		TaskDefinition: aws.String("app--web:1"),
+		Tags:           tags("app", "web"),
 	}).Return(&ecs.CreateServiceOutput{}, nil)
+	c.On("ListServicesPages", mock.Anything).Return(nil, []*ecs.ListServicesOutput{})
+	e.On("ListRules", mock.Anything).Return(&cloudwatchevents.ListRulesOutput{}, nil)
 	err := b.Build(app, processes...)
 	assert.NoError(t, err)
 }
 
+func TestStackBuilder_Build_Update(t *testing.T) {
+	c := new(mockECSClient)
+	e := new(mockEventsClient)
+	b := &StackBuilder{
+		Cluster:              "cluster",
+		EnableExecuteCommand: true,
+		events:               e,
+		ecs:                  c,
+	}
+
+	app := twelvefactor.App{
+		Name: "app",
+		ID:   "app",
+	}
+
+	processes := []twelvefactor.Process{
+		{
+			Name:         "web",
+			DesiredCount: 2,
+			Placement: twelvefactor.Placement{
+				Strategies: []twelvefactor.PlacementStrategy{{Type: twelvefactor.Spread, Field: twelvefactor.FieldAvailabilityZone}},
+			},
+		},
+	}
+
+	// The service was created before services were tagged, so it's found
+	// by its name.
+	c.On("ListServicesPages", &ecs.ListServicesInput{
+		Cluster:    aws.String("cluster"),
+		MaxResults: aws.Int64(100),
+	}).Return(nil, []*ecs.ListServicesOutput{
+		{ServiceArns: []*string{aws.String("arn:aws:ecs:us-east-1:012345678910:service/app--web")}},
+	})
+	c.On("DescribeServices", mock.Anything).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{ServiceName: aws.String("app--web")},
+		},
+	}, nil)
+	c.On("RegisterTaskDefinition", mock.Anything).Return(&ecs.RegisterTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			Family:   aws.String("app--web"),
+			Revision: aws.Int64(2),
+		},
+	}, nil)
+	c.On("UpdateService", &ecs.UpdateServiceInput{
+		Cluster:              aws.String("cluster"),
+		Service:              aws.String("app--web"),
+		DesiredCount:         aws.Int64(2),
+		TaskDefinition:       aws.String("app--web:2"),
+		EnableExecuteCommand: aws.Bool(true),
+		PlacementConstraints: []*ecs.PlacementConstraint{},
+		PlacementStrategy: []*ecs.PlacementStrategy{
+			{Type: aws.String("spread"), Field: aws.String("attribute:ecs.availability-zone")},
+		},
+	}).Return(&ecs.UpdateServiceOutput{}, nil)
+
+	e.On("ListRules", mock.Anything).Return(&cloudwatchevents.ListRulesOutput{}, nil)
+	err := b.Build(app, processes...)
+	assert.NoError(t, err)
+	c.AssertNotCalled(t, "CreateService", mock.Anything)
+}
+
+func TestStackBuilder_Build_Fargate(t *testing.T) {
+	c := new(mockECSClient)
+	e := new(mockEventsClient)
+	b := &StackBuilder{
+		Cluster:        "cluster",
+		Fargate:        true,
+		Subnets:        []string{"subnet-1"},
+		SecurityGroups: []string{"sg-1"},
+		index:          new(serviceIndex),
+		events:         e,
+		ecs:            c,
+	}
+
+	app := twelvefactor.App{ID: "app"}
+
+	c.On("RegisterTaskDefinition", &ecs.RegisterTaskDefinitionInput{
+		Family:                  aws.String("app--web"),
+		Cpu:                     aws.String("512"),
+		Memory:                  aws.String("1024"),
+		NetworkMode:             aws.String("awsvpc"),
+		RequiresCompatibilities: []*string{aws.String("FARGATE")},
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				Name:      aws.String("web"),
+				Cpu:       aws.Int64(0),
+				Memory:    aws.Int64(1024),
+				Image:     aws.String(""),
+				Essential: aws.Bool(true),
+			},
+		},
+	}).Return(&ecs.RegisterTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			Family:   aws.String("app--web"),
+			Revision: aws.Int64(1),
+		},
+	}, nil)
+	c.On("CreateService", &ecs.CreateServiceInput{
+		Cluster:        aws.String("cluster"),
+		DesiredCount:   aws.Int64(1),
+		Role:           aws.String(""),
+		ServiceName:    aws.String("app--web"),
+		TaskDefinition: aws.String("app--web:1"),
+		LaunchType:     aws.String("FARGATE"),
+		NetworkConfiguration: &ecs.NetworkConfiguration{
+			AwsvpcConfiguration: &ecs.AwsVpcConfiguration{
+				Subnets:        []*string{aws.String("subnet-1")},
+				SecurityGroups: []*string{aws.String("sg-1")},
+			},
+		},
+		Tags: tags("app", "web"),
+	}).Return(&ecs.CreateServiceOutput{}, nil)
+
+	c.On("ListServicesPages", mock.Anything).Return(nil, []*ecs.ListServicesOutput{})
+	e.On("ListRules", mock.Anything).Return(&cloudwatchevents.ListRulesOutput{}, nil)
+	err := b.Build(app, twelvefactor.Process{
+		Name:         "web",
+		DesiredCount: 1,
+		CPULimit:     500 * cpu.MilliCPU,
+		Memory:       bytesize.GiB,
+	})
+	assert.NoError(t, err)
+
+	// Fargate only supports some CPU sizes.
+	err = b.Build(app, twelvefactor.Process{
+		Name:     "web",
+		CPULimit: 3 * cpu.VCPU,
+		Memory:   bytesize.GiB,
+	})
+	assert.True(t, errors.Is(err, twelvefactor.ErrInvalidConfig))
+
+	// The service now exists, so it's updated, keeping its network
+	// configuration.
+	c.On("UpdateService", &ecs.UpdateServiceInput{
+		Cluster:              aws.String("cluster"),
+		Service:              aws.String("app--web"),
+		DesiredCount:         aws.Int64(1),
+		TaskDefinition:       aws.String("app--web:1"),
+		EnableExecuteCommand: aws.Bool(false),
+		NetworkConfiguration: &ecs.NetworkConfiguration{
+			AwsvpcConfiguration: &ecs.AwsVpcConfiguration{
+				Subnets:        []*string{aws.String("subnet-1")},
+				SecurityGroups: []*string{aws.String("sg-1")},
+			},
+		},
+	}).Return(&ecs.UpdateServiceOutput{}, nil)
+	err = b.Build(app, twelvefactor.Process{
+		Name:         "web",
+		DesiredCount: 1,
+		CPULimit:     500 * cpu.MilliCPU,
+		Memory:       bytesize.GiB,
+	})
+	assert.NoError(t, err)
+
+	c.AssertExpectations(t)
+}
+
+func TestStackBuilder_Build_Placement(t *testing.T) {
+	c := new(mockECSClient)
+	e := new(mockEventsClient)
+	b := &StackBuilder{
+		Cluster: "cluster",
+		events:  e,
+		ecs:     c,
+	}
+
+	app := twelvefactor.App{
+		Name: "app",
+		ID:   "app",
+	}
+
+	processes := []twelvefactor.Process{
+		{
+			Name: "worker",
+			Placement: twelvefactor.Placement{
+				Constraints: []twelvefactor.PlacementConstraint{
+					{Type: twelvefactor.DistinctInstance},
+					{Type: twelvefactor.MemberOf, Attribute: "ecs.instance-type", Operator: "=~", Value: "r3.*"},
+				},
+				Strategies: []twelvefactor.PlacementStrategy{
+					{Type: twelvefactor.Spread, Field: twelvefactor.FieldAvailabilityZone},
+					{Type: twelvefactor.Binpack, Field: twelvefactor.FieldMemory},
+				},
+			},
+		},
+	}
+
+	c.On("RegisterTaskDefinition", mock.Anything).Return(&ecs.RegisterTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			Family:   aws.String("app--worker"),
+			Revision: aws.Int64(1),
+		},
+	}, nil)
+	c.On("CreateService", &ecs.CreateServiceInput{
+		Cluster:        aws.String("cluster"),
+		DesiredCount:   aws.Int64(0),
+		Role:           aws.String(""),
+		ServiceName:    aws.String("app--worker"),
+		TaskDefinition: aws.String("app--worker:1"),
+		Tags:           tags("app", "worker"),
+		PlacementConstraints: []*ecs.PlacementConstraint{
+			{Type: aws.String("distinctInstance")},
+			{Type: aws.String("memberOf"), Expression: aws.String("attribute:ecs.instance-type =~ r3.*")},
+		},
+		PlacementStrategy: []*ecs.PlacementStrategy{
+			{Type: aws.String("spread"), Field: aws.String("attribute:ecs.availability-zone")},
+			{Type: aws.String("binpack"), Field: aws.String("memory")},
+		},
+	}).Return(&ecs.CreateServiceOutput{}, nil)
+	c.On("ListServicesPages", mock.Anything).Return(nil, []*ecs.ListServicesOutput{})
+	e.On("ListRules", mock.Anything).Return(&cloudwatchevents.ListRulesOutput{}, nil)
+	err := b.Build(app, processes...)
+	assert.NoError(t, err)
+}
+
+func TestStackBuilder_Build_RollingDeployment(t *testing.T) {
+	c := new(mockECSClient)
+	e := new(mockEventsClient)
+	b := &StackBuilder{
+		Cluster: "cluster",
+		events:  e,
+		ecs:     c,
+	}
+
+	app := twelvefactor.App{
+		Name: "app",
+		ID:   "app",
+	}
+
+	processes := []twelvefactor.Process{
+		{
+			Name: "web",
+			Deployment: twelvefactor.Deployment{
+				Strategy: twelvefactor.Rolling,
+				Rolling: twelvefactor.RollingDeployment{
+					MinimumHealthyPercent: 50,
+					MaximumPercent:        200,
+				},
+			},
+		},
+	}
+
+	c.On("RegisterTaskDefinition", mock.Anything).Return(&ecs.RegisterTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			Family:   aws.String("app--web"),
+			Revision: aws.Int64(1),
+		},
+	}, nil)
+	c.On("CreateService", &ecs.CreateServiceInput{
+		Cluster:        aws.String("cluster"),
+		DesiredCount:   aws.Int64(0),
+		Role:           aws.String(""),
+		ServiceName:    aws.String("app--web"),
+		TaskDefinition: aws.String("app--web:1"),
+		Tags:           tags("app", "web"),
+		DeploymentConfiguration: &ecs.DeploymentConfiguration{
+			MinimumHealthyPercent: aws.Int64(50),
+			MaximumPercent:        aws.Int64(200),
+		},
+	}).Return(&ecs.CreateServiceOutput{}, nil)
+	c.On("ListServicesPages", mock.Anything).Return(nil, []*ecs.ListServicesOutput{})
+	e.On("ListRules", mock.Anything).Return(&cloudwatchevents.ListRulesOutput{}, nil)
+	err := b.Build(app, processes...)
+	assert.NoError(t, err)
+}
+
+func TestStackBuilder_Build_UnsupportedDeployment(t *testing.T) {
+	c := new(mockECSClient)
+	e := new(mockEventsClient)
+	b := &StackBuilder{
+		Cluster: "cluster",
+		events:  e,
+		ecs:     c,
+	}
+
+	app := twelvefactor.App{
+		Name: "app",
+		ID:   "app",
+	}
+
+	processes := []twelvefactor.Process{
+		{
+			Name: "web",
+			Deployment: twelvefactor.Deployment{
+				Strategy: twelvefactor.Canary,
+			},
+		},
+	}
+
+	c.On("ListServicesPages", mock.Anything).Return(nil, []*ecs.ListServicesOutput{})
+	e.On("ListRules", mock.Anything).Return(&cloudwatchevents.ListRulesOutput{}, nil)
+	err := b.Build(app, processes...)
+
+	var strategyErr *twelvefactor.UnsupportedStrategyError
+	if assert.True(t, errors.As(err, &strategyErr)) {
+		assert.Equal(t, &twelvefactor.UnsupportedStrategyError{Strategy: twelvefactor.Canary}, strategyErr)
+	}
+}
+
+func TestDeploymentConfiguration(t *testing.T) {
+	tests := []struct {
+		in     twelvefactor.Deployment
+		config *ecs.DeploymentConfiguration
+		err    error
+	}{
+		{twelvefactor.Deployment{}, nil, nil},
+		{twelvefactor.Deployment{Strategy: twelvefactor.Rolling}, nil, nil},
+
+		// Percentages that aren't set are left out, rather than sent
+		// as 0.
+		{
+			twelvefactor.Deployment{Strategy: twelvefactor.Rolling, Rolling: twelvefactor.RollingDeployment{MaximumPercent: 150}},
+			&ecs.DeploymentConfiguration{MaximumPercent: aws.Int64(150)},
+			nil,
+		},
+		{
+			twelvefactor.Deployment{Rolling: twelvefactor.RollingDeployment{MinimumHealthyPercent: 50}},
+			&ecs.DeploymentConfiguration{MinimumHealthyPercent: aws.Int64(50)},
+			nil,
+		},
+
+		{twelvefactor.Deployment{Strategy: twelvefactor.Rolling, Rolling: twelvefactor.RollingDeployment{MaximumPercent: 50}}, nil, twelvefactor.ErrInvalidConfig},
+		{twelvefactor.Deployment{Canary: twelvefactor.CanaryDeployment{Percent: 10}}, nil, twelvefactor.ErrInvalidConfig},
+		{twelvefactor.Deployment{Strategy: twelvefactor.BlueGreen}, nil, twelvefactor.ErrUnsupported},
+	}
+
+	for i, tt := range tests {
+		config, err := deploymentConfiguration(tt.in)
+		assert.Equal(t, tt.config, config, "#%d", i)
+		if tt.err == nil {
+			assert.NoError(t, err, "#%d", i)
+		} else {
+			assert.True(t, errors.Is(err, tt.err), "#%d: %v", i, err)
+		}
+	}
+}
+
+func TestStackBuilder_Build_Errors(t *testing.T) {
+	c := new(mockECSClient)
+	e := new(mockEventsClient)
+	b := &StackBuilder{
+		Cluster: "cluster",
+		events:  e,
+		ecs:     c,
+	}
+
+	app := twelvefactor.App{
+		Name: "app",
+		ID:   "app",
+	}
+
+	canary := twelvefactor.Deployment{Strategy: twelvefactor.Canary}
+	processes := []twelvefactor.Process{
+		{Name: "web", Deployment: canary},
+		{Name: "worker"},
+		{Name: "metrics", Deployment: canary},
+	}
+
+	c.On("RegisterTaskDefinition", mock.Anything).Return(&ecs.RegisterTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			Family:   aws.String("app--worker"),
+			Revision: aws.Int64(1),
+		},
+	}, nil)
+	c.On("CreateService", mock.Anything).Return(&ecs.CreateServiceOutput{}, nil)
+	c.On("ListServicesPages", mock.Anything).Return(nil, []*ecs.ListServicesOutput{})
+	e.On("ListRules", mock.Anything).Return(&cloudwatchevents.ListRulesOutput{}, nil)
+	err := b.Build(app, processes...)
+
+	// Every process is attempted, and every failure is reported.
+	assert.Equal(t, &BuildError{
+		Errors: []*ProcessError{
+			{Process: "web", Err: &twelvefactor.UnsupportedStrategyError{Strategy: twelvefactor.Canary}},
+			{Process: "metrics", Err: &twelvefactor.UnsupportedStrategyError{Strategy: twelvefactor.Canary}},
+		},
+	}, err)
+	c.AssertNumberOfCalls(t, "CreateService", 1)
+	c.AssertNotCalled(t, "DeleteService", mock.Anything)
+}
+
+func TestStackBuilder_Build_Concurrency(t *testing.T) {
+	c := new(mockECSClient)
+	e := new(mockEventsClient)
+	b := &StackBuilder{
+		Cluster:     "cluster",
+		Concurrency: 2,
+		events:      e,
+		ecs:         c,
+	}
+
+	app := twelvefactor.App{
+		Name: "app",
+		ID:   "app",
+	}
+
+	var processes []twelvefactor.Process
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		processes = append(processes, twelvefactor.Process{Name: name})
+	}
+
+	var (
+		mu                sync.Mutex
+		inFlight, maxSeen int
+		started           = make(chan struct{})
+		release           = make(chan struct{})
+	)
+	c.On("RegisterTaskDefinition", mock.Anything).Return(&ecs.RegisterTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			Family:   aws.String("app--a"),
+			Revision: aws.Int64(1),
+		},
+	}, nil).Run(func(mock.Arguments) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxSeen {
+			maxSeen = inFlight
+		}
+		mu.Unlock()
+
+		started <- struct{}{}
+		<-release
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	})
+	c.On("CreateService", mock.Anything).Return(&ecs.CreateServiceOutput{}, nil)
+
+	c.On("ListServicesPages", mock.Anything).Return(nil, []*ecs.ListServicesOutput{})
+	e.On("ListRules", mock.Anything).Return(&cloudwatchevents.ListRulesOutput{}, nil)
+	done := make(chan error)
+	go func() {
+		done <- b.Build(app, processes...)
+	}()
+
+	// Processes are let through in pairs, which only completes if two
+	// are deployed at once.
+	for i := 0; i < len(processes); i += 2 {
+		for j := 0; j < 2; j++ {
+			select {
+			case <-started:
+			case <-time.After(time.Second):
+				t.Fatal("timed out waiting for processes to be deployed concurrently")
+			}
+		}
+		release <- struct{}{}
+		release <- struct{}{}
+	}
+
+	assert.NoError(t, <-done)
+	assert.Equal(t, 2, maxSeen)
+	c.AssertNumberOfCalls(t, "CreateService", len(processes))
+}
+
+func TestStackBuilder_Build_Atomic(t *testing.T) {
+	c := new(mockECSClient)
+	e := new(mockEventsClient)
+	b := &StackBuilder{
+		Cluster:    "cluster",
+		EventsRole: "arn:aws:iam::012345678910:role/events",
+		Atomic:     true,
+		ecs:        c,
+		events:     e,
+	}
+
+	app := twelvefactor.App{
+		Name: "app",
+		ID:   "app",
+	}
+
+	processes := []twelvefactor.Process{
+		// Already exists, so it's updated and then reverted.
+		{Name: "web", DesiredCount: 2},
+		// Created by this build, so it's removed.
+		{Name: "worker"},
+		// Already scheduled, so it's restored.
+		{Name: "cleanup", Schedule: "@daily"},
+		// Not scheduled yet, so it's removed.
+		{Name: "report", Schedule: "@hourly"},
+		// Fails.
+		{Name: "metrics", Deployment: twelvefactor.Deployment{Strategy: twelvefactor.Canary}},
+	}
+
+	previousTarget := &cloudwatchevents.Target{
+		Id:  aws.String("cleanup"),
+		Arn: aws.String("arn:aws:ecs:us-east-1:012345678910:cluster/cluster"),
+		EcsParameters: &cloudwatchevents.EcsParameters{
+			TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:012345678910:task-definition/app--cleanup:1"),
+			TaskCount:         aws.Int64(1),
+		},
+	}
+
+	c.On("ListServicesPages", &ecs.ListServicesInput{
+		Cluster:    aws.String("cluster"),
+		MaxResults: aws.Int64(100),
+	}).Return(nil, []*ecs.ListServicesOutput{
+		{
+			ServiceArns: []*string{
+				aws.String("arn:aws:ecs:us-east-1:012345678910:service/app--web"),
+			},
+		},
+	})
+	c.On("DescribeServices", mock.Anything).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{
+				ServiceName:    aws.String("app--web"),
+				TaskDefinition: aws.String("arn:aws:ecs:us-east-1:012345678910:task-definition/app--web:1"),
+				DesiredCount:   aws.Int64(1),
+			},
+		},
+	}, nil)
+	e.On("ListRules", &cloudwatchevents.ListRulesInput{
+		NamePrefix: aws.String("app--"),
+	}).Return(&cloudwatchevents.ListRulesOutput{
+		Rules: []*cloudwatchevents.Rule{
+			{Name: aws.String("app--cleanup")},
+		},
+	}, nil)
+	e.On("DescribeRule", &cloudwatchevents.DescribeRuleInput{
+		Name: aws.String("app--cleanup"),
+	}).Return(&cloudwatchevents.DescribeRuleOutput{
+		Name:               aws.String("app--cleanup"),
+		ScheduleExpression: aws.String("cron(0 4 * * ? *)"),
+		State:              aws.String("ENABLED"),
+	}, nil)
+	e.On("ListTargetsByRule", &cloudwatchevents.ListTargetsByRuleInput{
+		Rule: aws.String("app--cleanup"),
+	}).Return(&cloudwatchevents.ListTargetsByRuleOutput{
+		Targets: []*cloudwatchevents.Target{previousTarget},
+	}, nil)
+
+	c.On("RegisterTaskDefinition", mock.Anything).Return(&ecs.RegisterTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:012345678910:task-definition/app--web:2"),
+			Family:            aws.String("app--web"),
+			Revision:          aws.Int64(2),
+		},
+	}, nil)
+	c.On("CreateService", mock.Anything).Return(&ecs.CreateServiceOutput{}, nil)
+	c.On("UpdateService", mock.Anything).Return(&ecs.UpdateServiceOutput{}, nil)
+	c.On("DescribeClusters", mock.Anything).Return(&ecs.DescribeClustersOutput{
+		Clusters: []*ecs.Cluster{
+			{ClusterArn: aws.String("arn:aws:ecs:us-east-1:012345678910:cluster/cluster")},
+		},
+	}, nil)
+	e.On("PutRule", mock.Anything).Return(&cloudwatchevents.PutRuleOutput{}, nil)
+	e.On("PutTargets", mock.Anything).Return(&cloudwatchevents.PutTargetsOutput{}, nil)
+
+	// Reverts.
+	c.On("DeleteService", &ecs.DeleteServiceInput{
+		Cluster: aws.String("cluster"),
+		Service: aws.String("app--worker"),
+		Force:   aws.Bool(true),
+	}).Return(&ecs.DeleteServiceOutput{}, nil)
+	e.On("RemoveTargets", &cloudwatchevents.RemoveTargetsInput{
+		Rule: aws.String("app--report"),
+		Ids:  []*string{aws.String("report")},
+	}).Return(&cloudwatchevents.RemoveTargetsOutput{}, nil)
+	e.On("DeleteRule", &cloudwatchevents.DeleteRuleInput{
+		Name: aws.String("app--report"),
+	}).Return(&cloudwatchevents.DeleteRuleOutput{}, nil)
+
+	err := b.Build(app, processes...)
+	assert.Equal(t, &BuildError{
+		Errors: []*ProcessError{
+			{Process: "metrics", Err: &twelvefactor.UnsupportedStrategyError{Strategy: twelvefactor.Canary}},
+		},
+		Reverted: []string{"web", "worker", "cleanup", "report"},
+	}, err)
+
+	c.AssertCalled(t, "UpdateService", &ecs.UpdateServiceInput{
+		Cluster:              aws.String("cluster"),
+		Service:              aws.String("app--web"),
+		DesiredCount:         aws.Int64(2),
+		TaskDefinition:       aws.String("app--web:2"),
+		EnableExecuteCommand: aws.Bool(false),
+		PlacementConstraints: []*ecs.PlacementConstraint{},
+		PlacementStrategy:    []*ecs.PlacementStrategy{},
+	})
+	c.AssertCalled(t, "UpdateService", &ecs.UpdateServiceInput{
+		Cluster:        aws.String("cluster"),
+		Service:        aws.String("app--web"),
+		DesiredCount:   aws.Int64(1),
+		TaskDefinition: aws.String("arn:aws:ecs:us-east-1:012345678910:task-definition/app--web:1"),
+	})
+
+	c.AssertCalled(t, "DeleteService", &ecs.DeleteServiceInput{
+		Cluster: aws.String("cluster"),
+		Service: aws.String("app--worker"),
+		Force:   aws.Bool(true),
+	})
+	c.AssertNumberOfCalls(t, "DeleteService", 1)
+	e.AssertCalled(t, "PutRule", &cloudwatchevents.PutRuleInput{
+		Name:               aws.String("app--cleanup"),
+		ScheduleExpression: aws.String("cron(0 4 * * ? *)"),
+		State:              aws.String("ENABLED"),
+	})
+	e.AssertCalled(t, "PutTargets", &cloudwatchevents.PutTargetsInput{
+		Rule:    aws.String("app--cleanup"),
+		Targets: []*cloudwatchevents.Target{previousTarget},
+	})
+	e.AssertCalled(t, "DeleteRule", &cloudwatchevents.DeleteRuleInput{
+		Name: aws.String("app--report"),
+	})
+}
+
+func TestStackBuilder_Build_Atomic_RevertError(t *testing.T) {
+	c := new(mockECSClient)
+	b := &StackBuilder{
+		Cluster: "cluster",
+		Atomic:  true,
+		ecs:     c,
+		events:  new(mockEventsClient),
+	}
+
+	app := twelvefactor.App{
+		Name: "app",
+		ID:   "app",
+	}
+
+	processes := []twelvefactor.Process{
+		{Name: "web"},
+		{Name: "metrics", Deployment: twelvefactor.Deployment{Strategy: twelvefactor.Canary}},
+	}
+
+	c.On("ListServicesPages", mock.Anything).Return(nil, []*ecs.ListServicesOutput{})
+	b.events.(*mockEventsClient).On("ListRules", mock.Anything).Return(&cloudwatchevents.ListRulesOutput{}, nil)
+	c.On("RegisterTaskDefinition", mock.Anything).Return(&ecs.RegisterTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			Family:   aws.String("app--web"),
+			Revision: aws.Int64(1),
+		},
+	}, nil)
+	c.On("CreateService", mock.Anything).Return(&ecs.CreateServiceOutput{}, nil)
+	c.On("DeleteService", mock.Anything).Return(&ecs.DeleteServiceOutput{}, errors.New("boom"))
+
+	err := b.Build(app, processes...)
+	assert.EqualError(t, err, "failed to deploy metrics: canary deployment strategy is not supported; failed to revert web: boom")
+}
+
+func TestBuildError_Error(t *testing.T) {
+	err := &BuildError{
+		Errors: []*ProcessError{
+			{Process: "web", Err: errors.New("boom")},
+			{Process: "worker", Err: errors.New("bang")},
+		},
+		Reverted: []string{"cleanup", "metrics"},
+	}
+	assert.EqualError(t, err, "failed to deploy web: boom; worker: bang; reverted cleanup, metrics")
+}
+
+func TestStackBuilder_Build_Schedule(t *testing.T) {
+	c := new(mockECSClient)
+	e := new(mockEventsClient)
+	b := &StackBuilder{
+		Cluster:    "cluster",
+		EventsRole: "arn:aws:iam::012345678910:role/events",
+		ecs:        c,
+		events:     e,
+	}
+
+	app := twelvefactor.App{
+		Name: "app",
+		ID:   "app",
+	}
+
+	processes := []twelvefactor.Process{
+		{
+			Name:         "cleanup",
+			Schedule:     "30 4 * * 1-5",
+			DesiredCount: 2,
+		},
+	}
+
+	c.On("RegisterTaskDefinition", mock.Anything).Return(&ecs.RegisterTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:012345678910:task-definition/app--cleanup:1"),
+			Family:            aws.String("app--cleanup"),
+			Revision:          aws.Int64(1),
+		},
+	}, nil)
+	c.On("DescribeClusters", &ecs.DescribeClustersInput{
+		Clusters: []*string{aws.String("cluster")},
+	}).Return(&ecs.DescribeClustersOutput{
+		Clusters: []*ecs.Cluster{
+			{ClusterArn: aws.String("arn:aws:ecs:us-east-1:012345678910:cluster/cluster")},
+		},
+	}, nil)
+	e.On("PutRule", &cloudwatchevents.PutRuleInput{
+		Name:               aws.String("app--cleanup"),
+		Description:        aws.String("30 4 * * 1-5"),
+		ScheduleExpression: aws.String("cron(30 4 ? * 2-6 *)"),
+		State:              aws.String("ENABLED"),
+	}).Return(&cloudwatchevents.PutRuleOutput{}, nil)
+	e.On("PutTargets", &cloudwatchevents.PutTargetsInput{
+		Rule: aws.String("app--cleanup"),
+		Targets: []*cloudwatchevents.Target{
+			{
+				Id:      aws.String("cleanup"),
+				Arn:     aws.String("arn:aws:ecs:us-east-1:012345678910:cluster/cluster"),
+				RoleArn: aws.String("arn:aws:iam::012345678910:role/events"),
+				EcsParameters: &cloudwatchevents.EcsParameters{
+					TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:012345678910:task-definition/app--cleanup:1"),
+					TaskCount:         aws.Int64(2),
+				},
+			},
+		},
+	}).Return(&cloudwatchevents.PutTargetsOutput{}, nil)
+	c.On("ListServicesPages", mock.Anything).Return(nil, []*ecs.ListServicesOutput{})
+	e.On("ListRules", mock.Anything).Return(&cloudwatchevents.ListRulesOutput{}, nil)
+	err := b.Build(app, processes...)
+	assert.NoError(t, err)
+	c.AssertNotCalled(t, "CreateService", mock.Anything)
+}
+
+func TestStackBuilder_Build_ServiceToSchedule(t *testing.T) {
+	c := new(mockECSClient)
+	e := new(mockEventsClient)
+	b := &StackBuilder{
+		Cluster: "cluster",
+		ecs:     c,
+		events:  e,
+	}
+
+	app := twelvefactor.App{
+		Name: "app",
+		ID:   "app",
+	}
+
+	processes := []twelvefactor.Process{
+		{
+			Name:     "cleanup",
+			Schedule: "30 4 * * 1-5",
+		},
+	}
+
+	c.On("ListServicesPages", mock.Anything).Return(nil, []*ecs.ListServicesOutput{
+		{ServiceArns: []*string{aws.String("arn:aws:ecs:us-east-1:012345678910:service/app--cleanup")}},
+	})
+	c.On("DescribeServices", mock.Anything).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{ServiceName: aws.String("app--cleanup")},
+		},
+	}, nil)
+	e.On("ListRules", mock.Anything).Return(&cloudwatchevents.ListRulesOutput{}, nil)
+	c.On("RegisterTaskDefinition", mock.Anything).Return(&ecs.RegisterTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:012345678910:task-definition/app--cleanup:1"),
+			Family:            aws.String("app--cleanup"),
+			Revision:          aws.Int64(1),
+		},
+	}, nil)
+	c.On("DescribeClusters", mock.Anything).Return(&ecs.DescribeClustersOutput{
+		Clusters: []*ecs.Cluster{
+			{ClusterArn: aws.String("arn:aws:ecs:us-east-1:012345678910:cluster/cluster")},
+		},
+	}, nil)
+	e.On("PutRule", mock.Anything).Return(&cloudwatchevents.PutRuleOutput{}, nil)
+	e.On("PutTargets", mock.Anything).Return(&cloudwatchevents.PutTargetsOutput{}, nil)
+	c.On("DeleteService", &ecs.DeleteServiceInput{
+		Cluster: aws.String("cluster"),
+		Service: aws.String("app--cleanup"),
+		Force:   aws.Bool(true),
+	}).Return(&ecs.DeleteServiceOutput{}, nil)
+
+	err := b.Build(app, processes...)
+	assert.NoError(t, err)
+	c.AssertNotCalled(t, "UpdateService", mock.Anything)
+	c.AssertCalled(t, "DeleteService", mock.Anything)
+}
+
+func TestStackBuilder_Build_Prune(t *testing.T) {
+	c := new(mockECSClient)
+	e := new(mockEventsClient)
+	b := &StackBuilder{
+		Cluster: "cluster",
+		index:   new(serviceIndex),
+		ecs:     c,
+		events:  e,
+	}
+
+	app := twelvefactor.App{ID: "app"}
+
+	c.On("ListServicesPages", mock.Anything).Return(nil, []*ecs.ListServicesOutput{
+		{ServiceArns: []*string{aws.String("app--web"), aws.String("app--worker")}},
+	})
+	c.On("DescribeServices", mock.Anything).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{ServiceName: aws.String("app--web"), Tags: tags("app", "web")},
+			{ServiceName: aws.String("app--worker"), Tags: tags("app", "worker")},
+		},
+	}, nil)
+	e.On("ListRules", mock.Anything).Return(&cloudwatchevents.ListRulesOutput{
+		Rules: []*cloudwatchevents.Rule{{Name: aws.String("app--cleanup")}},
+	}, nil)
+	c.On("RegisterTaskDefinition", mock.Anything).Return(&ecs.RegisterTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			Family:   aws.String("app--web"),
+			Revision: aws.Int64(2),
+		},
+	}, nil)
+	c.On("UpdateService", mock.Anything).Return(&ecs.UpdateServiceOutput{}, nil)
+
+	// Only web is given, so worker's service and cleanup's rule are
+	// removed.
+	c.On("DeleteService", &ecs.DeleteServiceInput{
+		Cluster: aws.String("cluster"),
+		Service: aws.String("app--worker"),
+		Force:   aws.Bool(true),
+	}).Return(&ecs.DeleteServiceOutput{}, nil)
+	e.On("RemoveTargets", &cloudwatchevents.RemoveTargetsInput{
+		Rule: aws.String("app--cleanup"),
+		Ids:  []*string{aws.String("cleanup")},
+	}).Return(&cloudwatchevents.RemoveTargetsOutput{}, nil)
+	e.On("DeleteRule", &cloudwatchevents.DeleteRuleInput{
+		Name: aws.String("app--cleanup"),
+	}).Return(&cloudwatchevents.DeleteRuleOutput{}, nil)
+
+	err := b.Build(app, twelvefactor.Process{Name: "web", DesiredCount: 1})
+	assert.NoError(t, err)
+
+	services, err := b.Services("app")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"web": "app--web"}, services)
+
+	c.AssertExpectations(t)
+	e.AssertExpectations(t)
+}
+
+func TestStackBuilder_Build_Prune_Atomic(t *testing.T) {
+	c := new(mockECSClient)
+	e := new(mockEventsClient)
+	b := &StackBuilder{
+		Cluster: "cluster",
+		Atomic:  true,
+		ecs:     c,
+		events:  e,
+	}
+
+	c.On("ListServicesPages", mock.Anything).Return(nil, []*ecs.ListServicesOutput{
+		{ServiceArns: []*string{aws.String("app--worker")}},
+	})
+	c.On("DescribeServices", mock.Anything).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{ServiceName: aws.String("app--worker"), Tags: tags("app", "worker")},
+		},
+	}, nil)
+	e.On("ListRules", mock.Anything).Return(&cloudwatchevents.ListRulesOutput{}, nil)
+	c.On("RegisterTaskDefinition", mock.Anything).Return((*ecs.RegisterTaskDefinitionOutput)(nil), errors.New("boom"))
+
+	// Nothing is removed when an atomic Build fails.
+	err := b.Build(twelvefactor.App{ID: "app"}, twelvefactor.Process{Name: "web", DesiredCount: 1})
+	assert.EqualError(t, err, "failed to deploy web: boom")
+	c.AssertNotCalled(t, "DeleteService", mock.Anything)
+}
+
+func TestStackBuilder_Build_ScheduleToService(t *testing.T) {
+	c := new(mockECSClient)
+	e := new(mockEventsClient)
+	b := &StackBuilder{
+		Cluster: "cluster",
+		ecs:     c,
+		events:  e,
+	}
+
+	app := twelvefactor.App{
+		Name: "app",
+		ID:   "app",
+	}
+
+	processes := []twelvefactor.Process{
+		{Name: "cleanup"},
+	}
+
+	c.On("ListServicesPages", mock.Anything).Return(nil, []*ecs.ListServicesOutput{})
+	e.On("ListRules", mock.Anything).Return(&cloudwatchevents.ListRulesOutput{
+		Rules: []*cloudwatchevents.Rule{
+			{Name: aws.String("app--cleanup")},
+		},
+	}, nil)
+	c.On("RegisterTaskDefinition", mock.Anything).Return(&ecs.RegisterTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			Family:   aws.String("app--cleanup"),
+			Revision: aws.Int64(1),
+		},
+	}, nil)
+	c.On("CreateService", mock.Anything).Return(&ecs.CreateServiceOutput{}, nil)
+	e.On("RemoveTargets", &cloudwatchevents.RemoveTargetsInput{
+		Rule: aws.String("app--cleanup"),
+		Ids:  []*string{aws.String("cleanup")},
+	}).Return(&cloudwatchevents.RemoveTargetsOutput{}, nil)
+	e.On("DeleteRule", &cloudwatchevents.DeleteRuleInput{
+		Name: aws.String("app--cleanup"),
+	}).Return(&cloudwatchevents.DeleteRuleOutput{}, nil)
+
+	err := b.Build(app, processes...)
+	assert.NoError(t, err)
+	e.AssertCalled(t, "DeleteRule", mock.Anything)
+}
+
+func TestScheduleExpression(t *testing.T) {
+	tests := []struct {
+		in, out string
+		err     bool
+	}{
+		{"@every 1m", "rate(1 minute)", false},
+		{"@every 2h", "rate(120 minutes)", false},
+		{"@every 90s", "", true},
+		{"@daily", "cron(0 0 * * ? *)", false},
+		{"0 12 1 * *", "cron(0 12 1 * ? *)", false},
+		{"*/5 * * * 0,6", "cron(*/5 * ? * 1,7 *)", false},
+		{"0 0 * * 1-5/2", "cron(0 0 ? * 2-6/2 *)", false},
+		{"0 0 * * 7", "cron(0 0 ? * 1 *)", false},
+		{"0 0 * * 5-7", "cron(0 0 ? * 6,7,1 *)", false},
+		{"0 0 * * 1,3-7/2", "cron(0 0 ? * 2,4,6,1 *)", false},
+		{"0 0 1 * 1", "", true},
+		{"bogus", "", true},
+	}
+
+	for i, tt := range tests {
+		out, err := scheduleExpression(tt.in)
+		if tt.err {
+			assert.Error(t, err, "#%d", i)
+			continue
+		}
+
+		assert.NoError(t, err, "#%d", i)
+		assert.Equal(t, tt.out, out, "#%d", i)
+	}
+}
+
+func TestStackBuilder_RegisterTaskDefinition_Logs(t *testing.T) {
+	c := new(mockECSClient)
+	b := &StackBuilder{
+		LogGroup: "logs",
+		region:   "us-east-1",
+		ecs:      c,
+	}
+
+	c.On("RegisterTaskDefinition", &ecs.RegisterTaskDefinitionInput{
+		Family: aws.String("app--web"),
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				Name:      aws.String("web"),
+				Cpu:       aws.Int64(0),
+				Memory:    aws.Int64(0),
+				Image:     aws.String(""),
+				Essential: aws.Bool(true),
+				LogConfiguration: &ecs.LogConfiguration{
+					LogDriver: aws.String("awslogs"),
+					Options: map[string]*string{
+						"awslogs-group":         aws.String("logs"),
+						"awslogs-region":        aws.String("us-east-1"),
+						"awslogs-stream-prefix": aws.String("app"),
+					},
+				},
+			},
+		},
+	}).Return(&ecs.RegisterTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			Family:   aws.String("app--web"),
+			Revision: aws.Int64(2),
+		},
+	}, nil)
+	taskDefinition, err := b.RegisterTaskDefinition(twelvefactor.App{ID: "app"}, twelvefactor.Process{Name: "web"})
+	assert.NoError(t, err)
+	assert.Equal(t, "app--web:2", taskDefinition)
+}
+
+func TestStackBuilder_RegisterTaskDefinition_CPU(t *testing.T) {
+	c := new(mockECSClient)
+	b := &StackBuilder{
+		ecs: c,
+	}
+
+	c.On("RegisterTaskDefinition", &ecs.RegisterTaskDefinitionInput{
+		Family: aws.String("app--web"),
+		Cpu:    aws.String("2048"),
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				Name:      aws.String("web"),
+				Cpu:       aws.Int64(256),
+				Memory:    aws.Int64(0),
+				Image:     aws.String(""),
+				Essential: aws.Bool(true),
+			},
+		},
+	}).Return(&ecs.RegisterTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			Family:   aws.String("app--web"),
+			Revision: aws.Int64(1),
+		},
+	}, nil)
+
+	_, err := b.RegisterTaskDefinition(twelvefactor.App{ID: "app"}, twelvefactor.Process{
+		Name:     "web",
+		CPU:      250 * cpu.MilliCPU,
+		CPULimit: 2 * cpu.VCPU,
+	})
+	assert.NoError(t, err)
+
+	c.AssertExpectations(t)
+}
+
+func TestStackBuilder_RegisterTaskDefinition_MemoryReservation(t *testing.T) {
+	c := new(mockECSClient)
+	b := &StackBuilder{
+		ecs: c,
+	}
+
+	c.On("RegisterTaskDefinition", &ecs.RegisterTaskDefinitionInput{
+		Family: aws.String("app--worker"),
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				Name:              aws.String("worker"),
+				Cpu:               aws.Int64(0),
+				MemoryReservation: aws.Int64(256),
+				Image:             aws.String(""),
+				Essential:         aws.Bool(true),
+			},
+		},
+	}).Return(&ecs.RegisterTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			Family:   aws.String("app--worker"),
+			Revision: aws.Int64(1),
+		},
+	}, nil)
+
+	_, err := b.RegisterTaskDefinition(twelvefactor.App{ID: "app"}, twelvefactor.Process{
+		Name:              "worker",
+		MemoryReservation: 256 * bytesize.MiB,
+	})
+	assert.NoError(t, err)
+
+	c.AssertExpectations(t)
+}
+
+func TestHealthCheck(t *testing.T) {
+	assert.Nil(t, healthCheck(nil))
+
+	assert.Equal(t, &ecs.HealthCheck{
+		Command:  []*string{aws.String("CMD"), aws.String("curl"), aws.String("-f"), aws.String("http://localhost/health")},
+		Interval: aws.Int64(30),
+		Retries:  aws.Int64(3),
+	}, healthCheck(&twelvefactor.HealthCheck{
+		Command:  []string{"curl", "-f", "http://localhost/health"},
+		Interval: 30 * time.Second,
+		Retries:  3,
+	}))
+}
+
+func TestHealthCheck_Rounding(t *testing.T) {
+	check := healthCheck(&twelvefactor.HealthCheck{
+		Command:     []string{"true"},
+		Interval:    5500 * time.Millisecond,
+		Timeout:     500 * time.Millisecond,
+		StartPeriod: 10 * time.Second,
+	})
+	assert.Equal(t, aws.Int64(6), check.Interval)
+	assert.Equal(t, aws.Int64(1), check.Timeout)
+	assert.Equal(t, aws.Int64(10), check.StartPeriod)
+}
+
+func TestValidateHealthCheck(t *testing.T) {
+	tests := []struct {
+		check *twelvefactor.HealthCheck
+		err   string
+	}{
+		{nil, ""},
+		{&twelvefactor.HealthCheck{Command: []string{"true"}}, ""},
+		{&twelvefactor.HealthCheck{Interval: 30 * time.Second, Timeout: 5 * time.Second, Retries: 3, StartPeriod: time.Minute}, ""},
+		{&twelvefactor.HealthCheck{Interval: 4500 * time.Millisecond}, ""},
+		{&twelvefactor.HealthCheck{Interval: 500 * time.Millisecond}, "health check interval for web must be between 5s and 5m0s, got 1s"},
+		{&twelvefactor.HealthCheck{Interval: 10 * time.Minute}, "health check interval for web must be between 5s and 5m0s, got 10m0s"},
+		{&twelvefactor.HealthCheck{Timeout: time.Second}, "health check timeout for web must be between 2s and 1m0s, got 1s"},
+		{&twelvefactor.HealthCheck{Timeout: 2 * time.Minute}, "health check timeout for web must be between 2s and 1m0s, got 2m0s"},
+		{&twelvefactor.HealthCheck{Retries: 11}, "health check retries for web must be between 1 and 10, got 11"},
+		{&twelvefactor.HealthCheck{Retries: -1}, "health check retries for web must be between 1 and 10, got -1"},
+		{&twelvefactor.HealthCheck{StartPeriod: 301 * time.Second}, "health check start period for web must be at most 5m0s, got 5m1s"},
+	}
+
+	for i, tt := range tests {
+		err := validateHealthCheck(twelvefactor.Process{Name: "web", HealthCheck: tt.check})
+		if tt.err == "" {
+			assert.NoError(t, err, "#%d", i)
+		} else {
+			assert.EqualError(t, err, tt.err, "#%d", i)
+		}
+	}
+}
+
+func TestValidateMemory(t *testing.T) {
+	tests := []struct {
+		process twelvefactor.Process
+		err     string
+	}{
+		{twelvefactor.Process{Name: "web"}, ""},
+		{twelvefactor.Process{Name: "web", MemoryReservation: 512 * bytesize.MiB}, ""},
+		{twelvefactor.Process{Name: "web", Memory: bytesize.GiB, MemoryReservation: 512 * bytesize.MiB}, ""},
+		{twelvefactor.Process{Name: "web", Memory: 512 * bytesize.MiB, MemoryReservation: bytesize.GiB}, "memory reservation for web (1GiB) exceeds its limit (512MiB)"},
+	}
+
+	for i, tt := range tests {
+		err := validateMemory(tt.process)
+		if tt.err == "" {
+			assert.NoError(t, err, "#%d", i)
+		} else {
+			assert.EqualError(t, err, tt.err, "#%d", i)
+		}
+	}
+}
+
+func TestValidateCPU(t *testing.T) {
+	tests := []struct {
+		process twelvefactor.Process
+		err     string
+	}{
+		{twelvefactor.Process{Name: "web"}, ""},
+		{twelvefactor.Process{Name: "web", CPUShares: 1024, CPULimit: 2 * cpu.VCPU}, ""},
+		{twelvefactor.Process{Name: "web", CPU: 500 * cpu.MilliCPU, CPULimit: 125 * cpu.MilliCPU}, "cpu reservation for web (500m) exceeds its limit (125m)"},
+		{twelvefactor.Process{Name: "web", CPUShares: 2048, CPULimit: cpu.VCPU}, "cpu reservation for web (2) exceeds its limit (1)"},
+		{twelvefactor.Process{Name: "web", CPULimit: 100 * cpu.MilliCPU}, "cpu limit for web must be between 125m and 192, got 100m"},
+		{twelvefactor.Process{Name: "web", CPU: 256 * cpu.VCPU}, "cpu reservation for web must be at most 192, got 256"},
+	}
+
+	for i, tt := range tests {
+		err := validateCPU(tt.process)
+		if tt.err == "" {
+			assert.NoError(t, err, "#%d", i)
+		} else {
+			assert.EqualError(t, err, tt.err, "#%d", i)
+		}
+	}
+}
+
+func TestValidateFargate(t *testing.T) {
+	tests := []struct {
+		process twelvefactor.Process
+		err     string
+	}{
+		{twelvefactor.Process{Name: "web", CPULimit: 250 * cpu.MilliCPU, Memory: 512 * bytesize.MiB}, ""},
+		{twelvefactor.Process{Name: "web", CPULimit: 16 * cpu.VCPU, Memory: 32 * bytesize.GiB}, ""},
+		{twelvefactor.Process{Name: "web", Memory: 512 * bytesize.MiB}, "cpu limit for web must be one of 250m, 500m, 1, 2, 4, 8, 16 on Fargate, got 0"},
+		{twelvefactor.Process{Name: "web", CPULimit: 3 * cpu.VCPU, Memory: 8 * bytesize.GiB}, "cpu limit for web must be one of 250m, 500m, 1, 2, 4, 8, 16 on Fargate, got 3"},
+		{twelvefactor.Process{Name: "web", CPULimit: 125 * cpu.MilliCPU, Memory: 512 * bytesize.MiB}, "cpu limit for web must be one of 250m, 500m, 1, 2, 4, 8, 16 on Fargate, got 125m"},
+		{twelvefactor.Process{Name: "web", CPULimit: cpu.VCPU}, "memory limit for web is required on Fargate"},
+		{twelvefactor.Process{
+			Name:      "web",
+			CPULimit:  cpu.VCPU,
+			Memory:    2 * bytesize.GiB,
+			Placement: twelvefactor.Placement{Constraints: []twelvefactor.PlacementConstraint{{Type: twelvefactor.DistinctInstance}}},
+		}, "placement for web isn't supported on Fargate"},
+	}
+
+	for i, tt := range tests {
+		err := validateFargate(tt.process)
+		if tt.err == "" {
+			assert.NoError(t, err, "#%d", i)
+		} else {
+			assert.EqualError(t, err, tt.err, "#%d", i)
+		}
+	}
+}
+
+func TestRetryingECSClient_CreateService(t *testing.T) {
+	throttled := awserr.New("ThrottlingException", "Rate exceeded", nil)
+	invalid := awserr.New("InvalidParameterException", "Invalid", nil)
+
+	tests := []struct {
+		errs  []error
+		calls int
+		err   error
+	}{
+		{[]error{nil}, 1, nil},
+		{[]error{throttled, nil}, 2, nil},
+		{[]error{throttled, throttled, throttled}, 3, throttled},
+		{[]error{invalid}, 1, invalid},
+	}
+
+	for i, tt := range tests {
+		m := new(mockECSClient)
+		c := &retryingECSClient{ecsClient: m, retrier: testRetrier}
+
+		// Every attempt is made with the same token.
+		var tokens []string
+		input := &ecs.CreateServiceInput{ServiceName: aws.String("acme--web")}
+		for _, err := range tt.errs {
+			var resp *ecs.CreateServiceOutput
+			if err == nil {
+				resp = &ecs.CreateServiceOutput{}
+			}
+			m.On("CreateService", mock.Anything).Return(resp, err).Run(func(args mock.Arguments) {
+				tokens = append(tokens, aws.StringValue(args.Get(0).(*ecs.CreateServiceInput).ClientToken))
+			}).Once()
+		}
+
+		_, err := c.CreateService(input)
+		assert.Equal(t, tt.err, err, "#%d", i)
+		m.AssertNumberOfCalls(t, "CreateService", tt.calls)
+		assert.Len(t, tokens[0], 32, "#%d", i)
+		for _, token := range tokens {
+			assert.Equal(t, tokens[0], token, "#%d", i)
+		}
+		assert.Nil(t, input.ClientToken, "#%d", i)
+	}
+
+	// Tokens that are given are kept.
+	m := new(mockECSClient)
+	c := &retryingECSClient{ecsClient: m, retrier: testRetrier}
+	input := &ecs.CreateServiceInput{ServiceName: aws.String("acme--web"), ClientToken: aws.String("token")}
+	m.On("CreateService", input).Return(&ecs.CreateServiceOutput{}, nil)
+	_, err := c.CreateService(input)
+	assert.NoError(t, err)
+}
+
+func TestRetryingECSClient_ListServicesPages(t *testing.T) {
+	m := new(mockECSClient)
+	c := &retryingECSClient{ecsClient: m, retrier: testRetrier}
+
+	throttled := awserr.New("ThrottlingException", "Rate exceeded", nil)
+	m.On("ListServices", &ecs.ListServicesInput{
+		Cluster:    aws.String("cluster"),
+		MaxResults: aws.Int64(100),
+	}).Return(&ecs.ListServicesOutput{
+		ServiceArns: []*string{aws.String("app--web")},
+		NextToken:   aws.String("page2"),
+	}, nil).Once()
+	// Only the page that failed is retried.
+	m.On("ListServices", &ecs.ListServicesInput{
+		Cluster:    aws.String("cluster"),
+		MaxResults: aws.Int64(100),
+		NextToken:  aws.String("page2"),
+	}).Return((*ecs.ListServicesOutput)(nil), throttled).Once()
+	m.On("ListServices", &ecs.ListServicesInput{
+		Cluster:    aws.String("cluster"),
+		MaxResults: aws.Int64(100),
+		NextToken:  aws.String("page2"),
+	}).Return(&ecs.ListServicesOutput{
+		ServiceArns: []*string{aws.String("app--worker")},
+	}, nil).Once()
+
+	var services []string
+	err := c.ListServicesPages(&ecs.ListServicesInput{
+		Cluster:    aws.String("cluster"),
+		MaxResults: aws.Int64(100),
+	}, func(resp *ecs.ListServicesOutput, lastPage bool) bool {
+		services = append(services, aws.StringValueSlice(resp.ServiceArns)...)
+		return true
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app--web", "app--worker"}, services)
+
+	m.AssertExpectations(t)
+}
+
+func TestRetryingEventsClient(t *testing.T) {
+	m := new(mockEventsClient)
+	c := &retryingEventsClient{eventsClient: m, retrier: testRetrier}
+
+	throttled := awserr.New("ThrottlingException", "Rate exceeded", nil)
+	m.On("PutRule", mock.Anything).Return((*cloudwatchevents.PutRuleOutput)(nil), throttled).Once()
+	m.On("PutRule", mock.Anything).Return(&cloudwatchevents.PutRuleOutput{}, nil).Once()
+	m.On("PutTargets", mock.Anything).Return((*cloudwatchevents.PutTargetsOutput)(nil), throttled).Times(3)
+
+	_, err := c.PutRule(&cloudwatchevents.PutRuleInput{Name: aws.String("app--cleanup")})
+	assert.NoError(t, err)
+
+	_, err = c.PutTargets(&cloudwatchevents.PutTargetsInput{Rule: aws.String("app--cleanup")})
+	assert.Equal(t, throttled, err)
+
+	m.AssertExpectations(t)
+}
+
+func TestStackBuilder_WithContext(t *testing.T) {
+	m := new(mockECSClient)
+	b := &StackBuilder{
+		Cluster:     "cluster",
+		RetryPolicy: &retry.Policy{MaxAttempts: 3},
+		index:       new(serviceIndex),
+	}
+	b.ecs = &retryingECSClient{ecsClient: m, retrier: retrier{policy: b.retryPolicy}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := b.WithContext(ctx)
+	assert.Equal(t, b.index, c.index)
+
+	// Calls aren't made, or retried, once the context is done.
+	cancel()
+	_, err := c.ecs.DescribeServices(&ecs.DescribeServicesInput{})
+	assert.Equal(t, context.Canceled, err)
+
+	m.AssertNotCalled(t, "DescribeServices", mock.Anything)
+}
+
+func TestStackBuilder_memoryMiB(t *testing.T) {
+	tests := []struct {
+		rounding bytesize.Rounding
+		in       bytesize.ByteSize
+		out      int64
+	}{
+		{bytesize.RoundUp, 512 * bytesize.MiB, 512},
+		{bytesize.RoundUp, 512 * bytesize.Megabyte, 489},
+		{bytesize.RoundDown, 512 * bytesize.Megabyte, 488},
+		{bytesize.RoundNearest, 512 * bytesize.Megabyte, 488},
+		{bytesize.RoundNearest, 1536 * bytesize.KiB, 2},
+	}
+
+	for i, tt := range tests {
+		b := &StackBuilder{MemoryRounding: tt.rounding}
+		mib, err := b.memoryMiB(tt.in)
+		assert.NoError(t, err, "#%d", i)
+		assert.Equal(t, tt.out, mib, "#%d", i)
+	}
+
+	b := &StackBuilder{MemoryRounding: bytesize.RoundDown}
+	_, err := b.memoryMiB(512 * bytesize.KiB)
+	assert.EqualError(t, err, "512KiB rounds down to 0 MiB")
+}
+
 func TestStackBuilder_Remove(t *testing.T) {
 	c := new(mockECSClient)
+	e := new(mockEventsClient)
 	b := &StackBuilder{
 		Cluster: "cluster",
 		ecs:     c,
+		events:  e,
 	}
 
 	c.On("ListServicesPages", &ecs.ListServicesInput{
-		Cluster: aws.String("cluster"),
+		Cluster:    aws.String("cluster"),
+		MaxResults: aws.Int64(100),
 	}).Return(nil, []*ecs.ListServicesOutput{
 		{
 			ServiceArns: []*string{
@@ -84,14 +1421,209 @@ func TestStackBuilder_Remove(t *testing.T) {
 			},
 		},
 	})
+	c.On("DescribeServices", mock.Anything).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{ServiceName: aws.String("app--web")},
+		},
+	}, nil)
 	c.On("DeleteService", &ecs.DeleteServiceInput{
 		Cluster: aws.String("cluster"),
 		Service: aws.String("app--web"),
+		Force:   aws.Bool(true),
 	}).Return(&ecs.DeleteServiceOutput{}, nil)
+	e.On("ListRules", &cloudwatchevents.ListRulesInput{
+		NamePrefix: aws.String("app--"),
+	}).Return(&cloudwatchevents.ListRulesOutput{
+		Rules: []*cloudwatchevents.Rule{
+			{Name: aws.String("app--cleanup")},
+		},
+	}, nil)
+	e.On("RemoveTargets", &cloudwatchevents.RemoveTargetsInput{
+		Rule: aws.String("app--cleanup"),
+		Ids:  []*string{aws.String("cleanup")},
+	}).Return(&cloudwatchevents.RemoveTargetsOutput{}, nil)
+	e.On("DeleteRule", &cloudwatchevents.DeleteRuleInput{
+		Name: aws.String("app--cleanup"),
+	}).Return(&cloudwatchevents.DeleteRuleOutput{}, nil)
 	err := b.Remove("app")
 	assert.NoError(t, err)
 }
 
+func TestStackBuilder_Schedules(t *testing.T) {
+	e := new(mockEventsClient)
+	b := &StackBuilder{
+		events: e,
+	}
+
+	e.On("ListRules", &cloudwatchevents.ListRulesInput{
+		NamePrefix: aws.String("app--"),
+	}).Return(&cloudwatchevents.ListRulesOutput{
+		Rules: []*cloudwatchevents.Rule{
+			{Name: aws.String("app--cleanup"), Description: aws.String("30 4 * * 1-5"), ScheduleExpression: aws.String("cron(30 4 ? * 2-6 *)")},
+			{Name: aws.String("app--report"), ScheduleExpression: aws.String("cron(0 0 * * ? *)")},
+			{Name: aws.String("application--report")},
+		},
+	}, nil)
+
+	schedules, err := b.Schedules("app")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"cleanup": "app--cleanup",
+		"report":  "app--report",
+	}, schedules)
+
+	// Rules without a description were created before the expression
+	// was kept.
+	expressions, err := b.ScheduleExpressions("app")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"cleanup": "30 4 * * 1-5",
+	}, expressions)
+}
+
+func TestStackBuilder_RunTask(t *testing.T) {
+	c := new(mockECSClient)
+	b := &StackBuilder{
+		Cluster:              "cluster",
+		EnableExecuteCommand: true,
+		ecs:                  c,
+	}
+
+	c.On("ListServicesPages", &ecs.ListServicesInput{
+		Cluster:    aws.String("cluster"),
+		MaxResults: aws.Int64(100),
+	}).Return(nil, []*ecs.ListServicesOutput{
+		{ServiceArns: []*string{aws.String("app--worker"), aws.String("app--web")}},
+	})
+	c.On("DescribeServices", &ecs.DescribeServicesInput{
+		Cluster:  aws.String("cluster"),
+		Services: []*string{aws.String("app--worker"), aws.String("app--web")},
+		Include:  []*string{aws.String("TAGS")},
+	}).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{ServiceName: aws.String("app--worker")},
+			{ServiceName: aws.String("app--web")},
+		},
+	}, nil)
+	// migrate has no service, so the first service by name is used.
+	c.On("DescribeServices", &ecs.DescribeServicesInput{
+		Cluster:  aws.String("cluster"),
+		Services: []*string{aws.String("app--web")},
+	}).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{ServiceName: aws.String("app--web"), TaskDefinition: aws.String("app--web:3")},
+		},
+	}, nil)
+	c.On("DescribeTaskDefinition", &ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: aws.String("app--web:3"),
+	}).Return(&ecs.DescribeTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:012345678910:task-definition/app--web:3"),
+			ContainerDefinitions: []*ecs.ContainerDefinition{
+				{Name: aws.String("web")},
+			},
+		},
+	}, nil)
+	c.On("RunTask", &ecs.RunTaskInput{
+		Cluster:              aws.String("cluster"),
+		TaskDefinition:       aws.String("arn:aws:ecs:us-east-1:012345678910:task-definition/app--web:3"),
+		Count:                aws.Int64(1),
+		EnableExecuteCommand: aws.Bool(true),
+		Overrides: &ecs.TaskOverride{
+			ContainerOverrides: []*ecs.ContainerOverride{
+				{
+					Name:    aws.String("web"),
+					Command: aws.StringSlice([]string{"rake", "db:migrate"}),
+					Environment: []*ecs.KeyValuePair{
+						{Name: aws.String("A"), Value: aws.String("1")},
+						{Name: aws.String("B"), Value: aws.String("2")},
+					},
+				},
+			},
+		},
+	}).Return(&ecs.RunTaskOutput{
+		Tasks: []*ecs.Task{
+			{TaskArn: aws.String("arn:aws:ecs:us-east-1:012345678910:task/cluster/abcd")},
+		},
+	}, nil)
+
+	id, err := b.RunTask("app", twelvefactor.Process{
+		Name:    "migrate",
+		Command: []string{"rake", "db:migrate"},
+		Env:     map[string]string{"B": "2", "A": "1"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "abcd", id)
+
+	c.AssertExpectations(t)
+}
+
+func TestStackBuilder_RunTask_Failure(t *testing.T) {
+	c := new(mockECSClient)
+	b := &StackBuilder{
+		Cluster: "cluster",
+		ecs:     c,
+	}
+
+	c.On("ListServicesPages", &ecs.ListServicesInput{
+		Cluster:    aws.String("cluster"),
+		MaxResults: aws.Int64(100),
+	}).Return(nil, []*ecs.ListServicesOutput{
+		{ServiceArns: []*string{aws.String("app--web")}},
+	})
+	c.On("DescribeServices", &ecs.DescribeServicesInput{
+		Cluster:  aws.String("cluster"),
+		Services: []*string{aws.String("app--web")},
+		Include:  []*string{aws.String("TAGS")},
+	}).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{ServiceName: aws.String("app--web")},
+		},
+	}, nil)
+	c.On("DescribeServices", &ecs.DescribeServicesInput{
+		Cluster:  aws.String("cluster"),
+		Services: []*string{aws.String("app--web")},
+	}).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{ServiceName: aws.String("app--web"), TaskDefinition: aws.String("app--web:3")},
+		},
+	}, nil)
+	c.On("DescribeTaskDefinition", &ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: aws.String("app--web:3"),
+	}).Return(&ecs.DescribeTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			TaskDefinitionArn:    aws.String("app--web:3"),
+			ContainerDefinitions: []*ecs.ContainerDefinition{{Name: aws.String("web")}},
+		},
+	}, nil)
+	c.On("RunTask", mock.Anything).Return(&ecs.RunTaskOutput{
+		Failures: []*ecs.Failure{{Reason: aws.String("RESOURCE:MEMORY")}},
+	}, nil)
+
+	_, err := b.RunTask("app", twelvefactor.Process{Name: "web", Command: []string{"bash"}})
+	assert.EqualError(t, err, "error running task for web: RESOURCE:MEMORY")
+
+	c.AssertExpectations(t)
+}
+
+func TestStackBuilder_RunTask_AppNotFound(t *testing.T) {
+	c := new(mockECSClient)
+	b := &StackBuilder{
+		Cluster: "cluster",
+		ecs:     c,
+	}
+
+	c.On("ListServicesPages", &ecs.ListServicesInput{
+		Cluster:    aws.String("cluster"),
+		MaxResults: aws.Int64(100),
+	}).Return(nil, []*ecs.ListServicesOutput{{}})
+
+	_, err := b.RunTask("app", twelvefactor.Process{Name: "web", Command: []string{"bash"}})
+	assert.IsType(t, &twelvefactor.AppNotFoundError{}, err)
+
+	c.AssertExpectations(t)
+}
+
 func TestStackBuilder_Services(t *testing.T) {
 	c := new(mockECSClient)
 	b := &StackBuilder{
@@ -100,19 +1632,132 @@ func TestStackBuilder_Services(t *testing.T) {
 	}
 
 	c.On("ListServicesPages", &ecs.ListServicesInput{
-		Cluster: aws.String("cluster"),
+		Cluster:    aws.String("cluster"),
+		MaxResults: aws.Int64(100),
 	}).Return(nil, []*ecs.ListServicesOutput{
 		{
 			ServiceArns: []*string{
-				aws.String("arn:aws:ecs:us-east-1:012345678910:service/app--web"),
+				aws.String("arn:aws:ecs:us-east-1:012345678910:service/acme-web"),
+				aws.String("arn:aws:ecs:us-east-1:012345678910:service/app--worker"),
+				aws.String("arn:aws:ecs:us-east-1:012345678910:service/app--other"),
+				aws.String("arn:aws:ecs:us-east-1:012345678910:service/app--deleted"),
 			},
 		},
 	})
+	c.On("DescribeServices", &ecs.DescribeServicesInput{
+		Cluster: aws.String("cluster"),
+		Services: []*string{
+			aws.String("arn:aws:ecs:us-east-1:012345678910:service/acme-web"),
+			aws.String("arn:aws:ecs:us-east-1:012345678910:service/app--worker"),
+			aws.String("arn:aws:ecs:us-east-1:012345678910:service/app--other"),
+			aws.String("arn:aws:ecs:us-east-1:012345678910:service/app--deleted"),
+		},
+		Include: []*string{aws.String("TAGS")},
+	}).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			// Tagged services don't need to follow the naming
+			// convention.
+			{ServiceName: aws.String("acme-web"), Tags: tags("app", "web")},
+			// Legacy services fall back to their name.
+			{ServiceName: aws.String("app--worker")},
+			// Tags take precedence over the name.
+			{ServiceName: aws.String("app--other"), Tags: tags("other", "web")},
+			{ServiceName: aws.String("app--deleted"), Status: aws.String("INACTIVE")},
+		},
+	}, nil)
 	services, err := b.Services("app")
 	assert.NoError(t, err)
-	assert.Equal(t, services, map[string]string{
-		"web": "app--web",
-	})
+	assert.Equal(t, map[string]string{
+		"web":    "acme-web",
+		"worker": "app--worker",
+	}, services)
+}
+
+func TestStackBuilder_Service(t *testing.T) {
+	describe := &ecs.DescribeServicesInput{
+		Cluster:  aws.String("cluster"),
+		Services: []*string{aws.String("app--web")},
+		Include:  []*string{aws.String("TAGS")},
+	}
+	list := &ecs.ListServicesInput{
+		Cluster:    aws.String("cluster"),
+		MaxResults: aws.Int64(100),
+	}
+
+	tests := []struct {
+		services []*ecs.Service
+		listed   []*ecs.Service
+		service  string
+		err      string
+	}{
+		// Found by name.
+		{[]*ecs.Service{{ServiceName: aws.String("app--web"), Tags: tags("app", "web")}}, nil, "app--web", ""},
+		{[]*ecs.Service{{ServiceName: aws.String("app--web")}}, nil, "app--web", ""},
+
+		// Services that are missing, deleted, or belong to something
+		// else by their tags, fall back to the index.
+		{nil, []*ecs.Service{{ServiceName: aws.String("acme-web"), Tags: tags("app", "web")}}, "acme-web", ""},
+		{[]*ecs.Service{{ServiceName: aws.String("app--web"), Status: aws.String("INACTIVE")}}, nil, "", "web process not found"},
+		{[]*ecs.Service{{ServiceName: aws.String("app--web"), Tags: tags("app--web", "worker")}}, nil, "", "web process not found"},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("#%d", i), func(t *testing.T) {
+			c := new(mockECSClient)
+			b := &StackBuilder{
+				Cluster: "cluster",
+				ecs:     c,
+			}
+
+			c.On("DescribeServices", describe).Return(&ecs.DescribeServicesOutput{
+				Services: tt.services,
+			}, nil).Once()
+			if tt.services == nil || tt.service == "" {
+				var arns []*string
+				for _, service := range tt.listed {
+					arns = append(arns, service.ServiceName)
+				}
+				c.On("ListServicesPages", list).Return(nil, []*ecs.ListServicesOutput{{ServiceArns: arns}})
+				if len(arns) > 0 {
+					c.On("DescribeServices", &ecs.DescribeServicesInput{
+						Cluster:  aws.String("cluster"),
+						Services: arns,
+						Include:  []*string{aws.String("TAGS")},
+					}).Return(&ecs.DescribeServicesOutput{Services: tt.listed}, nil)
+				}
+			}
+
+			service, err := b.Service("app", "web")
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+			assert.Equal(t, tt.service, service)
+
+			c.AssertExpectations(t)
+		})
+	}
+}
+
+func TestStackBuilder_Service_Cache(t *testing.T) {
+	c := new(mockECSClient)
+	b := &StackBuilder{
+		Cluster: "cluster",
+		index:   new(serviceIndex),
+		ecs:     c,
+	}
+	b.index.set(map[string]map[string]string{"app": {"web": "acme-web"}}, time.Now().Add(time.Minute))
+
+	// The index is used when it's loaded, without calling ECS.
+	service, err := b.Service("app", "web")
+	assert.NoError(t, err)
+	assert.Equal(t, "acme-web", service)
+
+	_, err = b.Service("app", "worker")
+	assert.IsType(t, &twelvefactor.ProcessNotFoundError{}, err)
+
+	c.AssertExpectations(t)
 }
 
 func TestStackBuilder_Services_Pagination(t *testing.T) {
@@ -122,26 +1767,73 @@ func TestStackBuilder_Services_Pagination(t *testing.T) {
 		ecs:     c,
 	}
 
-	c.On("ListServicesPages", &ecs.ListServicesInput{
-		Cluster: aws.String("cluster"),
-	}).Return(nil, []*ecs.ListServicesOutput{
-		{
+	var pages []*ecs.ListServicesOutput
+	for i := 0; i < 25; i++ {
+		pages = append(pages, &ecs.ListServicesOutput{
 			ServiceArns: []*string{
-				aws.String("arn:aws:ecs:us-east-1:012345678910:service/app--web"),
+				aws.String(fmt.Sprintf("arn:aws:ecs:us-east-1:012345678910:service/app--p%d", i)),
 			},
+		})
+	}
+
+	c.On("ListServicesPages", &ecs.ListServicesInput{
+		Cluster:    aws.String("cluster"),
+		MaxResults: aws.Int64(100),
+	}).Return(nil, pages)
+	c.On("DescribeServices", mock.Anything).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{ServiceName: aws.String("app--web")},
 		},
+	}, nil)
+	services, err := b.Services("app")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"web": "app--web",
+	}, services)
+
+	// Services are described 10 at a time.
+	var described []int
+	for _, call := range c.Calls {
+		if call.Method == "DescribeServices" {
+			described = append(described, len(call.Arguments.Get(0).(*ecs.DescribeServicesInput).Services))
+		}
+	}
+	assert.Equal(t, []int{10, 10, 5}, described)
+}
+
+func TestStackBuilder_Services_LongARNs(t *testing.T) {
+	c := new(mockECSClient)
+	b := &StackBuilder{
+		Cluster: "cluster",
+		ecs:     c,
+	}
+
+	c.On("ListServicesPages", &ecs.ListServicesInput{
+		Cluster:    aws.String("cluster"),
+		MaxResults: aws.Int64(100),
+	}).Return(nil, []*ecs.ListServicesOutput{
 		{
 			ServiceArns: []*string{
-				aws.String("arn:aws:ecs:us-east-1:012345678910:service/app--worker"),
+				aws.String("arn:aws-cn:ecs:cn-north-1:012345678910:service/cluster/app--web"),
 			},
 		},
 	})
+	c.On("DescribeServices", &ecs.DescribeServicesInput{
+		Cluster: aws.String("cluster"),
+		Services: []*string{
+			aws.String("arn:aws-cn:ecs:cn-north-1:012345678910:service/cluster/app--web"),
+		},
+		Include: []*string{aws.String("TAGS")},
+	}).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{ServiceName: aws.String("app--web")},
+		},
+	}, nil)
 	services, err := b.Services("app")
 	assert.NoError(t, err)
-	assert.Equal(t, services, map[string]string{
-		"web":    "app--web",
-		"worker": "app--worker",
-	})
+	assert.Equal(t, map[string]string{
+		"web": "app--web",
+	}, services)
 }
 
 func TestStackBuilder_Services_Dirty(t *testing.T) {
@@ -152,7 +1844,8 @@ func TestStackBuilder_Services_Dirty(t *testing.T) {
 	}
 
 	c.On("ListServicesPages", &ecs.ListServicesInput{
-		Cluster: aws.String("cluster"),
+		Cluster:    aws.String("cluster"),
+		MaxResults: aws.Int64(100),
 	}).Return(nil, []*ecs.ListServicesOutput{
 		{
 			ServiceArns: []*string{
@@ -162,11 +1855,92 @@ func TestStackBuilder_Services_Dirty(t *testing.T) {
 			},
 		},
 	})
+	c.On("DescribeServices", &ecs.DescribeServicesInput{
+		Cluster: aws.String("cluster"),
+		Services: []*string{
+			aws.String("arn:aws:ecs:us-east-1:012345678910:service/app"),
+			aws.String("arn:aws:ecs:us-east-1:012345678910:service/app--web"),
+		},
+		Include: []*string{aws.String("TAGS")},
+	}).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{ServiceName: aws.String("app")},
+			{ServiceName: aws.String("app--web")},
+		},
+	}, nil)
 	services, err := b.Services("app")
 	assert.NoError(t, err)
-	assert.Equal(t, services, map[string]string{
+	assert.Equal(t, map[string]string{
 		"web": "app--web",
+	}, services)
+}
+
+func TestStackBuilder_Services_Cache(t *testing.T) {
+	c := new(mockECSClient)
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := &StackBuilder{
+		Cluster: "cluster",
+		index:   new(serviceIndex),
+		ecs:     c,
+		now:     func() time.Time { return now },
+	}
+
+	c.On("ListServicesPages", mock.Anything).Return(nil, []*ecs.ListServicesOutput{
+		{
+			ServiceArns: []*string{
+				aws.String("arn:aws:ecs:us-east-1:012345678910:service/app--web"),
+			},
+		},
 	})
+	c.On("DescribeServices", mock.Anything).Return(&ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{ServiceName: aws.String("app--web")},
+		},
+	}, nil)
+
+	services, err := b.Services("app")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"web": "app--web"}, services)
+
+	// Changes made through the StackBuilder are reflected without
+	// listing the services again.
+	c.On("RegisterTaskDefinition", mock.Anything).Return(&ecs.RegisterTaskDefinitionOutput{
+		TaskDefinition: &ecs.TaskDefinition{
+			Family:   aws.String("app--worker"),
+			Revision: aws.Int64(1),
+		},
+	}, nil)
+	c.On("CreateService", mock.Anything).Return(&ecs.CreateServiceOutput{}, nil)
+	assert.NoError(t, b.CreateService(twelvefactor.App{ID: "app"}, twelvefactor.Process{Name: "worker"}))
+
+	services, err = b.Services("app")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"web": "app--web", "worker": "app--worker"}, services)
+
+	services, err = b.Services("other")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{}, services)
+	c.AssertNumberOfCalls(t, "ListServicesPages", 1)
+
+	// Once the TTL passes, the services are listed again.
+	now = now.Add(DefaultCacheTTL)
+	services, err = b.Services("app")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"web": "app--web"}, services)
+	c.AssertNumberOfCalls(t, "ListServicesPages", 2)
+
+	b.Invalidate()
+	_, err = b.Services("app")
+	assert.NoError(t, err)
+	c.AssertNumberOfCalls(t, "ListServicesPages", 3)
+
+	// A negative TTL disables caching.
+	b.CacheTTL = -1
+	_, err = b.Services("app")
+	assert.NoError(t, err)
+	_, err = b.Services("app")
+	assert.NoError(t, err)
+	c.AssertNumberOfCalls(t, "ListServicesPages", 5)
 }
 
 // mockECSClient is an implementation of the ecsClient interface for testing.
@@ -184,6 +1958,11 @@ func (c *mockECSClient) ListServicesPages(input *ecs.ListServicesInput, fn func(
 	return args.Error(0)
 }
 
+func (c *mockECSClient) ListServices(input *ecs.ListServicesInput) (*ecs.ListServicesOutput, error) {
+	args := c.Called(input)
+	return args.Get(0).(*ecs.ListServicesOutput), args.Error(1)
+}
+
 func (c *mockECSClient) DeleteService(input *ecs.DeleteServiceInput) (*ecs.DeleteServiceOutput, error) {
 	args := c.Called(input)
 	return args.Get(0).(*ecs.DeleteServiceOutput), args.Error(1)
@@ -198,3 +1977,72 @@ func (c *mockECSClient) CreateService(input *ecs.CreateServiceInput) (*ecs.Creat
 	args := c.Called(input)
 	return args.Get(0).(*ecs.CreateServiceOutput), args.Error(1)
 }
+
+func (c *mockECSClient) UpdateService(input *ecs.UpdateServiceInput) (*ecs.UpdateServiceOutput, error) {
+	args := c.Called(input)
+	return args.Get(0).(*ecs.UpdateServiceOutput), args.Error(1)
+}
+
+func (c *mockECSClient) DescribeClusters(input *ecs.DescribeClustersInput) (*ecs.DescribeClustersOutput, error) {
+	args := c.Called(input)
+	return args.Get(0).(*ecs.DescribeClustersOutput), args.Error(1)
+}
+
+func (c *mockECSClient) DescribeServices(input *ecs.DescribeServicesInput) (*ecs.DescribeServicesOutput, error) {
+	args := c.Called(input)
+	return args.Get(0).(*ecs.DescribeServicesOutput), args.Error(1)
+}
+
+func (c *mockECSClient) DescribeTaskDefinition(input *ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error) {
+	args := c.Called(input)
+	return args.Get(0).(*ecs.DescribeTaskDefinitionOutput), args.Error(1)
+}
+
+func (c *mockECSClient) RunTask(input *ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
+	args := c.Called(input)
+	return args.Get(0).(*ecs.RunTaskOutput), args.Error(1)
+}
+
+// testRetrier retries calls three times, without waiting between them.
+var testRetrier = retrier{policy: func() *retry.Policy { return &retry.Policy{MaxAttempts: 3} }}
+
+// mockEventsClient is an implementation of the eventsClient interface for
+// testing.
+type mockEventsClient struct {
+	mock.Mock
+}
+
+func (c *mockEventsClient) PutRule(input *cloudwatchevents.PutRuleInput) (*cloudwatchevents.PutRuleOutput, error) {
+	args := c.Called(input)
+	return args.Get(0).(*cloudwatchevents.PutRuleOutput), args.Error(1)
+}
+
+func (c *mockEventsClient) PutTargets(input *cloudwatchevents.PutTargetsInput) (*cloudwatchevents.PutTargetsOutput, error) {
+	args := c.Called(input)
+	return args.Get(0).(*cloudwatchevents.PutTargetsOutput), args.Error(1)
+}
+
+func (c *mockEventsClient) RemoveTargets(input *cloudwatchevents.RemoveTargetsInput) (*cloudwatchevents.RemoveTargetsOutput, error) {
+	args := c.Called(input)
+	return args.Get(0).(*cloudwatchevents.RemoveTargetsOutput), args.Error(1)
+}
+
+func (c *mockEventsClient) DeleteRule(input *cloudwatchevents.DeleteRuleInput) (*cloudwatchevents.DeleteRuleOutput, error) {
+	args := c.Called(input)
+	return args.Get(0).(*cloudwatchevents.DeleteRuleOutput), args.Error(1)
+}
+
+func (c *mockEventsClient) ListRules(input *cloudwatchevents.ListRulesInput) (*cloudwatchevents.ListRulesOutput, error) {
+	args := c.Called(input)
+	return args.Get(0).(*cloudwatchevents.ListRulesOutput), args.Error(1)
+}
+
+func (c *mockEventsClient) DescribeRule(input *cloudwatchevents.DescribeRuleInput) (*cloudwatchevents.DescribeRuleOutput, error) {
+	args := c.Called(input)
+	return args.Get(0).(*cloudwatchevents.DescribeRuleOutput), args.Error(1)
+}
+
+func (c *mockEventsClient) ListTargetsByRule(input *cloudwatchevents.ListTargetsByRuleInput) (*cloudwatchevents.ListTargetsByRuleOutput, error) {
+	args := c.Called(input)
+	return args.Get(0).(*cloudwatchevents.ListTargetsByRuleOutput), args.Error(1)
+}
@@ -0,0 +1,205 @@
+package raw
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/remind101/12factor/pkg/aws/retry"
+)
+
+// retrier retries calls with a policy, until the context that they're made
+// with is done.
+type retrier struct {
+	// policy returns the policy to retry with. It's a func so that the
+	// StackBuilder's RetryPolicy can be changed after the clients are
+	// made.
+	policy func() *retry.Policy
+
+	// ctx is the context that calls are made with. The zero value is
+	// context.Background().
+	ctx context.Context
+}
+
+// do calls fn, retrying it according to the policy.
+func (r retrier) do(fn func() error) error {
+	ctx := r.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return r.policy().Do(ctx, fn)
+}
+
+// retryingECSClient is an ecsClient that retries calls that fail because of
+// throttling or transient server errors. RunTask isn't retried, since a retry
+// of a request that timed out after ECS started the task would start another
+// one.
+type retryingECSClient struct {
+	ecsClient
+	retrier
+}
+
+// CreateService retries with the same ClientToken, generating one if there
+// isn't one already, so that a retry of a request that timed out after ECS
+// created the service doesn't fail, or create it twice.
+func (c *retryingECSClient) CreateService(input *ecs.CreateServiceInput) (resp *ecs.CreateServiceOutput, err error) {
+	if input.ClientToken == nil {
+		token, err := clientToken()
+		if err != nil {
+			return nil, err
+		}
+
+		withToken := *input
+		withToken.ClientToken = aws.String(token)
+		input = &withToken
+	}
+
+	err = c.do(func() error {
+		resp, err = c.ecsClient.CreateService(input)
+		return err
+	})
+	return
+}
+
+// ListServicesPages lists each page with ListServices, so that pages are
+// retried on their own, and fn never sees a page twice.
+func (c *retryingECSClient) ListServicesPages(input *ecs.ListServicesInput, fn func(*ecs.ListServicesOutput, bool) bool) error {
+	page := *input
+	for {
+		resp, err := c.ListServices(&page)
+		if err != nil {
+			return err
+		}
+
+		lastPage := resp.NextToken == nil
+		if !fn(resp, lastPage) || lastPage {
+			return nil
+		}
+		page.NextToken = resp.NextToken
+	}
+}
+
+func (c *retryingECSClient) ListServices(input *ecs.ListServicesInput) (resp *ecs.ListServicesOutput, err error) {
+	err = c.do(func() error {
+		resp, err = c.ecsClient.ListServices(input)
+		return err
+	})
+	return
+}
+
+func (c *retryingECSClient) UpdateService(input *ecs.UpdateServiceInput) (resp *ecs.UpdateServiceOutput, err error) {
+	err = c.do(func() error {
+		resp, err = c.ecsClient.UpdateService(input)
+		return err
+	})
+	return
+}
+
+func (c *retryingECSClient) DeleteService(input *ecs.DeleteServiceInput) (resp *ecs.DeleteServiceOutput, err error) {
+	err = c.do(func() error {
+		resp, err = c.ecsClient.DeleteService(input)
+		return err
+	})
+	return
+}
+
+func (c *retryingECSClient) RegisterTaskDefinition(input *ecs.RegisterTaskDefinitionInput) (resp *ecs.RegisterTaskDefinitionOutput, err error) {
+	err = c.do(func() error {
+		resp, err = c.ecsClient.RegisterTaskDefinition(input)
+		return err
+	})
+	return
+}
+
+func (c *retryingECSClient) DescribeClusters(input *ecs.DescribeClustersInput) (resp *ecs.DescribeClustersOutput, err error) {
+	err = c.do(func() error {
+		resp, err = c.ecsClient.DescribeClusters(input)
+		return err
+	})
+	return
+}
+
+func (c *retryingECSClient) DescribeServices(input *ecs.DescribeServicesInput) (resp *ecs.DescribeServicesOutput, err error) {
+	err = c.do(func() error {
+		resp, err = c.ecsClient.DescribeServices(input)
+		return err
+	})
+	return
+}
+
+func (c *retryingECSClient) DescribeTaskDefinition(input *ecs.DescribeTaskDefinitionInput) (resp *ecs.DescribeTaskDefinitionOutput, err error) {
+	err = c.do(func() error {
+		resp, err = c.ecsClient.DescribeTaskDefinition(input)
+		return err
+	})
+	return
+}
+
+// retryingEventsClient is an eventsClient that retries calls that fail because
+// of throttling or transient server errors. RemoveTargets isn't retried, since
+// a retry of a request that timed out after the targets were removed would
+// report them as failed entries.
+type retryingEventsClient struct {
+	eventsClient
+	retrier
+}
+
+func (c *retryingEventsClient) PutRule(input *cloudwatchevents.PutRuleInput) (resp *cloudwatchevents.PutRuleOutput, err error) {
+	err = c.do(func() error {
+		resp, err = c.eventsClient.PutRule(input)
+		return err
+	})
+	return
+}
+
+func (c *retryingEventsClient) PutTargets(input *cloudwatchevents.PutTargetsInput) (resp *cloudwatchevents.PutTargetsOutput, err error) {
+	err = c.do(func() error {
+		resp, err = c.eventsClient.PutTargets(input)
+		return err
+	})
+	return
+}
+
+func (c *retryingEventsClient) DeleteRule(input *cloudwatchevents.DeleteRuleInput) (resp *cloudwatchevents.DeleteRuleOutput, err error) {
+	err = c.do(func() error {
+		resp, err = c.eventsClient.DeleteRule(input)
+		return err
+	})
+	return
+}
+
+func (c *retryingEventsClient) ListRules(input *cloudwatchevents.ListRulesInput) (resp *cloudwatchevents.ListRulesOutput, err error) {
+	err = c.do(func() error {
+		resp, err = c.eventsClient.ListRules(input)
+		return err
+	})
+	return
+}
+
+func (c *retryingEventsClient) DescribeRule(input *cloudwatchevents.DescribeRuleInput) (resp *cloudwatchevents.DescribeRuleOutput, err error) {
+	err = c.do(func() error {
+		resp, err = c.eventsClient.DescribeRule(input)
+		return err
+	})
+	return
+}
+
+func (c *retryingEventsClient) ListTargetsByRule(input *cloudwatchevents.ListTargetsByRuleInput) (resp *cloudwatchevents.ListTargetsByRuleOutput, err error) {
+	err = c.do(func() error {
+		resp, err = c.eventsClient.ListTargetsByRule(input)
+		return err
+	})
+	return
+}
+
+// clientToken returns a random token that makes a request idempotent.
+func clientToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -2,33 +2,60 @@
 package ecs
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/remind101/12factor"
 	"github.com/remind101/12factor/pkg/aws/arn"
+	"github.com/remind101/12factor/pkg/aws/retry"
+	"github.com/remind101/12factor/pkg/cron"
 	"github.com/remind101/12factor/scheduler/ecs/builders/raw"
+	"github.com/remind101/12factor/scheduler/ecs/internal/ecserr"
 )
 
 // ProcessNotFoundError is returned when attempting to operate on a process that
 // does not exist.
-type ProcessNotFoundError struct {
-	Process string
-}
-
-// Error implements the error interface.
-func (e *ProcessNotFoundError) Error() string {
-	return fmt.Sprintf("%s process not found", e.Process)
-}
+//
+// Deprecated: Use twelvefactor.ProcessNotFoundError, or check for
+// twelvefactor.ErrProcessNotFound with errors.Is.
+type ProcessNotFoundError = twelvefactor.ProcessNotFoundError
 
 // ecsClient represents a client for interacting with ECS.
 type ecsClient interface {
 	UpdateService(*ecs.UpdateServiceInput) (*ecs.UpdateServiceOutput, error)
 	ListTasks(*ecs.ListTasksInput) (*ecs.ListTasksOutput, error)
 	DescribeTasks(*ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error)
+	ExecuteCommand(*ecs.ExecuteCommandInput) (*ecs.ExecuteCommandOutput, error)
+	StopTask(*ecs.StopTaskInput) (*ecs.StopTaskOutput, error)
+}
+
+// logsClient represents a client for interacting with CloudWatch Logs.
+type logsClient interface {
+	FilterLogEventsPages(*cloudwatchlogs.FilterLogEventsInput, func(*cloudwatchlogs.FilterLogEventsOutput, bool) bool) error
 }
 
+// maxDescribeTasks is the maximum number of tasks that can be described in a
+// single DescribeTasks call.
+const maxDescribeTasks = 100
+
+// maxConcurrentQueries is the maximum number of services and schedules that
+// Tasks queries at once.
+const maxConcurrentQueries = 8
+
+// logsPollInterval is how often CloudWatch Logs is polled for new log events
+// when following logs.
+var logsPollInterval = 2 * time.Second
+
 // Scheduler is an implementation of the twelvefactor.Scheduler interface that
 // is backed by ECS.
 type Scheduler struct {
@@ -36,7 +63,28 @@ type Scheduler struct {
 	// value is the "default" cluster.
 	Cluster string
 
-	ecs ecsClient
+	// LogGroup is the name of the CloudWatch Logs log group that containers
+	// send their logs to. This should match the LogGroup that the
+	// StackBuilder configures on task definitions.
+	LogGroup string
+
+	// IncludeStopped makes Tasks include recently stopped tasks, along
+	// with the reason they stopped. ECS keeps stopped tasks for at least
+	// an hour.
+	IncludeStopped bool
+
+	// RetryPolicy is how calls to ECS that fail because of throttling or
+	// transient server errors are retried. The zero value is
+	// retry.DefaultPolicy. It doesn't apply to the StackBuilder, which
+	// has its own.
+	RetryPolicy *retry.Policy
+
+	// ctx is the context set by WithContext.
+	ctx context.Context
+
+	ecs     ecsClient
+	logs    logsClient
+	session sessionClient
 
 	// stackBuilder is the StackBuilder that will be used to provision AWS
 	// resources.
@@ -46,58 +94,346 @@ type Scheduler struct {
 // NewScheduler builds a new Scheduler instance backed by an ECS client
 // that's configured with the given config.
 func NewScheduler(config *aws.Config) *Scheduler {
-	return &Scheduler{
-		ecs:          ecs.New(config),
-		stackBuilder: raw.NewStackBuilder(config),
+	return NewSchedulerWithStackBuilder(config, raw.NewStackBuilder(config))
+}
+
+// NewSchedulerWithStackBuilder builds a new Scheduler instance that uses
+// stackBuilder to provision AWS resources. This is useful when the
+// StackBuilder needs configuring, such as a raw.StackBuilder with a Cluster
+// and LogGroup.
+func NewSchedulerWithStackBuilder(config *aws.Config, stackBuilder StackBuilder) *Scheduler {
+	sess := session.New(config)
+	s := &Scheduler{
+		logs:         cloudwatchlogs.New(sess),
+		session:      &pluginSession{Region: aws.StringValue(sess.Config.Region)},
+		stackBuilder: stackBuilder,
+	}
+	// Calls are retried with the RetryPolicy, so the SDK doesn't retry
+	// them as well.
+	s.ecs = &retryingECSClient{ecsClient: ecs.New(sess, aws.NewConfig().WithMaxRetries(0)), policy: s.retryPolicy}
+	return s
+}
+
+// WithContext returns a copy of s whose calls to AWS are made with ctx, so that
+// they're no longer retried once ctx is done, and logs stop being followed.
+// When the StackBuilder is a raw.StackBuilder, its calls are made with ctx too.
+func (s *Scheduler) WithContext(ctx context.Context) *Scheduler {
+	c := *s
+	c.ctx = ctx
+	if r, ok := s.ecs.(*retryingECSClient); ok {
+		c.ecs = &retryingECSClient{ecsClient: r.ecsClient, policy: c.retryPolicy, ctx: ctx}
+	}
+	if b, ok := s.stackBuilder.(*raw.StackBuilder); ok {
+		c.stackBuilder = b.WithContext(ctx)
+	}
+	return &c
+}
+
+func (s *Scheduler) retryPolicy() *retry.Policy {
+	if s.RetryPolicy == nil {
+		return retry.DefaultPolicy
 	}
+
+	return s.RetryPolicy
+}
+
+// context returns the context set by WithContext, or context.Background().
+func (s *Scheduler) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+
+	return s.ctx
 }
 
 // Run creates or updates the associated ECS services for the individual
-// processes within the application and runs them.
+// processes within the application and runs them. The services and schedules of
+// processes that are no longer part of the app are removed.
 func (s *Scheduler) Run(app twelvefactor.App, processes ...twelvefactor.Process) error {
 	return s.stackBuilder.Build(app, processes...)
 }
 
+// Plan implements the twelvefactor.Planner interface, when the StackBuilder
+// does.
+func (s *Scheduler) Plan(app twelvefactor.App, processes ...twelvefactor.Process) (*twelvefactor.Plan, error) {
+	p, ok := s.stackBuilder.(twelvefactor.Planner)
+	if !ok {
+		return nil, twelvefactor.NewError(twelvefactor.ErrUnsupported, fmt.Errorf("%T does not support planning", s.stackBuilder))
+	}
+	return p.Plan(app, processes...)
+}
+
+// Capabilities implements the twelvefactor.CapabilityProvider interface. Logs
+// and attached processes are only supported when a LogGroup is configured,
+// running processes needs a StackBuilder that's a TaskRunner, and the
+// capabilities that depend on how services are provisioned, like exec, health
+// checks and placement, come from the StackBuilder.
+func (s *Scheduler) Capabilities() []twelvefactor.Capability {
+	var capabilities []twelvefactor.Capability
+	if s.LogGroup != "" {
+		capabilities = append(capabilities, twelvefactor.CapabilityLogs)
+	}
+	if _, ok := s.stackBuilder.(TaskRunner); ok {
+		capabilities = append(capabilities, twelvefactor.CapabilityRunProcess)
+		// Attached processes are followed through their logs.
+		if s.LogGroup != "" {
+			capabilities = append(capabilities, twelvefactor.CapabilityAttachedRun)
+		}
+	}
+	if p, ok := s.stackBuilder.(twelvefactor.CapabilityProvider); ok {
+		capabilities = append(capabilities, p.Capabilities()...)
+	}
+	return capabilities
+}
+
+// RunProcess implements the twelvefactor.ProcessRunner interface by running a
+// one off task with the StackBuilder, which must be a TaskRunner.
+//
+// ECS tasks can't be attached to, so attached processes are followed through
+// their logs in CloudWatch Logs until the task stops, and process.Stdin is
+// ignored. An error is returned if the task exits with a non-zero code.
+func (s *Scheduler) RunProcess(app string, process twelvefactor.Process) error {
+	r, ok := s.stackBuilder.(TaskRunner)
+	if !ok {
+		return twelvefactor.NewError(twelvefactor.ErrUnsupported, fmt.Errorf("%T does not support running tasks", s.stackBuilder))
+	}
+
+	var stdout io.Writer
+	if process.Stdout != nil {
+		if s.LogGroup == "" {
+			return twelvefactor.NewError(twelvefactor.ErrUnsupported, errors.New("attached processes need a log group"))
+		}
+		if stdout, ok = process.Stdout.(io.Writer); !ok {
+			return twelvefactor.NewError(twelvefactor.ErrInvalidConfig, fmt.Errorf("stdout for %s must be an io.Writer, got %T", process.Name, process.Stdout))
+		}
+	}
+
+	id, err := r.RunTask(app, process)
+	if err != nil {
+		return err
+	}
+
+	if stdout == nil {
+		return nil
+	}
+
+	var task *ecs.Task
+	stopped := func() (bool, error) {
+		var err error
+		if task, err = s.describeTask(id); err != nil {
+			return false, err
+		}
+		return aws.StringValue(task.LastStatus) == ecs.DesiredStatusStopped, nil
+	}
+
+	if err := s.streamLogs(stdout, twelvefactor.LogsOptions{App: app, Task: id, Follow: true}, stopped); err != nil {
+		return err
+	}
+
+	// Containers that never started, like when their image can't be
+	// pulled, have no exit code.
+	if len(task.Containers) == 0 || task.Containers[0].ExitCode == nil {
+		return fmt.Errorf("%s stopped: %s", process.Name, aws.StringValue(task.StoppedReason))
+	}
+
+	if code := aws.Int64Value(task.Containers[0].ExitCode); code != 0 {
+		return fmt.Errorf("%s exited with code %d", process.Name, code)
+	}
+	return nil
+}
+
 // Remove removes the app and it's associated AWS resources.
 func (s *Scheduler) Remove(app string) error {
 	return s.stackBuilder.Remove(app)
 }
 
 // ScaleProcess scales the associated ECS service for the given app and process
-// name.
+// name. Scheduled processes can't be scaled, since the number of tasks that
+// they run is part of the schedule's target, which is set by Run.
 func (s *Scheduler) ScaleProcess(app, process string, desired int) error {
+	err := s.updateService(app, process, &ecs.UpdateServiceInput{
+		DesiredCount: aws.Int64(int64(desired)),
+	})
+	if !errors.Is(err, twelvefactor.ErrProcessNotFound) {
+		return err
+	}
+
+	schedules, serr := s.schedules(app)
+	if serr != nil {
+		return serr
+	}
+	if _, ok := schedules[process]; ok {
+		return twelvefactor.NewError(twelvefactor.ErrUnsupported, fmt.Errorf("%s is a scheduled process, set its scale and run the app again to change it", process))
+	}
+	return err
+}
+
+// Restart restarts every service for the app by forcing a new deployment, which
+// replaces all of its tasks. Scheduled processes only run when their schedule
+// fires, so there's nothing to restart for them.
+func (s *Scheduler) Restart(app string) error {
 	services, err := s.stackBuilder.Services(app)
 	if err != nil {
 		return err
 	}
 
+	schedules, err := s.schedules(app)
+	if err != nil {
+		return err
+	}
+
+	if len(services) == 0 && len(schedules) == 0 {
+		return &twelvefactor.AppNotFoundError{App: app}
+	}
+
+	processes := make([]string, 0, len(services))
+	for process := range services {
+		processes = append(processes, process)
+	}
+	sort.Strings(processes)
+
+	for _, process := range processes {
+		if err := s.RestartProcess(app, process); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestartProcess restarts the service for the process by forcing a new
+// deployment, which replaces all of its tasks.
+func (s *Scheduler) RestartProcess(app, process string) error {
+	return s.updateService(app, process, &ecs.UpdateServiceInput{
+		ForceNewDeployment: aws.Bool(true),
+	})
+}
+
+// updateService updates the ECS service for the process with input, filling in
+// the cluster and service name.
+func (s *Scheduler) updateService(app, process string, input *ecs.UpdateServiceInput) error {
+	service, err := s.service(app, process)
+	if err != nil {
+		return err
+	}
+
+	input.Cluster = aws.String(s.Cluster)
+	input.Service = aws.String(service)
+
+	_, err = s.ecs.UpdateService(input)
+	if ecserr.IsServiceNotFound(err) {
+		// The service was removed since the StackBuilder last looked,
+		// so whatever it has cached is out of date.
+		if i, ok := s.stackBuilder.(interface {
+			Invalidate()
+		}); ok {
+			i.Invalidate()
+		}
+		return &twelvefactor.ProcessNotFoundError{App: app, Process: process}
+	}
+	return ecserr.Translate(err)
+}
+
+// service returns the name of the ECS service for the process, using the
+// StackBuilder's ServiceFinder when it has one.
+func (s *Scheduler) service(app, process string) (string, error) {
+	if f, ok := s.stackBuilder.(ServiceFinder); ok {
+		return f.Service(app, process)
+	}
+
+	services, err := s.stackBuilder.Services(app)
+	if err != nil {
+		return "", err
+	}
+
 	// If there's no matching ECS service for this process, return an error.
-	if _, ok := services[process]; !ok {
-		return &ProcessNotFoundError{Process: process}
+	service, ok := services[process]
+	if !ok {
+		return "", &twelvefactor.ProcessNotFoundError{App: app, Process: process}
 	}
+	return service, nil
+}
 
-	_, err = s.ecs.UpdateService(&ecs.UpdateServiceInput{
-		Cluster:      aws.String(s.Cluster),
-		DesiredCount: aws.Int64(int64(desired)),
-		Service:      aws.String(services[process]),
+// StopTask stops the ECS task. Tasks that belong to a service are replaced by
+// ECS, so that the service keeps running its desired count.
+func (s *Scheduler) StopTask(taskID string) error {
+	_, err := s.ecs.StopTask(&ecs.StopTaskInput{
+		Cluster: aws.String(s.Cluster),
+		Task:    aws.String(taskID),
+		Reason:  aws.String("Stopped by 12factor"),
 	})
-	return err
+	if ecserr.IsTaskNotFound(err) {
+		return &twelvefactor.TaskNotFoundError{Task: taskID}
+	}
+	return ecserr.Translate(err)
 }
 
-// Tasks returns the RUNNING and PENDING ECS tasks for the ECS services.
+// Tasks returns the RUNNING and PENDING ECS tasks for the ECS services, as well
+// as any tasks that were started by a schedule. Services and schedules are
+// queried concurrently.
 func (s *Scheduler) Tasks(app string) ([]twelvefactor.Task, error) {
 	services, err := s.stackBuilder.Services(app)
 	if err != nil {
 		return nil, err
 	}
 
+	schedules, err := s.schedules(app)
+	if err != nil {
+		return nil, err
+	}
+
+	var expressions map[string]string
+	if len(schedules) > 0 {
+		if expressions, err = s.stackBuilder.(ScheduleLister).ScheduleExpressions(app); err != nil {
+			return nil, err
+		}
+	}
+
+	type query struct {
+		process string
+		list    func(string) ([]twelvefactor.Task, error)
+		name    string
+	}
+
+	var queries []query
+	for process, service := range services {
+		queries = append(queries, query{process, s.ServiceTasks, service})
+	}
+	for process, family := range schedules {
+		queries = append(queries, query{process, s.scheduledTasks(expressions[process]), family})
+	}
+	sort.SliceStable(queries, func(i, j int) bool {
+		return queries[i].process < queries[j].process
+	})
+
+	var (
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, maxConcurrentQueries)
+		results = make([][]twelvefactor.Task, len(queries))
+		errs    = make([]error, len(queries))
+	)
+	for i, q := range queries {
+		wg.Add(1)
+		go func(i int, q query) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i], errs[i] = q.list(q.name)
+			errs[i] = ecserr.Translate(errs[i])
+		}(i, q)
+	}
+	wg.Wait()
+
 	var tasks []twelvefactor.Task
-	for _, service := range services {
-		serviceTasks, err := s.ServiceTasks(service)
-		if err != nil {
-			return tasks, err
+	for i, q := range queries {
+		if errs[i] != nil {
+			return tasks, errs[i]
+		}
+
+		for _, task := range results[i] {
+			task.Process = q.process
+			tasks = append(tasks, task)
 		}
-		tasks = append(tasks, serviceTasks...)
 	}
 
 	return tasks, nil
@@ -105,39 +441,352 @@ func (s *Scheduler) Tasks(app string) ([]twelvefactor.Task, error) {
 
 // ServiceTasks returns the Tasks running for the given ECS service.
 func (s *Scheduler) ServiceTasks(service string) ([]twelvefactor.Task, error) {
-	listResp, err := s.ecs.ListTasks(&ecs.ListTasksInput{
+	return s.tasks(&ecs.ListTasksInput{
 		Cluster:     aws.String(s.Cluster),
 		ServiceName: aws.String(service),
 	})
-	if err != nil {
-		return nil, err
+}
+
+// ScheduledTasks returns the Tasks running for the given task definition
+// family. Tasks that were started by a CloudWatch Events rule will have their
+// TriggeredAt set to the time that the task was created, truncated to the
+// minute.
+func (s *Scheduler) ScheduledTasks(family string) ([]twelvefactor.Task, error) {
+	return s.scheduledTasks("")(family)
+}
+
+// scheduledTasks returns a function that lists the tasks for a task definition
+// family, like ScheduledTasks. When the cron expression that the tasks run on
+// is known, TriggeredAt is set to the last time that it fired before each task
+// was created.
+func (s *Scheduler) scheduledTasks(expr string) func(string) ([]twelvefactor.Task, error) {
+	// Interval schedules don't fire at fixed times, so they're treated
+	// like unknown ones.
+	schedule, err := cron.Parse(expr)
+	if err != nil || schedule.Every > 0 {
+		schedule = nil
+	}
+
+	return func(family string) ([]twelvefactor.Task, error) {
+		tasks, err := s.tasks(&ecs.ListTasksInput{
+			Cluster: aws.String(s.Cluster),
+			Family:  aws.String(family),
+		})
+		for i := range tasks {
+			tasks[i].TriggeredAt = triggeredAt(schedule, tasks[i].TriggeredAt)
+		}
+		return tasks, err
+	}
+}
+
+// triggeredAt returns the time that schedule fired to start a task that was
+// created at createdAt. CloudWatch Events evaluates schedules in UTC, with
+// minute granularity, so createdAt is truncated to the minute when the
+// schedule isn't known.
+func triggeredAt(schedule *cron.Schedule, createdAt time.Time) time.Time {
+	if createdAt.IsZero() {
+		return createdAt
+	}
+
+	if schedule != nil {
+		if t := schedule.Prev(createdAt.UTC()); !t.IsZero() {
+			return t
+		}
 	}
 
-	// No tasks.
-	if len(listResp.TaskArns) == 0 {
+	return createdAt.UTC().Truncate(time.Minute)
+}
+
+// schedules returns the schedules for the app, when the StackBuilder supports
+// them.
+func (s *Scheduler) schedules(app string) (map[string]string, error) {
+	l, ok := s.stackBuilder.(ScheduleLister)
+	if !ok {
 		return nil, nil
 	}
+	return l.Schedules(app)
+}
 
-	describeResp, err := s.ecs.DescribeTasks(&ecs.DescribeTasksInput{
-		Cluster: aws.String(s.Cluster),
-		Tasks:   listResp.TaskArns,
-	})
+func (s *Scheduler) tasks(input *ecs.ListTasksInput) ([]twelvefactor.Task, error) {
+	arns, err := s.listTasks(input)
 	if err != nil {
 		return nil, err
 	}
 
-	var tasks []twelvefactor.Task
-	for _, task := range describeResp.Tasks {
-		id, err := arn.ResourceID(*task.TaskArn)
+	if s.IncludeStopped {
+		stopped := *input
+		stopped.DesiredStatus = aws.String(ecs.DesiredStatusStopped)
+
+		stoppedArns, err := s.listTasks(&stopped)
 		if err != nil {
 			return nil, err
 		}
+		arns = append(arns, stoppedArns...)
+	}
+
+	var tasks []twelvefactor.Task
+	for len(arns) > 0 {
+		n := len(arns)
+		if n > maxDescribeTasks {
+			n = maxDescribeTasks
+		}
 
-		tasks = append(tasks, twelvefactor.Task{
-			ID:    id,
-			State: *task.LastStatus,
+		resp, err := s.ecs.DescribeTasks(&ecs.DescribeTasksInput{
+			Cluster: aws.String(s.Cluster),
+			Tasks:   arns[:n],
 		})
+		if err != nil {
+			return nil, err
+		}
+		arns = arns[n:]
+
+		for _, task := range resp.Tasks {
+			t, err := newTask(task)
+			if err != nil {
+				return nil, err
+			}
+			tasks = append(tasks, t)
+		}
 	}
 
 	return tasks, nil
 }
+
+// listTasks returns the ARNs of every task that matches input, following
+// pagination.
+func (s *Scheduler) listTasks(input *ecs.ListTasksInput) ([]*string, error) {
+	in := *input
+
+	var arns []*string
+	for {
+		resp, err := s.ecs.ListTasks(&in)
+		if err != nil {
+			return nil, err
+		}
+		arns = append(arns, resp.TaskArns...)
+
+		if resp.NextToken == nil {
+			return arns, nil
+		}
+		in.NextToken = resp.NextToken
+	}
+}
+
+// newTask converts an ECS task into a twelvefactor.Task.
+func newTask(task *ecs.Task) (twelvefactor.Task, error) {
+	id, err := arn.ResourceID(aws.StringValue(task.TaskArn))
+	if err != nil {
+		return twelvefactor.Task{}, err
+	}
+
+	t := twelvefactor.Task{
+		ID:          id,
+		State:       aws.StringValue(task.LastStatus),
+		StopReason:  aws.StringValue(task.StoppedReason),
+		Health:      health(aws.StringValue(task.HealthStatus)),
+		IPAddresses: ipAddresses(task),
+		StartedAt:   aws.TimeValue(task.StartedAt),
+		StoppedAt:   aws.TimeValue(task.StoppedAt),
+	}
+
+	if task.ContainerInstanceArn != nil {
+		if t.Host, err = arn.ResourceID(*task.ContainerInstanceArn); err != nil {
+			return t, err
+		}
+	}
+
+	// Tasks have a single container, which is the process.
+	if len(task.Containers) > 0 && task.Containers[0].ExitCode != nil {
+		code := int(*task.Containers[0].ExitCode)
+		t.ExitCode = &code
+	}
+
+	// CloudWatch Events starts tasks with a StartedBy of
+	// "events-rule/<rule name>". The time that the rule fired isn't
+	// recorded on the task, so this is refined from the schedule by
+	// ScheduledTasks.
+	if strings.HasPrefix(aws.StringValue(task.StartedBy), "events-rule/") {
+		t.TriggeredAt = aws.TimeValue(task.CreatedAt)
+	}
+
+	return t, nil
+}
+
+// health converts an ECS health status into a twelvefactor health.
+func health(status string) string {
+	switch status {
+	case ecs.HealthStatusHealthy:
+		return twelvefactor.HealthHealthy
+	case ecs.HealthStatusUnhealthy:
+		return twelvefactor.HealthUnhealthy
+	default:
+		return twelvefactor.HealthUnknown
+	}
+}
+
+// ipAddresses returns the IP addresses of the task's network interfaces, which
+// tasks using the awsvpc network mode have.
+func ipAddresses(task *ecs.Task) []string {
+	var ips []string
+	seen := make(map[string]bool)
+	for _, c := range task.Containers {
+		for _, ni := range c.NetworkInterfaces {
+			for _, ip := range []*string{ni.PrivateIpv4Address, ni.Ipv6Address} {
+				if aws.StringValue(ip) == "" || seen[*ip] {
+					continue
+				}
+				seen[*ip] = true
+				ips = append(ips, *ip)
+			}
+		}
+	}
+	return ips
+}
+
+// StreamLogs writes the log lines for the app, process or task from CloudWatch
+// Logs to w. Each line is prefixed with the process and task that it came
+// from.
+func (s *Scheduler) StreamLogs(w io.Writer, opts twelvefactor.LogsOptions) error {
+	return s.streamLogs(w, opts, nil)
+}
+
+// streamLogs implements StreamLogs. When stopped isn't nil, following ends
+// once it returns true, after one last poll for the events that were written
+// before then.
+func (s *Scheduler) streamLogs(w io.Writer, opts twelvefactor.LogsOptions, stopped func() (bool, error)) error {
+	if s.LogGroup == "" {
+		return twelvefactor.NewError(twelvefactor.ErrInvalidConfig, errors.New("no log group configured"))
+	}
+
+	process := opts.Process
+	if opts.Task != "" && process == "" {
+		var err error
+		if process, err = s.taskProcess(opts.Task); err != nil {
+			return err
+		}
+	}
+
+	// Log streams are named "<app>/<process>/<task id>".
+	prefix := opts.App + "/"
+	if process != "" {
+		prefix += process + "/"
+		if opts.Task != "" {
+			prefix += opts.Task
+		}
+	}
+
+	input := &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName:        aws.String(s.LogGroup),
+		LogStreamNamePrefix: aws.String(prefix),
+	}
+	if !opts.Since.IsZero() {
+		input.StartTime = aws.Int64(timestamp(opts.Since))
+	}
+	if !opts.Until.IsZero() {
+		input.EndTime = aws.Int64(timestamp(opts.Until))
+	}
+
+	// When following, events at the last seen timestamp will be returned
+	// again on the next poll, so we track which ones have been written,
+	// along with their timestamps.
+	seen := make(map[string]int64)
+	var done bool
+	for {
+		var werr error
+		if err := s.logs.FilterLogEventsPages(input, func(resp *cloudwatchlogs.FilterLogEventsOutput, lastPage bool) bool {
+			for _, event := range resp.Events {
+				id := aws.StringValue(event.EventId)
+				if _, ok := seen[id]; ok {
+					continue
+				}
+				seen[id] = aws.Int64Value(event.Timestamp)
+
+				if t := aws.Int64Value(event.Timestamp); input.StartTime == nil || t > *input.StartTime {
+					input.StartTime = aws.Int64(t)
+				}
+
+				stream := strings.TrimPrefix(aws.StringValue(event.LogStreamName), opts.App+"/")
+				if _, werr = fmt.Fprintf(w, "%s: %s\n", stream, strings.TrimSuffix(aws.StringValue(event.Message), "\n")); werr != nil {
+					return false
+				}
+			}
+			return true
+		}); err != nil {
+			return err
+		}
+
+		if werr != nil {
+			return werr
+		}
+
+		if done || !opts.Follow || (!opts.Until.IsZero() && time.Now().After(opts.Until)) {
+			return nil
+		}
+
+		if input.StartTime != nil {
+			pruneSeen(seen, *input.StartTime)
+		}
+
+		input.NextToken = nil
+		select {
+		case <-s.context().Done():
+			return s.context().Err()
+		case <-time.After(logsPollInterval):
+		}
+
+		if stopped != nil {
+			var err error
+			if done, err = stopped(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// pruneSeen removes the events from seen that are older than start, since
+// they won't be returned again. This keeps seen from growing for as long as
+// logs are followed.
+func pruneSeen(seen map[string]int64, start int64) {
+	for id, t := range seen {
+		if t < start {
+			delete(seen, id)
+		}
+	}
+}
+
+// taskProcess returns the name of the process that the task belongs to, which
+// is the name of its container.
+func (s *Scheduler) taskProcess(task string) (string, error) {
+	t, err := s.describeTask(task)
+	if err != nil {
+		return "", err
+	}
+
+	if len(t.Containers) == 0 {
+		return "", &twelvefactor.TaskNotFoundError{Task: task}
+	}
+
+	return aws.StringValue(t.Containers[0].Name), nil
+}
+
+// describeTask returns the ECS task with the given ID.
+func (s *Scheduler) describeTask(task string) (*ecs.Task, error) {
+	resp, err := s.ecs.DescribeTasks(&ecs.DescribeTasksInput{
+		Cluster: aws.String(s.Cluster),
+		Tasks:   []*string{aws.String(task)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Tasks) == 0 {
+		return nil, &twelvefactor.TaskNotFoundError{Task: task}
+	}
+
+	return resp.Tasks[0], nil
+}
+
+// timestamp converts t into milliseconds since the epoch.
+func timestamp(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
@@ -1,15 +1,32 @@
 package ecs
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/pkg/aws/retry"
+	"github.com/remind101/12factor/pkg/cron"
+	"github.com/remind101/12factor/scheduler/ecs/builders/raw"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
+var _ twelvefactor.Scheduler = (*Scheduler)(nil)
+
 func TestScheduler_Run(t *testing.T) {
 	b := new(mockStackBuilder)
 	s := &Scheduler{
@@ -22,6 +39,37 @@ func TestScheduler_Run(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestScheduler_Capabilities(t *testing.T) {
+	s := &Scheduler{
+		stackBuilder: new(raw.StackBuilder),
+	}
+	assert.Equal(t, []twelvefactor.Capability{
+		twelvefactor.CapabilityRunProcess,
+		twelvefactor.CapabilityHealthChecks,
+		twelvefactor.CapabilityPlacement,
+		twelvefactor.CapabilityPlan,
+		twelvefactor.CapabilitySchedules,
+	}, twelvefactor.Capabilities(s))
+
+	// Exec only works when services are created with execute command
+	// enabled.
+	s.stackBuilder = &raw.StackBuilder{EnableExecuteCommand: true}
+	assert.True(t, twelvefactor.Supports(s, twelvefactor.CapabilityExec))
+
+	// Attached processes are followed through their logs.
+	assert.False(t, twelvefactor.Supports(s, twelvefactor.CapabilityAttachedRun))
+	s.LogGroup = "acme"
+	assert.True(t, twelvefactor.Supports(s, twelvefactor.CapabilityLogs))
+	assert.True(t, twelvefactor.Supports(s, twelvefactor.CapabilityAttachedRun))
+
+	s.stackBuilder = new(mockStackBuilder)
+	assert.False(t, twelvefactor.Supports(s, twelvefactor.CapabilityPlacement))
+	assert.False(t, twelvefactor.Supports(s, twelvefactor.CapabilityRunProcess))
+
+	_, err := s.Plan(twelvefactor.App{ID: "app"})
+	assert.ErrorIs(t, err, twelvefactor.ErrUnsupported)
+}
+
 func TestScheduler_Remove(t *testing.T) {
 	b := new(mockStackBuilder)
 	s := &Scheduler{
@@ -54,6 +102,28 @@ func TestScheduler_ScaleProcess(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestScheduler_ScaleProcess_ServiceFinder(t *testing.T) {
+	b := new(mockServiceFinder)
+	c := new(mockECSClient)
+	s := &Scheduler{
+		Cluster:      "cluster",
+		stackBuilder: b,
+		ecs:          c,
+	}
+
+	// The service is found without listing the app's services.
+	b.On("Service", "app", "web").Return("acme-web", nil)
+	c.On("UpdateService", &ecs.UpdateServiceInput{
+		Cluster:      aws.String("cluster"),
+		DesiredCount: aws.Int64(1),
+		Service:      aws.String("acme-web"),
+	}).Return(&ecs.UpdateServiceOutput{}, nil)
+	err := s.ScaleProcess("app", "web", 1)
+	assert.NoError(t, err)
+
+	b.AssertNotCalled(t, "Services", "app")
+}
+
 func TestScheduler_ScaleProcess_NotFound(t *testing.T) {
 	b := new(mockStackBuilder)
 	c := new(mockECSClient)
@@ -64,8 +134,179 @@ func TestScheduler_ScaleProcess_NotFound(t *testing.T) {
 	}
 
 	b.On("Services", "app").Return(map[string]string{}, nil)
+	b.On("Schedules", "app").Return(map[string]string{}, nil)
 	err := s.ScaleProcess("app", "web", 1)
-	assert.Error(t, err, "web process not found")
+	assert.EqualError(t, err, "web process not found")
+	assert.True(t, errors.Is(err, twelvefactor.ErrProcessNotFound))
+}
+
+func TestScheduler_ScaleProcess_Scheduled(t *testing.T) {
+	b := new(mockStackBuilder)
+	c := new(mockECSClient)
+	s := &Scheduler{
+		Cluster:      "cluster",
+		stackBuilder: b,
+		ecs:          c,
+	}
+
+	b.On("Services", "app").Return(map[string]string{}, nil)
+	b.On("Schedules", "app").Return(map[string]string{"cleanup": "app--cleanup"}, nil)
+	err := s.ScaleProcess("app", "cleanup", 1)
+	assert.True(t, errors.Is(err, twelvefactor.ErrUnsupported))
+	c.AssertNotCalled(t, "UpdateService", mock.Anything)
+}
+
+func TestScheduler_Restart(t *testing.T) {
+	b := new(mockStackBuilder)
+	c := new(mockECSClient)
+	s := &Scheduler{
+		Cluster:      "cluster",
+		stackBuilder: b,
+		ecs:          c,
+	}
+
+	b.On("Services", "app").Return(map[string]string{
+		"web":    "app--web",
+		"worker": "app--worker",
+	}, nil)
+	b.On("Schedules", "app").Return(map[string]string{}, nil)
+	for _, service := range []string{"app--web", "app--worker"} {
+		c.On("UpdateService", &ecs.UpdateServiceInput{
+			Cluster:            aws.String("cluster"),
+			ForceNewDeployment: aws.Bool(true),
+			Service:            aws.String(service),
+		}).Return(&ecs.UpdateServiceOutput{}, nil).Once()
+	}
+
+	err := s.Restart("app")
+	assert.NoError(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestScheduler_Restart_NotFound(t *testing.T) {
+	b := new(mockStackBuilder)
+	s := &Scheduler{
+		stackBuilder: b,
+	}
+
+	b.On("Services", "app").Return(map[string]string{}, nil)
+	b.On("Schedules", "app").Return(map[string]string{}, nil)
+	err := s.Restart("app")
+	assert.True(t, errors.Is(err, twelvefactor.ErrAppNotFound))
+}
+
+func TestScheduler_RestartProcess(t *testing.T) {
+	b := new(mockStackBuilder)
+	c := new(mockECSClient)
+	s := &Scheduler{
+		Cluster:      "cluster",
+		stackBuilder: b,
+		ecs:          c,
+	}
+
+	b.On("Services", "app").Return(map[string]string{
+		"web": "app--web",
+	}, nil)
+	c.On("UpdateService", &ecs.UpdateServiceInput{
+		Cluster:            aws.String("cluster"),
+		ForceNewDeployment: aws.Bool(true),
+		Service:            aws.String("app--web"),
+	}).Return(&ecs.UpdateServiceOutput{}, nil)
+
+	err := s.RestartProcess("app", "web")
+	assert.NoError(t, err)
+
+	err = s.RestartProcess("app", "worker")
+	assert.True(t, errors.Is(err, twelvefactor.ErrProcessNotFound))
+}
+
+func TestScheduler_StopTask(t *testing.T) {
+	c := new(mockECSClient)
+	s := &Scheduler{
+		Cluster: "cluster",
+		ecs:     c,
+	}
+
+	c.On("StopTask", &ecs.StopTaskInput{
+		Cluster: aws.String("cluster"),
+		Reason:  aws.String("Stopped by 12factor"),
+		Task:    aws.String("0b69d5c0"),
+	}).Return(&ecs.StopTaskOutput{}, nil)
+	c.On("StopTask", &ecs.StopTaskInput{
+		Cluster: aws.String("cluster"),
+		Reason:  aws.String("Stopped by 12factor"),
+		Task:    aws.String("unknown"),
+	}).Return((*ecs.StopTaskOutput)(nil), awserr.New(ecs.ErrCodeInvalidParameterException, "The referenced task was not found.", nil))
+
+	err := s.StopTask("0b69d5c0")
+	assert.NoError(t, err)
+
+	err = s.StopTask("unknown")
+	assert.EqualError(t, err, "unknown task not found")
+	assert.True(t, errors.Is(err, twelvefactor.ErrTaskNotFound))
+}
+
+func TestRetryingECSClient_UpdateService(t *testing.T) {
+	throttled := awserr.New("ThrottlingException", "Rate exceeded", nil)
+	unavailable := awserr.NewRequestFailure(awserr.New("ServerException", "Service Unavailable", nil), 503, "")
+	notFound := awserr.New("ServiceNotFoundException", "Service not found", nil)
+
+	tests := []struct {
+		errs  []error
+		calls int
+		err   error
+	}{
+		{[]error{nil}, 1, nil},
+		{[]error{throttled, unavailable, nil}, 3, nil},
+		{[]error{unavailable, unavailable, unavailable}, 3, unavailable},
+		{[]error{notFound}, 1, notFound},
+	}
+
+	for i, tt := range tests {
+		m := new(mockECSClient)
+		c := &retryingECSClient{ecsClient: m, policy: func() *retry.Policy { return &retry.Policy{MaxAttempts: 3} }}
+
+		input := &ecs.UpdateServiceInput{Service: aws.String("acme--web")}
+		for _, err := range tt.errs {
+			var resp *ecs.UpdateServiceOutput
+			if err == nil {
+				resp = &ecs.UpdateServiceOutput{}
+			}
+			m.On("UpdateService", input).Return(resp, err).Once()
+		}
+
+		_, err := c.UpdateService(input)
+		assert.Equal(t, tt.err, err, "#%d", i)
+		m.AssertNumberOfCalls(t, "UpdateService", tt.calls)
+	}
+}
+
+func TestScheduler_WithContext(t *testing.T) {
+	m := new(mockECSClient)
+	l := new(mockLogsClient)
+	s := &Scheduler{
+		LogGroup:     "logs",
+		RetryPolicy:  &retry.Policy{MaxAttempts: 3},
+		logs:         l,
+		stackBuilder: new(raw.StackBuilder),
+	}
+	s.ecs = &retryingECSClient{ecsClient: m, policy: s.retryPolicy}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := s.WithContext(ctx)
+	assert.NotSame(t, s.stackBuilder, c.stackBuilder)
+
+	// Following logs stops once the context is done.
+	l.On("FilterLogEventsPages", mock.Anything).Return(nil, []*cloudwatchlogs.FilterLogEventsOutput{}).Run(func(mock.Arguments) {
+		cancel()
+	}).Once()
+	err := c.StreamLogs(new(bytes.Buffer), twelvefactor.LogsOptions{App: "app", Follow: true})
+	assert.Equal(t, context.Canceled, err)
+
+	// And calls aren't made.
+	_, err = c.ecs.ListTasks(&ecs.ListTasksInput{})
+	assert.Equal(t, context.Canceled, err)
+	m.AssertNotCalled(t, "ListTasks", mock.Anything)
 }
 
 func TestScheduler_Tasks(t *testing.T) {
@@ -80,6 +321,7 @@ func TestScheduler_Tasks(t *testing.T) {
 	b.On("Services", "app").Return(map[string]string{
 		"web": "app--web",
 	}, nil)
+	b.On("Schedules", "app").Return(map[string]string{}, nil)
 	c.On("ListTasks", &ecs.ListTasksInput{
 		Cluster:     aws.String("cluster"),
 		ServiceName: aws.String("app--web"),
@@ -105,12 +347,698 @@ func TestScheduler_Tasks(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, tasks, []twelvefactor.Task{
 		{
-			ID:    "0b69d5c0-d655-4695-98cd-5d2d526d9d5a",
+			ID:      "0b69d5c0-d655-4695-98cd-5d2d526d9d5a",
+			Process: "web",
+			State:   "RUNNING",
+		},
+	})
+}
+
+func TestScheduler_ServiceTasks_Pagination(t *testing.T) {
+	c := new(mockECSClient)
+	s := &Scheduler{
+		Cluster: "cluster",
+		ecs:     c,
+	}
+
+	var arns []*string
+	var described []*ecs.Task
+	for i := 0; i < 250; i++ {
+		arn := aws.String(fmt.Sprintf("arn:aws:ecs:us-east-1:012345678910:task/%d", i))
+		arns = append(arns, arn)
+		described = append(described, &ecs.Task{TaskArn: arn, LastStatus: aws.String("RUNNING")})
+	}
+
+	c.On("ListTasks", &ecs.ListTasksInput{
+		Cluster:     aws.String("cluster"),
+		ServiceName: aws.String("app--web"),
+	}).Return(&ecs.ListTasksOutput{
+		TaskArns:  arns[:100],
+		NextToken: aws.String("1"),
+	}, nil)
+	c.On("ListTasks", &ecs.ListTasksInput{
+		Cluster:     aws.String("cluster"),
+		ServiceName: aws.String("app--web"),
+		NextToken:   aws.String("1"),
+	}).Return(&ecs.ListTasksOutput{
+		TaskArns:  arns[100:200],
+		NextToken: aws.String("2"),
+	}, nil)
+	c.On("ListTasks", &ecs.ListTasksInput{
+		Cluster:     aws.String("cluster"),
+		ServiceName: aws.String("app--web"),
+		NextToken:   aws.String("2"),
+	}).Return(&ecs.ListTasksOutput{
+		TaskArns: arns[200:],
+	}, nil)
+	for _, chunk := range [][2]int{{0, 100}, {100, 200}, {200, 250}} {
+		c.On("DescribeTasks", &ecs.DescribeTasksInput{
+			Cluster: aws.String("cluster"),
+			Tasks:   arns[chunk[0]:chunk[1]],
+		}).Return(&ecs.DescribeTasksOutput{
+			Tasks: described[chunk[0]:chunk[1]],
+		}, nil)
+	}
+
+	tasks, err := s.ServiceTasks("app--web")
+	assert.NoError(t, err)
+	assert.Len(t, tasks, 250)
+	assert.Equal(t, "249", tasks[249].ID)
+	c.AssertNumberOfCalls(t, "DescribeTasks", 3)
+}
+
+func TestScheduler_ServiceTasks_IncludeStopped(t *testing.T) {
+	c := new(mockECSClient)
+	s := &Scheduler{
+		Cluster:        "cluster",
+		IncludeStopped: true,
+		ecs:            c,
+	}
+
+	c.On("ListTasks", &ecs.ListTasksInput{
+		Cluster:     aws.String("cluster"),
+		ServiceName: aws.String("app--web"),
+	}).Return(&ecs.ListTasksOutput{
+		TaskArns: []*string{
+			aws.String("arn:aws:ecs:us-east-1:012345678910:task/running"),
+		},
+	}, nil)
+	c.On("ListTasks", &ecs.ListTasksInput{
+		Cluster:       aws.String("cluster"),
+		ServiceName:   aws.String("app--web"),
+		DesiredStatus: aws.String("STOPPED"),
+	}).Return(&ecs.ListTasksOutput{
+		TaskArns: []*string{
+			aws.String("arn:aws:ecs:us-east-1:012345678910:task/stopped"),
+		},
+	}, nil)
+	c.On("DescribeTasks", &ecs.DescribeTasksInput{
+		Cluster: aws.String("cluster"),
+		Tasks: []*string{
+			aws.String("arn:aws:ecs:us-east-1:012345678910:task/running"),
+			aws.String("arn:aws:ecs:us-east-1:012345678910:task/stopped"),
+		},
+	}).Return(&ecs.DescribeTasksOutput{
+		Tasks: []*ecs.Task{
+			{
+				TaskArn:    aws.String("arn:aws:ecs:us-east-1:012345678910:task/running"),
+				LastStatus: aws.String("RUNNING"),
+			},
+			{
+				TaskArn:       aws.String("arn:aws:ecs:us-east-1:012345678910:task/stopped"),
+				LastStatus:    aws.String("STOPPED"),
+				StoppedReason: aws.String("Essential container in task exited"),
+			},
+		},
+	}, nil)
+
+	tasks, err := s.ServiceTasks("app--web")
+	assert.NoError(t, err)
+	assert.Equal(t, []twelvefactor.Task{
+		{ID: "running", State: "RUNNING"},
+		{ID: "stopped", State: "STOPPED", StopReason: "Essential container in task exited"},
+	}, tasks)
+}
+
+func TestNewTask(t *testing.T) {
+	started := time.Date(2015, time.October, 14, 4, 30, 0, 0, time.UTC)
+	stopped := started.Add(time.Hour)
+
+	task, err := newTask(&ecs.Task{
+		TaskArn:              aws.String("arn:aws:ecs:us-east-1:012345678910:task/cluster/0b69d5c0d6554695"),
+		ContainerInstanceArn: aws.String("arn:aws:ecs:us-east-1:012345678910:container-instance/cluster/5e1e3a6a0c5e4b5c"),
+		LastStatus:           aws.String("STOPPED"),
+		StoppedReason:        aws.String("Essential container in task exited"),
+		HealthStatus:         aws.String("UNHEALTHY"),
+		StartedAt:            aws.Time(started),
+		StoppedAt:            aws.Time(stopped),
+		Containers: []*ecs.Container{
+			{
+				Name:     aws.String("worker"),
+				ExitCode: aws.Int64(137),
+				NetworkInterfaces: []*ecs.NetworkInterface{
+					{PrivateIpv4Address: aws.String("10.0.0.12"), Ipv6Address: aws.String("2600:1f18::12")},
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	exitCode := 137
+	assert.Equal(t, twelvefactor.Task{
+		ID:          "0b69d5c0d6554695",
+		State:       "STOPPED",
+		StopReason:  "Essential container in task exited",
+		ExitCode:    &exitCode,
+		Health:      twelvefactor.HealthUnhealthy,
+		Host:        "5e1e3a6a0c5e4b5c",
+		IPAddresses: []string{"10.0.0.12", "2600:1f18::12"},
+		StartedAt:   started,
+		StoppedAt:   stopped,
+	}, task)
+}
+
+func TestScheduler_Tasks_Concurrent(t *testing.T) {
+	b := new(mockStackBuilder)
+	c := new(mockECSClient)
+	s := &Scheduler{
+		Cluster:      "cluster",
+		stackBuilder: b,
+		ecs:          c,
+	}
+
+	services := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		process := fmt.Sprintf("p%02d", i)
+		services[process] = "app--" + process
+
+		arn := aws.String("arn:aws:ecs:us-east-1:012345678910:task/" + process)
+		c.On("ListTasks", &ecs.ListTasksInput{
+			Cluster:     aws.String("cluster"),
+			ServiceName: aws.String("app--" + process),
+		}).Return(&ecs.ListTasksOutput{TaskArns: []*string{arn}}, nil)
+		c.On("DescribeTasks", &ecs.DescribeTasksInput{
+			Cluster: aws.String("cluster"),
+			Tasks:   []*string{arn},
+		}).Return(&ecs.DescribeTasksOutput{
+			Tasks: []*ecs.Task{{TaskArn: arn, LastStatus: aws.String("RUNNING")}},
+		}, nil)
+	}
+	b.On("Services", "app").Return(services, nil)
+	b.On("Schedules", "app").Return(map[string]string{}, nil)
+
+	tasks, err := s.Tasks("app")
+	assert.NoError(t, err)
+	if assert.Len(t, tasks, 20) {
+		// Tasks are ordered by process.
+		for i, task := range tasks {
+			assert.Equal(t, fmt.Sprintf("p%02d", i), task.Process)
+			assert.Equal(t, task.Process, task.ID)
+		}
+	}
+}
+
+func TestScheduler_ServiceTasks_LongARNs(t *testing.T) {
+	c := new(mockECSClient)
+	s := &Scheduler{
+		Cluster: "cluster",
+		ecs:     c,
+	}
+
+	c.On("ListTasks", &ecs.ListTasksInput{
+		Cluster:     aws.String("cluster"),
+		ServiceName: aws.String("app--web"),
+	}).Return(&ecs.ListTasksOutput{
+		TaskArns: []*string{
+			aws.String("arn:aws:ecs:us-east-1:012345678910:task/cluster/0b69d5c0d6554695"),
+		},
+	}, nil)
+	c.On("DescribeTasks", mock.Anything).Return(&ecs.DescribeTasksOutput{
+		Tasks: []*ecs.Task{
+			{
+				TaskArn:    aws.String("arn:aws:ecs:us-east-1:012345678910:task/cluster/0b69d5c0d6554695"),
+				LastStatus: aws.String("RUNNING"),
+			},
+		},
+	}, nil)
+	tasks, err := s.ServiceTasks("app--web")
+	assert.NoError(t, err)
+	assert.Equal(t, tasks, []twelvefactor.Task{
+		{
+			ID:    "0b69d5c0d6554695",
 			State: "RUNNING",
 		},
 	})
 }
 
+func TestScheduler_Tasks_Scheduled(t *testing.T) {
+	b := new(mockStackBuilder)
+	c := new(mockECSClient)
+	s := &Scheduler{
+		Cluster:      "cluster",
+		stackBuilder: b,
+		ecs:          c,
+	}
+
+	// ECS took over a minute to create the task after the rule fired.
+	createdAt := time.Date(2015, time.October, 14, 4, 31, 5, 0, time.UTC)
+
+	b.On("Services", "app").Return(map[string]string{}, nil)
+	b.On("Schedules", "app").Return(map[string]string{
+		"cleanup": "app--cleanup",
+	}, nil)
+	b.On("ScheduleExpressions", "app").Return(map[string]string{
+		"cleanup": "30 4 * * *",
+	}, nil)
+	c.On("ListTasks", &ecs.ListTasksInput{
+		Cluster: aws.String("cluster"),
+		Family:  aws.String("app--cleanup"),
+	}).Return(&ecs.ListTasksOutput{
+		TaskArns: []*string{
+			aws.String("arn:aws:ecs:us-east-1:012345678910:task/0b69d5c0-d655-4695-98cd-5d2d526d9d5a"),
+		},
+	}, nil)
+	c.On("DescribeTasks", &ecs.DescribeTasksInput{
+		Cluster: aws.String("cluster"),
+		Tasks: []*string{
+			aws.String("arn:aws:ecs:us-east-1:012345678910:task/0b69d5c0-d655-4695-98cd-5d2d526d9d5a"),
+		},
+	}).Return(&ecs.DescribeTasksOutput{
+		Tasks: []*ecs.Task{
+			{
+				TaskArn:    aws.String("arn:aws:ecs:us-east-1:012345678910:task/0b69d5c0-d655-4695-98cd-5d2d526d9d5a"),
+				LastStatus: aws.String("RUNNING"),
+				StartedBy:  aws.String("events-rule/app--cleanup"),
+				CreatedAt:  aws.Time(createdAt),
+			},
+		},
+	}, nil)
+	tasks, err := s.Tasks("app")
+	assert.NoError(t, err)
+	assert.Equal(t, tasks, []twelvefactor.Task{
+		{
+			ID:          "0b69d5c0-d655-4695-98cd-5d2d526d9d5a",
+			Process:     "cleanup",
+			State:       "RUNNING",
+			TriggeredAt: time.Date(2015, time.October, 14, 4, 30, 0, 0, time.UTC),
+		},
+	})
+}
+
+func TestTriggeredAt(t *testing.T) {
+	daily, _ := cron.Parse("@daily")
+	every, _ := cron.Parse("@every 5m")
+	createdAt := time.Date(2015, time.October, 14, 0, 1, 5, 0, time.FixedZone("PDT", -7*60*60))
+
+	tests := []struct {
+		schedule  *cron.Schedule
+		createdAt time.Time
+		out       time.Time
+	}{
+		// Schedules are evaluated in UTC.
+		{daily, createdAt, time.Date(2015, time.October, 14, 0, 0, 0, 0, time.UTC)},
+		{nil, createdAt, time.Date(2015, time.October, 14, 7, 1, 0, 0, time.UTC)},
+		{every, createdAt, time.Date(2015, time.October, 14, 7, 1, 0, 0, time.UTC)},
+		{daily, time.Time{}, time.Time{}},
+	}
+
+	for i, tt := range tests {
+		assert.Equal(t, tt.out, triggeredAt(tt.schedule, tt.createdAt), "#%d", i)
+	}
+}
+
+func TestScheduler_StreamLogs(t *testing.T) {
+	l := new(mockLogsClient)
+	s := &Scheduler{
+		LogGroup: "logs",
+		logs:     l,
+	}
+
+	since := time.Date(2015, time.October, 14, 4, 30, 0, 0, time.UTC)
+
+	l.On("FilterLogEventsPages", &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName:        aws.String("logs"),
+		LogStreamNamePrefix: aws.String("app/web/"),
+		StartTime:           aws.Int64(1444797000000),
+	}).Return(nil, []*cloudwatchlogs.FilterLogEventsOutput{
+		{
+			Events: []*cloudwatchlogs.FilteredLogEvent{
+				{
+					EventId:       aws.String("1"),
+					LogStreamName: aws.String("app/web/0b69d5c0"),
+					Message:       aws.String("Started GET /\n"),
+					Timestamp:     aws.Int64(1444797001000),
+				},
+			},
+		},
+		{
+			Events: []*cloudwatchlogs.FilteredLogEvent{
+				{
+					EventId:       aws.String("2"),
+					LogStreamName: aws.String("app/web/1c8ad5c0"),
+					Message:       aws.String("Completed 200 OK"),
+					Timestamp:     aws.Int64(1444797002000),
+				},
+			},
+		},
+	})
+
+	w := new(bytes.Buffer)
+	err := s.StreamLogs(w, twelvefactor.LogsOptions{
+		App:     "app",
+		Process: "web",
+		Since:   since,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "web/0b69d5c0: Started GET /\nweb/1c8ad5c0: Completed 200 OK\n", w.String())
+}
+
+func TestScheduler_StreamLogs_Follow(t *testing.T) {
+	l := new(mockLogsClient)
+	s := &Scheduler{
+		LogGroup: "logs",
+		logs:     l,
+	}
+
+	defer func(d time.Duration) { logsPollInterval = d }(logsPollInterval)
+	logsPollInterval = 0
+
+	event := func(id string, timestamp int64) *cloudwatchlogs.FilteredLogEvent {
+		return &cloudwatchlogs.FilteredLogEvent{
+			EventId:       aws.String(id),
+			LogStreamName: aws.String("app/web/0b69d5c0"),
+			Message:       aws.String("event " + id),
+			Timestamp:     aws.Int64(timestamp),
+		}
+	}
+
+	l.On("FilterLogEventsPages", &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName:        aws.String("logs"),
+		LogStreamNamePrefix: aws.String("app/"),
+	}).Return(nil, []*cloudwatchlogs.FilterLogEventsOutput{
+		{Events: []*cloudwatchlogs.FilteredLogEvent{event("1", 1000), event("2", 2000)}},
+	}).Once()
+
+	// Events at the last timestamp are returned again.
+	l.On("FilterLogEventsPages", &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName:        aws.String("logs"),
+		LogStreamNamePrefix: aws.String("app/"),
+		StartTime:           aws.Int64(2000),
+	}).Return(nil, []*cloudwatchlogs.FilterLogEventsOutput{
+		{Events: []*cloudwatchlogs.FilteredLogEvent{event("2", 2000), event("3", 3000)}},
+	}).Once()
+
+	l.On("FilterLogEventsPages", &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName:        aws.String("logs"),
+		LogStreamNamePrefix: aws.String("app/"),
+		StartTime:           aws.Int64(3000),
+	}).Return(errors.New("boom"), []*cloudwatchlogs.FilterLogEventsOutput{}).Once()
+
+	w := new(bytes.Buffer)
+	err := s.StreamLogs(w, twelvefactor.LogsOptions{
+		App:    "app",
+		Follow: true,
+	})
+	assert.EqualError(t, err, "boom")
+	assert.Equal(t, "web/0b69d5c0: event 1\nweb/0b69d5c0: event 2\nweb/0b69d5c0: event 3\n", w.String())
+}
+
+func TestPruneSeen(t *testing.T) {
+	seen := map[string]int64{"1": 1000, "2": 2000, "3": 3000}
+	pruneSeen(seen, 2000)
+	assert.Equal(t, map[string]int64{"2": 2000, "3": 3000}, seen)
+}
+
+func TestScheduler_StreamLogs_Task(t *testing.T) {
+	c := new(mockECSClient)
+	l := new(mockLogsClient)
+	s := &Scheduler{
+		Cluster:  "cluster",
+		LogGroup: "logs",
+		ecs:      c,
+		logs:     l,
+	}
+
+	c.On("DescribeTasks", &ecs.DescribeTasksInput{
+		Cluster: aws.String("cluster"),
+		Tasks:   []*string{aws.String("0b69d5c0")},
+	}).Return(&ecs.DescribeTasksOutput{
+		Tasks: []*ecs.Task{
+			{
+				Containers: []*ecs.Container{
+					{Name: aws.String("worker")},
+				},
+			},
+		},
+	}, nil)
+	l.On("FilterLogEventsPages", &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName:        aws.String("logs"),
+		LogStreamNamePrefix: aws.String("app/worker/0b69d5c0"),
+	}).Return(nil, []*cloudwatchlogs.FilterLogEventsOutput{})
+
+	err := s.StreamLogs(new(bytes.Buffer), twelvefactor.LogsOptions{
+		App:  "app",
+		Task: "0b69d5c0",
+	})
+	assert.NoError(t, err)
+}
+
+func TestScheduler_RunProcess_Detached(t *testing.T) {
+	b := new(mockTaskRunner)
+	s := &Scheduler{
+		stackBuilder: b,
+	}
+
+	process := twelvefactor.Process{Name: "run", Command: []string{"rake", "db:migrate"}}
+	b.On("RunTask", "app", process).Return("0b69d5c0", nil)
+
+	err := s.RunProcess("app", process)
+	assert.NoError(t, err)
+
+	b.AssertExpectations(t)
+}
+
+func TestScheduler_RunProcess_Attached(t *testing.T) {
+	tests := []struct {
+		container *ecs.Container
+		reason    string
+		err       string
+	}{
+		{&ecs.Container{Name: aws.String("web"), ExitCode: aws.Int64(0)}, "", ""},
+		{&ecs.Container{Name: aws.String("web"), ExitCode: aws.Int64(2)}, "", "run exited with code 2"},
+		{&ecs.Container{Name: aws.String("web")}, "CannotPullContainerError", "run stopped: CannotPullContainerError"},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("#%d", i), func(t *testing.T) {
+			b := new(mockTaskRunner)
+			c := new(mockECSClient)
+			l := new(mockLogsClient)
+			s := &Scheduler{
+				Cluster:      "cluster",
+				LogGroup:     "logs",
+				ecs:          c,
+				logs:         l,
+				stackBuilder: b,
+			}
+
+			defer func(d time.Duration) { logsPollInterval = d }(logsPollInterval)
+			logsPollInterval = 0
+
+			w := new(bytes.Buffer)
+			b.On("RunTask", "app", mock.Anything).Return("0b69d5c0", nil)
+
+			describe := &ecs.DescribeTasksInput{
+				Cluster: aws.String("cluster"),
+				Tasks:   []*string{aws.String("0b69d5c0")},
+			}
+			c.On("DescribeTasks", describe).Return(&ecs.DescribeTasksOutput{
+				Tasks: []*ecs.Task{
+					{LastStatus: aws.String("RUNNING"), Containers: []*ecs.Container{{Name: aws.String("web")}}},
+				},
+			}, nil).Twice()
+			c.On("DescribeTasks", describe).Return(&ecs.DescribeTasksOutput{
+				Tasks: []*ecs.Task{
+					{LastStatus: aws.String("STOPPED"), StoppedReason: aws.String(tt.reason), Containers: []*ecs.Container{tt.container}},
+				},
+			}, nil).Once()
+
+			l.On("FilterLogEventsPages", &cloudwatchlogs.FilterLogEventsInput{
+				LogGroupName:        aws.String("logs"),
+				LogStreamNamePrefix: aws.String("app/web/0b69d5c0"),
+			}).Return(nil, []*cloudwatchlogs.FilterLogEventsOutput{
+				{Events: []*cloudwatchlogs.FilteredLogEvent{
+					{EventId: aws.String("1"), LogStreamName: aws.String("app/web/0b69d5c0"), Message: aws.String("migrating"), Timestamp: aws.Int64(1000)},
+				}},
+			}).Once()
+			// Events that are written before the task stops are
+			// picked up by the last poll.
+			l.On("FilterLogEventsPages", mock.Anything).Return(nil, []*cloudwatchlogs.FilterLogEventsOutput{
+				{Events: []*cloudwatchlogs.FilteredLogEvent{
+					{EventId: aws.String("2"), LogStreamName: aws.String("app/web/0b69d5c0"), Message: aws.String("done"), Timestamp: aws.Int64(2000)},
+				}},
+			})
+
+			err := s.RunProcess("app", twelvefactor.Process{
+				Name:    "run",
+				Command: []string{"rake", "db:migrate"},
+				Stdout:  w,
+			})
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+			assert.Equal(t, "web/0b69d5c0: migrating\nweb/0b69d5c0: done\n", w.String())
+
+			b.AssertExpectations(t)
+			c.AssertExpectations(t)
+		})
+	}
+}
+
+func TestScheduler_RunProcess_Unsupported(t *testing.T) {
+	s := &Scheduler{
+		stackBuilder: new(mockStackBuilder),
+	}
+
+	err := s.RunProcess("app", twelvefactor.Process{Name: "run", Command: []string{"bash"}})
+	assert.ErrorIs(t, err, twelvefactor.ErrUnsupported)
+
+	// Attached processes need a log group to follow.
+	s.stackBuilder = new(mockTaskRunner)
+	err = s.RunProcess("app", twelvefactor.Process{Name: "run", Command: []string{"bash"}, Stdout: new(bytes.Buffer)})
+	assert.ErrorIs(t, err, twelvefactor.ErrUnsupported)
+}
+
+func TestScheduler_Exec(t *testing.T) {
+	c := new(mockECSClient)
+	sess := new(mockSessionClient)
+	s := &Scheduler{
+		Cluster: "cluster",
+		ecs:     c,
+		session: sess,
+	}
+
+	c.On("DescribeTasks", &ecs.DescribeTasksInput{
+		Cluster: aws.String("cluster"),
+		Tasks:   []*string{aws.String("0b69d5c0")},
+	}).Return(&ecs.DescribeTasksOutput{
+		Tasks: []*ecs.Task{
+			{
+				ClusterArn: aws.String("arn:aws:ecs:us-east-1:012345678910:cluster/cluster"),
+				Containers: []*ecs.Container{
+					{Name: aws.String("web"), RuntimeId: aws.String("0b69d5c0-1234")},
+				},
+			},
+		},
+	}, nil)
+	session := &ecs.Session{SessionId: aws.String("session")}
+	c.On("ExecuteCommand", &ecs.ExecuteCommandInput{
+		Cluster:     aws.String("cluster"),
+		Task:        aws.String("0b69d5c0"),
+		Container:   aws.String("web"),
+		Command:     aws.String(`sh -c ''\''rails'\'' '\''console'\''; echo '\''exit='\''$?'`),
+		Interactive: aws.Bool(true),
+	}).Return(&ecs.ExecuteCommandOutput{Session: session}, nil)
+
+	defer func(f func() string) { newExitMarker = f }(newExitMarker)
+	newExitMarker = func() string { return "exit=" }
+
+	stdin, stdout := new(bytes.Buffer), new(bytes.Buffer)
+	tty := &twelvefactor.TTY{}
+	sess.On("Attach", session, "ecs:cluster_0b69d5c0_0b69d5c0-1234", stdin, mock.Anything, tty).Return(0, nil).Run(func(args mock.Arguments) {
+		w := args.Get(3).(io.Writer)
+		io.WriteString(w, "Loading production environment\r\nex")
+		io.WriteString(w, "it=3\r\n")
+	}).Once()
+
+	code, err := s.Exec("0b69d5c0", []string{"rails", "console"}, stdin, stdout, tty)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, code)
+	assert.Equal(t, "Loading production environment\r\n", stdout.String())
+
+	// The session ended before the command reported its exit code.
+	sess.On("Attach", session, "ecs:cluster_0b69d5c0_0b69d5c0-1234", stdin, mock.Anything, tty).Return(255, nil).Once()
+
+	_, err = s.Exec("0b69d5c0", []string{"rails", "console"}, stdin, stdout, tty)
+	assert.EqualError(t, err, "session ended with code 255 without reporting the exit code of the command")
+}
+
+func TestScheduler_Exec_NoTTY(t *testing.T) {
+	s := &Scheduler{Cluster: "cluster"}
+
+	_, err := s.Exec("0b69d5c0", []string{"rails", "console"}, nil, new(bytes.Buffer), nil)
+	assert.ErrorIs(t, err, twelvefactor.ErrUnsupported)
+}
+
+func TestPluginSession_Attach_Resize(t *testing.T) {
+	// A fake plugin that prints the size of its terminal, and again when
+	// the terminal is resized.
+	dir := t.TempDir()
+	script := "#!/bin/sh\ntrap 'stty size; exit 3' WINCH\nstty size\nwhile :; do sleep 0.1; done\n"
+	if err := os.WriteFile(filepath.Join(dir, "session-manager-plugin"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	resize := make(chan twelvefactor.TerminalSize)
+	tty := &twelvefactor.TTY{
+		Size:   twelvefactor.TerminalSize{Width: 80, Height: 24},
+		Resize: resize,
+	}
+
+	r, w := io.Pipe()
+	type result struct {
+		code int
+		err  error
+	}
+	done := make(chan result)
+	go func() {
+		p := &pluginSession{Region: "us-east-1"}
+		code, err := p.Attach(&ecs.Session{}, "ecs:cluster_task_runtime", strings.NewReader(""), w, tty)
+		w.Close()
+		done <- result{code, err}
+	}()
+
+	out := bufio.NewReader(r)
+	line, err := out.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "24 80", strings.TrimSpace(line))
+
+	resize <- twelvefactor.TerminalSize{Width: 100, Height: 40}
+	line, err = out.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "40 100", strings.TrimSpace(line))
+
+	io.Copy(io.Discard, r)
+	res := <-done
+	assert.NoError(t, res.err)
+	assert.Equal(t, 3, res.code)
+}
+
+func TestExecCommand(t *testing.T) {
+	tests := []struct {
+		command []string
+		out     string
+	}{
+		{[]string{"ls"}, `sh -c ''\''ls'\''; echo '\''exit='\''$?'`},
+		{[]string{"echo", "it's"}, `sh -c ''\''echo'\'' '\''it'\''\'\'''\''s'\''; echo '\''exit='\''$?'`},
+	}
+
+	for i, tt := range tests {
+		assert.Equal(t, tt.out, execCommand(tt.command, "exit="), "#%d", i)
+	}
+}
+
+func TestExitCodeWriter(t *testing.T) {
+	tests := []struct {
+		writes []string
+		out    string
+		code   *int
+	}{
+		{[]string{"hello\n", "exit=0\n"}, "hello\n", aws.Int(0)},
+		{[]string{"hello\nexit=12\r\n"}, "hello\n", aws.Int(12)},
+		{[]string{"e", "x", "it", "=", "1", "\n"}, "", aws.Int(1)},
+		{[]string{"exiting\n"}, "exiting\n", nil},
+		{[]string{"hello\nexi"}, "hello\nexi", nil},
+	}
+
+	for i, tt := range tests {
+		out := new(bytes.Buffer)
+		w := &exitCodeWriter{w: out, marker: []byte("exit=")}
+		for _, p := range tt.writes {
+			n, err := w.Write([]byte(p))
+			assert.NoError(t, err)
+			assert.Equal(t, len(p), n)
+		}
+		assert.NoError(t, w.Flush())
+
+		assert.Equal(t, tt.out, out.String(), "#%d", i)
+		assert.Equal(t, tt.code, w.code, "#%d", i)
+	}
+}
+
 // mockECSClient is an implementation of the ecsClient interface for testing.
 type mockECSClient struct {
 	mock.Mock
@@ -131,6 +1059,44 @@ func (c *mockECSClient) DescribeTasks(input *ecs.DescribeTasksInput) (*ecs.Descr
 	return args.Get(0).(*ecs.DescribeTasksOutput), args.Error(1)
 }
 
+func (c *mockECSClient) StopTask(input *ecs.StopTaskInput) (*ecs.StopTaskOutput, error) {
+	args := c.Called(input)
+	return args.Get(0).(*ecs.StopTaskOutput), args.Error(1)
+}
+
+func (c *mockECSClient) ExecuteCommand(input *ecs.ExecuteCommandInput) (*ecs.ExecuteCommandOutput, error) {
+	args := c.Called(input)
+	return args.Get(0).(*ecs.ExecuteCommandOutput), args.Error(1)
+}
+
+// mockSessionClient is an implementation of the sessionClient interface for
+// testing.
+type mockSessionClient struct {
+	mock.Mock
+}
+
+func (c *mockSessionClient) Attach(session *ecs.Session, target string, stdin io.Reader, stdout io.Writer, tty *twelvefactor.TTY) (int, error) {
+	args := c.Called(session, target, stdin, stdout, tty)
+	return args.Int(0), args.Error(1)
+}
+
+// mockLogsClient is an implementation of the logsClient interface for testing.
+type mockLogsClient struct {
+	mock.Mock
+}
+
+func (c *mockLogsClient) FilterLogEventsPages(input *cloudwatchlogs.FilterLogEventsInput, fn func(*cloudwatchlogs.FilterLogEventsOutput, bool) bool) error {
+	// Copy the input, since StreamLogs mutates it between calls.
+	in := *input
+	args := c.Called(&in)
+	for _, resp := range args.Get(1).([]*cloudwatchlogs.FilterLogEventsOutput) {
+		if !fn(resp, false) {
+			break
+		}
+	}
+	return args.Error(0)
+}
+
 // mockStackBuilder is an implementation of the StackBuilder interface for
 // testing.
 type mockStackBuilder struct {
@@ -151,3 +1117,33 @@ func (b *mockStackBuilder) Services(app string) (map[string]string, error) {
 	args := b.Called(app)
 	return args.Get(0).(map[string]string), args.Error(1)
 }
+
+func (b *mockStackBuilder) Schedules(app string) (map[string]string, error) {
+	args := b.Called(app)
+	return args.Get(0).(map[string]string), args.Error(1)
+}
+
+func (b *mockStackBuilder) ScheduleExpressions(app string) (map[string]string, error) {
+	args := b.Called(app)
+	return args.Get(0).(map[string]string), args.Error(1)
+}
+
+// mockServiceFinder is a mockStackBuilder that can also find single services.
+type mockServiceFinder struct {
+	mockStackBuilder
+}
+
+func (b *mockServiceFinder) Service(app, process string) (string, error) {
+	args := b.Called(app, process)
+	return args.String(0), args.Error(1)
+}
+
+// mockTaskRunner is a mockStackBuilder that can also run tasks.
+type mockTaskRunner struct {
+	mockStackBuilder
+}
+
+func (b *mockTaskRunner) RunTask(app string, process twelvefactor.Process) (string, error) {
+	args := b.Called(app, process)
+	return args.String(0), args.Error(1)
+}
@@ -0,0 +1,568 @@
+// Package ecstest provides a fake of the ECS and CloudWatch Events APIs, for
+// testing the ECS scheduler end to end with the real AWS clients.
+package ecstest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// The account and region that ARNs are made in.
+const (
+	Account = "012345678910"
+	Region  = "us-east-1"
+)
+
+// The prefixes of the X-Amz-Target header for each API.
+const (
+	ecsTarget    = "AmazonEC2ContainerServiceV20141113"
+	eventsTarget = "AWSEvents"
+)
+
+// Server is an HTTP server that fakes the parts of the ECS and CloudWatch
+// Events APIs that the ECS scheduler uses, keeping services, task definitions
+// and rules in memory. Every cluster exists, and the tasks for a service are
+// always running at its desired count.
+//
+// Operations that aren't faked fail with an UnknownOperationException.
+type Server struct {
+	*httptest.Server
+
+	mu sync.Mutex
+
+	// services maps a cluster name to its services, by name, and tokens
+	// maps a service ARN to the ClientToken that it was created with.
+	services map[string]map[string]*ecs.Service
+	tokens   map[string]string
+
+	// taskDefinitions maps an ARN to the task definition, and revisions
+	// maps a family to its latest revision.
+	taskDefinitions map[string]*ecs.TaskDefinition
+	revisions       map[string]int64
+
+	// rules and targets map a rule name to the rule, and its targets.
+	rules   map[string]*cloudwatchevents.Rule
+	targets map[string][]*cloudwatchevents.Target
+
+	operations map[string]reflect.Value
+}
+
+// NewServer starts and returns a new Server. The caller should call Close when
+// finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		services:        make(map[string]map[string]*ecs.Service),
+		tokens:          make(map[string]string),
+		taskDefinitions: make(map[string]*ecs.TaskDefinition),
+		revisions:       make(map[string]int64),
+		rules:           make(map[string]*cloudwatchevents.Rule),
+		targets:         make(map[string][]*cloudwatchevents.Target),
+	}
+
+	s.operations = make(map[string]reflect.Value)
+	for target, fn := range map[string]interface{}{
+		ecsTarget + ".ListServices":           s.listServices,
+		ecsTarget + ".DescribeServices":       s.describeServices,
+		ecsTarget + ".CreateService":          s.createService,
+		ecsTarget + ".UpdateService":          s.updateService,
+		ecsTarget + ".DeleteService":          s.deleteService,
+		ecsTarget + ".RegisterTaskDefinition": s.registerTaskDefinition,
+		ecsTarget + ".DescribeTaskDefinition": s.describeTaskDefinition,
+		ecsTarget + ".DescribeClusters":       s.describeClusters,
+		ecsTarget + ".ListTasks":              s.listTasks,
+		ecsTarget + ".DescribeTasks":          s.describeTasks,
+		eventsTarget + ".PutRule":             s.putRule,
+		eventsTarget + ".PutTargets":          s.putTargets,
+		eventsTarget + ".RemoveTargets":       s.removeTargets,
+		eventsTarget + ".DeleteRule":          s.deleteRule,
+		eventsTarget + ".ListRules":           s.listRules,
+		eventsTarget + ".DescribeRule":        s.describeRule,
+		eventsTarget + ".ListTargetsByRule":   s.listTargetsByRule,
+	} {
+		s.operations[target] = reflect.ValueOf(fn)
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Config returns the configuration for AWS clients that talk to the server.
+func (s *Server) Config() *aws.Config {
+	return aws.NewConfig().
+		WithRegion(Region).
+		WithEndpoint(s.URL).
+		WithCredentials(credentials.NewStaticCredentials("AKID", "SECRET", ""))
+}
+
+// serveHTTP calls the operation in the X-Amz-Target header with the request
+// body, which is decoded into the operation's input.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.Header.Get("X-Amz-Target")
+	fn, ok := s.operations[target]
+	if !ok {
+		writeError(w, awserr.New("UnknownOperationException", fmt.Sprintf("operation not faked: %s", target), nil))
+		return
+	}
+
+	input := reflect.New(fn.Type().In(0).Elem())
+	if err := jsonutil.UnmarshalJSON(input.Interface(), r.Body); err != nil {
+		writeError(w, awserr.New("SerializationException", err.Error(), nil))
+		return
+	}
+
+	// The output is encoded before unlocking, since it can share state
+	// with the server.
+	s.mu.Lock()
+	out := fn.Call([]reflect.Value{input})
+	if err, _ := out[1].Interface().(error); err != nil {
+		s.mu.Unlock()
+		writeError(w, err)
+		return
+	}
+	body, err := jsonutil.BuildJSON(out[0].Interface())
+	s.mu.Unlock()
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.Write(body)
+}
+
+// writeError writes an error in the format of the AWS JSON protocol.
+func writeError(w http.ResponseWriter, err error) {
+	code, message := "ServerException", err.Error()
+	if err, ok := err.(awserr.Error); ok {
+		code, message = err.Code(), err.Message()
+	}
+
+	var body bytes.Buffer
+	json.NewEncoder(&body).Encode(map[string]string{"__type": code, "message": message})
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(body.Bytes())
+}
+
+func arn(resource string) string {
+	return fmt.Sprintf("arn:aws:ecs:%s:%s:%s", Region, Account, resource)
+}
+
+// name returns the name of a resource from its name or ARN.
+func name(nameOrARN string) string {
+	return nameOrARN[strings.LastIndex(nameOrARN, "/")+1:]
+}
+
+// cluster returns the name of the cluster from its name or ARN, which is
+// "default" if it's not given.
+func cluster(nameOrARN *string) string {
+	if nameOrARN == nil {
+		return "default"
+	}
+	return name(*nameOrARN)
+}
+
+// service returns an active service. s.mu must be held.
+func (s *Server) service(clusterName, nameOrARN *string) (*ecs.Service, error) {
+	service, ok := s.services[cluster(clusterName)][name(aws.StringValue(nameOrARN))]
+	if !ok || aws.StringValue(service.Status) != "ACTIVE" {
+		return nil, awserr.New(ecs.ErrCodeServiceNotFoundException, "Service not found.", nil)
+	}
+	return service, nil
+}
+
+func (s *Server) listServices(input *ecs.ListServicesInput) (*ecs.ListServicesOutput, error) {
+	var names []string
+	for name, service := range s.services[cluster(input.Cluster)] {
+		if aws.StringValue(service.Status) == "ACTIVE" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	start, _ := strconv.Atoi(aws.StringValue(input.NextToken))
+	end := len(names)
+	if max := int(aws.Int64Value(input.MaxResults)); max > 0 && start+max < end {
+		end = start + max
+	}
+
+	out := &ecs.ListServicesOutput{ServiceArns: []*string{}}
+	for _, name := range names[start:end] {
+		out.ServiceArns = append(out.ServiceArns, s.services[cluster(input.Cluster)][name].ServiceArn)
+	}
+	if end < len(names) {
+		out.NextToken = aws.String(strconv.Itoa(end))
+	}
+	return out, nil
+}
+
+func (s *Server) describeServices(input *ecs.DescribeServicesInput) (*ecs.DescribeServicesOutput, error) {
+	out := &ecs.DescribeServicesOutput{Services: []*ecs.Service{}, Failures: []*ecs.Failure{}}
+	for _, nameOrARN := range input.Services {
+		service, ok := s.services[cluster(input.Cluster)][name(aws.StringValue(nameOrARN))]
+		if !ok {
+			out.Failures = append(out.Failures, &ecs.Failure{Arn: nameOrARN, Reason: aws.String("MISSING")})
+			continue
+		}
+		out.Services = append(out.Services, service)
+	}
+	return out, nil
+}
+
+func (s *Server) createService(input *ecs.CreateServiceInput) (*ecs.CreateServiceOutput, error) {
+	clusterName := cluster(input.Cluster)
+	if service, err := s.service(input.Cluster, input.ServiceName); err == nil {
+		if token := s.tokens[aws.StringValue(service.ServiceArn)]; token != "" && token == aws.StringValue(input.ClientToken) {
+			return &ecs.CreateServiceOutput{Service: service}, nil
+		}
+		return nil, awserr.New(ecs.ErrCodeInvalidParameterException, "Creation of service was not idempotent.", nil)
+	}
+
+	taskDefinition, err := s.taskDefinition(input.TaskDefinition)
+	if err != nil {
+		return nil, err
+	}
+
+	service := &ecs.Service{
+		ClusterArn:              aws.String(arn("cluster/" + clusterName)),
+		ServiceName:             input.ServiceName,
+		ServiceArn:              aws.String(arn("service/" + clusterName + "/" + aws.StringValue(input.ServiceName))),
+		Status:                  aws.String("ACTIVE"),
+		TaskDefinition:          taskDefinition.TaskDefinitionArn,
+		DesiredCount:            aws.Int64(aws.Int64Value(input.DesiredCount)),
+		RunningCount:            aws.Int64(aws.Int64Value(input.DesiredCount)),
+		LaunchType:              input.LaunchType,
+		PlacementConstraints:    input.PlacementConstraints,
+		PlacementStrategy:       input.PlacementStrategy,
+		DeploymentConfiguration: input.DeploymentConfiguration,
+		NetworkConfiguration:    input.NetworkConfiguration,
+		EnableExecuteCommand:    input.EnableExecuteCommand,
+		Tags:                    input.Tags,
+	}
+	s.tokens[aws.StringValue(service.ServiceArn)] = aws.StringValue(input.ClientToken)
+	if s.services[clusterName] == nil {
+		s.services[clusterName] = make(map[string]*ecs.Service)
+	}
+	s.services[clusterName][aws.StringValue(input.ServiceName)] = service
+
+	return &ecs.CreateServiceOutput{Service: service}, nil
+}
+
+func (s *Server) updateService(input *ecs.UpdateServiceInput) (*ecs.UpdateServiceOutput, error) {
+	service, err := s.service(input.Cluster, input.Service)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.TaskDefinition != nil {
+		taskDefinition, err := s.taskDefinition(input.TaskDefinition)
+		if err != nil {
+			return nil, err
+		}
+		service.TaskDefinition = taskDefinition.TaskDefinitionArn
+	}
+	if input.DesiredCount != nil {
+		service.DesiredCount = aws.Int64(*input.DesiredCount)
+		service.RunningCount = aws.Int64(*input.DesiredCount)
+	}
+	if input.PlacementConstraints != nil {
+		service.PlacementConstraints = input.PlacementConstraints
+	}
+	if input.PlacementStrategy != nil {
+		service.PlacementStrategy = input.PlacementStrategy
+	}
+	if input.DeploymentConfiguration != nil {
+		service.DeploymentConfiguration = input.DeploymentConfiguration
+	}
+	if input.NetworkConfiguration != nil {
+		service.NetworkConfiguration = input.NetworkConfiguration
+	}
+	if input.EnableExecuteCommand != nil {
+		service.EnableExecuteCommand = input.EnableExecuteCommand
+	}
+
+	return &ecs.UpdateServiceOutput{Service: service}, nil
+}
+
+func (s *Server) deleteService(input *ecs.DeleteServiceInput) (*ecs.DeleteServiceOutput, error) {
+	service, err := s.service(input.Cluster, input.Service)
+	if err != nil {
+		return nil, err
+	}
+
+	if aws.Int64Value(service.DesiredCount) > 0 && !aws.BoolValue(input.Force) {
+		return nil, awserr.New(ecs.ErrCodeInvalidParameterException, "The service cannot be stopped while it is scaled above 0.", nil)
+	}
+
+	// Deleted services are described as INACTIVE, like they are by ECS
+	// for a while.
+	service.Status = aws.String("INACTIVE")
+	service.DesiredCount = aws.Int64(0)
+	service.RunningCount = aws.Int64(0)
+	return &ecs.DeleteServiceOutput{Service: service}, nil
+}
+
+// taskDefinition returns a task definition by ARN, "family:revision", or family
+// for the latest revision. s.mu must be held.
+func (s *Server) taskDefinition(taskDefinition *string) (*ecs.TaskDefinition, error) {
+	id := name(aws.StringValue(taskDefinition))
+	if !strings.Contains(id, ":") {
+		id = fmt.Sprintf("%s:%d", id, s.revisions[id])
+	}
+
+	t, ok := s.taskDefinitions[arn("task-definition/"+id)]
+	if !ok {
+		return nil, awserr.New(ecs.ErrCodeClientException, "Unable to describe task definition.", nil)
+	}
+	return t, nil
+}
+
+func (s *Server) registerTaskDefinition(input *ecs.RegisterTaskDefinitionInput) (*ecs.RegisterTaskDefinitionOutput, error) {
+	family := aws.StringValue(input.Family)
+	s.revisions[family]++
+	revision := s.revisions[family]
+
+	t := &ecs.TaskDefinition{
+		TaskDefinitionArn:       aws.String(arn(fmt.Sprintf("task-definition/%s:%d", family, revision))),
+		Family:                  input.Family,
+		Revision:                aws.Int64(revision),
+		Status:                  aws.String("ACTIVE"),
+		ContainerDefinitions:    input.ContainerDefinitions,
+		Cpu:                     input.Cpu,
+		Memory:                  input.Memory,
+		NetworkMode:             input.NetworkMode,
+		RequiresCompatibilities: input.RequiresCompatibilities,
+		ExecutionRoleArn:        input.ExecutionRoleArn,
+		TaskRoleArn:             input.TaskRoleArn,
+	}
+	s.taskDefinitions[*t.TaskDefinitionArn] = t
+
+	return &ecs.RegisterTaskDefinitionOutput{TaskDefinition: t}, nil
+}
+
+func (s *Server) describeTaskDefinition(input *ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error) {
+	t, err := s.taskDefinition(input.TaskDefinition)
+	if err != nil {
+		return nil, err
+	}
+	return &ecs.DescribeTaskDefinitionOutput{TaskDefinition: t}, nil
+}
+
+func (s *Server) describeClusters(input *ecs.DescribeClustersInput) (*ecs.DescribeClustersOutput, error) {
+	out := &ecs.DescribeClustersOutput{Clusters: []*ecs.Cluster{}}
+	for _, c := range input.Clusters {
+		out.Clusters = append(out.Clusters, &ecs.Cluster{
+			ClusterName: aws.String(cluster(c)),
+			ClusterArn:  aws.String(arn("cluster/" + cluster(c))),
+			Status:      aws.String("ACTIVE"),
+		})
+	}
+	return out, nil
+}
+
+// tasks returns the running tasks for the services in the cluster, which are
+// made up from the services' desired counts. s.mu must be held.
+func (s *Server) tasks(clusterName string) []*ecs.Task {
+	var names []string
+	for name := range s.services[clusterName] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var tasks []*ecs.Task
+	for _, name := range names {
+		service := s.services[clusterName][name]
+		for i := int64(0); i < aws.Int64Value(service.RunningCount); i++ {
+			tasks = append(tasks, &ecs.Task{
+				ClusterArn:        service.ClusterArn,
+				TaskArn:           aws.String(arn(fmt.Sprintf("task/%s/%s-%d", clusterName, name, i))),
+				TaskDefinitionArn: service.TaskDefinition,
+				Group:             aws.String("service:" + name),
+				StartedBy:         aws.String("ecs-svc/" + name),
+				LastStatus:        aws.String(ecs.DesiredStatusRunning),
+				DesiredStatus:     aws.String(ecs.DesiredStatusRunning),
+				LaunchType:        service.LaunchType,
+				StartedAt:         aws.Time(time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)),
+			})
+		}
+	}
+	return tasks
+}
+
+func (s *Server) listTasks(input *ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+	out := &ecs.ListTasksOutput{TaskArns: []*string{}}
+	if aws.StringValue(input.DesiredStatus) == ecs.DesiredStatusStopped {
+		return out, nil
+	}
+
+	for _, task := range s.tasks(cluster(input.Cluster)) {
+		if input.ServiceName != nil && aws.StringValue(task.Group) != "service:"+name(*input.ServiceName) {
+			continue
+		}
+		if input.Family != nil {
+			if t, ok := s.taskDefinitions[aws.StringValue(task.TaskDefinitionArn)]; !ok || aws.StringValue(t.Family) != *input.Family {
+				continue
+			}
+		}
+		out.TaskArns = append(out.TaskArns, task.TaskArn)
+	}
+	return out, nil
+}
+
+func (s *Server) describeTasks(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+	byARN := make(map[string]*ecs.Task)
+	for _, task := range s.tasks(cluster(input.Cluster)) {
+		byARN[aws.StringValue(task.TaskArn)] = task
+	}
+
+	out := &ecs.DescribeTasksOutput{Tasks: []*ecs.Task{}, Failures: []*ecs.Failure{}}
+	for _, taskARN := range input.Tasks {
+		task, ok := byARN[aws.StringValue(taskARN)]
+		if !ok {
+			out.Failures = append(out.Failures, &ecs.Failure{Arn: taskARN, Reason: aws.String("MISSING")})
+			continue
+		}
+		out.Tasks = append(out.Tasks, task)
+	}
+	return out, nil
+}
+
+// rule returns a rule by name. s.mu must be held.
+func (s *Server) rule(name *string) (*cloudwatchevents.Rule, error) {
+	rule, ok := s.rules[aws.StringValue(name)]
+	if !ok {
+		return nil, awserr.New(cloudwatchevents.ErrCodeResourceNotFoundException, fmt.Sprintf("Rule %s does not exist.", aws.StringValue(name)), nil)
+	}
+	return rule, nil
+}
+
+func (s *Server) putRule(input *cloudwatchevents.PutRuleInput) (*cloudwatchevents.PutRuleOutput, error) {
+	ruleARN := fmt.Sprintf("arn:aws:events:%s:%s:rule/%s", Region, Account, aws.StringValue(input.Name))
+
+	state := input.State
+	if state == nil {
+		state = aws.String(cloudwatchevents.RuleStateEnabled)
+	}
+
+	s.rules[aws.StringValue(input.Name)] = &cloudwatchevents.Rule{
+		Arn:                aws.String(ruleARN),
+		Name:               input.Name,
+		Description:        input.Description,
+		ScheduleExpression: input.ScheduleExpression,
+		State:              state,
+	}
+	return &cloudwatchevents.PutRuleOutput{RuleArn: aws.String(ruleARN)}, nil
+}
+
+func (s *Server) putTargets(input *cloudwatchevents.PutTargetsInput) (*cloudwatchevents.PutTargetsOutput, error) {
+	if _, err := s.rule(input.Rule); err != nil {
+		return nil, err
+	}
+
+	targets := s.targets[aws.StringValue(input.Rule)]
+	for _, target := range input.Targets {
+		replaced := false
+		for i, t := range targets {
+			if aws.StringValue(t.Id) == aws.StringValue(target.Id) {
+				targets[i], replaced = target, true
+			}
+		}
+		if !replaced {
+			targets = append(targets, target)
+		}
+	}
+	s.targets[aws.StringValue(input.Rule)] = targets
+
+	return &cloudwatchevents.PutTargetsOutput{FailedEntryCount: aws.Int64(0), FailedEntries: []*cloudwatchevents.PutTargetsResultEntry{}}, nil
+}
+
+func (s *Server) removeTargets(input *cloudwatchevents.RemoveTargetsInput) (*cloudwatchevents.RemoveTargetsOutput, error) {
+	if _, err := s.rule(input.Rule); err != nil {
+		return nil, err
+	}
+
+	remove := make(map[string]bool, len(input.Ids))
+	for _, id := range input.Ids {
+		remove[aws.StringValue(id)] = true
+	}
+
+	var targets []*cloudwatchevents.Target
+	for _, t := range s.targets[aws.StringValue(input.Rule)] {
+		if !remove[aws.StringValue(t.Id)] {
+			targets = append(targets, t)
+		}
+	}
+	s.targets[aws.StringValue(input.Rule)] = targets
+
+	return &cloudwatchevents.RemoveTargetsOutput{FailedEntryCount: aws.Int64(0), FailedEntries: []*cloudwatchevents.RemoveTargetsResultEntry{}}, nil
+}
+
+func (s *Server) deleteRule(input *cloudwatchevents.DeleteRuleInput) (*cloudwatchevents.DeleteRuleOutput, error) {
+	if len(s.targets[aws.StringValue(input.Name)]) > 0 {
+		return nil, awserr.New(cloudwatchevents.ErrCodeConcurrentModificationException, "Rule can't be deleted since it has targets.", nil)
+	}
+
+	delete(s.rules, aws.StringValue(input.Name))
+	delete(s.targets, aws.StringValue(input.Name))
+	return &cloudwatchevents.DeleteRuleOutput{}, nil
+}
+
+func (s *Server) listRules(input *cloudwatchevents.ListRulesInput) (*cloudwatchevents.ListRulesOutput, error) {
+	var names []string
+	for name := range s.rules {
+		if strings.HasPrefix(name, aws.StringValue(input.NamePrefix)) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	out := &cloudwatchevents.ListRulesOutput{Rules: []*cloudwatchevents.Rule{}}
+	for _, name := range names {
+		out.Rules = append(out.Rules, s.rules[name])
+	}
+	return out, nil
+}
+
+func (s *Server) describeRule(input *cloudwatchevents.DescribeRuleInput) (*cloudwatchevents.DescribeRuleOutput, error) {
+	rule, err := s.rule(input.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cloudwatchevents.DescribeRuleOutput{
+		Arn:                rule.Arn,
+		Name:               rule.Name,
+		Description:        rule.Description,
+		ScheduleExpression: rule.ScheduleExpression,
+		State:              rule.State,
+	}, nil
+}
+
+func (s *Server) listTargetsByRule(input *cloudwatchevents.ListTargetsByRuleInput) (*cloudwatchevents.ListTargetsByRuleOutput, error) {
+	if _, err := s.rule(input.Rule); err != nil {
+		return nil, err
+	}
+
+	targets := s.targets[aws.StringValue(input.Rule)]
+	if targets == nil {
+		targets = []*cloudwatchevents.Target{}
+	}
+	return &cloudwatchevents.ListTargetsByRuleOutput{Targets: targets}, nil
+}
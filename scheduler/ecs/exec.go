@@ -0,0 +1,279 @@
+package ecs
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/creack/pty"
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/pkg/aws/arn"
+)
+
+// sessionClient attaches to the SSM session that ECS Exec starts for a
+// command.
+type sessionClient interface {
+	// Attach attaches stdin and stdout to the session, blocking until it
+	// ends, and returns the exit code of the session. target is the SSM
+	// target for the container, in the form
+	// "ecs:<cluster>_<task id>_<runtime id>".
+	Attach(session *ecs.Session, target string, stdin io.Reader, stdout io.Writer, tty *twelvefactor.TTY) (int, error)
+}
+
+// newExitMarker returns the prefix of the line that reports the exit code of
+// an Exec'd command. It's random, so that it can't be confused with the
+// command's own output.
+var newExitMarker = func() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "12factor-exit-" + hex.EncodeToString(b) + "="
+}
+
+// Exec runs the command inside of the task's container using ECS Exec. The
+// service must have been created with execute command enabled, and the task
+// role must allow the SSM messages API.
+//
+// ECS Exec only supports interactive sessions, so tty is required. SSM doesn't
+// report the exit code of the command, so the command is run with sh, which
+// must be available in the container, and the exit code is read from a line
+// that's written after the command exits.
+func (s *Scheduler) Exec(taskID string, command []string, stdin io.Reader, stdout io.Writer, tty *twelvefactor.TTY) (int, error) {
+	if tty == nil {
+		return 0, twelvefactor.NewError(twelvefactor.ErrUnsupported, errors.New("ECS Exec only supports interactive sessions, which require a tty"))
+	}
+
+	resp, err := s.ecs.DescribeTasks(&ecs.DescribeTasksInput{
+		Cluster: aws.String(s.Cluster),
+		Tasks:   []*string{aws.String(taskID)},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(resp.Tasks) == 0 || len(resp.Tasks[0].Containers) == 0 {
+		return 0, &twelvefactor.TaskNotFoundError{Task: taskID}
+	}
+
+	task := resp.Tasks[0]
+	container := task.Containers[0]
+
+	cluster, err := arn.ResourceID(aws.StringValue(task.ClusterArn))
+	if err != nil {
+		return 0, err
+	}
+
+	marker := newExitMarker()
+	execResp, err := s.ecs.ExecuteCommand(&ecs.ExecuteCommandInput{
+		Cluster:     aws.String(s.Cluster),
+		Task:        aws.String(taskID),
+		Container:   container.Name,
+		Command:     aws.String(execCommand(command, marker)),
+		Interactive: aws.Bool(true),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	w := &exitCodeWriter{w: stdout, marker: []byte(marker)}
+	target := fmt.Sprintf("ecs:%s_%s_%s", cluster, taskID, aws.StringValue(container.RuntimeId))
+	code, err := s.session.Attach(execResp.Session, target, stdin, w, tty)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := w.Flush(); err != nil {
+		return 0, err
+	}
+
+	if w.code == nil {
+		return 0, fmt.Errorf("session ended with code %d without reporting the exit code of the command", code)
+	}
+	return *w.code, nil
+}
+
+// execCommand returns the command line that runs command with sh, and then
+// writes its exit code on a line that starts with marker.
+func execCommand(command []string, marker string) string {
+	args := make([]string, len(command))
+	for i, arg := range command {
+		args[i] = shellQuote(arg)
+	}
+
+	script := fmt.Sprintf("%s; echo %s$?", strings.Join(args, " "), shellQuote(marker))
+	return "sh -c " + shellQuote(script)
+}
+
+// shellQuote quotes s so that sh treats it as a single word.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// exitCodeWriter passes output through to w, except for the line that starts
+// with marker, which it parses the exit code from.
+type exitCodeWriter struct {
+	w      io.Writer
+	marker []byte
+
+	// buf holds output that hasn't been written yet, because it might be
+	// the start of the marker line.
+	buf []byte
+
+	code *int
+}
+
+// Write implements the io.Writer interface.
+func (e *exitCodeWriter) Write(p []byte) (int, error) {
+	e.buf = append(e.buf, p...)
+
+	for {
+		i := bytes.Index(e.buf, e.marker)
+		if i < 0 {
+			break
+		}
+
+		end := bytes.IndexByte(e.buf[i:], '\n')
+		if end < 0 {
+			// Wait for the rest of the line.
+			if _, err := e.w.Write(e.buf[:i]); err != nil {
+				return 0, err
+			}
+			e.buf = e.buf[i:]
+			return len(p), nil
+		}
+
+		line := bytes.TrimRight(e.buf[i+len(e.marker):i+end], "\r")
+		if code, err := strconv.Atoi(string(line)); err == nil {
+			e.code = &code
+		}
+
+		if _, err := e.w.Write(e.buf[:i]); err != nil {
+			return 0, err
+		}
+		e.buf = e.buf[i+end+1:]
+	}
+
+	// Hold back anything that could be the start of the marker.
+	n := len(e.buf)
+	for k := len(e.marker) - 1; k > 0; k-- {
+		if k <= len(e.buf) && bytes.HasSuffix(e.buf, e.marker[:k]) {
+			n = len(e.buf) - k
+			break
+		}
+	}
+
+	if _, err := e.w.Write(e.buf[:n]); err != nil {
+		return 0, err
+	}
+	e.buf = append(e.buf[:0], e.buf[n:]...)
+	return len(p), nil
+}
+
+// Flush writes any output that was held back.
+func (e *exitCodeWriter) Flush() error {
+	_, err := e.w.Write(e.buf)
+	e.buf = nil
+	return err
+}
+
+// pluginSession is a sessionClient that shells out to the AWS
+// session-manager-plugin, in the same way that the AWS CLI does.
+//
+// The plugin sizes the remote terminal from its own stdin, and resizes it when
+// it gets SIGWINCH, so it's run on a pseudo terminal that's resized whenever
+// the tty is.
+type pluginSession struct {
+	// Region that the session was started in.
+	Region string
+}
+
+// Attach implements the sessionClient interface.
+func (p *pluginSession) Attach(session *ecs.Session, target string, stdin io.Reader, stdout io.Writer, tty *twelvefactor.TTY) (int, error) {
+	sess, err := json.Marshal(session)
+	if err != nil {
+		return 0, err
+	}
+
+	params, err := json.Marshal(map[string]string{"Target": target})
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := exec.Command("session-manager-plugin",
+		string(sess),
+		p.Region,
+		"StartSession",
+		"",
+		string(params),
+		fmt.Sprintf("https://ssm.%s.amazonaws.com", p.Region),
+	)
+
+	var size *pty.Winsize
+	if tty != nil && tty.Size.Width > 0 && tty.Size.Height > 0 {
+		size = winsize(tty.Size)
+	}
+
+	f, err := pty.StartWithSize(cmd, size)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	// The pseudo terminal can only be closed once it's no longer being
+	// resized.
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	defer func() {
+		close(done)
+		wg.Wait()
+	}()
+	if tty != nil && tty.Resize != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case size, ok := <-tty.Resize:
+					if !ok {
+						return
+					}
+					pty.Setsize(f, winsize(size))
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	go io.Copy(f, stdin)
+
+	// Reading from the pseudo terminal fails once the plugin exits and
+	// there's nothing left to read.
+	io.Copy(stdout, f)
+
+	if err := cmd.Wait(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode(), nil
+		}
+		return 0, err
+	}
+
+	return 0, nil
+}
+
+// winsize converts size to the size of a pseudo terminal.
+func winsize(size twelvefactor.TerminalSize) *pty.Winsize {
+	return &pty.Winsize{
+		Rows: uint16(size.Height),
+		Cols: uint16(size.Width),
+	}
+}
@@ -0,0 +1,80 @@
+// Package ecserr translates errors from the ECS and CloudWatch Events APIs into
+// the errors defined by the twelvefactor package.
+package ecserr
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/remind101/12factor"
+)
+
+// kinds maps AWS error codes to the kind of twelvefactor error.
+var kinds = map[string]error{
+	ecs.ErrCodeServiceNotFoundException:  twelvefactor.ErrProcessNotFound,
+	ecs.ErrCodeServiceNotActiveException: twelvefactor.ErrProcessNotFound,
+
+	ecs.ErrCodeClusterNotFoundException:                       twelvefactor.ErrInvalidConfig,
+	ecs.ErrCodeInvalidParameterException:                      twelvefactor.ErrInvalidConfig,
+	ecs.ErrCodeClientException:                                twelvefactor.ErrInvalidConfig,
+	ecs.ErrCodePlatformTaskDefinitionIncompatibilityException: twelvefactor.ErrInvalidConfig,
+	cloudwatchevents.ErrCodeInvalidEventPatternException:      twelvefactor.ErrInvalidConfig,
+	"ValidationException":                                     twelvefactor.ErrInvalidConfig,
+
+	ecs.ErrCodeLimitExceededException:          twelvefactor.ErrCapacityExceeded,
+	ecs.ErrCodeAttributeLimitExceededException: twelvefactor.ErrCapacityExceeded,
+
+	ecs.ErrCodeResourceInUseException:                       twelvefactor.ErrConflict,
+	ecs.ErrCodeUpdateInProgressException:                    twelvefactor.ErrConflict,
+	ecs.ErrCodeConflictException:                            twelvefactor.ErrConflict,
+	cloudwatchevents.ErrCodeConcurrentModificationException: twelvefactor.ErrConflict,
+
+	ecs.ErrCodeUnsupportedFeatureException: twelvefactor.ErrUnsupported,
+	ecs.ErrCodePlatformUnknownException:    twelvefactor.ErrUnsupported,
+}
+
+// Translate classifies an AWS error as one of the twelvefactor errors, such as
+// twelvefactor.ErrInvalidConfig. Other errors are returned as is.
+func Translate(err error) error {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return err
+	}
+
+	if kind, ok := kinds[aerr.Code()]; ok {
+		return twelvefactor.NewError(kind, err)
+	}
+
+	return err
+}
+
+// IsServiceNotFound reports whether err means that an ECS service doesn't
+// exist, or has been deleted.
+func IsServiceNotFound(err error) bool {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return false
+	}
+
+	switch aerr.Code() {
+	case ecs.ErrCodeServiceNotFoundException, ecs.ErrCodeServiceNotActiveException:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsTaskNotFound reports whether err means that an ECS task doesn't exist. ECS
+// doesn't have a specific error code for this, so it's recognized by the
+// message of the InvalidParameterException that StopTask returns.
+func IsTaskNotFound(err error) bool {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return false
+	}
+
+	return aerr.Code() == ecs.ErrCodeInvalidParameterException && strings.Contains(aerr.Message(), "task was not found")
+}
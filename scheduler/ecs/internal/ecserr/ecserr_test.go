@@ -0,0 +1,39 @@
+package ecserr
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/remind101/12factor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind error
+	}{
+		{awserr.New("ServiceNotFoundException", "Service not found.", nil), twelvefactor.ErrProcessNotFound},
+		{awserr.New("ClusterNotFoundException", "Cluster not found.", nil), twelvefactor.ErrInvalidConfig},
+		{awserr.New("LimitExceededException", "Too many services.", nil), twelvefactor.ErrCapacityExceeded},
+		{awserr.New("UpdateInProgressException", "Update in progress.", nil), twelvefactor.ErrConflict},
+		{awserr.New("UnsupportedFeatureException", "Not supported.", nil), twelvefactor.ErrUnsupported},
+	}
+
+	for i, tt := range tests {
+		err := Translate(tt.err)
+		assert.True(t, errors.Is(err, tt.kind), "#%d", i)
+		assert.True(t, errors.Is(err, tt.err), "#%d", i)
+		assert.EqualError(t, err, tt.err.Error(), "#%d", i)
+	}
+
+	// Errors that can't be classified are returned as is.
+	for _, err := range []error{
+		awserr.New("AccessDeniedException", "Access denied.", nil),
+		errors.New("boom"),
+		nil,
+	} {
+		assert.Equal(t, err, Translate(err))
+	}
+}
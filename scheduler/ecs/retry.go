@@ -0,0 +1,57 @@
+package ecs
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/remind101/12factor/pkg/aws/retry"
+)
+
+// retryingECSClient is an ecsClient that retries calls that fail because of
+// throttling or transient server errors. ExecuteCommand isn't retried, since it
+// starts a new session each time, and neither is StopTask, since stopping a
+// task that's already stopping fails.
+type retryingECSClient struct {
+	ecsClient
+
+	// policy returns the policy to retry with. It's a func so that the
+	// Scheduler's RetryPolicy can be changed after the client is made.
+	policy func() *retry.Policy
+
+	// ctx is the context that calls are made with. The zero value is
+	// context.Background().
+	ctx context.Context
+}
+
+// do calls fn, retrying it according to the policy.
+func (c *retryingECSClient) do(fn func() error) error {
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return c.policy().Do(ctx, fn)
+}
+
+func (c *retryingECSClient) UpdateService(input *ecs.UpdateServiceInput) (resp *ecs.UpdateServiceOutput, err error) {
+	err = c.do(func() error {
+		resp, err = c.ecsClient.UpdateService(input)
+		return err
+	})
+	return
+}
+
+func (c *retryingECSClient) ListTasks(input *ecs.ListTasksInput) (resp *ecs.ListTasksOutput, err error) {
+	err = c.do(func() error {
+		resp, err = c.ecsClient.ListTasks(input)
+		return err
+	})
+	return
+}
+
+func (c *retryingECSClient) DescribeTasks(input *ecs.DescribeTasksInput) (resp *ecs.DescribeTasksOutput, err error) {
+	err = c.do(func() error {
+		resp, err = c.ecsClient.DescribeTasks(input)
+		return err
+	})
+	return
+}
@@ -14,3 +14,36 @@ type StackBuilder interface {
 	// Services returns a mapping of process name to ECS service name.
 	Services(app string) (map[string]string, error)
 }
+
+// ServiceFinder is implemented by StackBuilders that can find the service for a
+// single process more cheaply than listing all of the app's services. The
+// Scheduler uses it, when it's implemented, to find the service to update.
+type ServiceFinder interface {
+	// Service returns the name of the ECS service for the process, or a
+	// twelvefactor.ProcessNotFoundError if there isn't one.
+	Service(app, process string) (string, error)
+}
+
+// ScheduleLister is implemented by StackBuilders that can run processes on a
+// schedule. The Scheduler only lists scheduled tasks when the StackBuilder
+// implements it.
+type ScheduleLister interface {
+	// Schedules returns a mapping of process name to task definition
+	// family, for processes that run on a schedule.
+	Schedules(app string) (map[string]string, error)
+
+	// ScheduleExpressions returns a mapping of process name to the cron
+	// expression that the process runs on. Processes can be left out if
+	// their expression isn't known.
+	ScheduleExpressions(app string) (map[string]string, error)
+}
+
+// TaskRunner is implemented by StackBuilders that can run one off tasks for an
+// app. The Scheduler only supports RunProcess when the StackBuilder implements
+// it.
+type TaskRunner interface {
+	// RunTask starts a one off task for the process, with the
+	// configuration that the app was last deployed with, and returns the
+	// ID of the task.
+	RunTask(app string, process twelvefactor.Process) (string, error)
+}
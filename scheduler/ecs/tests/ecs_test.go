@@ -27,7 +27,7 @@ var processes = []twelvefactor.Process{
 		Name:      "web",
 		Command:   []string{"acme-inc", "web"},
 		CPUShares: 256,
-		Memory:    10 * int(bytesize.MB),
+		Memory:    10 * bytesize.MiB,
 	},
 }
 
@@ -63,6 +63,6 @@ func newScheduler(t testing.TB) *ecs.Scheduler {
 		t.Skip("Skipping ECS test because AWS_ environment variables are not present.")
 	}
 
-	config := defaults.DefaultConfig.WithCredentials(credentials.NewCredentials(creds))
+	config := defaults.Config().WithCredentials(credentials.NewCredentials(creds))
 	return ecs.NewScheduler(config)
 }
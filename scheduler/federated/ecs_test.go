@@ -0,0 +1,70 @@
+package federated_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awsecs "github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/scheduler/ecs"
+	"github.com/remind101/12factor/scheduler/ecs/builders/raw"
+	"github.com/remind101/12factor/scheduler/ecs/ecstest"
+	"github.com/remind101/12factor/scheduler/federated"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler_ECS(t *testing.T) {
+	eastSrv, westSrv := ecstest.NewServer(), ecstest.NewServer()
+	defer eastSrv.Close()
+	defer westSrv.Close()
+
+	newScheduler := func(srv *ecstest.Server, cluster string) *ecs.Scheduler {
+		b := raw.NewStackBuilder(srv.Config())
+		b.Cluster = cluster
+		s := ecs.NewSchedulerWithStackBuilder(srv.Config(), b)
+		s.Cluster = cluster
+		return s
+	}
+	s := federated.NewScheduler(
+		federated.Target{Name: "us-east-1", Scheduler: newScheduler(eastSrv, "east")},
+		federated.Target{Name: "us-west-2", Scheduler: newScheduler(westSrv, "west"), Scale: map[string]int{"web": 1}},
+	)
+
+	desiredCount := func(srv *ecstest.Server, cluster string) int64 {
+		t.Helper()
+
+		resp, err := awsecs.New(session.New(srv.Config())).DescribeServices(&awsecs.DescribeServicesInput{
+			Cluster:  aws.String(cluster),
+			Services: []*string{aws.String("app--web")},
+		})
+		assert.NoError(t, err)
+		if len(resp.Services) == 0 {
+			return -1
+		}
+		return aws.Int64Value(resp.Services[0].DesiredCount)
+	}
+
+	app := twelvefactor.App{ID: "app", Image: "acme-inc:v1"}
+	process := twelvefactor.Process{Name: "web", DesiredCount: 2}
+	assert.NoError(t, s.Run(app, process))
+	assert.Equal(t, int64(2), desiredCount(eastSrv, "east"))
+	assert.Equal(t, int64(1), desiredCount(westSrv, "west"))
+
+	tasks, err := s.Tasks("app")
+	assert.NoError(t, err)
+	var locations []string
+	for _, task := range tasks {
+		locations = append(locations, task.Location)
+	}
+	assert.Equal(t, []string{"us-east-1", "us-east-1", "us-west-2"}, locations)
+
+	plan, err := s.Plan(app, process)
+	assert.NoError(t, err)
+	assert.True(t, plan.Empty(), "plan: %v", plan)
+
+	// Scale overrides only apply to Run.
+	assert.NoError(t, s.ScaleProcess("app", "web", 0))
+	assert.Equal(t, int64(0), desiredCount(eastSrv, "east"))
+	assert.Equal(t, int64(0), desiredCount(westSrv, "west"))
+}
@@ -0,0 +1,319 @@
+// Package federated provides a Scheduler that runs apps on several child
+// schedulers at once, such as ECS clusters in different regions:
+//
+//	s := federated.NewScheduler(
+//		federated.Target{
+//			Name:      "us-east-1",
+//			Scheduler: ecs.NewScheduler(aws.NewConfig().WithRegion("us-east-1")),
+//		},
+//		federated.Target{
+//			Name:      "us-west-2",
+//			Scheduler: ecs.NewScheduler(aws.NewConfig().WithRegion("us-west-2")),
+//			Env:       map[string]string{"REGION": "us-west-2"},
+//			Scale:     map[string]int{"web": 1},
+//		},
+//	)
+//
+// Calls are made to every target concurrently. When some targets fail, the
+// others aren't rolled back, and an *Error reports which targets failed.
+package federated
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/remind101/12factor"
+)
+
+// Target is a child scheduler that apps are run on.
+type Target struct {
+	// Name identifies the target, such as "us-east-1". Tasks from the
+	// target have it as their Location.
+	Name string
+
+	// The Scheduler that runs apps on the target.
+	Scheduler twelvefactor.Scheduler
+
+	// Env is merged into the environment of apps that are run on the
+	// target, overriding any variables with the same name.
+	Env map[string]string
+
+	// Scale overrides the desired count of processes that are run on the
+	// target, by process name. It only applies to Run, so ScaleProcess
+	// still scales the process on every target, including to 0.
+	Scale map[string]int
+}
+
+// TargetError is the error from a single target.
+type TargetError struct {
+	Target string
+	Err    error
+}
+
+// Error implements the error interface.
+func (e *TargetError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Target, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *TargetError) Unwrap() error {
+	return e.Err
+}
+
+// Error is returned when a call fails on one or more targets.
+type Error struct {
+	// The errors from the targets that failed, in the order that the
+	// targets were given.
+	Errors []*TargetError
+
+	// The names of the targets that succeeded.
+	Succeeded []string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	total := len(e.Errors) + len(e.Succeeded)
+	return fmt.Sprintf("failed on %d of %d targets: %s", len(e.Errors), total, strings.Join(msgs, "; "))
+}
+
+// Is reports whether any of the target errors match target, so that errors
+// like twelvefactor.ErrAppNotFound can still be checked for with errors.Is.
+func (e *Error) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Scheduler is a twelvefactor.Scheduler that fans calls out to its Targets.
+type Scheduler struct {
+	Targets []Target
+}
+
+// NewScheduler returns a Scheduler for the targets.
+func NewScheduler(targets ...Target) *Scheduler {
+	return &Scheduler{Targets: targets}
+}
+
+// Run runs the app on every target, with the target's overrides applied.
+func (s *Scheduler) Run(app twelvefactor.App, processes ...twelvefactor.Process) error {
+	return s.each(func(_ int, t Target) error {
+		a, ps := t.override(app, processes)
+		return t.Scheduler.Run(a, ps...)
+	})
+}
+
+// Remove removes the app from every target. Targets that don't have the app
+// are ignored.
+func (s *Scheduler) Remove(app string) error {
+	return s.each(func(_ int, t Target) error {
+		if err := t.Scheduler.Remove(app); err != nil && !errors.Is(err, twelvefactor.ErrAppNotFound) {
+			return err
+		}
+		return nil
+	})
+}
+
+// ScaleProcess scales the process on every target.
+func (s *Scheduler) ScaleProcess(app, process string, desired int) error {
+	return s.each(func(_ int, t Target) error {
+		return t.Scheduler.ScaleProcess(app, process, desired)
+	})
+}
+
+// Restart restarts the app on every target.
+func (s *Scheduler) Restart(app string) error {
+	return s.each(func(_ int, t Target) error {
+		return t.Scheduler.Restart(app)
+	})
+}
+
+// RestartProcess restarts the process on every target.
+func (s *Scheduler) RestartProcess(app, process string) error {
+	return s.each(func(_ int, t Target) error {
+		return t.Scheduler.RestartProcess(app, process)
+	})
+}
+
+// Tasks returns the tasks for the app from every target, in the order that the
+// targets were given, with their Location set to the name of the target.
+//
+// When some targets fail, the tasks from the others are returned along with an
+// *Error. Targets that don't have the app are ignored, unless none of them do.
+func (s *Scheduler) Tasks(app string) ([]twelvefactor.Task, error) {
+	results := make([][]twelvefactor.Task, len(s.Targets))
+	notFound := make([]bool, len(s.Targets))
+
+	err := s.each(func(i int, t Target) error {
+		tasks, err := t.Scheduler.Tasks(app)
+		if errors.Is(err, twelvefactor.ErrAppNotFound) {
+			notFound[i] = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for j := range tasks {
+			tasks[j].Location = t.Name
+		}
+		results[i] = tasks
+		return nil
+	})
+
+	if allTrue(notFound) {
+		return nil, &twelvefactor.AppNotFoundError{App: app}
+	}
+
+	var tasks []twelvefactor.Task
+	for _, r := range results {
+		tasks = append(tasks, r...)
+	}
+	return tasks, err
+}
+
+// Plan implements the twelvefactor.Planner interface by planning the app on
+// every target, with the target's overrides applied. The changes for each
+// target have their Location set to its name, and are in the order that the
+// targets were given. Every target must support planning.
+func (s *Scheduler) Plan(app twelvefactor.App, processes ...twelvefactor.Process) (*twelvefactor.Plan, error) {
+	plans := make([]*twelvefactor.Plan, len(s.Targets))
+	err := s.each(func(i int, t Target) error {
+		p, ok := t.Scheduler.(twelvefactor.Planner)
+		if !ok || !twelvefactor.Supports(t.Scheduler, twelvefactor.CapabilityPlan) {
+			return twelvefactor.NewError(twelvefactor.ErrUnsupported, fmt.Errorf("%T does not support planning", t.Scheduler))
+		}
+
+		a, ps := t.override(app, processes)
+		plan, err := p.Plan(a, ps...)
+		plans[i] = plan
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &twelvefactor.Plan{App: app.ID, Changes: []twelvefactor.ProcessChange{}}
+	for i, p := range plans {
+		for _, c := range p.Changes {
+			c.Location = s.Targets[i].Name
+			plan.Changes = append(plan.Changes, c)
+		}
+	}
+	return plan, nil
+}
+
+// federatedCapabilities are the capabilities that the Scheduler can pass
+// through to its targets. The rest need methods that it doesn't have.
+var federatedCapabilities = []twelvefactor.Capability{
+	twelvefactor.CapabilityAutoscaling,
+	twelvefactor.CapabilityHealthChecks,
+	twelvefactor.CapabilityPlacement,
+	twelvefactor.CapabilityPlan,
+	twelvefactor.CapabilitySchedules,
+}
+
+// Capabilities implements the twelvefactor.CapabilityProvider interface. A
+// capability is only supported if every target supports it.
+func (s *Scheduler) Capabilities() []twelvefactor.Capability {
+	if len(s.Targets) == 0 {
+		return nil
+	}
+
+	var capabilities []twelvefactor.Capability
+	for _, c := range federatedCapabilities {
+		supported := true
+		for _, t := range s.Targets {
+			if !twelvefactor.Supports(t.Scheduler, c) {
+				supported = false
+				break
+			}
+		}
+		if supported {
+			capabilities = append(capabilities, c)
+		}
+	}
+	return capabilities
+}
+
+// StopTask stops the task on whichever target it belongs to. Each target is
+// asked to stop it in turn, until one doesn't return a
+// twelvefactor.ErrTaskNotFound.
+func (s *Scheduler) StopTask(taskID string) error {
+	for _, t := range s.Targets {
+		if err := t.Scheduler.StopTask(taskID); !errors.Is(err, twelvefactor.ErrTaskNotFound) {
+			return err
+		}
+	}
+	return &twelvefactor.TaskNotFoundError{Task: taskID}
+}
+
+// each calls fn for every target concurrently, collecting the errors into an
+// *Error.
+func (s *Scheduler) each(fn func(i int, t Target) error) error {
+	errs := make([]error, len(s.Targets))
+
+	var wg sync.WaitGroup
+	for i, t := range s.Targets {
+		wg.Add(1)
+		go func(i int, t Target) {
+			defer wg.Done()
+			errs[i] = fn(i, t)
+		}(i, t)
+	}
+	wg.Wait()
+
+	e := new(Error)
+	for i, err := range errs {
+		if err != nil {
+			e.Errors = append(e.Errors, &TargetError{Target: s.Targets[i].Name, Err: err})
+		} else {
+			e.Succeeded = append(e.Succeeded, s.Targets[i].Name)
+		}
+	}
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// override returns copies of the app and processes with the target's
+// overrides applied.
+func (t Target) override(app twelvefactor.App, processes []twelvefactor.Process) (twelvefactor.App, []twelvefactor.Process) {
+	if len(t.Env) > 0 {
+		env := make(map[string]string, len(app.Env)+len(t.Env))
+		for k, v := range app.Env {
+			env[k] = v
+		}
+		for k, v := range t.Env {
+			env[k] = v
+		}
+		app.Env = env
+	}
+
+	ps := make([]twelvefactor.Process, len(processes))
+	copy(ps, processes)
+	for i := range ps {
+		if n, ok := t.Scale[ps[i].Name]; ok {
+			ps[i].DesiredCount = n
+		}
+	}
+	return app, ps
+}
+
+func allTrue(bs []bool) bool {
+	for _, b := range bs {
+		if !b {
+			return false
+		}
+	}
+	return len(bs) > 0
+}
@@ -0,0 +1,204 @@
+package federated
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/scheduler/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testApp       = twelvefactor.App{ID: "acme", Image: "acme-inc:v1", Env: map[string]string{"RAILS_ENV": "production"}}
+	testProcesses = []twelvefactor.Process{{Name: "web", DesiredCount: 2}, {Name: "worker", DesiredCount: 1}}
+)
+
+func TestScheduler_Run(t *testing.T) {
+	east, west := memory.NewScheduler(), memory.NewScheduler()
+	s := NewScheduler(
+		Target{Name: "us-east-1", Scheduler: east},
+		Target{Name: "us-west-2", Scheduler: west, Env: map[string]string{"REGION": "us-west-2"}, Scale: map[string]int{"web": 1}},
+	)
+
+	assert.NoError(t, s.Run(testApp, testProcesses...))
+
+	plan, err := east.Plan(testApp, testProcesses...)
+	assert.NoError(t, err)
+	assert.True(t, plan.Empty())
+
+	plan, err = west.Plan(
+		twelvefactor.App{ID: "acme", Image: "acme-inc:v1", Env: map[string]string{"RAILS_ENV": "production", "REGION": "us-west-2"}},
+		twelvefactor.Process{Name: "web", DesiredCount: 1},
+		twelvefactor.Process{Name: "worker", DesiredCount: 1},
+	)
+	assert.NoError(t, err)
+	assert.True(t, plan.Empty())
+
+	// The overrides don't leak into the app or processes.
+	assert.Equal(t, map[string]string{"RAILS_ENV": "production"}, testApp.Env)
+	assert.Equal(t, 2, testProcesses[0].DesiredCount)
+}
+
+func TestScheduler_ScaleProcess(t *testing.T) {
+	east, west := memory.NewScheduler(), memory.NewScheduler()
+	s := NewScheduler(
+		Target{Name: "us-east-1", Scheduler: east},
+		Target{Name: "us-west-2", Scheduler: west, Scale: map[string]int{"web": 1}},
+	)
+	assert.NoError(t, s.Run(testApp, testProcesses...))
+
+	assert.Equal(t, 2, count(t, east, "web"))
+	assert.Equal(t, 1, count(t, west, "web"))
+
+	// Overrides only apply to Run, so processes can still be scaled
+	// everywhere, including down to 0.
+	assert.NoError(t, s.ScaleProcess("acme", "web", 3))
+	assert.Equal(t, 3, count(t, east, "web"))
+	assert.Equal(t, 3, count(t, west, "web"))
+
+	assert.NoError(t, s.ScaleProcess("acme", "web", 0))
+	assert.Equal(t, 0, count(t, east, "web"))
+	assert.Equal(t, 0, count(t, west, "web"))
+}
+
+func TestScheduler_PartialFailure(t *testing.T) {
+	east := memory.NewScheduler()
+	s := NewScheduler(
+		Target{Name: "us-east-1", Scheduler: east},
+		Target{Name: "us-west-2", Scheduler: &failing{memory.NewScheduler()}},
+	)
+
+	err := s.Run(testApp, testProcesses...)
+	assert.EqualError(t, err, "failed on 1 of 2 targets: us-west-2: boom")
+
+	var e *Error
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, []string{"us-east-1"}, e.Succeeded)
+	assert.Equal(t, "us-west-2", e.Errors[0].Target)
+
+	// The targets that succeeded aren't rolled back.
+	assert.Equal(t, 2, count(t, east, "web"))
+}
+
+func TestScheduler_Tasks(t *testing.T) {
+	east, west := memory.NewScheduler(), memory.NewScheduler()
+	s := NewScheduler(
+		Target{Name: "us-east-1", Scheduler: east},
+		Target{Name: "us-west-2", Scheduler: west},
+	)
+	assert.NoError(t, s.Run(testApp, twelvefactor.Process{Name: "web", DesiredCount: 1}))
+
+	tasks, err := s.Tasks("acme")
+	assert.NoError(t, err)
+	assert.Len(t, tasks, 2)
+	assert.Equal(t, "us-east-1", tasks[0].Location)
+	assert.Equal(t, "us-west-2", tasks[1].Location)
+
+	// Tasks can be stopped on whichever target they're on.
+	assert.NoError(t, s.StopTask(tasks[1].ID))
+	assert.True(t, errors.Is(s.StopTask("unknown"), twelvefactor.ErrTaskNotFound))
+
+	// Tasks from the targets that succeed are still returned.
+	s.Targets[1].Scheduler = &failing{west}
+	tasks, err = s.Tasks("acme")
+	assert.EqualError(t, err, "failed on 1 of 2 targets: us-west-2: boom")
+	assert.Len(t, tasks, 1)
+	assert.Equal(t, "us-east-1", tasks[0].Location)
+}
+
+func TestScheduler_Remove(t *testing.T) {
+	east, west := memory.NewScheduler(), memory.NewScheduler()
+	s := NewScheduler(
+		Target{Name: "us-east-1", Scheduler: east},
+		Target{Name: "us-west-2", Scheduler: &notFound{west}},
+	)
+	assert.NoError(t, east.Run(testApp, testProcesses...))
+
+	// Targets that don't have the app are fine.
+	assert.NoError(t, s.Remove("acme"))
+	_, err := east.Tasks("acme")
+	assert.True(t, errors.Is(err, twelvefactor.ErrAppNotFound))
+}
+
+func TestScheduler_Plan(t *testing.T) {
+	east, west := memory.NewScheduler(), memory.NewScheduler()
+	s := NewScheduler(
+		Target{Name: "us-east-1", Scheduler: east},
+		Target{Name: "us-west-2", Scheduler: west, Scale: map[string]int{"web": 1}},
+	)
+	assert.NoError(t, s.Run(testApp, testProcesses...))
+
+	// Each target is compared with its overrides applied.
+	plan, err := s.Plan(testApp, testProcesses...)
+	assert.NoError(t, err)
+	assert.True(t, plan.Empty())
+
+	assert.NoError(t, west.ScaleProcess("acme", "web", 3))
+	plan, err = s.Plan(testApp, testProcesses...)
+	assert.NoError(t, err)
+	assert.Equal(t, &twelvefactor.Plan{App: "acme", Changes: []twelvefactor.ProcessChange{
+		{
+			Process:  "web",
+			Action:   twelvefactor.ChangeUpdate,
+			Location: "us-west-2",
+			Scale:    &twelvefactor.ScaleChange{From: 3, To: 1},
+		},
+	}}, plan)
+
+	// Every target needs to support planning.
+	s.Targets[1].Scheduler = &failing{west}
+	_, err = s.Plan(testApp, testProcesses...)
+	assert.True(t, errors.Is(err, twelvefactor.ErrUnsupported))
+	assert.False(t, twelvefactor.Supports(s, twelvefactor.CapabilityPlan))
+}
+
+func TestScheduler_Capabilities(t *testing.T) {
+	s := NewScheduler(
+		Target{Name: "us-east-1", Scheduler: memory.NewScheduler()},
+		Target{Name: "us-west-2", Scheduler: memory.NewScheduler()},
+	)
+	assert.Equal(t, []twelvefactor.Capability{twelvefactor.CapabilityPlan}, twelvefactor.Capabilities(s))
+
+	assert.Nil(t, twelvefactor.Capabilities(NewScheduler()))
+}
+
+// count returns the number of tasks for the process.
+func count(t testing.TB, s twelvefactor.Scheduler, process string) int {
+	t.Helper()
+
+	tasks, err := s.Tasks("acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	for _, task := range tasks {
+		if task.Process == process {
+			n++
+		}
+	}
+	return n
+}
+
+// failing is a scheduler that fails to run apps and list tasks.
+type failing struct {
+	twelvefactor.Scheduler
+}
+
+func (s *failing) Run(app twelvefactor.App, processes ...twelvefactor.Process) error {
+	return errors.New("boom")
+}
+
+func (s *failing) Tasks(app string) ([]twelvefactor.Task, error) {
+	return nil, errors.New("boom")
+}
+
+// notFound is a scheduler that doesn't know about any apps.
+type notFound struct {
+	twelvefactor.Scheduler
+}
+
+func (s *notFound) Remove(app string) error {
+	return &twelvefactor.AppNotFoundError{App: app}
+}
@@ -0,0 +1,156 @@
+package lock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// DefaultTTL is the default amount of time that a DynamoDBLocker lock is held
+// for before it's considered abandoned.
+const DefaultTTL = 15 * time.Minute
+
+// dynamodbPollInterval is how often a held DynamoDB lock is retried.
+var dynamodbPollInterval = time.Second
+
+type dynamodbClient interface {
+	PutItem(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	DeleteItem(*dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error)
+}
+
+// DynamoDBLocker is a Locker that uses conditional writes to a DynamoDB table,
+// which serializes operations between hosts. The table should have a string
+// hash key named "LockID".
+type DynamoDBLocker struct {
+	// The name of the DynamoDB table.
+	Table string
+
+	// TTL is how long a lock is held before it's considered abandoned, in
+	// case the process holding it dies. It should be longer than any
+	// operation takes. The zero value is DefaultTTL.
+	TTL time.Duration
+
+	db dynamodbClient
+
+	// now returns the current time. The zero value is time.Now.
+	now func() time.Time
+}
+
+// NewDynamoDBLocker returns a new DynamoDBLocker using the table, with a
+// DynamoDB client configured from config.
+func NewDynamoDBLocker(config *aws.Config, table string) *DynamoDBLocker {
+	return &DynamoDBLocker{
+		Table: table,
+		db:    dynamodb.New(session.New(config)),
+	}
+}
+
+// Lock implements the Locker interface.
+func (l *DynamoDBLocker) Lock(key string, timeout time.Duration) (Lock, error) {
+	owner, err := newOwner()
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := l.clock().Add(timeout)
+	for {
+		err := l.acquire(key, owner)
+		if err == nil {
+			break
+		}
+
+		if !isConditionalCheckFailed(err) {
+			return nil, err
+		}
+
+		if !l.clock().Before(deadline) {
+			return nil, ErrTimeout
+		}
+
+		time.Sleep(dynamodbPollInterval)
+	}
+
+	return unlockFunc(func() error {
+		return l.release(key, owner)
+	}), nil
+}
+
+// acquire writes the lock item, as long as the lock isn't held or it has
+// expired.
+func (l *DynamoDBLocker) acquire(key, owner string) error {
+	now := l.clock()
+
+	ttl := l.TTL
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+
+	_, err := l.db.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(l.Table),
+		Item: map[string]*dynamodb.AttributeValue{
+			"LockID":  {S: aws.String(key)},
+			"Owner":   {S: aws.String(owner)},
+			"Expires": {N: aws.String(strconv.FormatInt(now.Add(ttl).Unix(), 10))},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#id) OR #expires < :now"),
+		ExpressionAttributeNames: map[string]*string{
+			"#id":      aws.String("LockID"),
+			"#expires": aws.String("Expires"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+		},
+	})
+	return err
+}
+
+// release deletes the lock item, as long as it's still held by owner. If the
+// lock expired and was acquired by someone else, it's left alone.
+func (l *DynamoDBLocker) release(key, owner string) error {
+	_, err := l.db.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(l.Table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"LockID": {S: aws.String(key)},
+		},
+		ConditionExpression: aws.String("#owner = :owner"),
+		ExpressionAttributeNames: map[string]*string{
+			"#owner": aws.String("Owner"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner": {S: aws.String(owner)},
+		},
+	})
+	if isConditionalCheckFailed(err) {
+		return nil
+	}
+	return err
+}
+
+func (l *DynamoDBLocker) clock() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+	return time.Now()
+}
+
+// newOwner returns a random identifier for the holder of a lock.
+func newOwner() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func isConditionalCheckFailed(err error) bool {
+	if err, ok := err.(awserr.Error); ok {
+		return err.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+	}
+	return false
+}
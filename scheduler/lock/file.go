@@ -0,0 +1,23 @@
+package lock
+
+import (
+	"net/url"
+	"path/filepath"
+	"time"
+)
+
+// filePollInterval is how often a held file lock is retried.
+var filePollInterval = 100 * time.Millisecond
+
+// FileLocker is a Locker that uses advisory file locks (flock) on files in Dir,
+// which serializes operations between processes on the same host. It's only
+// supported on unix systems.
+type FileLocker struct {
+	// The directory to create lock files in.
+	Dir string
+}
+
+// path returns the path of the lock file for key.
+func (l *FileLocker) path(key string) string {
+	return filepath.Join(l.Dir, url.PathEscape(key)+".lock")
+}
@@ -0,0 +1,14 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package lock
+
+import (
+	"errors"
+	"time"
+)
+
+// Lock implements the Locker interface.
+func (l *FileLocker) Lock(key string, timeout time.Duration) (Lock, error) {
+	return nil, errors.New("file locks are not supported on this platform")
+}
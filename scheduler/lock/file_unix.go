@@ -0,0 +1,41 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package lock
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// Lock implements the Locker interface.
+func (l *FileLocker) Lock(key string, timeout time.Duration) (Lock, error) {
+	f, err := os.OpenFile(l.path(key), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+
+		if err != syscall.EWOULDBLOCK || !time.Now().Before(deadline) {
+			f.Close()
+			if err == syscall.EWOULDBLOCK {
+				return nil, ErrTimeout
+			}
+			return nil, err
+		}
+
+		time.Sleep(filePollInterval)
+	}
+
+	return unlockFunc(func() error {
+		// Closing the file releases the lock.
+		return f.Close()
+	}), nil
+}
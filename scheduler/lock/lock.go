@@ -0,0 +1,88 @@
+// Package lock provides a scheduler middleware that serializes operations on
+// the same app, so that concurrent deploys and scales can't race with each
+// other. Locks are acquired from a pluggable Locker, which can be in process
+// (NewMemoryLocker), on the local filesystem (FileLocker), or shared between
+// hosts (DynamoDBLocker).
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/scheduler/middleware"
+)
+
+// ErrTimeout is returned by a Locker when the lock couldn't be acquired before
+// the timeout.
+var ErrTimeout = errors.New("timed out waiting for lock")
+
+// Locker acquires exclusive locks by key.
+type Locker interface {
+	// Lock acquires the lock for key, waiting up to timeout for it to be
+	// released if it's held. A timeout of 0 doesn't wait at all. ErrTimeout
+	// is returned if the lock isn't acquired in time.
+	Lock(key string, timeout time.Duration) (Lock, error)
+}
+
+// Lock is a held lock.
+type Lock interface {
+	// Unlock releases the lock.
+	Unlock() error
+}
+
+// unlockFunc is a function that implements the Lock interface.
+type unlockFunc func() error
+
+func (fn unlockFunc) Unlock() error {
+	return fn()
+}
+
+// AppBusyError is returned when an operation can't be performed because
+// another operation on the app is in progress.
+type AppBusyError struct {
+	App string
+}
+
+// Error implements the error interface.
+func (e *AppBusyError) Error() string {
+	return fmt.Sprintf("%s is busy with another operation, try again later", e.App)
+}
+
+// Is reports whether target is twelvefactor.ErrConflict.
+func (e *AppBusyError) Is(target error) bool {
+	return target == twelvefactor.ErrConflict
+}
+
+// methods are the Scheduler methods that change an app, which are serialized.
+// Reads, one off processes and stopping a task don't need the lock.
+var methods = map[string]bool{
+	"Run":            true,
+	"Remove":         true,
+	"ScaleProcess":   true,
+	"Restart":        true,
+	"RestartProcess": true,
+}
+
+// Middleware returns a scheduler middleware that holds the app's lock during
+// every operation that changes the app. If the lock isn't acquired within
+// timeout, an AppBusyError is returned.
+func Middleware(l Locker, timeout time.Duration) middleware.Middleware {
+	return middleware.Func(func(call middleware.Call, next func() error) error {
+		if !methods[call.Method] {
+			return next()
+		}
+
+		lock, err := l.Lock(call.App, timeout)
+		if err == ErrTimeout {
+			return &AppBusyError{App: call.App}
+		}
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+
+		return next()
+	})
+}
@@ -0,0 +1,207 @@
+package lock
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/scheduler/memory"
+	"github.com/remind101/12factor/scheduler/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMiddleware(t *testing.T) {
+	l := NewMemoryLocker()
+	s := middleware.Chain(memory.NewScheduler(), Middleware(l, 0))
+
+	assert.NoError(t, s.Run(twelvefactor.App{ID: "acme"}, twelvefactor.Process{Name: "web"}))
+
+	// While the app is locked, changes fail, but reads and other apps
+	// still work.
+	lock, err := l.Lock("acme", 0)
+	assert.NoError(t, err)
+
+	assert.Equal(t, &AppBusyError{App: "acme"}, s.ScaleProcess("acme", "web", 2))
+	assert.EqualError(t, s.Restart("acme"), "acme is busy with another operation, try again later")
+	_, err = s.Tasks("acme")
+	assert.NoError(t, err)
+	assert.NoError(t, s.Run(twelvefactor.App{ID: "other"}))
+
+	lock.Unlock()
+	assert.NoError(t, s.ScaleProcess("acme", "web", 2))
+}
+
+func TestMiddleware_Serializes(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		running int
+		max     int
+	)
+
+	slow := middleware.Func(func(call middleware.Call, next func() error) error {
+		mu.Lock()
+		running++
+		if running > max {
+			max = running
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return next()
+	})
+
+	s := middleware.Chain(memory.NewScheduler(), Middleware(NewMemoryLocker(), time.Second), slow)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Run(twelvefactor.App{ID: "acme"})
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, max)
+}
+
+func TestMemoryLocker_Timeout(t *testing.T) {
+	l := NewMemoryLocker()
+
+	lock, err := l.Lock("acme", 0)
+	assert.NoError(t, err)
+
+	_, err = l.Lock("acme", 10*time.Millisecond)
+	assert.Equal(t, ErrTimeout, err)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		lock.Unlock()
+	}()
+
+	lock, err = l.Lock("acme", time.Second)
+	assert.NoError(t, err)
+	lock.Unlock()
+}
+
+func TestMemoryLocker_Unlock(t *testing.T) {
+	l := NewMemoryLocker()
+
+	lock, err := l.Lock("acme", 0)
+	assert.NoError(t, err)
+
+	// Unlocking twice doesn't block, or release a lock that's held by
+	// someone else.
+	assert.NoError(t, lock.Unlock())
+	other, err := l.Lock("acme", 0)
+	assert.NoError(t, err)
+	assert.NoError(t, lock.Unlock())
+	_, err = l.Lock("acme", 0)
+	assert.Equal(t, ErrTimeout, err)
+
+	// Locks are forgotten once nothing holds or waits for them.
+	assert.NoError(t, other.Unlock())
+	assert.Empty(t, l.locks)
+}
+
+func TestFileLocker(t *testing.T) {
+	l := &FileLocker{Dir: t.TempDir()}
+
+	lock, err := l.Lock("acme", 0)
+	assert.NoError(t, err)
+
+	// flock locks are per open file, so a second lock on the same file
+	// is held, even within the same process.
+	_, err = l.Lock("acme", 0)
+	assert.Equal(t, ErrTimeout, err)
+
+	other, err := l.Lock("other", 0)
+	assert.NoError(t, err)
+	other.Unlock()
+
+	assert.NoError(t, lock.Unlock())
+
+	lock, err = l.Lock("acme", 0)
+	assert.NoError(t, err)
+	lock.Unlock()
+}
+
+func TestDynamoDBLocker(t *testing.T) {
+	db := new(mockDynamoDBClient)
+	l := &DynamoDBLocker{
+		Table: "locks",
+		db:    db,
+		now:   func() time.Time { return time.Unix(1000, 0) },
+	}
+
+	db.On("PutItem", mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return *input.TableName == "locks" &&
+			*input.Item["LockID"].S == "acme" &&
+			*input.Item["Expires"].N == "1900" &&
+			*input.ConditionExpression == "attribute_not_exists(#id) OR #expires < :now" &&
+			*input.ExpressionAttributeValues[":now"].N == "1000"
+	})).Return(&dynamodb.PutItemOutput{}, nil).Once()
+
+	lock, err := l.Lock("acme", 0)
+	assert.NoError(t, err)
+
+	db.On("DeleteItem", mock.MatchedBy(func(input *dynamodb.DeleteItemInput) bool {
+		return *input.TableName == "locks" &&
+			*input.Key["LockID"].S == "acme" &&
+			*input.ConditionExpression == "#owner = :owner"
+	})).Return(&dynamodb.DeleteItemOutput{}, nil)
+
+	assert.NoError(t, lock.Unlock())
+
+	db.AssertExpectations(t)
+}
+
+func TestDynamoDBLocker_Held(t *testing.T) {
+	db := new(mockDynamoDBClient)
+	l := &DynamoDBLocker{
+		Table: "locks",
+		db:    db,
+	}
+
+	db.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil))
+
+	_, err := l.Lock("acme", 0)
+	assert.Equal(t, ErrTimeout, err)
+
+	db.AssertNumberOfCalls(t, "PutItem", 1)
+}
+
+func TestDynamoDBLocker_Error(t *testing.T) {
+	db := new(mockDynamoDBClient)
+	l := &DynamoDBLocker{
+		Table: "locks",
+		db:    db,
+	}
+
+	db.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, awserr.New(dynamodb.ErrCodeResourceNotFoundException, "Requested resource not found", nil))
+
+	_, err := l.Lock("acme", time.Minute)
+	assert.EqualError(t, err, "ResourceNotFoundException: Requested resource not found")
+}
+
+type mockDynamoDBClient struct {
+	mock.Mock
+}
+
+func (m *mockDynamoDBClient) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*dynamodb.PutItemOutput), args.Error(1)
+}
+
+func (m *mockDynamoDBClient) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*dynamodb.DeleteItemOutput), args.Error(1)
+}
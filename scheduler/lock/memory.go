@@ -0,0 +1,82 @@
+package lock
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryLocker is a Locker that holds locks in memory, which serializes
+// operations within a single process.
+type MemoryLocker struct {
+	mu    sync.Mutex
+	locks map[string]*memoryLock
+}
+
+// memoryLock is the lock for a key. It's held while a value is buffered in ch.
+// refs counts the callers holding or waiting for the lock, so that it can be
+// deleted once there are none.
+type memoryLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+// NewMemoryLocker returns a new MemoryLocker.
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{
+		locks: make(map[string]*memoryLock),
+	}
+}
+
+// Lock implements the Locker interface. Unlocking more than once is a no-op.
+func (l *MemoryLocker) Lock(key string, timeout time.Duration) (Lock, error) {
+	l.mu.Lock()
+	m, ok := l.locks[key]
+	if !ok {
+		m = &memoryLock{ch: make(chan struct{}, 1)}
+		l.locks[key] = m
+	}
+	m.refs++
+	l.mu.Unlock()
+
+	var once sync.Once
+	unlock := unlockFunc(func() error {
+		once.Do(func() {
+			<-m.ch
+			l.release(key, m)
+		})
+		return nil
+	})
+
+	if timeout <= 0 {
+		select {
+		case m.ch <- struct{}{}:
+			return unlock, nil
+		default:
+			l.release(key, m)
+			return nil, ErrTimeout
+		}
+	}
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+
+	select {
+	case m.ch <- struct{}{}:
+		return unlock, nil
+	case <-t.C:
+		l.release(key, m)
+		return nil, ErrTimeout
+	}
+}
+
+// release drops a reference to the lock for key, deleting it when it's no
+// longer held or waited for.
+func (l *MemoryLocker) release(key string, m *memoryLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	m.refs--
+	if m.refs == 0 {
+		delete(l.locks, key)
+	}
+}
@@ -0,0 +1,388 @@
+// Package memory provides a scheduler that keeps track of apps and their tasks
+// in memory, without running anything. It's useful for testing, and for trying
+// out tools that are built on top of twelvefactor.
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/remind101/12factor"
+)
+
+// The states that tasks can be in.
+const (
+	StateRunning = "RUNNING"
+)
+
+// Host is the Host of every task, since they all live in this process.
+const Host = "memory"
+
+// Scheduler is an implementation of the twelvefactor.Scheduler interface that
+// stores everything in memory. Running an app "starts" DesiredCount tasks for
+// each of its processes, and tasks are replaced when the app is restarted or
+// a new version is run. Processes with a Schedule never start any tasks.
+type Scheduler struct {
+	mu     sync.Mutex
+	apps   map[string]*app
+	nextID int
+
+	// now returns the current time. The zero value is time.Now.
+	now func() time.Time
+}
+
+// app is the state of an app within the Scheduler.
+type app struct {
+	app       twelvefactor.App
+	processes map[string]twelvefactor.Process
+
+	// tasks for the app's processes, which are managed by the
+	// Scheduler.
+	tasks []twelvefactor.Task
+
+	// runs are tasks that were started with RunProcess, which stick
+	// around until they're stopped.
+	runs []twelvefactor.Task
+}
+
+// NewScheduler returns a new empty Scheduler.
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		apps: make(map[string]*app),
+	}
+}
+
+// Run stores the app and its processes, replacing any processes from a
+// previous Run, and starts tasks up to the desired count of each process. Like
+// the other schedulers, only rolling deployments are supported.
+func (s *Scheduler) Run(a twelvefactor.App, processes ...twelvefactor.Process) error {
+	for _, p := range processes {
+		switch strategy := p.Deployment.Strategy; strategy {
+		case "", twelvefactor.Rolling:
+		default:
+			return &twelvefactor.UnsupportedStrategyError{Strategy: strategy}
+		}
+
+		if err := p.Deployment.Validate(); err != nil {
+			return twelvefactor.NewError(twelvefactor.ErrInvalidConfig, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.apps[a.ID]
+	if !ok {
+		state = &app{}
+		s.apps[a.ID] = state
+	}
+
+	state.app = a
+	state.processes = make(map[string]twelvefactor.Process)
+	for _, p := range processes {
+		state.processes[p.Name] = p
+	}
+
+	s.reconcile(state)
+	return nil
+}
+
+// RunProcess starts a one off task for the process. Attached processes have
+// nothing to attach to, so they return immediately without starting a task.
+func (s *Scheduler) RunProcess(a string, process twelvefactor.Process) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, err := s.app(a)
+	if err != nil {
+		return err
+	}
+
+	if process.Stdout != nil {
+		return nil
+	}
+
+	state.runs = append(state.runs, s.newTask(state.app, process.Name))
+	return nil
+}
+
+// Remove removes the app and all of its tasks.
+func (s *Scheduler) Remove(a string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.apps, a)
+	return nil
+}
+
+// ScaleProcess changes the desired count of the process, starting or stopping
+// tasks to match.
+func (s *Scheduler) ScaleProcess(a, process string, desired int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, err := s.app(a)
+	if err != nil {
+		return err
+	}
+
+	p, ok := state.processes[process]
+	if !ok {
+		return &twelvefactor.ProcessNotFoundError{App: a, Process: process}
+	}
+
+	p.DesiredCount = desired
+	state.processes[process] = p
+
+	s.reconcile(state)
+	return nil
+}
+
+// Restart replaces all of the app's tasks with new ones.
+func (s *Scheduler) Restart(a string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, err := s.app(a)
+	if err != nil {
+		return err
+	}
+
+	state.tasks = nil
+	s.reconcile(state)
+	return nil
+}
+
+// RestartProcess replaces the tasks for a single process with new ones.
+func (s *Scheduler) RestartProcess(a, process string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, err := s.app(a)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := state.processes[process]; !ok {
+		return &twelvefactor.ProcessNotFoundError{App: a, Process: process}
+	}
+
+	var tasks []twelvefactor.Task
+	for _, t := range state.tasks {
+		if t.Process != process {
+			tasks = append(tasks, t)
+		}
+	}
+	state.tasks = tasks
+
+	s.reconcile(state)
+	return nil
+}
+
+// Tasks returns the tasks for the app, sorted by process and then ID.
+func (s *Scheduler) Tasks(a string) ([]twelvefactor.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.apps[a]
+	if !ok {
+		return nil, &twelvefactor.AppNotFoundError{App: a}
+	}
+
+	var tasks []twelvefactor.Task
+	tasks = append(tasks, state.tasks...)
+	tasks = append(tasks, state.runs...)
+
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].Process != tasks[j].Process {
+			return tasks[i].Process < tasks[j].Process
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+
+	return tasks, nil
+}
+
+// StopTask stops a task. Tasks that belong to a process are replaced with a
+// new task, as long as the process still wants them.
+func (s *Scheduler) StopTask(taskID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, state := range s.apps {
+		if i := indexOf(state.runs, taskID); i >= 0 {
+			state.runs = append(state.runs[:i], state.runs[i+1:]...)
+			return nil
+		}
+
+		if i := indexOf(state.tasks, taskID); i >= 0 {
+			state.tasks = append(state.tasks[:i], state.tasks[i+1:]...)
+			s.reconcile(state)
+			return nil
+		}
+	}
+
+	return &twelvefactor.TaskNotFoundError{Task: taskID}
+}
+
+// Plan implements the twelvefactor.Planner interface. Run replaces all of the
+// app's processes, so any processes that aren't given are deleted.
+func (s *Scheduler) Plan(a twelvefactor.App, processes ...twelvefactor.Process) (*twelvefactor.Plan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var current twelvefactor.App
+	existing := make(map[string]twelvefactor.Process)
+	if state, ok := s.apps[a.ID]; ok {
+		current, existing = state.app, state.processes
+	}
+
+	plan := &twelvefactor.Plan{App: a.ID, Changes: []twelvefactor.ProcessChange{}}
+	given := make(map[string]bool)
+	for _, p := range processes {
+		given[p.Name] = true
+
+		change := twelvefactor.ProcessChange{Process: p.Name, Action: twelvefactor.ChangeUpdate}
+		old, ok := existing[p.Name]
+		oldApp := current
+		if !ok {
+			change.Action = twelvefactor.ChangeCreate
+			oldApp = twelvefactor.App{}
+		}
+
+		oldFields, newFields := fields(oldApp, old), fields(a, p)
+		for i := range newFields {
+			if oldFields[i][1] != newFields[i][1] {
+				change.Fields = append(change.Fields, twelvefactor.FieldChange{
+					Field: newFields[i][0],
+					Old:   oldFields[i][1],
+					New:   newFields[i][1],
+				})
+			}
+		}
+
+		change.Env = twelvefactor.DiffEnv(twelvefactor.ProcessEnv(oldApp, old), twelvefactor.ProcessEnv(a, p))
+
+		if old.DesiredCount != p.DesiredCount {
+			change.Scale = &twelvefactor.ScaleChange{From: old.DesiredCount, To: p.DesiredCount}
+		}
+
+		if change.Action == twelvefactor.ChangeCreate || len(change.Fields) > 0 || len(change.Env) > 0 || change.Scale != nil {
+			plan.Changes = append(plan.Changes, change)
+		}
+	}
+
+	var deleted []string
+	for name := range existing {
+		if !given[name] {
+			deleted = append(deleted, name)
+		}
+	}
+	sort.Strings(deleted)
+	for _, name := range deleted {
+		plan.Changes = append(plan.Changes, twelvefactor.ProcessChange{Process: name, Action: twelvefactor.ChangeDelete})
+	}
+
+	return plan, nil
+}
+
+// fields returns the name and value of the fields of a process that are
+// compared by Plan.
+func fields(a twelvefactor.App, p twelvefactor.Process) [][2]string {
+	format := func(v interface{}, zero bool) string {
+		if zero {
+			return ""
+		}
+		return fmt.Sprint(v)
+	}
+
+	return [][2]string{
+		{"image", a.Image},
+		{"command", strings.Join(p.Command, " ")},
+		{"cpu", format(p.CPUReservation(), p.CPUReservation() == 0)},
+		{"cpu_limit", format(p.CPULimit, p.CPULimit == 0)},
+		{"memory", format(p.Memory, p.Memory == 0)},
+		{"memory_reservation", format(p.MemoryReservation, p.MemoryReservation == 0)},
+		{"schedule", p.Schedule},
+	}
+}
+
+// app returns the state of an app, or an error if it doesn't exist.
+func (s *Scheduler) app(a string) (*app, error) {
+	state, ok := s.apps[a]
+	if !ok {
+		return nil, &twelvefactor.AppNotFoundError{App: a}
+	}
+	return state, nil
+}
+
+// reconcile stops tasks for processes that no longer exist or that are from an
+// old version of the app, and starts or stops tasks so that each process has
+// its desired count.
+func (s *Scheduler) reconcile(state *app) {
+	counts := make(map[string]int)
+
+	var tasks []twelvefactor.Task
+	for _, t := range state.tasks {
+		p, ok := state.processes[t.Process]
+		if !ok || p.Schedule != "" || t.Version != state.app.Version || counts[t.Process] >= p.DesiredCount {
+			continue
+		}
+
+		counts[t.Process]++
+		tasks = append(tasks, t)
+	}
+
+	var names []string
+	for name := range state.processes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := state.processes[name]
+		if p.Schedule != "" {
+			continue
+		}
+
+		for i := counts[name]; i < p.DesiredCount; i++ {
+			tasks = append(tasks, s.newTask(state.app, name))
+		}
+	}
+
+	state.tasks = tasks
+}
+
+// newTask returns a new running task for the process.
+func (s *Scheduler) newTask(a twelvefactor.App, process string) twelvefactor.Task {
+	s.nextID++
+
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+
+	t := now()
+	return twelvefactor.Task{
+		ID:        fmt.Sprintf("%012x", s.nextID),
+		Version:   a.Version,
+		Process:   process,
+		State:     StateRunning,
+		Time:      t,
+		StartedAt: t,
+		Host:      Host,
+	}
+}
+
+// indexOf returns the index of the task with the given id, or -1.
+func indexOf(tasks []twelvefactor.Task, id string) int {
+	for i, t := range tasks {
+		if t.ID == id {
+			return i
+		}
+	}
+	return -1
+}
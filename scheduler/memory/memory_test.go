@@ -0,0 +1,171 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/remind101/12factor"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testApp = twelvefactor.App{ID: "acme", Version: "v1"}
+
+	processes = []twelvefactor.Process{
+		{Name: "web", DesiredCount: 2},
+		{Name: "worker", DesiredCount: 1},
+		{Name: "cleanup", DesiredCount: 1, Schedule: "@daily"},
+	}
+)
+
+func TestScheduler_Run(t *testing.T) {
+	s := newTestScheduler()
+
+	err := s.Run(testApp, processes...)
+	assert.NoError(t, err)
+
+	tasks, err := s.Tasks("acme")
+	assert.NoError(t, err)
+	assert.Equal(t, []twelvefactor.Task{
+		{ID: "000000000001", Version: "v1", Process: "web", State: StateRunning, Time: now, StartedAt: now, Host: Host},
+		{ID: "000000000002", Version: "v1", Process: "web", State: StateRunning, Time: now, StartedAt: now, Host: Host},
+		{ID: "000000000003", Version: "v1", Process: "worker", State: StateRunning, Time: now, StartedAt: now, Host: Host},
+	}, tasks)
+
+	// Running a new version replaces the tasks, and removes processes
+	// that are no longer defined.
+	err = s.Run(twelvefactor.App{ID: "acme", Version: "v2"}, processes[0])
+	assert.NoError(t, err)
+
+	tasks, err = s.Tasks("acme")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"000000000004", "000000000005"}, taskIDs(tasks))
+	assert.Equal(t, "v2", tasks[0].Version)
+}
+
+func TestScheduler_Run_Deployment(t *testing.T) {
+	s := newTestScheduler()
+
+	err := s.Run(testApp, twelvefactor.Process{Name: "web", Deployment: twelvefactor.Deployment{Strategy: twelvefactor.Canary}})
+	assert.True(t, errors.Is(err, twelvefactor.ErrUnsupported))
+
+	err = s.Run(testApp, twelvefactor.Process{Name: "web", Deployment: twelvefactor.Deployment{Canary: twelvefactor.CanaryDeployment{Percent: 10}}})
+	assert.True(t, errors.Is(err, twelvefactor.ErrInvalidConfig))
+}
+
+func TestScheduler_Plan(t *testing.T) {
+	s := newTestScheduler()
+
+	plan, err := s.Plan(testApp, processes[0])
+	assert.NoError(t, err)
+	assert.Equal(t, []twelvefactor.ProcessChange{
+		{Process: "web", Action: twelvefactor.ChangeCreate, Scale: &twelvefactor.ScaleChange{From: 0, To: 2}},
+	}, plan.Changes)
+
+	s.Run(testApp, processes...)
+
+	plan, err = s.Plan(testApp, processes...)
+	assert.NoError(t, err)
+	assert.True(t, plan.Empty())
+
+	app := twelvefactor.App{ID: "acme", Version: "v2", Image: "acme-inc:v2", Env: map[string]string{"RAILS_ENV": "production"}}
+	plan, err = s.Plan(app, twelvefactor.Process{Name: "web", DesiredCount: 3, Memory: 512 * 1024 * 1024})
+	assert.NoError(t, err)
+	assert.Equal(t, []twelvefactor.ProcessChange{
+		{
+			Process: "web",
+			Action:  twelvefactor.ChangeUpdate,
+			Fields: []twelvefactor.FieldChange{
+				{Field: "image", New: "acme-inc:v2"},
+				{Field: "memory", New: "512MiB"},
+			},
+			Env:   []twelvefactor.EnvChange{{Name: "RAILS_ENV", Action: twelvefactor.ChangeCreate}},
+			Scale: &twelvefactor.ScaleChange{From: 2, To: 3},
+		},
+		{Process: "cleanup", Action: twelvefactor.ChangeDelete},
+		{Process: "worker", Action: twelvefactor.ChangeDelete},
+	}, plan.Changes)
+}
+
+func TestScheduler_ScaleProcess(t *testing.T) {
+	s := newTestScheduler()
+	s.Run(testApp, processes...)
+
+	assert.NoError(t, s.ScaleProcess("acme", "web", 1))
+	assert.NoError(t, s.ScaleProcess("acme", "worker", 2))
+
+	tasks, _ := s.Tasks("acme")
+	assert.Equal(t, []string{"000000000001", "000000000003", "000000000004"}, taskIDs(tasks))
+
+	assert.EqualError(t, s.ScaleProcess("acme", "api", 1), "api process not found")
+	assert.EqualError(t, s.ScaleProcess("foo", "web", 1), "foo app not found")
+	assert.True(t, errors.Is(s.ScaleProcess("acme", "api", 1), twelvefactor.ErrProcessNotFound))
+	assert.True(t, errors.Is(s.ScaleProcess("foo", "web", 1), twelvefactor.ErrAppNotFound))
+}
+
+func TestScheduler_Restart(t *testing.T) {
+	s := newTestScheduler()
+	s.Run(testApp, processes...)
+
+	assert.NoError(t, s.RestartProcess("acme", "worker"))
+	tasks, _ := s.Tasks("acme")
+	assert.Equal(t, []string{"000000000001", "000000000002", "000000000004"}, taskIDs(tasks))
+
+	assert.NoError(t, s.Restart("acme"))
+	tasks, _ = s.Tasks("acme")
+	assert.Equal(t, []string{"000000000005", "000000000006", "000000000007"}, taskIDs(tasks))
+}
+
+func TestScheduler_StopTask(t *testing.T) {
+	s := newTestScheduler()
+	s.Run(testApp, processes...)
+
+	// Stopped service tasks are replaced.
+	assert.NoError(t, s.StopTask("000000000001"))
+	tasks, _ := s.Tasks("acme")
+	assert.Equal(t, []string{"000000000002", "000000000004", "000000000003"}, taskIDs(tasks))
+
+	assert.EqualError(t, s.StopTask("000000000001"), "000000000001 task not found")
+}
+
+func TestScheduler_RunProcess(t *testing.T) {
+	s := newTestScheduler()
+	s.Run(testApp)
+
+	assert.NoError(t, s.RunProcess("acme", twelvefactor.Process{Name: "migrate"}))
+	tasks, _ := s.Tasks("acme")
+	assert.Equal(t, []twelvefactor.Task{
+		{ID: "000000000001", Version: "v1", Process: "migrate", State: StateRunning, Time: now, StartedAt: now, Host: Host},
+	}, tasks)
+
+	// One off tasks aren't replaced when stopped.
+	assert.NoError(t, s.StopTask("000000000001"))
+	tasks, _ = s.Tasks("acme")
+	assert.Empty(t, tasks)
+}
+
+func TestScheduler_Remove(t *testing.T) {
+	s := newTestScheduler()
+	s.Run(testApp, processes...)
+
+	assert.NoError(t, s.Remove("acme"))
+	_, err := s.Tasks("acme")
+	assert.True(t, errors.Is(err, twelvefactor.ErrAppNotFound))
+}
+
+var now = time.Date(2015, time.October, 14, 4, 30, 0, 0, time.UTC)
+
+func newTestScheduler() *Scheduler {
+	s := NewScheduler()
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func taskIDs(tasks []twelvefactor.Task) []string {
+	var ids []string
+	for _, t := range tasks {
+		ids = append(ids, t.ID)
+	}
+	return ids
+}
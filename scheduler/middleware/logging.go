@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"time"
+)
+
+// Logger is a structured logger, which logs alternating keys and values. It's
+// compatible with go-kit's log.Logger.
+type Logger interface {
+	Log(keyvals ...interface{}) error
+}
+
+// Logging returns a Middleware that logs every call to the Scheduler, along
+// with the app, process or task that it operates on, how long it took, and
+// the error if it failed.
+func Logging(l Logger) Middleware {
+	return Func(func(call Call, next func() error) error {
+		start := time.Now()
+		err := next()
+
+		keyvals := []interface{}{"method", call.Method}
+		if call.App != "" {
+			keyvals = append(keyvals, "app", call.App)
+		}
+		if call.Process != "" {
+			keyvals = append(keyvals, "process", call.Process)
+		}
+		if call.Task != "" {
+			keyvals = append(keyvals, "task", call.Task)
+		}
+		keyvals = append(keyvals, "duration", time.Since(start))
+		if err != nil {
+			keyvals = append(keyvals, "err", err)
+		}
+
+		l.Log(keyvals...)
+		return err
+	})
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import "time"
+
+// MetricsCollector receives metrics about calls to a Scheduler. It's modeled
+// after Prometheus collectors, so it can be implemented with a HistogramVec and
+// a CounterVec that have a "method" label.
+type MetricsCollector interface {
+	// ObserveLatency records how long a call to method took.
+	ObserveLatency(method string, d time.Duration)
+
+	// IncErrors increments the number of calls to method that failed.
+	IncErrors(method string)
+}
+
+// Metrics returns a Middleware that records the latency of every call to the
+// Scheduler, and counts the calls that fail, per method.
+func Metrics(m MetricsCollector) Middleware {
+	return Func(func(call Call, next func() error) error {
+		start := time.Now()
+		err := next()
+
+		m.ObserveLatency(call.Method, time.Since(start))
+		if err != nil {
+			m.IncErrors(call.Method)
+		}
+
+		return err
+	})
+}
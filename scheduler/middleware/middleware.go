@@ -0,0 +1,204 @@
+// Package middleware provides decorators for a twelvefactor.Scheduler, for
+// cross cutting concerns like logging, metrics and tracing. Each middleware
+// wraps a Scheduler and delegates to it, so they can be composed with Chain:
+//
+//	s := middleware.Chain(ecs.NewScheduler(config),
+//		middleware.Logging(logger),
+//		middleware.Metrics(metrics),
+//		middleware.Tracing(tracer),
+//	)
+//
+// Scheduler methods don't take a context, so callers that have one, like an
+// HTTP handler, use WithContext to make calls with it:
+//
+//	err := middleware.WithContext(s, r.Context()).Restart(app)
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/remind101/12factor"
+)
+
+// Call describes a call to a Scheduler method.
+type Call struct {
+	// The name of the method, such as "Run" or "ScaleProcess".
+	Method string
+
+	// The app, process and task that the call operates on, when
+	// applicable.
+	App     string
+	Process string
+	Task    string
+
+	// The arguments of calls that change the desired state of the app:
+	// the app and processes given to Run, and the count given to
+	// ScaleProcess.
+	Config       *twelvefactor.App
+	Processes    []twelvefactor.Process
+	DesiredCount int
+
+	// The context that the call was made with, from WithContext. It's
+	// context.Background() otherwise.
+	Context context.Context
+}
+
+// Middleware wraps a Scheduler, returning a new Scheduler.
+type Middleware func(twelvefactor.Scheduler) twelvefactor.Scheduler
+
+// Chain wraps s with the middleware. The first middleware is the outermost, so
+// it sees calls first.
+func Chain(s twelvefactor.Scheduler, middleware ...Middleware) twelvefactor.Scheduler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		s = middleware[i](s)
+	}
+	return s
+}
+
+// Func returns a Middleware that calls fn around every call to the Scheduler.
+// fn should call next to delegate to the wrapped Scheduler, and return its
+// error.
+//
+// Besides the Scheduler methods, the returned Scheduler also implements the
+// optional ProcessRunner, LogStreamer, Execer and Planner interfaces. These
+// return an error when the wrapped Scheduler doesn't implement them, so use
+// twelvefactor.Capabilities, which sees through the middleware, to find out
+// what's supported.
+func Func(fn func(call Call, next func() error) error) Middleware {
+	return func(s twelvefactor.Scheduler) twelvefactor.Scheduler {
+		return &scheduler{next: s, around: fn}
+	}
+}
+
+// WithContext returns a copy of s whose calls are made with ctx, so that the
+// middleware can see it, such as Tracing starting spans as children of the
+// span in ctx. Schedulers that aren't wrapped with middleware are returned as
+// is.
+func WithContext(s twelvefactor.Scheduler, ctx context.Context) twelvefactor.Scheduler {
+	m, ok := s.(*scheduler)
+	if !ok {
+		return s
+	}
+
+	c := *m
+	c.ctx = ctx
+	c.next = WithContext(m.next, ctx)
+	return &c
+}
+
+// scheduler is a Scheduler that calls around for every method.
+type scheduler struct {
+	next   twelvefactor.Scheduler
+	around func(call Call, next func() error) error
+
+	// The context that calls are made with, set by WithContext.
+	ctx context.Context
+}
+
+// do calls around with the call, and the context that it's made with.
+func (s *scheduler) do(call Call, next func() error) error {
+	call.Context = s.ctx
+	if call.Context == nil {
+		call.Context = context.Background()
+	}
+	return s.around(call, next)
+}
+
+// Unwrap returns the wrapped Scheduler.
+func (s *scheduler) Unwrap() twelvefactor.Scheduler {
+	return s.next
+}
+
+func (s *scheduler) Run(app twelvefactor.App, processes ...twelvefactor.Process) error {
+	return s.do(Call{Method: "Run", App: app.ID, Config: &app, Processes: processes}, func() error {
+		return s.next.Run(app, processes...)
+	})
+}
+
+func (s *scheduler) Remove(app string) error {
+	return s.do(Call{Method: "Remove", App: app}, func() error {
+		return s.next.Remove(app)
+	})
+}
+
+func (s *scheduler) ScaleProcess(app, process string, desired int) error {
+	return s.do(Call{Method: "ScaleProcess", App: app, Process: process, DesiredCount: desired}, func() error {
+		return s.next.ScaleProcess(app, process, desired)
+	})
+}
+
+func (s *scheduler) Restart(app string) error {
+	return s.do(Call{Method: "Restart", App: app}, func() error {
+		return s.next.Restart(app)
+	})
+}
+
+func (s *scheduler) RestartProcess(app, process string) error {
+	return s.do(Call{Method: "RestartProcess", App: app, Process: process}, func() error {
+		return s.next.RestartProcess(app, process)
+	})
+}
+
+func (s *scheduler) Tasks(app string) (tasks []twelvefactor.Task, err error) {
+	err = s.do(Call{Method: "Tasks", App: app}, func() error {
+		tasks, err = s.next.Tasks(app)
+		return err
+	})
+	return
+}
+
+func (s *scheduler) StopTask(taskID string) error {
+	return s.do(Call{Method: "StopTask", Task: taskID}, func() error {
+		return s.next.StopTask(taskID)
+	})
+}
+
+func (s *scheduler) RunProcess(app string, process twelvefactor.Process) error {
+	return s.do(Call{Method: "RunProcess", App: app, Process: process.Name}, func() error {
+		r, ok := s.next.(twelvefactor.ProcessRunner)
+		if !ok {
+			return s.unsupported("RunProcess")
+		}
+		return r.RunProcess(app, process)
+	})
+}
+
+func (s *scheduler) StreamLogs(w io.Writer, opts twelvefactor.LogsOptions) error {
+	return s.do(Call{Method: "StreamLogs", App: opts.App, Process: opts.Process, Task: opts.Task}, func() error {
+		l, ok := s.next.(twelvefactor.LogStreamer)
+		if !ok {
+			return s.unsupported("StreamLogs")
+		}
+		return l.StreamLogs(w, opts)
+	})
+}
+
+func (s *scheduler) Exec(taskID string, command []string, stdin io.Reader, stdout io.Writer, tty *twelvefactor.TTY) (code int, err error) {
+	err = s.do(Call{Method: "Exec", Task: taskID}, func() error {
+		e, ok := s.next.(twelvefactor.Execer)
+		if !ok {
+			return s.unsupported("Exec")
+		}
+		code, err = e.Exec(taskID, command, stdin, stdout, tty)
+		return err
+	})
+	return
+}
+
+func (s *scheduler) Plan(app twelvefactor.App, processes ...twelvefactor.Process) (plan *twelvefactor.Plan, err error) {
+	err = s.do(Call{Method: "Plan", App: app.ID}, func() error {
+		p, ok := s.next.(twelvefactor.Planner)
+		if !ok {
+			return s.unsupported("Plan")
+		}
+		plan, err = p.Plan(app, processes...)
+		return err
+	})
+	return
+}
+
+func (s *scheduler) unsupported(method string) error {
+	return twelvefactor.NewError(twelvefactor.ErrUnsupported, fmt.Errorf("%T does not implement %s", s.next, method))
+}
@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/scheduler/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChain(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return Func(func(call Call, next func() error) error {
+			calls = append(calls, name+" "+call.Method)
+			return next()
+		})
+	}
+
+	s := Chain(memory.NewScheduler(), record("a"), record("b"))
+	err := s.Run(twelvefactor.App{ID: "acme"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"a Run", "b Run"}, calls)
+}
+
+func TestLogging(t *testing.T) {
+	l := new(testLogger)
+	s := Logging(l)(memory.NewScheduler())
+
+	s.Run(twelvefactor.App{ID: "acme"}, twelvefactor.Process{Name: "web"})
+	s.ScaleProcess("acme", "worker", 1)
+	s.StopTask("1234")
+
+	assert.Equal(t, [][]interface{}{
+		{"method", "Run", "app", "acme"},
+		{"method", "ScaleProcess", "app", "acme", "process", "worker", "err", &twelvefactor.ProcessNotFoundError{App: "acme", Process: "worker"}},
+		{"method", "StopTask", "task", "1234", "err", &twelvefactor.TaskNotFoundError{Task: "1234"}},
+	}, l.lines)
+}
+
+func TestMetrics(t *testing.T) {
+	m := &testMetrics{latencies: make(map[string]int), errors: make(map[string]int)}
+	s := Metrics(m)(memory.NewScheduler())
+
+	s.Run(twelvefactor.App{ID: "acme"}, twelvefactor.Process{Name: "web"})
+	s.ScaleProcess("acme", "web", 1)
+	s.ScaleProcess("acme", "worker", 1)
+
+	assert.Equal(t, map[string]int{"Run": 1, "ScaleProcess": 2}, m.latencies)
+	assert.Equal(t, map[string]int{"ScaleProcess": 1}, m.errors)
+}
+
+func TestTracing(t *testing.T) {
+	tr := new(testTracer)
+	s := Tracing(tr)(memory.NewScheduler())
+
+	s.Run(twelvefactor.App{ID: "acme"}, twelvefactor.Process{Name: "web"})
+	s.RestartProcess("acme", "worker")
+
+	assert.Equal(t, []*testSpan{
+		{name: "twelvefactor.Run", attributes: map[string]string{"app": "acme"}, ended: true},
+		{name: "twelvefactor.RestartProcess", attributes: map[string]string{"app": "acme", "process": "worker"}, err: &twelvefactor.ProcessNotFoundError{App: "acme", Process: "worker"}, ended: true},
+	}, tr.spans)
+
+	// Spans are children of the span in the context that calls are made
+	// with.
+	ctx := context.WithValue(context.Background(), spanKey{}, "request")
+	WithContext(s, ctx).Tasks("acme")
+	assert.Equal(t, "request", tr.spans[2].parent)
+}
+
+func TestWithContext(t *testing.T) {
+	var contexts []context.Context
+	record := Func(func(call Call, next func() error) error {
+		contexts = append(contexts, call.Context)
+		return next()
+	})
+
+	m := memory.NewScheduler()
+	s := Chain(m, record, record)
+
+	ctx := context.WithValue(context.Background(), spanKey{}, "request")
+	assert.NoError(t, WithContext(s, ctx).Run(twelvefactor.App{ID: "acme"}))
+	assert.NoError(t, s.Run(twelvefactor.App{ID: "acme"}))
+	assert.Equal(t, []context.Context{ctx, ctx, context.Background(), context.Background()}, contexts)
+
+	// Schedulers without middleware are returned as is.
+	assert.Equal(t, twelvefactor.Scheduler(m), WithContext(m, ctx))
+}
+
+func TestUnsupported(t *testing.T) {
+	s := Func(func(call Call, next func() error) error {
+		return next()
+	})(memory.NewScheduler())
+
+	err := s.(twelvefactor.LogStreamer).StreamLogs(new(bytes.Buffer), twelvefactor.LogsOptions{App: "acme"})
+	assert.EqualError(t, err, "*memory.Scheduler does not implement StreamLogs")
+
+	// Optional interfaces that are implemented are passed through.
+	err = s.(twelvefactor.ProcessRunner).RunProcess("acme", twelvefactor.Process{Name: "migrate"})
+	assert.EqualError(t, err, "acme app not found")
+}
+
+func TestCapabilities(t *testing.T) {
+	s := Chain(memory.NewScheduler(), Logging(new(testLogger)), Tracing(new(testTracer)))
+
+	// The middleware implements every optional interface, but only the
+	// capabilities of the memory scheduler should be reported.
+	assert.Equal(t, []twelvefactor.Capability{twelvefactor.CapabilityRunProcess, twelvefactor.CapabilityPlan}, twelvefactor.Capabilities(s))
+}
+
+// testLogger records log lines, without the duration.
+type testLogger struct {
+	lines [][]interface{}
+}
+
+func (l *testLogger) Log(keyvals ...interface{}) error {
+	var line []interface{}
+	for i := 0; i < len(keyvals); i += 2 {
+		if keyvals[i] == "duration" {
+			continue
+		}
+		line = append(line, keyvals[i], keyvals[i+1])
+	}
+	l.lines = append(l.lines, line)
+	return nil
+}
+
+type testMetrics struct {
+	latencies map[string]int
+	errors    map[string]int
+}
+
+func (m *testMetrics) ObserveLatency(method string, d time.Duration) {
+	m.latencies[method]++
+}
+
+func (m *testMetrics) IncErrors(method string) {
+	m.errors[method]++
+}
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string, attributes map[string]string) Span {
+	s := &testSpan{name: name, attributes: attributes, parent: ctx.Value(spanKey{})}
+	t.spans = append(t.spans, s)
+	return s
+}
+
+// spanKey is the context key for the parent of test spans.
+type spanKey struct{}
+
+type testSpan struct {
+	name       string
+	attributes map[string]string
+	parent     interface{}
+	err        error
+	ended      bool
+}
+
+func (s *testSpan) RecordError(err error) { s.err = err }
+func (s *testSpan) End()                  { s.ended = true }
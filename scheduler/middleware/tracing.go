@@ -0,0 +1,48 @@
+package middleware
+
+import "context"
+
+// Tracer starts spans. It's modeled after OpenTelemetry's tracer, so it can be
+// implemented by an adapter around one.
+type Tracer interface {
+	// Start starts a new span with the given name and attributes, as a
+	// child of the span in ctx, if there is one.
+	Start(ctx context.Context, name string, attributes map[string]string) Span
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// RecordError records that the operation failed.
+	RecordError(err error)
+
+	// End ends the span.
+	End()
+}
+
+// Tracing returns a Middleware that starts a span for every call to the
+// Scheduler, named like "twelvefactor.Run", with the app, process or task as
+// attributes. Spans are children of the span in the context given to
+// WithContext, and are root spans otherwise.
+func Tracing(t Tracer) Middleware {
+	return Func(func(call Call, next func() error) error {
+		attributes := make(map[string]string)
+		if call.App != "" {
+			attributes["app"] = call.App
+		}
+		if call.Process != "" {
+			attributes["process"] = call.Process
+		}
+		if call.Task != "" {
+			attributes["task"] = call.Task
+		}
+
+		span := t.Start(call.Context, "twelvefactor."+call.Method, attributes)
+		defer span.End()
+
+		err := next()
+		if err != nil {
+			span.RecordError(err)
+		}
+		return err
+	})
+}
@@ -0,0 +1,99 @@
+package state
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/remind101/12factor"
+)
+
+// FileStore is a Store that keeps the state of each app in a JSON file in Dir,
+// which persists it between runs on the same host.
+type FileStore struct {
+	// The directory to store state in.
+	Dir string
+}
+
+// path returns the path of the file that stores the state of app.
+func (s *FileStore) path(app string) string {
+	return filepath.Join(s.Dir, url.PathEscape(app)+".json")
+}
+
+// Get implements the Store interface.
+func (s *FileStore) Get(app string) (*State, error) {
+	raw, err := ioutil.ReadFile(s.path(app))
+	if os.IsNotExist(err) {
+		return nil, &twelvefactor.AppNotFoundError{App: app}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state State
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Put implements the Store interface. The file is replaced atomically, so
+// readers never see partially written state.
+func (s *FileStore) Put(state *State) error {
+	raw, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(s.Dir, ".state-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(raw); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), s.path(state.App.ID))
+}
+
+// Delete implements the Store interface.
+func (s *FileStore) Delete(app string) error {
+	if err := os.Remove(s.path(app)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Apps implements the Store interface.
+func (s *FileStore) Apps() ([]string, error) {
+	entries, err := ioutil.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var apps []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		app, err := url.PathUnescape(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue
+		}
+		apps = append(apps, app)
+	}
+	sort.Strings(apps)
+	return apps, nil
+}
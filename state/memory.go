@@ -0,0 +1,77 @@
+package state
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/remind101/12factor"
+)
+
+// MemoryStore is a Store that keeps state in memory.
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string][]byte
+}
+
+// NewMemoryStore returns a new empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		states: make(map[string][]byte),
+	}
+}
+
+// Get implements the Store interface.
+func (s *MemoryStore) Get(app string) (*State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	raw, ok := s.states[app]
+	if !ok {
+		return nil, &twelvefactor.AppNotFoundError{App: app}
+	}
+
+	// States are stored encoded, so that callers can't change them
+	// without calling Put.
+	var state State
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Put implements the Store interface.
+func (s *MemoryStore) Put(state *State) error {
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[state.App.ID] = raw
+	return nil
+}
+
+// Delete implements the Store interface.
+func (s *MemoryStore) Delete(app string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.states, app)
+	return nil
+}
+
+// Apps implements the Store interface.
+func (s *MemoryStore) Apps() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	apps := make([]string, 0, len(s.states))
+	for app := range s.states {
+		apps = append(apps, app)
+	}
+	sort.Strings(apps)
+	return apps, nil
+}
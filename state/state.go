@@ -0,0 +1,103 @@
+// Package state stores the desired state of apps: the App and processes that
+// they were last run with. The Middleware records every change that's made
+// through a Scheduler, so that the desired state can later be compared against
+// what's actually running, or run again.
+package state
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/scheduler/middleware"
+)
+
+// State is the desired state of an app.
+type State struct {
+	App       twelvefactor.App       `json:"app"`
+	Processes []twelvefactor.Process `json:"processes"`
+}
+
+// Process returns the process with the given name, or nil.
+func (s *State) Process(name string) *twelvefactor.Process {
+	for i := range s.Processes {
+		if s.Processes[i].Name == name {
+			return &s.Processes[i]
+		}
+	}
+	return nil
+}
+
+// Store stores the desired state of apps.
+type Store interface {
+	// Get returns the state of the app, or an
+	// *twelvefactor.AppNotFoundError if there isn't one.
+	Get(app string) (*State, error)
+
+	// Put stores the state, replacing any existing state for the app.
+	Put(state *State) error
+
+	// Delete removes the state of the app, if there is one.
+	Delete(app string) error
+
+	// Apps returns the ids of all of the apps with state, sorted.
+	Apps() ([]string, error)
+}
+
+// Middleware returns a scheduler middleware that records the desired state of
+// apps in store whenever it's changed successfully: Run replaces the state,
+// ScaleProcess updates the desired count of the process, and Remove deletes
+// it.
+func Middleware(store Store) middleware.Middleware {
+	return middleware.Func(func(call middleware.Call, next func() error) error {
+		if err := next(); err != nil {
+			return err
+		}
+
+		var err error
+		switch call.Method {
+		case "Run":
+			err = store.Put(&State{App: *call.Config, Processes: detach(call.Processes)})
+		case "ScaleProcess":
+			err = scale(store, call.App, call.Process, call.DesiredCount)
+		case "Remove":
+			err = store.Delete(call.App)
+		}
+		if err != nil {
+			return fmt.Errorf("error recording state of %s: %w", call.App, err)
+		}
+
+		return nil
+	})
+}
+
+// scale updates the desired count of a process. Apps that were run before
+// their state was recorded are ignored.
+func scale(store Store, app, process string, desired int) error {
+	s, err := store.Get(app)
+	if errors.Is(err, twelvefactor.ErrAppNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	p := s.Process(process)
+	if p == nil {
+		return nil
+	}
+	p.DesiredCount = desired
+
+	return store.Put(s)
+}
+
+// detach returns a copy of the processes without their Stdout and Stdin, which
+// can't be stored.
+func detach(processes []twelvefactor.Process) []twelvefactor.Process {
+	detached := make([]twelvefactor.Process, len(processes))
+	for i, p := range processes {
+		p.Stdout, p.Stdin = nil, nil
+		detached[i] = p
+	}
+	return detached
+}
@@ -0,0 +1,77 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/remind101/12factor"
+	"github.com/remind101/12factor/scheduler/memory"
+	"github.com/remind101/12factor/scheduler/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	store := NewMemoryStore()
+	s := middleware.Chain(memory.NewScheduler(), Middleware(store))
+
+	app := twelvefactor.App{ID: "acme", Image: "acme-inc:v1"}
+	assert.NoError(t, s.Run(app, twelvefactor.Process{Name: "web", DesiredCount: 1, Stdout: new(bytes.Buffer)}))
+
+	state, err := store.Get("acme")
+	assert.NoError(t, err)
+	assert.Equal(t, &State{App: app, Processes: []twelvefactor.Process{{Name: "web", DesiredCount: 1}}}, state)
+
+	assert.NoError(t, s.ScaleProcess("acme", "web", 3))
+	state, _ = store.Get("acme")
+	assert.Equal(t, 3, state.Process("web").DesiredCount)
+
+	// Failed changes aren't recorded.
+	assert.Error(t, s.ScaleProcess("acme", "worker", 2))
+	state, _ = store.Get("acme")
+	assert.Nil(t, state.Process("worker"))
+
+	assert.NoError(t, s.Remove("acme"))
+	_, err = store.Get("acme")
+	assert.True(t, errors.Is(err, twelvefactor.ErrAppNotFound))
+}
+
+func TestStores(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"file":   &FileStore{Dir: t.TempDir()},
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			_, err := store.Get("acme/web")
+			assert.Equal(t, &twelvefactor.AppNotFoundError{App: "acme/web"}, err)
+
+			state := &State{
+				App:       twelvefactor.App{ID: "acme/web", Env: map[string]string{"RAILS_ENV": "production"}},
+				Processes: []twelvefactor.Process{{Name: "web", DesiredCount: 2}},
+			}
+			assert.NoError(t, store.Put(state))
+			assert.NoError(t, store.Put(&State{App: twelvefactor.App{ID: "other"}}))
+
+			got, err := store.Get("acme/web")
+			assert.NoError(t, err)
+			assert.Equal(t, state, got)
+
+			// Changing the returned state doesn't change what's
+			// stored.
+			got.Processes[0].DesiredCount = 5
+			got, _ = store.Get("acme/web")
+			assert.Equal(t, 2, got.Processes[0].DesiredCount)
+
+			apps, err := store.Apps()
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"acme/web", "other"}, apps)
+
+			assert.NoError(t, store.Delete("acme/web"))
+			assert.NoError(t, store.Delete("acme/web"))
+			apps, _ = store.Apps()
+			assert.Equal(t, []string{"other"}, apps)
+		})
+	}
+}
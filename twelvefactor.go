@@ -2,7 +2,13 @@
 // which are defined in http://12factor.net/
 package twelvefactor
 
-import "time"
+import (
+	"fmt"
+	"time"
+
+	"github.com/remind101/12factor/pkg/bytesize"
+	"github.com/remind101/12factor/pkg/cpu"
+)
 
 // App represents a 12factor application. We define an application has a
 // collection of processes that share a common environment.
@@ -50,11 +56,133 @@ type Process struct {
 	// The desired number of instances to run.
 	DesiredCount int
 
-	// The amount of memory to allocate to this process, in bytes.
-	Memory int
+	// A hard limit on the amount of memory this process can use. Processes
+	// that exceed it are killed.
+	Memory bytesize.ByteSize
+
+	// A soft limit on the amount of memory to reserve for this process.
+	// Processes can use more than their reservation, up to Memory, when
+	// the host has memory available. If Memory is zero, the process has no
+	// hard limit.
+	MemoryReservation bytesize.ByteSize
 
 	// The number of CPU Shares to allocate to this process.
+	//
+	// Deprecated: Use CPU instead, which is portable between schedulers.
+	// CPUShares is only used when CPU is zero.
 	CPUShares int
+
+	// The amount of CPU to reserve for this process, such as "0.25" or
+	// "250m". When the host is under contention, processes get CPU time
+	// relative to their reservation.
+	CPU cpu.CPU
+
+	// A hard limit on the amount of CPU that this process can use. The zero
+	// value means no limit.
+	CPULimit cpu.CPU
+
+	// Where instances of this process should be placed within the
+	// cluster. The zero value lets the scheduler decide.
+	Placement Placement
+
+	// How new versions of this process should be rolled out. The zero
+	// value uses the scheduler's default deployment behavior.
+	Deployment Deployment
+
+	// A cron expression (e.g. "0 12 * * *" or "@every 1h") that, when
+	// provided, turns this process into a scheduled task that runs
+	// DesiredCount instances each time the schedule fires, rather than a
+	// long running service. Schedules are evaluated in UTC. See the
+	// pkg/cron package for the supported syntax.
+	Schedule string
+
+	// An optional command that the scheduler runs inside instances of this
+	// process to determine whether they're healthy.
+	HealthCheck *HealthCheck
+}
+
+// Placement describes the rules for deciding where the instances of a Process
+// should be run.
+type Placement struct {
+	// Constraints that a host must satisfy before an instance of the
+	// process can be placed on it.
+	Constraints []PlacementConstraint
+
+	// Strategies to use when selecting between hosts that satisfy the
+	// constraints. Strategies are evaluated in order.
+	Strategies []PlacementStrategy
+}
+
+// PlacementConstraintType is the type of a PlacementConstraint.
+type PlacementConstraintType string
+
+const (
+	// DistinctInstance places each instance of the process on a different
+	// host.
+	DistinctInstance PlacementConstraintType = "distinct-instance"
+
+	// MemberOf places instances of the process on hosts whose Attribute
+	// matches the Value of the constraint.
+	MemberOf PlacementConstraintType = "member-of"
+)
+
+// PlacementConstraint represents a single rule that a host must satisfy.
+type PlacementConstraint struct {
+	Type PlacementConstraintType
+
+	// The host attribute to match against, for example
+	// "ecs.instance-type" or "node.labels.tier". Only used for MemberOf
+	// constraints.
+	Attribute string
+
+	// The comparison operator, either "==" or "!=". The zero value is
+	// "==".
+	Operator string
+
+	// The value to compare the attribute against.
+	Value string
+}
+
+// PlacementStrategyType is the type of a PlacementStrategy.
+type PlacementStrategyType string
+
+const (
+	// Spread distributes instances evenly across the values of Field.
+	Spread PlacementStrategyType = "spread"
+
+	// Binpack places instances on the hosts with the least amount of Field
+	// remaining.
+	Binpack PlacementStrategyType = "binpack"
+
+	// Random places instances on random hosts.
+	Random PlacementStrategyType = "random"
+)
+
+// Well known fields that can be used with placement strategies. Any other value
+// is treated as the name of a host attribute.
+const (
+	FieldAvailabilityZone = "availability-zone"
+	FieldInstance         = "instance"
+	FieldMemory           = "memory"
+	FieldCPU              = "cpu"
+)
+
+// PlacementStrategy represents a strategy for selecting between hosts.
+type PlacementStrategy struct {
+	Type PlacementStrategyType
+
+	// The field to apply the strategy to. For Spread, this is generally
+	// FieldAvailabilityZone or FieldInstance. For Binpack, this is
+	// FieldMemory or FieldCPU.
+	Field string
+}
+
+// Op returns the comparison operator for the constraint, defaulting to "==".
+func (c PlacementConstraint) Op() string {
+	if c.Operator == "" {
+		return "=="
+	}
+	return c.Operator
 }
 
 // Task represents the state of an individual instance of a Process.
@@ -71,8 +199,191 @@ type Task struct {
 	// The state that this task is in.
 	State string
 
+	// For stopped tasks, why the task stopped, such as "Essential
+	// container in task exited".
+	StopReason string
+
+	// For stopped tasks, the exit code of the process. Nil when the task
+	// hasn't stopped or the exit code isn't known.
+	ExitCode *int
+
+	// The health of the task, as reported by the process's HealthCheck.
+	// One of the Health constants.
+	Health string
+
+	// An identifier for the host or instance that the task runs on.
+	Host string
+
+	// Where the task runs, such as a region or cluster, for schedulers
+	// that run apps in more than one place.
+	Location string
+
+	// The IP addresses assigned to the task.
+	IPAddresses []string
+
+	// The times that the task started running and stopped. Zero until
+	// they happen.
+	StartedAt time.Time
+	StoppedAt time.Time
+
 	// The time that this state was recorded at.
 	Time time.Time
+
+	// For tasks that were started by a Schedule, the time that the
+	// schedule triggered the task.
+	TriggeredAt time.Time
+}
+
+// The health of a Task.
+const (
+	// HealthUnknown is the health of tasks without a HealthCheck, or
+	// whose health can't be determined.
+	HealthUnknown = ""
+
+	// HealthStarting is the health of a task that's still within its
+	// start period, or hasn't been checked yet.
+	HealthStarting = "starting"
+
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+// DeploymentStrategy is the strategy used to roll out a new version of a
+// Process.
+type DeploymentStrategy string
+
+const (
+	// Rolling replaces old instances with new instances incrementally.
+	Rolling DeploymentStrategy = "rolling"
+
+	// BlueGreen starts a complete set of new instances alongside the old
+	// ones, then switches traffic over to them. None of the schedulers in
+	// this package support it yet, and they return an
+	// UnsupportedStrategyError.
+	BlueGreen DeploymentStrategy = "blue-green"
+
+	// Canary runs a percentage of new instances for a bake period before
+	// rolling out to the rest. None of the schedulers in this package
+	// support it yet, and they return an UnsupportedStrategyError.
+	Canary DeploymentStrategy = "canary"
+)
+
+// Deployment configures how a new version of a Process is rolled out.
+type Deployment struct {
+	// The strategy to use. The zero value uses the scheduler's default.
+	Strategy DeploymentStrategy
+
+	// Options for the Rolling strategy.
+	Rolling RollingDeployment
+
+	// Options for the BlueGreen strategy.
+	BlueGreen BlueGreenDeployment
+
+	// Options for the Canary strategy.
+	Canary CanaryDeployment
+}
+
+// Validate checks that only the options for the chosen strategy are set, and
+// that the Rolling percentages are within range. It doesn't check whether a
+// scheduler supports the strategy.
+func (d Deployment) Validate() error {
+	strategy := d.Strategy
+	if strategy == "" {
+		strategy = Rolling
+	}
+
+	if strategy != Rolling && d.Rolling != (RollingDeployment{}) {
+		return fmt.Errorf("rolling options can't be used with the %s deployment strategy", strategy)
+	}
+	if strategy != BlueGreen && d.BlueGreen != (BlueGreenDeployment{}) {
+		return fmt.Errorf("blue-green options can't be used with the %s deployment strategy", strategy)
+	}
+	if strategy != Canary && d.Canary != (CanaryDeployment{}) {
+		return fmt.Errorf("canary options can't be used with the %s deployment strategy", strategy)
+	}
+
+	min, max := d.Rolling.MinimumHealthyPercent, d.Rolling.MaximumPercent
+	if min < 0 || min > 100 {
+		return fmt.Errorf("minimum healthy percent must be between 0 and 100, got %d", min)
+	}
+	if max != 0 && max < 100 {
+		return fmt.Errorf("maximum percent must be at least 100, got %d", max)
+	}
+	if max != 0 && max <= min {
+		return fmt.Errorf("maximum percent (%d) must be greater than the minimum healthy percent (%d)", max, min)
+	}
+
+	return nil
+}
+
+// RollingDeployment holds the options for a Rolling deployment. The zero value
+// for either percentage uses the scheduler's default.
+type RollingDeployment struct {
+	// The lower limit on the number of running instances during a
+	// deployment, as a percentage of DesiredCount.
+	MinimumHealthyPercent int
+
+	// The upper limit on the number of running instances during a
+	// deployment, as a percentage of DesiredCount. It must be at least
+	// 100.
+	MaximumPercent int
+}
+
+// TrafficSwitch determines how traffic is moved to the new instances in a
+// BlueGreen deployment.
+type TrafficSwitch string
+
+const (
+	// AllAtOnce moves all traffic to the new instances at once.
+	AllAtOnce TrafficSwitch = "all-at-once"
+
+	// Linear moves traffic to the new instances in equal increments.
+	Linear TrafficSwitch = "linear"
+)
+
+// BlueGreenDeployment holds the options for a BlueGreen deployment.
+type BlueGreenDeployment struct {
+	// How traffic is switched over to the new instances. The zero value is
+	// AllAtOnce.
+	TrafficSwitch TrafficSwitch
+
+	// How long to keep the old instances around after traffic has been
+	// switched over, so that the deployment can be rolled back.
+	TerminationWait time.Duration
+}
+
+// CanaryDeployment holds the options for a Canary deployment.
+type CanaryDeployment struct {
+	// The percentage of instances to run with the new version during the
+	// bake period.
+	Percent int
+
+	// How long to run the canary instances before rolling out to the rest.
+	BakeTime time.Duration
+}
+
+// HealthCheck describes how a scheduler should check that an instance of a
+// Process is healthy. The zero value for any of the durations or Retries uses
+// the scheduler's default.
+type HealthCheck struct {
+	// The command to run inside the instance. An exit status of 0 means
+	// that the instance is healthy.
+	Command []string
+
+	// How often to run the command.
+	Interval time.Duration
+
+	// How long to wait for the command to finish before considering the
+	// check failed.
+	Timeout time.Duration
+
+	// The number of consecutive failed checks before the instance is
+	// considered unhealthy.
+	Retries int
+
+	// How long to give the instance to start up, during which failed
+	// checks don't count towards Retries.
+	StartPeriod time.Duration
 }
 
 // Stdout is an interface that represents a the location to send Stdout to.
@@ -81,6 +392,15 @@ type Stdout interface{}
 // Stdin represents the location to get Stdin from.
 type Stdin interface{}
 
+// CPUReservation returns the amount of CPU to reserve for the process,
+// falling back to the legacy CPUShares when CPU isn't set.
+func (p Process) CPUReservation() cpu.CPU {
+	if p.CPU != 0 {
+		return p.CPU
+	}
+	return cpu.FromShares(int64(p.CPUShares))
+}
+
 // ProcessEnv merges the App environment with any environment variables provided
 // in the process.
 func ProcessEnv(app App, process Process) map[string]string {
@@ -2,6 +2,7 @@ package twelvefactor
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -33,3 +34,32 @@ func TestMergeEnv(t *testing.T) {
 		assert.Equal(t, out, tt.out)
 	}
 }
+
+func TestDeployment_Validate(t *testing.T) {
+	tests := []struct {
+		in  Deployment
+		err string
+	}{
+		{Deployment{}, ""},
+		{Deployment{Strategy: Rolling}, ""},
+		{Deployment{Rolling: RollingDeployment{MinimumHealthyPercent: 50}}, ""},
+		{Deployment{Strategy: Rolling, Rolling: RollingDeployment{MinimumHealthyPercent: 50, MaximumPercent: 150}}, ""},
+		{Deployment{Strategy: Canary, Canary: CanaryDeployment{Percent: 10}}, ""},
+		{Deployment{Strategy: Rolling, Rolling: RollingDeployment{MinimumHealthyPercent: 101}}, "minimum healthy percent must be between 0 and 100, got 101"},
+		{Deployment{Strategy: Rolling, Rolling: RollingDeployment{MinimumHealthyPercent: -1}}, "minimum healthy percent must be between 0 and 100, got -1"},
+		{Deployment{Strategy: Rolling, Rolling: RollingDeployment{MaximumPercent: 50}}, "maximum percent must be at least 100, got 50"},
+		{Deployment{Strategy: Rolling, Rolling: RollingDeployment{MinimumHealthyPercent: 100, MaximumPercent: 100}}, "maximum percent (100) must be greater than the minimum healthy percent (100)"},
+		{Deployment{Canary: CanaryDeployment{BakeTime: time.Minute}}, "canary options can't be used with the rolling deployment strategy"},
+		{Deployment{Strategy: Canary, BlueGreen: BlueGreenDeployment{TrafficSwitch: Linear}}, "blue-green options can't be used with the canary deployment strategy"},
+		{Deployment{Strategy: BlueGreen, Rolling: RollingDeployment{MaximumPercent: 200}}, "rolling options can't be used with the blue-green deployment strategy"},
+	}
+
+	for i, tt := range tests {
+		err := tt.in.Validate()
+		if tt.err == "" {
+			assert.NoError(t, err, "#%d", i)
+		} else {
+			assert.EqualError(t, err, tt.err, "#%d", i)
+		}
+	}
+}